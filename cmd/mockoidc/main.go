@@ -0,0 +1,29 @@
+// Package main - 本地模拟OIDC身份提供方
+// 功能：用于在本地调试第三方登录流程，不依赖真实的身份提供方
+// 用法：go run ./cmd/mockoidc -addr :9000，并在 config.yaml 的 oidc.providers 中将 issuer 配置为 http://localhost:9000
+// 注意：仅供开发调试，授权页面允许输入任意身份，切勿在生产环境使用
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"faulty_in_culture/go_back/internal/infra/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer地址（需与后端配置一致）")
+	clientID := flag.String("client-id", "game-backend", "客户端ID")
+	clientSecret := flag.String("client-secret", "dev-secret", "客户端密钥")
+	flag.Parse()
+
+	s, err := oidctest.NewServer(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("生成签名密钥失败: %v", err)
+	}
+
+	log.Printf("Mock OIDC 已启动: issuer=%s client_id=%s", *issuer, *clientID)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
@@ -0,0 +1,70 @@
+// Package main - 从MySQL重建Redis排行榜
+// 功能：MySQL是排行榜的唯一数据来源，Redis数据丢失、迁移或怀疑不一致时用本命令重建
+// 用法：go run ./cmd/rebuildleaderboard [-type 3] [-config config.yaml]，不指定类型时重建所有排行榜类型
+// 说明：先写入临时键再原子替换，服务运行期间也可以执行；服务在发现排行榜未加载时也会自动重建
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"faulty_in_culture/go_back/internal/infra/cache"
+	"faulty_in_culture/go_back/internal/infra/config"
+	"faulty_in_culture/go_back/internal/infra/db"
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/ranking"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func main() {
+	configPath := flag.String("config", "config.yaml", "配置文件路径")
+	rankType := flag.Int("type", 0, "要重建的排行榜类型（0表示全部）")
+	flag.Parse()
+
+	if err := config.LoadConfig(*configPath); err != nil {
+		fmt.Printf("加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.InitLogger(config.GlobalConfig.App.LogMode); err != nil {
+		fmt.Printf("初始化日志失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	if err := db.InitDatabase(); err != nil {
+		fmt.Printf("数据库初始化失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cache.InitCache(); err != nil {
+		fmt.Printf("Redis初始化失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	var rankTypes []int
+	if *rankType != 0 {
+		rankTypes = []int{*rankType}
+	}
+
+	service := ranking.NewService(ranking.NewRepository(db.GetDB()), nil, cache.GetCache())
+	if err := service.EnsureDefaultBoards(); err != nil {
+		fmt.Printf("加载排行榜定义失败: %v\n", err)
+		os.Exit(1)
+	}
+	counts, err := service.RebuildLeaderboards(rankTypes)
+	rebuilt := make([]int, 0, len(counts))
+	for t := range counts {
+		rebuilt = append(rebuilt, t)
+	}
+	sort.Ints(rebuilt)
+	for _, t := range rebuilt {
+		fmt.Printf("排行榜类型 %d：已写入 %d 条记录\n", t, counts[t])
+	}
+	if err != nil {
+		fmt.Printf("重建失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("重建完成")
+}
@@ -6,16 +6,24 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"faulty_in_culture/go_back/internal/account"
 	"faulty_in_culture/go_back/internal/chat"
+	"faulty_in_culture/go_back/internal/friend"
 	"faulty_in_culture/go_back/internal/infra/cache"
 	"faulty_in_culture/go_back/internal/infra/config"
 	"faulty_in_culture/go_back/internal/infra/db"
 	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/infra/scheduler"
+	"faulty_in_culture/go_back/internal/infra/storage"
 	"faulty_in_culture/go_back/internal/infra/ws"
 	"faulty_in_culture/go_back/internal/ranking"
 	"faulty_in_culture/go_back/internal/routes"
 	"faulty_in_culture/go_back/internal/savegame"
+	"faulty_in_culture/go_back/internal/shared/middleware"
+	"faulty_in_culture/go_back/internal/shared/security"
 	"faulty_in_culture/go_back/internal/user"
 
 	"github.com/gin-gonic/gin"
@@ -45,10 +53,17 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 加载JWT签名密钥（生产环境使用默认密钥时拒绝启动）
+	if err := security.InitJWT(); err != nil {
+		logger.Error("JWT密钥初始化失败", zap.Error(err))
+		os.Exit(1)
+	}
+
 	logger.Info("应用启动（RESTful架构）",
 		zap.String("environment", cfg.App.Environment),
 		zap.String("db_host", cfg.Database.Host),
-		zap.String("redis_host", cfg.Redis.Host))
+		zap.String("redis_host", cfg.Redis.Host),
+		zap.String("jwt_algorithm", security.SigningAlgorithm()))
 
 	// 初始化数据库连接
 	if err := db.InitDatabase(); err != nil {
@@ -70,33 +85,176 @@ func main() {
 	database := db.GetDB()
 	cacheInstance := cache.GetCache()
 
+	// 文件存储（头像等上传文件），未配置对外地址时使用相对路径
+	storageDir := cfg.Storage.LocalDir
+	if storageDir == "" {
+		storageDir = "uploads"
+	}
+	baseURL := strings.TrimRight(cfg.Server.PublicBaseURL, "/")
+	if baseURL == "" {
+		baseURL = "/api"
+	}
+	fileStorage, err := storage.NewLocalStorage(storageDir, baseURL+"/files")
+	if err != nil {
+		logger.Error("文件存储初始化失败", zap.Error(err))
+		os.Exit(1)
+	}
+
 	// User模块 - 用户认证管理
 	userRepo := user.NewRepository(database)
-	userService := user.NewService(userRepo, user.NewPasswordHasher(), user.NewTokenGenerator(), cacheInstance)
+	userService := user.NewService(userRepo, user.NewPasswordHasher(), user.NewTokenGenerator(), cacheInstance, user.NewLoginLimiter(cacheInstance), user.NewPasswordPolicy(), fileStorage)
 	userHandler := user.NewHandler(userService)
+	middleware.SetSessionChecker(userService) // 认证中间件校验登录会话（踢下线）
+	middleware.SetBanChecker(userService)     // 认证中间件校验封禁
+	userService.SetAvatarSources(cfg.Profile.AvatarPresets, cfg.Profile.AvatarHosts)
+	userService.SetIdentityProviders(user.NewIdentityProviders(nil), cfg.OIDC.AllowedReturnURLs)
+	if err := userService.EnsureAdmins(cfg.Admin.BootstrapUsernames); err != nil {
+		logger.Error("初始化管理员失败", zap.Error(err))
+	}
 
-	// Ranking模块 - 排行榜管理（依赖UserService，使用批量查询优化）
+	// Ranking模块 - 排行榜管理（依赖UserService，使用批量查询优化；Redis有序集合提供排名查询）
 	rankingRepo := ranking.NewRepository(database)
 	rankingService := ranking.NewService(rankingRepo, userService, cacheInstance)
 	rankingHandler := ranking.NewHandler(rankingService)
+	userService.SetDeletionHook(rankingService.RemoveUsers) // 删除用户时同步移出Redis排行榜
+	if err := rankingService.EnsureDefaultBoards(); err != nil {
+		logger.Error("加载排行榜定义失败", zap.Error(err))
+		os.Exit(1)
+	}
+	rankingCfg := cfg.Ranking
+	if rankingCfg.Timezone != "" {
+		loc, err := time.LoadLocation(rankingCfg.Timezone)
+		if err != nil {
+			logger.Error("排行榜时区配置无效，使用服务器时区", zap.String("timezone", rankingCfg.Timezone), zap.Error(err))
+		} else {
+			rankingService.SetPeriodLocation(loc)
+		}
+	}
+	if rankingCfg.Season.FirstStart != "" {
+		firstStart, err := time.Parse(time.RFC3339, rankingCfg.Season.FirstStart)
+		if err != nil {
+			logger.Error("赛季开始时间配置无效", zap.String("first_start", rankingCfg.Season.FirstStart), zap.Error(err))
+			os.Exit(1)
+		}
+		rankingService.SetSeasonConfig(ranking.SeasonConfig{
+			FirstStart: firstStart,
+			Length:     time.Duration(rankingCfg.Season.LengthDays) * 24 * time.Hour,
+			ArchiveTop: rankingCfg.Season.ArchiveTop,
+		})
+	}
+	antiCheatCfg := rankingCfg.AntiCheat // 提交频率限制使用Redis计数；要求签名但未配置签名密钥时拒绝启动
+	if err := rankingService.SetAntiCheat(ranking.AntiCheatConfig{
+		RequireSignature: antiCheatCfg.RequireSignature,
+		SigningSecret:    antiCheatCfg.SigningSecret,
+		RunTTL:           time.Duration(antiCheatCfg.RunTTLMinutes) * time.Minute,
+		SubmitLimit:      antiCheatCfg.SubmitLimit,
+		SubmitWindow:     time.Duration(antiCheatCfg.SubmitWindowSeconds) * time.Second,
+	}, cacheInstance); err != nil {
+		logger.Error("成绩提交校验初始化失败", zap.Error(err))
+		os.Exit(1)
+	}
 
-	// Chat模块 - AI聊天管理（创建WebSocket管理器）
+	// Chat模块 - AI聊天和玩家私聊（创建WebSocket管理器）
 	wsManager := ws.NewManager()
+	wsManager.SetAdmission(func(userID uint) error {
+		return userService.CheckBan(userID, security.BanScopeFull) // 全部封禁的用户不能建立实时连接（禁言只限制发送消息）
+	})
+	userService.SetConnectionKicker(wsManager) // 全部封禁时断开实时连接
 	chatRepo := chat.NewRepository(database)
-	chatService := chat.NewService(chatRepo, chat.NewAIClient(), wsManager, cacheInstance)
+	chatService := chat.NewService(chatRepo, chat.NewAIClient(), wsManager, cacheInstance, userService)
 	chatHandler := chat.NewHandler(chatService)
 
+	// Friend模块 - 好友关系（在线状态来自WebSocket管理器，上线/下线时通知好友）
+	friendRepo := friend.NewRepository(database)
+	friendService := friend.NewService(friendRepo, userService, wsManager)
+	friendHandler := friend.NewHandler(friendService)
+	rankingService.SetNotifier(wsManager)                      // 推送名次变化和订阅的排行榜更新
+	rankingService.SetGroupSources(friendService, chatService) // 好友榜和聊天室榜
+	wsManager.SetPresenceHandler(func(userID uint, online bool) {
+		friendService.OnPresenceChange(userID, online)
+		rankingService.OnPresenceChange(userID, online) // 连接全部断开时清除排行榜订阅
+	})
+	chatService.SetBlockChecker(friendService) // 屏蔽后不能私聊
+
 	// SaveGame模块 - 存档管理
 	saveGameRepo := savegame.NewRepository(database)
 	saveGameService := savegame.NewService(saveGameRepo)
 	saveGameHandler := savegame.NewHandler(saveGameService)
 
+	// 删除用户（游客清理、注销账号）时各模块在同一事务中清理自己的数据
+	userService.AddPurgeHook(chatService.PurgeUsers)
+	userService.AddPurgeHook(friendService.PurgeUsers)
+	userService.AddPurgeHook(rankingService.PurgeUsers)
+	userService.AddPurgeHook(saveGameService.PurgeUsers)
+
+	// Account模块 - 个人数据导出（汇总用户、排行榜、存档、聊天数据）
+	accountRepo := account.NewRepository(database)
+	accountService := account.NewService(accountRepo, userService)
+	accountHandler := account.NewHandler(accountService)
+
+	// ============================================================
+	// 定时任务
+	// ============================================================
+	sched := scheduler.New()
+
+	// 清理长期不活跃的游客账号
+	guestCfg := cfg.Guest
+	if guestCfg.InactiveDays <= 0 {
+		guestCfg.InactiveDays = 30
+	}
+	if guestCfg.CleanupIntervalHours <= 0 {
+		guestCfg.CleanupIntervalHours = 24
+	}
+	sched.Every("guest_cleanup", time.Duration(guestCfg.CleanupIntervalHours)*time.Hour, func() {
+		userService.CleanupInactiveGuests(time.Duration(guestCfg.InactiveDays) * 24 * time.Hour)
+	})
+
+	// 删除注销宽限期已结束的账号
+	accountCfg := cfg.Account
+	if accountCfg.DeletionGraceDays <= 0 {
+		accountCfg.DeletionGraceDays = 7
+	}
+	if accountCfg.DeletionCheckIntervalMinutes <= 0 {
+		accountCfg.DeletionCheckIntervalMinutes = 60
+	}
+	userService.SetDeletionGrace(time.Duration(accountCfg.DeletionGraceDays) * 24 * time.Hour)
+	sched.Every("account_deletion", time.Duration(accountCfg.DeletionCheckIntervalMinutes)*time.Minute, func() {
+		userService.PurgeDeletedAccounts()
+	})
+
+	// 赛季结束时归档最终排名并开始下一赛季
+	if rankingCfg.Season.CheckIntervalMinutes <= 0 {
+		rankingCfg.Season.CheckIntervalMinutes = 1
+	}
+	sched.Every("ranking_season_rollover", time.Duration(rankingCfg.Season.CheckIntervalMinutes)*time.Minute, func() {
+		rankingService.RolloverSeasons()
+	})
+
+	// 清理过期的日榜/周榜/月榜成绩和成绩提交记录
+	if rankingCfg.PeriodRetentionDays <= 0 {
+		rankingCfg.PeriodRetentionDays = 90
+	}
+	if rankingCfg.SubmissionRetentionDays <= 0 {
+		rankingCfg.SubmissionRetentionDays = 180
+	}
+	sched.Every("ranking_period_cleanup", 24*time.Hour, func() {
+		rankingService.PurgeExpiredPeriods(time.Duration(rankingCfg.PeriodRetentionDays) * 24 * time.Hour)
+		rankingService.PurgeSubmissions(time.Duration(rankingCfg.SubmissionRetentionDays) * 24 * time.Hour)
+		rankingService.PurgeExpiredRuns()
+	})
+
+	sched.Start()
+	defer sched.Stop()
+
 	// 组装所有处理器
 	handlers := &routes.Handlers{
 		User:     userHandler,
 		Chat:     chatHandler,
 		SaveGame: saveGameHandler,
 		Ranking:  rankingHandler,
+		Account:  accountHandler,
+		Friend:   friendHandler,
+		Files:    fileStorage.Handler(),
 	}
 
 	// 启动HTTP服务器
@@ -124,7 +282,7 @@ func main() {
 	logger.Info("服务启动成功",
 		zap.String("port", cfg.App.Port),
 		zap.String("swagger", fmt.Sprintf("http://localhost:%s/swagger/index.html", cfg.App.Port)),
-		zap.Strings("modules", []string{"user", "ranking", "chat", "savegame"}))
+		zap.Strings("modules", []string{"user", "account", "friend", "ranking", "chat", "savegame"}))
 
 	if err := router.Run(":" + cfg.App.Port); err != nil {
 		logger.Error("服务启动失败", zap.Error(err))
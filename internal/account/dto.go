@@ -0,0 +1,104 @@
+// Package account - 账号数据模块数据传输对象
+// 功能：定义导出文件中各部分的数据结构
+package account
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProfileExport 账号、资料和登录设备（profile.json）
+type ProfileExport struct {
+	User     UserExport      `json:"user"`
+	Profile  ProfileData     `json:"profile"`
+	Sessions []SessionExport `json:"sessions"` // 未过期的登录设备
+}
+
+// UserExport 账号信息
+type UserExport struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	IsGuest     bool      `json:"is_guest"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// ProfileData 玩家资料
+type ProfileData struct {
+	DisplayName string          `json:"display_name"`
+	Avatar      string          `json:"avatar"`
+	Bio         string          `json:"bio"`
+	Locale      string          `json:"locale"`
+	Preferences json.RawMessage `json:"preferences"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// SessionExport 登录设备
+type SessionExport struct {
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// RankingExport 排行榜成绩（rankings.json）
+type RankingExport struct {
+	RankType  int       `json:"rank_type"`
+	Score     int       `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RankingPeriodExport 赛季榜和日榜/周榜/月榜成绩（ranking_periods.json）
+type RankingPeriodExport struct {
+	RankType  int       `json:"rank_type"`
+	Period    string    `json:"period"`
+	PeriodKey string    `json:"period_key"`
+	Score     int       `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SeasonStandingExport 已结束赛季的最终排名（season_standings.json）
+type SeasonStandingExport struct {
+	SeasonID uint `json:"season_id"`
+	RankType int  `json:"rank_type"`
+	Rank     int  `json:"rank"`
+	Score    int  `json:"score"`
+}
+
+// SubmissionExport 成绩提交记录（ranking_submissions.json）
+type SubmissionExport struct {
+	RankType      int       `json:"rank_type"`
+	Score         int       `json:"score"`
+	Status        string    `json:"status"`
+	ClientVersion string    `json:"client_version"`
+	RunID         string    `json:"run_id"`
+	ReplayHash    string    `json:"replay_hash"`
+	ClientIP      string    `json:"client_ip"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SaveGameExport 存档（save_games.json）
+type SaveGameExport struct {
+	SlotNumber int       `json:"slot_number"`
+	GameData   string    `json:"game_data"`
+	SavedAt    time.Time `json:"saved_at"`
+}
+
+// ChatSessionExport 聊天会话及其消息（chat_history.json）
+type ChatSessionExport struct {
+	ID        uint                `json:"id"`
+	Title     string              `json:"title"`
+	Type      int                 `json:"type"`
+	CreatedAt time.Time           `json:"created_at"`
+	Messages  []ChatMessageExport `json:"messages"`
+}
+
+// ChatMessageExport 聊天消息
+type ChatMessageExport struct {
+	ID        uint      `json:"id"`
+	SenderID  uint      `json:"sender_id,omitempty"` // 私聊和聊天室消息的发送者（只导出用户自己发送的消息）
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
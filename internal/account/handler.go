@@ -0,0 +1,57 @@
+// Package account - 账号数据模块HTTP处理层
+// 功能：处理个人数据导出请求
+// 架构：MVC中的Controller层
+package account
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/shared/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 账号数据处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建账号数据处理器
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Export 导出个人数据
+// @Summary 导出我的数据
+// @Description 下载zip压缩包，包含账号资料和登录设备（profile.json）、排行榜成绩（rankings.json、ranking_periods.json、season_standings.json、ranking_submissions.json）、存档（save_games.json）和聊天记录（chat_history.json，私聊和聊天室只包含本人发送的消息）
+// @Tags user
+// @Produce application/zip
+// @Success 200 {file} file "数据压缩包"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/me/export [get]
+func (h *Handler) Export(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	// 先在内存中生成完整的压缩包，出错时仍可返回JSON错误
+	var buf bytes.Buffer
+	if err := h.service.Export(userID, &buf); err != nil {
+		var e *errcode.Error
+		if stderrors.As(err, &e) && e.Code == errcode.UserNotFound {
+			response.Error(c, http.StatusNotFound, e.Code)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("export-%d-%s.zip", userID, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
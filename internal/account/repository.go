@@ -0,0 +1,100 @@
+// Package account - 账号数据模块数据访问层
+// 功能：跨模块读取单个用户的全部数据（用于数据导出）
+// 设计模式：Repository模式
+package account
+
+import (
+	"faulty_in_culture/go_back/internal/chat"
+	"faulty_in_culture/go_back/internal/ranking"
+	"faulty_in_culture/go_back/internal/savegame"
+
+	"gorm.io/gorm"
+)
+
+// Repository 账号数据仓储接口
+type Repository interface {
+	// FindRankings 获取用户的所有排行榜成绩
+	FindRankings(userID uint) ([]*ranking.Entity, error)
+	// FindPeriodScores 获取用户的赛季榜和日榜/周榜/月榜成绩
+	FindPeriodScores(userID uint) ([]*ranking.PeriodScore, error)
+	// FindSeasonStandings 获取用户在已结束赛季中的最终排名
+	FindSeasonStandings(userID uint) ([]*ranking.SeasonStanding, error)
+	// FindSubmissions 获取用户的成绩提交记录
+	FindSubmissions(userID uint) ([]*ranking.Submission, error)
+	// FindSaveGames 获取用户的所有存档
+	FindSaveGames(userID uint) ([]*savegame.Entity, error)
+	// FindChatSessions 获取用户的所有聊天会话（包括参与的私聊和聊天室）
+	FindChatSessions(userID uint) ([]*chat.Session, error)
+	// FindChatMessages 分页获取用户可导出的聊天消息（ID大于afterID，按ID升序，最多limit条）
+	// ownSessionIDs 中的会话（用户自己的AI会话）导出全部消息，sharedSessionIDs 中的会话（私聊、聊天室）只导出用户自己发送的消息
+	FindChatMessages(userID uint, ownSessionIDs, sharedSessionIDs []uint, afterID uint, limit int) ([]*chat.Message, error)
+}
+
+// repositoryImpl Repository的GORM实现
+type repositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建账号数据仓储实例
+func NewRepository(db *gorm.DB) Repository {
+	return &repositoryImpl{db: db}
+}
+
+// FindRankings 获取用户的所有排行榜成绩
+func (r *repositoryImpl) FindRankings(userID uint) ([]*ranking.Entity, error) {
+	var rankings []*ranking.Entity
+	err := r.db.Where("user_id = ?", userID).Order("rank_type ASC").Find(&rankings).Error
+	return rankings, err
+}
+
+// FindPeriodScores 获取用户的赛季榜和日榜/周榜/月榜成绩
+func (r *repositoryImpl) FindPeriodScores(userID uint) ([]*ranking.PeriodScore, error) {
+	var scores []*ranking.PeriodScore
+	err := r.db.Where("user_id = ?", userID).Order("rank_type ASC, period ASC, period_key ASC").Find(&scores).Error
+	return scores, err
+}
+
+// FindSeasonStandings 获取用户在已结束赛季中的最终排名
+func (r *repositoryImpl) FindSeasonStandings(userID uint) ([]*ranking.SeasonStanding, error) {
+	var standings []*ranking.SeasonStanding
+	err := r.db.Where("user_id = ?", userID).Order("season_id ASC, rank_type ASC").Find(&standings).Error
+	return standings, err
+}
+
+// FindSubmissions 获取用户的成绩提交记录
+func (r *repositoryImpl) FindSubmissions(userID uint) ([]*ranking.Submission, error) {
+	var subs []*ranking.Submission
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&subs).Error
+	return subs, err
+}
+
+// FindSaveGames 获取用户的所有存档
+func (r *repositoryImpl) FindSaveGames(userID uint) ([]*savegame.Entity, error) {
+	var saves []*savegame.Entity
+	err := r.db.Where("user_id = ?", userID).Order("slot_number ASC").Find(&saves).Error
+	return saves, err
+}
+
+// FindChatSessions 获取用户的所有聊天会话（包括参与的私聊和聊天室）
+func (r *repositoryImpl) FindChatSessions(userID uint) ([]*chat.Session, error) {
+	var sessions []*chat.Session
+	err := r.db.Where("user_id = ? OR id IN (?)", userID,
+		r.db.Model(&chat.Participant{}).Select("session_id").Where("user_id = ?", userID)).
+		Order("created_at ASC").Find(&sessions).Error
+	return sessions, err
+}
+
+// FindChatMessages 分页获取用户可导出的聊天消息（ID大于afterID，按ID升序，最多limit条）
+func (r *repositoryImpl) FindChatMessages(userID uint, ownSessionIDs, sharedSessionIDs []uint, afterID uint, limit int) ([]*chat.Message, error) {
+	if len(ownSessionIDs) == 0 && len(sharedSessionIDs) == 0 {
+		return []*chat.Message{}, nil
+	}
+	var messages []*chat.Message
+	// 其中一个列表为空时生成 IN (NULL)，不匹配任何会话
+	err := r.db.Where("id > ?", afterID).
+		Where("(session_id IN ? OR (session_id IN ? AND sender_id = ?))", ownSessionIDs, sharedSessionIDs, userID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
@@ -0,0 +1,279 @@
+// Package account - 账号数据模块业务逻辑层
+// 功能：导出用户的全部个人数据（资料、排行榜、存档、聊天记录）
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"faulty_in_culture/go_back/internal/chat"
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/user"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// Service层 - 业务逻辑层
+// 职责：
+// 1. 汇总各模块中属于同一用户的数据
+// 2. 打包为zip格式的导出文件
+// ============================================================
+
+// UserService 用户服务接口（依赖倒置）
+type UserService interface {
+	GetProfile(userID uint) (*user.Entity, *user.Profile, error)
+	ListSessions(userID uint) ([]*user.DeviceSession, error)
+	BriefOf(u *user.Entity, profile *user.Profile) user.Brief
+}
+
+// Service 账号数据服务
+type Service struct {
+	repo        Repository
+	userService UserService
+}
+
+// NewService 创建账号数据服务实例
+func NewService(repo Repository, userService UserService) *Service {
+	return &Service{
+		repo:        repo,
+		userService: userService,
+	}
+}
+
+// exportFile 导出文件中的一个条目
+type exportFile struct {
+	name string
+	data interface{}
+}
+
+// Export 导出用户的全部数据，以zip格式写入w
+// 先查询完所有数据再开始写入，查询失败时不会输出半个文件
+func (s *Service) Export(userID uint, w io.Writer) error {
+	logger.Info("[account.Export] 导出用户数据", zap.Uint("user_id", userID))
+
+	files, err := s.collect(userID)
+	if err != nil {
+		logger.Error("[account.Export] 查询数据失败", zap.Uint("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		header := &zip.FileHeader{
+			Name:     f.name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(fw)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.data); err != nil {
+			return fmt.Errorf("写入%s失败: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	logger.Info("[account.Export] 导出完成", zap.Uint("user_id", userID))
+	return nil
+}
+
+// collect 汇总用户的全部数据
+func (s *Service) collect(userID uint) ([]exportFile, error) {
+	u, profile, err := s.userService.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.userService.ListSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	rankings, err := s.repo.FindRankings(userID)
+	if err != nil {
+		return nil, err
+	}
+	periodScores, err := s.repo.FindPeriodScores(userID)
+	if err != nil {
+		return nil, err
+	}
+	standings, err := s.repo.FindSeasonStandings(userID)
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := s.repo.FindSubmissions(userID)
+	if err != nil {
+		return nil, err
+	}
+	saves, err := s.repo.FindSaveGames(userID)
+	if err != nil {
+		return nil, err
+	}
+	chatSessions, err := s.repo.FindChatSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.chatMessages(userID, chatSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	// 账号与资料
+	preferences := profile.Preferences
+	if preferences == "" {
+		preferences = "{}"
+	}
+	brief := s.userService.BriefOf(u, profile)
+	profileExport := ProfileExport{
+		User: UserExport{
+			ID:          u.ID,
+			Username:    u.Username,
+			Role:        u.Role,
+			IsGuest:     u.IsGuest,
+			CreatedAt:   u.CreatedAt,
+			LastLoginAt: u.LastLoginAt,
+		},
+		Profile: ProfileData{
+			DisplayName: brief.DisplayName,
+			Avatar:      brief.Avatar,
+			Bio:         profile.Bio,
+			Locale:      profile.Locale,
+			Preferences: json.RawMessage(preferences),
+			UpdatedAt:   profile.UpdatedAt,
+		},
+		Sessions: make([]SessionExport, len(sessions)),
+	}
+	for i, ds := range sessions {
+		profileExport.Sessions[i] = SessionExport{
+			DeviceName: ds.DeviceName,
+			IP:         ds.IP,
+			UserAgent:  ds.UserAgent,
+			CreatedAt:  ds.CreatedAt,
+			LastSeenAt: ds.LastSeenAt,
+		}
+	}
+
+	// 排行榜
+	rankingExports := make([]RankingExport, len(rankings))
+	for i, r := range rankings {
+		rankingExports[i] = RankingExport{
+			RankType:  r.RankType,
+			Score:     r.Score,
+			UpdatedAt: r.UpdatedAt,
+		}
+	}
+
+	periodExports := make([]RankingPeriodExport, len(periodScores))
+	for i, ps := range periodScores {
+		periodExports[i] = RankingPeriodExport{
+			RankType:  ps.RankType,
+			Period:    ps.Period,
+			PeriodKey: ps.PeriodKey,
+			Score:     ps.Score,
+			UpdatedAt: ps.UpdatedAt,
+		}
+	}
+	standingExports := make([]SeasonStandingExport, len(standings))
+	for i, st := range standings {
+		standingExports[i] = SeasonStandingExport{
+			SeasonID: st.SeasonID,
+			RankType: st.RankType,
+			Rank:     st.Rank,
+			Score:    st.Score,
+		}
+	}
+
+	submissionExports := make([]SubmissionExport, len(submissions))
+	for i, sub := range submissions {
+		submissionExports[i] = SubmissionExport{
+			RankType:      sub.RankType,
+			Score:         sub.Score,
+			Status:        sub.Status,
+			ClientVersion: sub.ClientVersion,
+			RunID:         sub.RunID,
+			ReplayHash:    sub.ReplayHash,
+			ClientIP:      sub.ClientIP,
+			CreatedAt:     sub.CreatedAt,
+		}
+	}
+
+	// 存档
+	saveExports := make([]SaveGameExport, len(saves))
+	for i, sg := range saves {
+		saveExports[i] = SaveGameExport{
+			SlotNumber: sg.SlotNumber,
+			GameData:   sg.GameData,
+			SavedAt:    sg.SavedAt,
+		}
+	}
+
+	// 聊天记录（消息按会话分组）
+	chatExports := make([]ChatSessionExport, len(chatSessions))
+	index := make(map[uint]int, len(chatSessions))
+	for i, cs := range chatSessions {
+		chatExports[i] = ChatSessionExport{
+			ID:        cs.ID,
+			Title:     cs.Title,
+			Type:      cs.Type,
+			CreatedAt: cs.CreatedAt,
+			Messages:  []ChatMessageExport{},
+		}
+		index[cs.ID] = i
+	}
+	for _, m := range messages {
+		i := index[m.SessionID]
+		chatExports[i].Messages = append(chatExports[i].Messages, ChatMessageExport{
+			ID:        m.ID,
+			SenderID:  m.SenderID,
+			Content:   m.Content,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	return []exportFile{
+		{name: "profile.json", data: profileExport},
+		{name: "rankings.json", data: rankingExports},
+		{name: "ranking_periods.json", data: periodExports},
+		{name: "season_standings.json", data: standingExports},
+		{name: "ranking_submissions.json", data: submissionExports},
+		{name: "save_games.json", data: saveExports},
+		{name: "chat_history.json", data: chatExports},
+	}, nil
+}
+
+// chatMessagePageSize 导出聊天记录时每次查询的消息数
+const chatMessagePageSize = 1000
+
+// chatMessages 分页查询要导出的聊天消息
+// 用户自己的AI会话导出全部消息；私聊和聊天室只导出用户自己发送的消息，不包含其他人的消息
+func (s *Service) chatMessages(userID uint, chatSessions []*chat.Session) ([]*chat.Message, error) {
+	var own, shared []uint
+	for _, cs := range chatSessions {
+		if cs.IsDirect() || cs.IsRoom() {
+			shared = append(shared, cs.ID)
+		} else if cs.UserID == userID {
+			own = append(own, cs.ID)
+		}
+	}
+
+	var messages []*chat.Message
+	var afterID uint
+	for {
+		page, err := s.repo.FindChatMessages(userID, own, shared, afterID, chatMessagePageSize)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, page...)
+		if len(page) < chatMessagePageSize {
+			return messages, nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
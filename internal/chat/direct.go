@@ -0,0 +1,391 @@
+// Package chat - 玩家私聊
+// 功能：两名玩家共享一个会话，消息通过WebSocket推送给双方，支持已读回执和未读数
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/user"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 玩家私聊
+// 授权：AI会话只有创建者可以访问；私聊会话的双方都记录在 chat_participants 中，
+//       历史、撤回、已读等接口按参与者授权
+// 推送事件：
+//   direct_message    新消息（推送给双方，发送方用于多端同步）
+//   read_receipt      对方已读位置变化
+//   message_recalled  消息被撤回
+// ============================================================
+
+// maxDirectMessageLength 私聊消息最大长度（字符数）
+const maxDirectMessageLength = 1000
+
+// directKey 私聊双方的唯一标识（与发起方向无关）
+func directKey(a, b uint) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%d:%d", a, b)
+}
+
+// authorize 校验用户能否访问会话
+func (s *Service) authorize(userID uint, session *Session) error {
+	if !session.HasParticipants() {
+		if session.UserID != userID {
+			return fmt.Errorf("未授权")
+		}
+		return nil
+	}
+
+	p, err := s.repo.FindParticipant(session.ID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("未授权")
+	}
+	return nil
+}
+
+// StartDirect 发起与另一名玩家的私聊（双方之间已有私聊会话时直接返回该会话）
+func (s *Service) StartDirect(userID, peerID uint) (*Session, error) {
+	if userID == peerID {
+		return nil, errcode.New(errcode.CannotChatSelf)
+	}
+	briefs, err := s.userService.GetUsernames([]uint{peerID})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := briefs[peerID]; !ok {
+		return nil, errcode.New(errcode.UserNotFound)
+	}
+	if err := s.checkBlocked(userID, peerID); err != nil {
+		return nil, err
+	}
+
+	key := directKey(userID, peerID)
+	session, err := s.repo.FindSessionByDirectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil {
+		return session, nil
+	}
+
+	session = &Session{
+		UserID:    userID,
+		Type:      SessionTypeDirect,
+		DirectKey: &key,
+	}
+	if err := s.repo.CreateSessionWithParticipants(session, []uint{userID, peerID}); err != nil {
+		// 双方同时发起时唯一索引冲突，返回对方创建的会话
+		if existing, findErr := s.repo.FindSessionByDirectKey(key); findErr == nil && existing != nil {
+			return existing, nil
+		}
+		logger.Error("[chat.StartDirect] 创建私聊会话失败", zap.Uint("user_id", userID), zap.Uint("peer_id", peerID), zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("[chat.StartDirect] 私聊会话已创建",
+		zap.Uint("session_id", session.ID),
+		zap.Uint("user_id", userID),
+		zap.Uint("peer_id", peerID))
+	return session, nil
+}
+
+// sendDirect 发送私聊消息：保存后推送给双方，发送方的已读位置前移到该消息
+func (s *Service) sendDirect(userID uint, session *Session, content string) (*Message, int, error) {
+	participants, err := s.repo.ListParticipants([]uint{session.ID})
+	if err != nil {
+		return nil, 0, err
+	}
+	isParticipant := false
+	for _, p := range participants {
+		if p.UserID == userID {
+			isParticipant = true
+			continue
+		}
+		if err := s.checkBlocked(userID, p.UserID); err != nil {
+			return nil, 0, err
+		}
+	}
+	if !isParticipant {
+		return nil, 0, fmt.Errorf("未授权")
+	}
+	if utf8.RuneCountInString(content) > maxDirectMessageLength {
+		return nil, 0, errcode.New(errcode.MessageTooLong)
+	}
+
+	msg := &Message{
+		SessionID: session.ID,
+		SenderID:  userID,
+		Content:   content,
+	}
+	if err := s.repo.CreateMessage(msg); err != nil {
+		return nil, 0, err
+	}
+	if err := s.repo.TouchSession(session.ID, msg.CreatedAt); err != nil {
+		logger.Warn("[chat.sendDirect] 更新会话活跃时间失败", zap.Uint("session_id", session.ID), zap.Error(err))
+	}
+	if _, err := s.repo.UpdateLastRead(session.ID, userID, msg.ID); err != nil {
+		logger.Warn("[chat.sendDirect] 更新已读位置失败", zap.Uint("session_id", session.ID), zap.Error(err))
+	}
+
+	count, err := s.repo.CountMessagesBySessionID(session.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	event := DirectMessageEvent{
+		Type:      "direct_message",
+		SessionID: session.ID,
+		Message:   toMessageVO(msg, int(count)),
+	}
+	for _, p := range participants {
+		s.push(p.UserID, event)
+	}
+	return msg, int(count), nil
+}
+
+// recallDirect 撤回私聊消息（只能撤回自己发送的消息），并通知双方
+func (s *Service) recallDirect(userID uint, session *Session, messageIDs []uint) error {
+	deleted, err := s.repo.DeleteMessagesBySender(session.ID, userID, messageIDs)
+	if err != nil {
+		logger.Error("[chat.recallDirect] 撤回失败", zap.Uint("session_id", session.ID), zap.Error(err))
+		return err
+	}
+	if len(deleted) == 0 {
+		return fmt.Errorf("未授权")
+	}
+
+	s.pushToParticipants(session.ID, 0, MessageRecalledEvent{
+		Type:       "message_recalled",
+		SessionID:  session.ID,
+		MessageIDs: deleted,
+	})
+
+	logger.Info("[chat.recallDirect] 撤回成功",
+		zap.Uint("session_id", session.ID),
+		zap.Uint("user_id", userID),
+		zap.Int("count", len(deleted)))
+	return nil
+}
+
+// MarkRead 标记私聊/聊天室已读到指定消息（messageID为0时标记到最新消息），私聊的已读位置变化时通知对方
+func (s *Service) MarkRead(userID, sessionID, messageID uint) (*ReadStateVO, error) {
+	session, err := s.repo.FindSessionByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.HasParticipants() {
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, "只有私聊和聊天室支持标记已读")
+	}
+	p, err := s.repo.FindParticipant(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("未授权")
+	}
+
+	latest, err := s.repo.LatestMessageID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if messageID == 0 || messageID > latest {
+		messageID = latest
+	}
+
+	changed, err := s.repo.UpdateLastRead(sessionID, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	state := &ReadStateVO{SessionID: sessionID, LastReadMessageID: p.LastReadMessageID}
+	if !changed {
+		return state, nil
+	}
+
+	state.LastReadMessageID = messageID
+	if !session.IsDirect() {
+		// 聊天室不推送已读回执
+		return state, nil
+	}
+	s.pushToParticipants(sessionID, userID, ReadReceiptEvent{
+		Type:              "read_receipt",
+		SessionID:         sessionID,
+		UserID:            userID,
+		LastReadMessageID: messageID,
+	})
+	return state, nil
+}
+
+// UnreadSummary 未读消息统计（所有私聊和聊天室）
+func (s *Service) UnreadSummary(userID uint) (*UnreadVO, error) {
+	counts, err := s.repo.CountUnread(userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &UnreadVO{Sessions: make([]SessionUnreadVO, 0, len(counts))}
+	for sessionID, count := range counts {
+		summary.Total += count
+		summary.Sessions = append(summary.Sessions, SessionUnreadVO{SessionID: sessionID, Count: count})
+	}
+	sort.Slice(summary.Sessions, func(i, j int) bool {
+		return summary.Sessions[i].SessionID > summary.Sessions[j].SessionID
+	})
+	return summary, nil
+}
+
+// ListSessionViews 获取会话列表（私聊附带对方信息，私聊和聊天室附带未读数）
+func (s *Service) ListSessionViews(userID uint, offset, limit int) ([]SessionVO, error) {
+	sessions, err := s.ListSessions(userID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	vos := make([]SessionVO, len(sessions))
+	var directIDs, memberIDs []uint
+	for i, session := range sessions {
+		vos[i] = toSessionVO(session)
+		if session.IsDirect() {
+			directIDs = append(directIDs, session.ID)
+		}
+		if session.HasParticipants() {
+			memberIDs = append(memberIDs, session.ID)
+		}
+	}
+	if len(memberIDs) == 0 {
+		return vos, nil
+	}
+
+	// 私聊的对方信息和未读数（查询失败时降级为不返回）
+	participants, err := s.repo.ListParticipants(directIDs)
+	if err != nil {
+		logger.Warn("[chat.ListSessionViews] 参与者查询失败", zap.Uint("user_id", userID), zap.Error(err))
+		return vos, nil
+	}
+	peers := make(map[uint]*Participant, len(directIDs))
+	peerIDs := make([]uint, 0, len(participants))
+	for _, p := range participants {
+		if p.UserID != userID {
+			peers[p.SessionID] = p
+			peerIDs = append(peerIDs, p.UserID)
+		}
+	}
+	briefs := s.briefs(peerIDs)
+	unread, err := s.repo.CountUnread(userID, memberIDs)
+	if err != nil {
+		logger.Warn("[chat.ListSessionViews] 未读数查询失败", zap.Uint("user_id", userID), zap.Error(err))
+	}
+
+	for i := range vos {
+		if p, ok := peers[vos[i].ID]; ok {
+			peer := toPeerVO(p, briefs[p.UserID])
+			vos[i].Peer = &peer
+		}
+		vos[i].UnreadCount = unread[vos[i].ID]
+	}
+	return vos, nil
+}
+
+// participantViews 会话参与者（含已读位置）
+func (s *Service) participantViews(sessionID uint) ([]PeerVO, error) {
+	participants, err := s.repo.ListParticipants([]uint{sessionID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(participants))
+	for i, p := range participants {
+		ids[i] = p.UserID
+	}
+	briefs := s.briefs(ids)
+
+	vos := make([]PeerVO, len(participants))
+	for i, p := range participants {
+		vos[i] = toPeerVO(p, briefs[p.UserID])
+	}
+	return vos, nil
+}
+
+// checkBlocked 检查双方之间是否存在屏蔽
+func (s *Service) checkBlocked(userID, peerID uint) error {
+	if s.blocks == nil {
+		return nil
+	}
+	blocked, err := s.blocks.IsBlocked(userID, peerID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errcode.New(errcode.DirectChatBlocked)
+	}
+	return nil
+}
+
+// pushToParticipants 向会话参与者推送事件（exceptUserID非0时跳过该用户）
+func (s *Service) pushToParticipants(sessionID, exceptUserID uint, event interface{}) {
+	participants, err := s.repo.ListParticipants([]uint{sessionID})
+	if err != nil {
+		logger.Warn("[chat.pushToParticipants] 参与者查询失败", zap.Uint("session_id", sessionID), zap.Error(err))
+		return
+	}
+	for _, p := range participants {
+		if p.UserID != exceptUserID {
+			s.push(p.UserID, event)
+		}
+	}
+}
+
+// push 通过WebSocket推送事件（失败只记录日志）
+func (s *Service) push(userID uint, event interface{}) {
+	if s.wsManager == nil {
+		return
+	}
+	if err := s.wsManager.SendToUser(userID, event); err != nil {
+		logger.Warn("[chat.push] WebSocket推送失败", zap.Uint("user_id", userID), zap.Error(err))
+	}
+}
+
+// briefs 批量获取用户展示信息（失败时降级为只返回用户ID）
+func (s *Service) briefs(userIDs []uint) map[uint]user.Brief {
+	if len(userIDs) == 0 || s.userService == nil {
+		return nil
+	}
+	briefs, err := s.userService.GetUsernames(userIDs)
+	if err != nil {
+		logger.Warn("[chat.briefs] 批量查询用户信息失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		return nil
+	}
+	return briefs
+}
+
+// toPeerVO 转换参与者信息
+func toPeerVO(p *Participant, brief user.Brief) PeerVO {
+	return PeerVO{
+		UserID:            p.UserID,
+		Username:          brief.Username,
+		DisplayName:       brief.DisplayName,
+		Avatar:            brief.Avatar,
+		LastReadMessageID: p.LastReadMessageID,
+	}
+}
+
+// toMessageVO 转换消息（index为消息在会话中的序号）
+func toMessageVO(msg *Message, index int) MessageVO {
+	return MessageVO{
+		ID:           msg.ID,
+		SessionID:    msg.SessionID,
+		SenderID:     msg.SenderID,
+		MessageIndex: index,
+		Content:      msg.Content,
+		CreatedAt:    msg.CreatedAt,
+	}
+}
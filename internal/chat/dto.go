@@ -16,6 +16,47 @@ type UpdateSessionRequest struct {
 	Title string `json:"title" binding:"required" example:"更新的标题"`
 }
 
+// StartDirectRequest 发起私聊请求
+type StartDirectRequest struct {
+	UserID uint `json:"user_id" binding:"required" example:"2"`
+}
+
+// MarkReadRequest 标记已读请求（message_id 为空时标记到最新消息）
+type MarkReadRequest struct {
+	MessageID uint `json:"message_id" example:"10"`
+}
+
+// CreateRoomRequest 创建聊天室请求
+type CreateRoomRequest struct {
+	Name        string `json:"name" binding:"required,max=50" example:"星辰公会"`
+	Description string `json:"description" binding:"max=255" example:"每晚八点组队"`
+	NPCEnabled  bool   `json:"npc_enabled" example:"true"`              // 启用AI NPC（消息中@NPC名称时回复）
+	NPCName     string `json:"npc_name" binding:"max=32" example:"小助手"` // 为空时使用默认名称
+}
+
+// UpdateRoomRequest 更新聊天室请求（字段为空时不修改）
+type UpdateRoomRequest struct {
+	Name        string  `json:"name" binding:"max=50" example:"星辰公会"`
+	Description *string `json:"description" binding:"omitempty,max=255" example:"每晚八点组队"`
+	NPCEnabled  *bool   `json:"npc_enabled" example:"true"`
+	NPCName     string  `json:"npc_name" binding:"max=32" example:"小助手"`
+}
+
+// JoinRoomRequest 通过邀请码加入聊天室请求
+type JoinRoomRequest struct {
+	InviteCode string `json:"invite_code" binding:"required" example:"K7P2QX9M"`
+}
+
+// SetMemberRoleRequest 设置成员角色请求（设置为owner即转让群主）
+type SetMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner moderator member" example:"moderator"`
+}
+
+// MuteMemberRequest 禁言成员请求
+type MuteMemberRequest struct {
+	Minutes int `json:"minutes" binding:"required,min=1,max=43200" example:"60"` // 禁言时长（分钟，最长30天）
+}
+
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
 	Content string `json:"content" binding:"required" example:"你好"`
@@ -25,26 +66,126 @@ type SendMessageRequest struct {
 
 // SessionVO 会话值对象
 type SessionVO struct {
-	ID        uint      `json:"id" example:"1"`
-	UserID    uint      `json:"user_id" example:"1"`
-	Title     string    `json:"title" example:"我的对话"`
-	Type      int       `json:"type" example:"1"`
-	CreatedAt time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+	ID          uint      `json:"id" example:"1"`
+	UserID      uint      `json:"user_id" example:"1"`
+	Title       string    `json:"title" example:"我的对话"`
+	Type        int       `json:"type" example:"1"` // 1=AI对话, 3=私聊, 4=聊天室
+	Peer        *PeerVO   `json:"peer,omitempty"`   // 私聊的对方
+	UnreadCount int64     `json:"unread_count" example:"0"`
+	CreatedAt   time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// PeerVO 会话参与者信息
+type PeerVO struct {
+	UserID            uint   `json:"user_id" example:"2"`
+	Username          string `json:"username" example:"player2"`
+	DisplayName       string `json:"display_name" example:"小明"`
+	Avatar            string `json:"avatar" example:"/api/files/avatars/2/a1b2c3.png"`
+	LastReadMessageID uint   `json:"last_read_message_id" example:"10"` // 已读回执：对方已读到的最后一条消息
 }
 
 // MessageVO 消息值对象
 type MessageVO struct {
 	ID           uint      `json:"id" example:"1"`
 	SessionID    uint      `json:"session_id" example:"1"`
-	MessageIndex int       `json:"message_index" example:"1"` // 1=用户, 2=AI, 3=用户...
+	SenderID     uint      `json:"sender_id,omitempty" example:"1"` // 私聊/聊天室消息的发送者（聊天室NPC的消息为0）
+	MessageIndex int       `json:"message_index" example:"1"`       // AI会话：1=用户, 2=AI, 3=用户...
 	Content      string    `json:"content" example:"你好"`
 	CreatedAt    time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
 }
 
 // HistoryResponse 聊天历史响应
 type HistoryResponse struct {
-	Session  SessionVO   `json:"session"`
-	Messages []MessageVO `json:"messages"`
+	Session      SessionVO   `json:"session"`
+	Participants []PeerVO    `json:"participants,omitempty"` // 私聊双方（含已读位置）
+	Messages     []MessageVO `json:"messages"`
+	Total        int64       `json:"total,omitempty" example:"120"` // 聊天室消息总数（用于向前翻页）
+	Offset       int         `json:"offset" example:"70"`           // 本页第一条消息的偏移量
+}
+
+// RoomVO 聊天室信息
+type RoomVO struct {
+	SessionID   uint      `json:"session_id" example:"1"`
+	Name        string    `json:"name" example:"星辰公会"`
+	Description string    `json:"description" example:"每晚八点组队"`
+	OwnerID     uint      `json:"owner_id" example:"1"`
+	InviteCode  string    `json:"invite_code,omitempty" example:"K7P2QX9M"` // 只有群主和管理员可见
+	NPCEnabled  bool      `json:"npc_enabled" example:"true"`
+	NPCName     string    `json:"npc_name,omitempty" example:"小助手"`
+	MemberCount int64     `json:"member_count" example:"12"`
+	MyRole      string    `json:"my_role" example:"member"`
+	UnreadCount int64     `json:"unread_count" example:"0"`
+	CreatedAt   time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// RoomMemberVO 聊天室成员
+type RoomMemberVO struct {
+	UserID      uint       `json:"user_id" example:"2"`
+	Username    string     `json:"username" example:"player2"`
+	DisplayName string     `json:"display_name" example:"小明"`
+	Avatar      string     `json:"avatar" example:"/api/files/avatars/2/a1b2c3.png"`
+	Role        string     `json:"role" example:"member"`
+	MutedUntil  *time.Time `json:"muted_until,omitempty" example:"2023-12-20T11:00:00Z"`
+	Online      bool       `json:"online" example:"true"`
+	JoinedAt    time.Time  `json:"joined_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// RoomDetailVO 聊天室详情
+type RoomDetailVO struct {
+	Room    RoomVO         `json:"room"`
+	Members []RoomMemberVO `json:"members"`
+}
+
+// ReadStateVO 标记已读结果
+type ReadStateVO struct {
+	SessionID         uint `json:"session_id" example:"1"`
+	LastReadMessageID uint `json:"last_read_message_id" example:"10"`
+}
+
+// UnreadVO 未读消息统计
+type UnreadVO struct {
+	Total    int64             `json:"total" example:"3"`
+	Sessions []SessionUnreadVO `json:"sessions"`
+}
+
+// SessionUnreadVO 单个会话的未读数
+type SessionUnreadVO struct {
+	SessionID uint  `json:"session_id" example:"1"`
+	Count     int64 `json:"count" example:"3"`
+}
+
+// DirectMessageEvent 私聊新消息（推送给双方的所有连接，发送方用于多端同步）
+type DirectMessageEvent struct {
+	Type      string    `json:"type"` // "direct_message"
+	SessionID uint      `json:"session_id"`
+	Message   MessageVO `json:"message"`
+}
+
+// ReadReceiptEvent 已读回执（推送给会话的其他参与者）
+type ReadReceiptEvent struct {
+	Type              string `json:"type"` // "read_receipt"
+	SessionID         uint   `json:"session_id"`
+	UserID            uint   `json:"user_id"`
+	LastReadMessageID uint   `json:"last_read_message_id"`
+}
+
+// MessageRecalledEvent 私聊/聊天室消息被撤回
+type MessageRecalledEvent struct {
+	Type       string `json:"type"` // "message_recalled"
+	SessionID  uint   `json:"session_id"`
+	MessageIDs []uint `json:"message_ids"`
+}
+
+// RoomEvent 聊天室事件（推送给聊天室成员，按Type携带不同字段）
+type RoomEvent struct {
+	Type      string        `json:"type"` // room_message/room_member_joined/room_member_left/room_member_updated/room_dissolved
+	SessionID uint          `json:"session_id"`
+	Message   *MessageVO    `json:"message,omitempty"`
+	Member    *RoomMemberVO `json:"member,omitempty"`
+	UserID    uint          `json:"user_id,omitempty"`
+	Reason    string        `json:"reason,omitempty"` // room_member_left：leave/kick
 }
 
 // WebSocketMessage WebSocket消息
@@ -7,21 +7,110 @@ import (
 	"time"
 )
 
+// 会话类型
+const (
+	SessionTypeAI     = 1 // 用户与AI对话（只有创建者可以访问）
+	SessionTypeDirect = 3 // 玩家私聊（两名参与者，通过 chat_participants 授权）
+	SessionTypeRoom   = 4 // 聊天室/公会频道（多名成员，通过 chat_participants 授权，附加信息见 chat_rooms）
+)
+
+// 聊天室成员角色
+const (
+	RoleOwner     = "owner"     // 群主（每个聊天室一名，即会话的 user_id）
+	RoleModerator = "moderator" // 管理员：可以禁言、踢出普通成员，撤回任意消息
+	RoleMember    = "member"    // 普通成员
+)
+
 // Session 聊天会话实体
 type Session struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"` // 创建者（聊天室为当前群主）
 	Title     string    `gorm:"type:varchar(255);not null" json:"title"`
 	Type      int       `gorm:"not null;default:1" json:"type"`
+	DirectKey *string   `gorm:"type:varchar(64);uniqueIndex" json:"-"` // 私聊双方的唯一标识（"较小ID:较大ID"），其他会话为NULL
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"` // 最后活跃时间（私聊有新消息时更新）
 }
 
 func (Session) TableName() string { return "chat_sessions" }
 
+// IsDirect 是否是玩家私聊会话
+func (s *Session) IsDirect() bool {
+	return s.Type == SessionTypeDirect
+}
+
+// IsRoom 是否是聊天室会话
+func (s *Session) IsRoom() bool {
+	return s.Type == SessionTypeRoom
+}
+
+// HasParticipants 是否按参与者授权（私聊和聊天室）
+func (s *Session) HasParticipants() bool {
+	return s.IsDirect() || s.IsRoom()
+}
+
+// Participant 会话参与者实体（私聊会话的双方、聊天室的每名成员各一条记录；AI会话没有参与者记录，只有创建者可以访问）
+type Participant struct {
+	SessionID         uint       `gorm:"primaryKey;autoIncrement:false" json:"session_id"`
+	UserID            uint       `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+	Role              string     `gorm:"type:varchar(16);not null;default:member" json:"role"` // 聊天室角色（私聊固定为member）
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`                                // 聊天室禁言截止时间
+	LastReadMessageID uint       `gorm:"not null;default:0" json:"last_read_message_id"`       // 已读到的最后一条消息ID
+	JoinedAt          time.Time  `gorm:"autoCreateTime" json:"joined_at"`
+}
+
+func (Participant) TableName() string { return "chat_participants" }
+
+// IsMuted 是否处于禁言中
+func (p *Participant) IsMuted(now time.Time) bool {
+	return p.MutedUntil != nil && p.MutedUntil.After(now)
+}
+
+// roleRank 角色等级（越大权限越高）
+func roleRank(role string) int {
+	switch role {
+	case RoleOwner:
+		return 3
+	case RoleModerator:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// CanModerate 是否可以管理目标成员（管理员及以上，且角色高于对方）
+func (p *Participant) CanModerate(target *Participant) bool {
+	return roleRank(p.Role) >= roleRank(RoleModerator) && roleRank(p.Role) > roleRank(target.Role)
+}
+
+// Room 聊天室附加信息实体（与 chat_sessions 一对一，名称使用会话标题）
+type Room struct {
+	SessionID   uint      `gorm:"primaryKey;autoIncrement:false" json:"session_id"`
+	Description string    `gorm:"type:varchar(255);not null;default:''" json:"description"`
+	InviteCode  string    `gorm:"type:varchar(16);uniqueIndex;not null" json:"invite_code"` // 加入聊天室的邀请码（可重新生成）
+	NPCEnabled  bool      `gorm:"not null;default:false" json:"npc_enabled"`                // 是否启用AI NPC（被@时回复）
+	NPCName     string    `gorm:"type:varchar(32);not null;default:''" json:"npc_name"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (Room) TableName() string { return "chat_rooms" }
+
+// RoomRestriction 聊天室对用户的限制（成员被踢出或带着禁言退出时记录，重新加入时检查，避免退出再加入绕过）
+type RoomRestriction struct {
+	SessionID  uint       `gorm:"primaryKey;autoIncrement:false" json:"session_id"`
+	UserID     uint       `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+	Kicked     bool       `gorm:"not null;default:false" json:"kicked"` // 被踢出后不能再通过邀请码加入
+	MutedUntil *time.Time `json:"muted_until,omitempty"`                // 退出时尚未到期的禁言，重新加入后继续生效
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (RoomRestriction) TableName() string { return "chat_room_restrictions" }
+
 // Message 聊天消息实体
 type Message struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	SessionID uint      `gorm:"index:idx_session_created;not null" json:"session_id"`
+	SenderID  uint      `gorm:"not null;default:0" json:"sender_id"` // 私聊/聊天室消息的发送者（AI会话的消息和聊天室NPC的消息为0）
 	Content   string    `gorm:"type:text;not null" json:"content"`
 	CreatedAt time.Time `gorm:"index:idx_session_created;autoCreateTime" json:"created_at"`
 }
@@ -5,6 +5,7 @@ package chat
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -51,8 +52,24 @@ func (h *Handler) WebSocketHandler(c *gin.Context) {
 		return
 	}
 
-	// 注册客户端
-	client := h.service.ConnectWebSocket(userID, conn)
+	// 注册客户端（未通过准入检查时推送原因后关闭连接）
+	client, err := h.service.ConnectWebSocket(userID, conn)
+	if err != nil {
+		rejectMsg := map[string]interface{}{
+			"type":    "rejected",
+			"code":    errcode.ServerError,
+			"message": err.Error(),
+		}
+		var e *errcode.Error
+		if stderrors.As(err, &e) {
+			rejectMsg["code"] = e.Code
+			rejectMsg["data"] = e.Data
+		}
+		conn.WriteMessage(ws.TextMessage, mustMarshal(rejectMsg))
+		conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.ClosePolicyViolation, "rejected"))
+		conn.Close()
+		return
+	}
 
 	// 发送连接成功消息
 	welcomeMsg := map[string]interface{}{
@@ -79,11 +96,29 @@ func toSessionVO(session *Session) SessionVO {
 		Title:     session.Title,
 		Type:      session.Type,
 		CreatedAt: session.CreatedAt,
+		UpdatedAt: session.UpdatedAt,
 	}
 }
 
 // handleSessionError 统一处理会话错误
 func handleSessionError(c *gin.Context, err error) {
+	var e *errcode.Error
+	if stderrors.As(err, &e) {
+		switch e.Code {
+		case errcode.UserNotFound, errcode.InviteCodeInvalid:
+			response.Error(c, http.StatusNotFound, e.Code)
+		case errcode.DirectChatBlocked, errcode.DirectChatReadOnly, errcode.PermissionDenied, errcode.KickedFromRoom:
+			response.ErrorWithMessage(c, http.StatusForbidden, e.Code, e.Message)
+		case errcode.RoomMuted:
+			response.ErrorWithData(c, http.StatusForbidden, e.Code, e.Data)
+		case errcode.AlreadyInRoom, errcode.RoomFull:
+			response.Error(c, http.StatusConflict, e.Code)
+		default:
+			response.ErrorWithMessage(c, http.StatusBadRequest, e.Code, e.Message)
+		}
+		return
+	}
+
 	if strings.Contains(err.Error(), "会话不存在") {
 		response.Error(c, http.StatusNotFound, errcode.SessionNotFound)
 	} else if strings.Contains(err.Error(), "未授权") {
@@ -121,6 +156,7 @@ func (h *Handler) StartChat(c *gin.Context) {
 
 // ListSessions 获取会话列表
 // @Summary 获取会话列表
+// @Description 包括AI对话和私聊，按最后活跃时间排序；私聊附带对方信息和未读数
 // @Tags 聊天
 // @Produce json
 // @Param offset query int false "偏移量" default(0)
@@ -132,17 +168,12 @@ func (h *Handler) ListSessions(c *gin.Context) {
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	sessions, err := h.service.ListSessions(userID, offset, limit)
+	vos, err := h.service.ListSessionViews(userID, offset, limit)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
 		return
 	}
 
-	vos := make([]SessionVO, len(sessions))
-	for i, s := range sessions {
-		vos[i] = toSessionVO(s)
-	}
-
 	response.Success(c, vos)
 }
 
@@ -231,6 +262,7 @@ func (h *Handler) DeleteSession(c *gin.Context) {
 
 // SendMessage 发送消息
 // @Summary 发送消息
+// @Description AI会话：AI回复通过WebSocket推送（ai_message）；私聊：消息通过WebSocket推送给双方（direct_message）；聊天室：推送给所有成员（room_message），被禁言时返回403
 // @Tags 聊天
 // @Accept json
 // @Produce json
@@ -255,7 +287,10 @@ func (h *Handler) SendMessage(c *gin.Context) {
 
 	message, messageIndex, err := h.service.SendMessage(userID, uint(sessionID), req.Content)
 	if err != nil {
-		if strings.Contains(err.Error(), "未授权") {
+		var e *errcode.Error
+		if stderrors.As(err, &e) {
+			handleSessionError(c, err)
+		} else if strings.Contains(err.Error(), "未授权") {
 			response.Error(c, http.StatusForbidden, errcode.Unauthorized)
 		} else if strings.Contains(err.Error(), "消息") {
 			response.Error(c, http.StatusBadRequest, errcode.MessageTooLong)
@@ -265,12 +300,11 @@ func (h *Handler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	vo := MessageVO{
-		ID:           message.ID,
-		SessionID:    message.SessionID,
-		MessageIndex: messageIndex,
-		Content:      message.Content,
-		CreatedAt:    message.CreatedAt,
+	vo := toMessageVO(message, messageIndex)
+	if message.SenderID != 0 {
+		// 私聊/聊天室消息已推送给其他成员
+		response.SuccessWithMessage(c, "已发送", vo)
+		return
 	}
 
 	response.SuccessWithMessage(c, "已发送，AI正在思考..", vo)
@@ -282,7 +316,7 @@ func (h *Handler) SendMessage(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "会话ID"
-// @Param offset query int false "偏移量" default(0)
+// @Param offset query int false "偏移量（聊天室不传时返回最新一页）" default(0)
 // @Param limit query int false "每页数量" default(50)
 // @Success 200 {object} response.Response{data=HistoryResponse}
 // @Router /api/chat/sessions/{id}/messages [get]
@@ -295,7 +329,10 @@ func (h *Handler) GetHistory(c *gin.Context) {
 		return
 	}
 
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		offset = -1 // 未指定：AI会话和私聊从头开始，聊天室返回最新一页
+	}
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 
 	history, err := h.service.GetHistory(userID, uint(sessionID), offset, limit)
@@ -307,8 +344,76 @@ func (h *Handler) GetHistory(c *gin.Context) {
 	response.Success(c, history)
 }
 
+// AdminListSessions 查询指定玩家的会话列表（客服排查问题用）
+// @Summary 查询玩家会话列表（管理后台）
+// @Description 需要 support:view 权限
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param offset query int false "偏移量" default(0)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} response.Response{data=[]SessionVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/users/{id}/chat/sessions [get]
+func (h *Handler) AdminListSessions(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if userID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	sessions, err := h.service.ListSessions(uint(userID), offset, limit)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	vos := make([]SessionVO, len(sessions))
+	for i, s := range sessions {
+		vos[i] = toSessionVO(s)
+	}
+
+	response.Success(c, vos)
+}
+
+// AdminGetHistory 查询指定玩家某个会话的消息历史（客服排查问题用）
+// @Summary 查询玩家会话消息（管理后台）
+// @Description 会话必须属于该玩家；需要 support:view 权限
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param session_id path int true "会话ID"
+// @Param offset query int false "偏移量" default(0)
+// @Param limit query int false "每页数量" default(50)
+// @Success 200 {object} response.Response{data=HistoryResponse}
+// @Failure 403 {object} response.Response "权限不足或会话不属于该玩家"
+// @Failure 404 {object} response.Response "会话不存在"
+// @Router /api/admin/users/{id}/chat/sessions/{session_id}/messages [get]
+func (h *Handler) AdminGetHistory(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	sessionID, _ := strconv.ParseUint(c.Param("session_id"), 10, 64)
+	if userID == 0 || sessionID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	history, err := h.service.GetHistory(uint(userID), uint(sessionID), offset, limit)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, history)
+}
+
 // RecallMessages 撤回消息
 // @Summary 撤回消息
+// @Description 私聊只能撤回自己发送的消息；聊天室管理员可以撤回任意消息。撤回后成员收到 message_recalled 推送
 // @Tags 聊天
 // @Accept json
 // @Produce json
@@ -335,7 +440,9 @@ func (h *Handler) RecallMessages(c *gin.Context) {
 	messageIDs := []uint{uint(messageID)}
 	err := h.service.RecallMessages(userID, uint(sessionID), messageIDs)
 	if err != nil {
-		if strings.Contains(err.Error(), "未授权") {
+		if strings.Contains(err.Error(), "会话不存在") {
+			response.Error(c, http.StatusNotFound, errcode.SessionNotFound)
+		} else if strings.Contains(err.Error(), "未授权") {
 			response.Error(c, http.StatusForbidden, errcode.Unauthorized)
 		} else {
 			response.Error(c, http.StatusInternalServerError, errcode.ServerError)
@@ -346,6 +453,418 @@ func (h *Handler) RecallMessages(c *gin.Context) {
 	response.SuccessWithMessage(c, "撤回成功", nil)
 }
 
+// StartDirect 发起私聊
+// @Summary 发起私聊
+// @Description 与另一名玩家私聊，双方之间已有私聊会话时直接返回该会话；被对方屏蔽时不能私聊
+// @Tags 聊天
+// @Accept json
+// @Produce json
+// @Param request body StartDirectRequest true "对方用户ID"
+// @Success 200 {object} response.Response{data=SessionVO}
+// @Failure 403 {object} response.Response "对方拒绝接收你的消息"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/chat/direct [post]
+func (h *Handler) StartDirect(c *gin.Context) {
+	var req StartDirectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	session, err := h.service.StartDirect(c.GetUint("user_id"), req.UserID)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, toSessionVO(session))
+}
+
+// MarkRead 标记私聊/聊天室已读
+// @Summary 标记已读
+// @Description 已读位置只能前移；私聊的已读位置变化时对方收到 read_receipt 推送
+// @Tags 聊天
+// @Accept json
+// @Produce json
+// @Param id path int true "会话ID"
+// @Param request body MarkReadRequest false "已读到的消息ID（为空时标记到最新消息）"
+// @Success 200 {object} response.Response{data=ReadStateVO}
+// @Router /api/chat/sessions/{id}/read [post]
+func (h *Handler) MarkRead(c *gin.Context) {
+	sessionID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if sessionID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	var req MarkReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+			return
+		}
+	}
+
+	state, err := h.service.MarkRead(c.GetUint("user_id"), uint(sessionID), req.MessageID)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, state)
+}
+
+// GetUnread 未读消息统计
+// @Summary 未读消息统计
+// @Description 所有私聊和聊天室的未读消息数
+// @Tags 聊天
+// @Produce json
+// @Success 200 {object} response.Response{data=UnreadVO}
+// @Router /api/chat/unread [get]
+func (h *Handler) GetUnread(c *gin.Context) {
+	summary, err := h.service.UnreadSummary(c.GetUint("user_id"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	response.Success(c, summary)
+}
+
+// CreateRoom 创建聊天室
+// @Summary 创建聊天室
+// @Description 创建者成为群主，返回的邀请码用于邀请其他玩家加入；启用NPC后消息中@NPC名称时NPC会回复
+// @Tags 聊天室
+// @Accept json
+// @Produce json
+// @Param request body CreateRoomRequest true "聊天室信息"
+// @Success 200 {object} response.Response{data=RoomVO}
+// @Router /api/chat/rooms [post]
+func (h *Handler) CreateRoom(c *gin.Context) {
+	var req CreateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	room, err := h.service.CreateRoom(c.GetUint("user_id"), req)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, room)
+}
+
+// ListRooms 我加入的聊天室
+// @Summary 我加入的聊天室
+// @Description 最近活跃的在前，附带成员数和未读数
+// @Tags 聊天室
+// @Produce json
+// @Success 200 {object} response.Response{data=[]RoomVO}
+// @Router /api/chat/rooms [get]
+func (h *Handler) ListRooms(c *gin.Context) {
+	rooms, err := h.service.ListRooms(c.GetUint("user_id"))
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, rooms)
+}
+
+// GetRoom 聊天室详情
+// @Summary 聊天室详情
+// @Description 包含成员列表（角色、禁言状态、在线状态）；消息历史使用 GET /api/chat/sessions/{id}/messages
+// @Tags 聊天室
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Success 200 {object} response.Response{data=RoomDetailVO}
+// @Failure 403 {object} response.Response "不是聊天室成员"
+// @Failure 404 {object} response.Response "聊天室不存在"
+// @Router /api/chat/rooms/{id} [get]
+func (h *Handler) GetRoom(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	detail, err := h.service.GetRoom(c.GetUint("user_id"), sessionID)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, detail)
+}
+
+// UpdateRoom 修改聊天室
+// @Summary 修改聊天室
+// @Description 修改名称、简介和NPC设置，需要管理员及以上
+// @Tags 聊天室
+// @Accept json
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Param request body UpdateRoomRequest true "要修改的字段"
+// @Success 200 {object} response.Response{data=RoomVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id} [put]
+func (h *Handler) UpdateRoom(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	var req UpdateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	room, err := h.service.UpdateRoom(c.GetUint("user_id"), sessionID, req)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, room)
+}
+
+// DissolveRoom 解散聊天室
+// @Summary 解散聊天室
+// @Description 仅群主；删除所有消息和成员，成员收到 room_dissolved 推送
+// @Tags 聊天室
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id} [delete]
+func (h *Handler) DissolveRoom(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DissolveRoom(c.GetUint("user_id"), sessionID); err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "聊天室已解散", nil)
+}
+
+// JoinRoom 通过邀请码加入聊天室
+// @Summary 加入聊天室
+// @Description 加入后其他成员收到 room_member_joined 推送；被踢出的用户不能加入，禁言中退出的成员重新加入后禁言继续生效
+// @Tags 聊天室
+// @Accept json
+// @Produce json
+// @Param request body JoinRoomRequest true "邀请码"
+// @Success 200 {object} response.Response{data=RoomVO}
+// @Failure 403 {object} response.Response "已被踢出该聊天室"
+// @Failure 404 {object} response.Response "邀请码无效"
+// @Failure 409 {object} response.Response "已经在聊天室中/聊天室人数已满"
+// @Router /api/chat/rooms/join [post]
+func (h *Handler) JoinRoom(c *gin.Context) {
+	var req JoinRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	room, err := h.service.JoinRoom(c.GetUint("user_id"), req.InviteCode)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, room)
+}
+
+// LeaveRoom 退出聊天室
+// @Summary 退出聊天室
+// @Description 群主需要先转让群主或解散聊天室
+// @Tags 聊天室
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "群主不能退出聊天室"
+// @Router /api/chat/rooms/{id}/leave [post]
+func (h *Handler) LeaveRoom(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.LeaveRoom(c.GetUint("user_id"), sessionID); err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "已退出聊天室", nil)
+}
+
+// RegenerateInviteCode 重新生成邀请码
+// @Summary 重新生成邀请码
+// @Description 旧邀请码立即失效，需要管理员及以上
+// @Tags 聊天室
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Success 200 {object} response.Response{data=map[string]string}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id}/invite-code [post]
+func (h *Handler) RegenerateInviteCode(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	code, err := h.service.RegenerateInviteCode(c.GetUint("user_id"), sessionID)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"invite_code": code})
+}
+
+// SetMemberRole 设置成员角色
+// @Summary 设置成员角色
+// @Description 仅群主；role 为 owner 时转让群主，原群主降为管理员
+// @Tags 聊天室
+// @Accept json
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Param user_id path int true "成员用户ID"
+// @Param request body SetMemberRoleRequest true "角色"
+// @Success 200 {object} response.Response{data=RoomMemberVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id}/members/{user_id}/role [put]
+func (h *Handler) SetMemberRole(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+	var req SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	member, err := h.service.SetMemberRole(c.GetUint("user_id"), sessionID, targetID, req.Role)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, member)
+}
+
+// MuteMember 禁言成员
+// @Summary 禁言成员
+// @Description 管理员及以上，只能禁言角色低于自己的成员
+// @Tags 聊天室
+// @Accept json
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Param user_id path int true "成员用户ID"
+// @Param request body MuteMemberRequest true "禁言时长"
+// @Success 200 {object} response.Response{data=RoomMemberVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id}/members/{user_id}/mute [post]
+func (h *Handler) MuteMember(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+	var req MuteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	member, err := h.service.MuteMember(c.GetUint("user_id"), sessionID, targetID, req.Minutes)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, member)
+}
+
+// UnmuteMember 解除禁言
+// @Summary 解除禁言
+// @Tags 聊天室
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Param user_id path int true "成员用户ID"
+// @Success 200 {object} response.Response{data=RoomMemberVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id}/members/{user_id}/mute [delete]
+func (h *Handler) UnmuteMember(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	member, err := h.service.MuteMember(c.GetUint("user_id"), sessionID, targetID, 0)
+	if err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.Success(c, member)
+}
+
+// KickMember 踢出成员
+// @Summary 踢出成员
+// @Description 管理员及以上，只能踢出角色低于自己的成员；被踢出的成员收到 room_member_left 推送（reason=kick），之后不能再通过邀请码加入
+// @Tags 聊天室
+// @Produce json
+// @Param id path int true "聊天室会话ID"
+// @Param user_id path int true "成员用户ID"
+// @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/chat/rooms/{id}/members/{user_id} [delete]
+func (h *Handler) KickMember(c *gin.Context) {
+	sessionID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	targetID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.KickMember(c.GetUint("user_id"), sessionID, targetID); err != nil {
+		handleSessionError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "已踢出", nil)
+}
+
+// uintParam 解析路径中的ID参数（无效时直接返回400）
+func uintParam(c *gin.Context, name string) (uint, bool) {
+	id, _ := strconv.ParseUint(c.Param(name), 10, 64)
+	if id == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return 0, false
+	}
+	return uint(id), true
+}
+
 // mustMarshal 将对象序列化为JSON（如果失败则panic）
 func mustMarshal(v interface{}) []byte {
 	data, err := json.Marshal(v)
@@ -4,9 +4,20 @@
 package chat
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JoinRoom 拒绝加入的原因（由Service转换为错误码）
+var (
+	errRoomNotFound   = errors.New("聊天室不存在")
+	errKickedFromRoom = errors.New("已被踢出聊天室")
+	errAlreadyInRoom  = errors.New("已经在聊天室中")
+	errRoomFull       = errors.New("聊天室人数已满")
 )
 
 // Repository 聊天仓储接口
@@ -23,6 +34,57 @@ type Repository interface {
 	FindMessagesBySessionID(sessionID uint, offset, limit int) ([]*Message, error)
 	CountMessagesBySessionID(sessionID uint) (int64, error)
 	DeleteMessages(sessionID uint, messageIDs []uint) error
+
+	// 私聊相关
+	// FindSessionByDirectKey 根据私聊双方标识查找会话（不存在时返回nil, nil）
+	FindSessionByDirectKey(key string) (*Session, error)
+	// CreateSessionWithParticipants 在一个事务中创建会话及其参与者
+	CreateSessionWithParticipants(session *Session, userIDs []uint) error
+	// FindParticipant 查找会话参与者（不存在时返回nil, nil）
+	FindParticipant(sessionID, userID uint) (*Participant, error)
+	// ListParticipants 批量获取会话的参与者
+	ListParticipants(sessionIDs []uint) ([]*Participant, error)
+	// UpdateLastRead 前移已读位置（只能前移），返回是否有变化
+	UpdateLastRead(sessionID, userID, messageID uint) (bool, error)
+	// CountUnread 统计用户在各会话中的未读消息数（对方发送且ID大于已读位置），sessionIDs为空时统计全部会话
+	CountUnread(userID uint, sessionIDs []uint) (map[uint]int64, error)
+	// LatestMessageID 会话最新一条消息的ID（没有消息时为0）
+	LatestMessageID(sessionID uint) (uint, error)
+	// TouchSession 更新会话的最后活跃时间
+	TouchSession(sessionID uint, at time.Time) error
+	// DeleteMessagesBySender 删除指定发送者的消息，返回实际删除的消息ID
+	DeleteMessagesBySender(sessionID, senderID uint, messageIDs []uint) ([]uint, error)
+
+	// 聊天室相关
+	// CreateRoom 在一个事务中创建聊天室会话、附加信息和群主成员记录
+	CreateRoom(session *Session, room *Room) error
+	// FindRoom 查找聊天室附加信息（不存在时返回nil, nil）
+	FindRoom(sessionID uint) (*Room, error)
+	// FindRoomByInviteCode 根据邀请码查找聊天室（不存在时返回nil, nil）
+	FindRoomByInviteCode(code string) (*Room, error)
+	// FindRooms 批量获取聊天室附加信息
+	FindRooms(sessionIDs []uint) ([]*Room, error)
+	// UpdateRoom 更新聊天室附加信息
+	UpdateRoom(room *Room) error
+	// ListRoomSessions 用户加入的聊天室会话（最近活跃的在前）
+	ListRoomSessions(userID uint) ([]*Session, error)
+	// CountParticipants 批量统计会话的成员数
+	CountParticipants(sessionIDs []uint) (map[uint]int64, error)
+	// JoinRoom 在一个事务中加入聊天室（锁定聊天室行后检查踢出记录、是否已是成员和成员上限），返回加入后的成员数
+	JoinRoom(p *Participant, maxMembers int64) (int64, error)
+	// UpdateParticipant 更新成员的角色和禁言状态
+	UpdateParticipant(p *Participant) error
+	// RemoveParticipant 移除会话成员（kicked表示被踢出），返回该成员是否存在
+	RemoveParticipant(sessionID, userID uint, kicked bool) (bool, error)
+	// TransferOwnership 在一个事务中转让群主（原群主降为管理员）
+	TransferOwnership(sessionID, fromUserID, toUserID uint) error
+	// DeleteRoom 在一个事务中删除聊天室及其消息和成员
+	DeleteRoom(sessionID uint) error
+	// DeleteMessagesByIDs 删除会话中的指定消息（不限发送者），返回实际删除的消息ID
+	DeleteMessagesByIDs(sessionID uint, messageIDs []uint) ([]uint, error)
+
+	// PurgeUsers 在tx中删除用户的聊天数据，返回转让了群主的聊天室（聊天室ID -> 新群主）
+	PurgeUsers(tx *gorm.DB, userIDs []uint) (map[uint]uint, error)
 }
 
 // repositoryImpl Repository的GORM实现
@@ -53,10 +115,11 @@ func (r *repositoryImpl) FindSessionByID(id uint) (*Session, error) {
 	return &session, nil
 }
 
-// ListSessionsByUserID 根据用户ID获取会话列表
+// ListSessionsByUserID 根据用户ID获取会话列表（包括自己创建的会话和参与的私聊）
 func (r *repositoryImpl) ListSessionsByUserID(userID uint, offset, limit int) ([]*Session, error) {
 	var sessions []*Session
-	err := r.db.Where("user_id = ?", userID).
+	err := r.db.Where("user_id = ? OR id IN (?)", userID,
+		r.db.Model(&Participant{}).Select("session_id").Where("user_id = ?", userID)).
 		Order("updated_at DESC").
 		Offset(offset).
 		Limit(limit).
@@ -83,7 +146,7 @@ func (r *repositoryImpl) CreateMessage(message *Message) error {
 func (r *repositoryImpl) FindMessagesBySessionID(sessionID uint, offset, limit int) ([]*Message, error) {
 	var messages []*Message
 	err := r.db.Where("session_id = ?", sessionID).
-		Order("created_at ASC").
+		Order("created_at ASC, id ASC").
 		Offset(offset).
 		Limit(limit).
 		Find(&messages).Error
@@ -101,3 +164,419 @@ func (r *repositoryImpl) CountMessagesBySessionID(sessionID uint) (int64, error)
 func (r *repositoryImpl) DeleteMessages(sessionID uint, messageIDs []uint) error {
 	return r.db.Where("session_id = ? AND id IN ?", sessionID, messageIDs).Delete(&Message{}).Error
 }
+
+// FindSessionByDirectKey 根据私聊双方标识查找会话（不存在时返回nil, nil）
+func (r *repositoryImpl) FindSessionByDirectKey(key string) (*Session, error) {
+	var session Session
+	err := r.db.Where("direct_key = ?", key).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CreateSessionWithParticipants 在一个事务中创建会话及其参与者
+func (r *repositoryImpl) CreateSessionWithParticipants(session *Session, userIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		participants := make([]Participant, len(userIDs))
+		for i, id := range userIDs {
+			participants[i] = Participant{SessionID: session.ID, UserID: id}
+		}
+		return tx.Create(&participants).Error
+	})
+}
+
+// FindParticipant 查找会话参与者（不存在时返回nil, nil）
+func (r *repositoryImpl) FindParticipant(sessionID, userID uint) (*Participant, error) {
+	var p Participant
+	err := r.db.Where("session_id = ? AND user_id = ?", sessionID, userID).First(&p).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListParticipants 批量获取会话的参与者
+func (r *repositoryImpl) ListParticipants(sessionIDs []uint) ([]*Participant, error) {
+	var participants []*Participant
+	if len(sessionIDs) == 0 {
+		return participants, nil
+	}
+	err := r.db.Where("session_id IN ?", sessionIDs).Order("joined_at ASC").Find(&participants).Error
+	return participants, err
+}
+
+// UpdateLastRead 前移已读位置（只能前移），返回是否有变化
+func (r *repositoryImpl) UpdateLastRead(sessionID, userID, messageID uint) (bool, error) {
+	result := r.db.Model(&Participant{}).
+		Where("session_id = ? AND user_id = ? AND last_read_message_id < ?", sessionID, userID, messageID).
+		Update("last_read_message_id", messageID)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountUnread 统计用户在各会话中的未读消息数
+func (r *repositoryImpl) CountUnread(userID uint, sessionIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		SessionID uint
+		Count     int64
+	}
+	query := r.db.Table("chat_messages AS m").
+		Select("m.session_id AS session_id, COUNT(*) AS count").
+		Joins("JOIN chat_participants AS p ON p.session_id = m.session_id AND p.user_id = ?", userID).
+		Where("m.id > p.last_read_message_id AND m.sender_id <> ?", userID)
+	if len(sessionIDs) > 0 {
+		query = query.Where("m.session_id IN ?", sessionIDs)
+	}
+	if err := query.Group("m.session_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.SessionID] = row.Count
+	}
+	return counts, nil
+}
+
+// LatestMessageID 会话最新一条消息的ID（没有消息时为0）
+func (r *repositoryImpl) LatestMessageID(sessionID uint) (uint, error) {
+	var id uint
+	err := r.db.Model(&Message{}).Where("session_id = ?", sessionID).
+		Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// TouchSession 更新会话的最后活跃时间
+func (r *repositoryImpl) TouchSession(sessionID uint, at time.Time) error {
+	return r.db.Model(&Session{}).Where("id = ?", sessionID).UpdateColumn("updated_at", at).Error
+}
+
+// DeleteMessagesBySender 删除指定发送者的消息，返回实际删除的消息ID
+func (r *repositoryImpl) DeleteMessagesBySender(sessionID, senderID uint, messageIDs []uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Message{}).
+			Where("session_id = ? AND sender_id = ? AND id IN ?", sessionID, senderID, messageIDs).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", ids).Delete(&Message{}).Error
+	})
+	return ids, err
+}
+
+// CreateRoom 在一个事务中创建聊天室会话、附加信息和群主成员记录
+func (r *repositoryImpl) CreateRoom(session *Session, room *Room) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		room.SessionID = session.ID
+		if err := tx.Create(room).Error; err != nil {
+			return err
+		}
+		return tx.Create(&Participant{SessionID: session.ID, UserID: session.UserID, Role: RoleOwner}).Error
+	})
+}
+
+// FindRoom 查找聊天室附加信息（不存在时返回nil, nil）
+func (r *repositoryImpl) FindRoom(sessionID uint) (*Room, error) {
+	var room Room
+	err := r.db.Where("session_id = ?", sessionID).First(&room).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// FindRoomByInviteCode 根据邀请码查找聊天室（不存在时返回nil, nil）
+func (r *repositoryImpl) FindRoomByInviteCode(code string) (*Room, error) {
+	var room Room
+	err := r.db.Where("invite_code = ?", code).First(&room).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// FindRooms 批量获取聊天室附加信息
+func (r *repositoryImpl) FindRooms(sessionIDs []uint) ([]*Room, error) {
+	var rooms []*Room
+	if len(sessionIDs) == 0 {
+		return rooms, nil
+	}
+	err := r.db.Where("session_id IN ?", sessionIDs).Find(&rooms).Error
+	return rooms, err
+}
+
+// UpdateRoom 更新聊天室附加信息
+func (r *repositoryImpl) UpdateRoom(room *Room) error {
+	return r.db.Save(room).Error
+}
+
+// ListRoomSessions 用户加入的聊天室会话（最近活跃的在前）
+func (r *repositoryImpl) ListRoomSessions(userID uint) ([]*Session, error) {
+	var sessions []*Session
+	err := r.db.Where("type = ? AND id IN (?)", SessionTypeRoom,
+		r.db.Model(&Participant{}).Select("session_id").Where("user_id = ?", userID)).
+		Order("updated_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// CountParticipants 批量统计会话的成员数
+func (r *repositoryImpl) CountParticipants(sessionIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		SessionID uint
+		Count     int64
+	}
+	if len(sessionIDs) > 0 {
+		if err := r.db.Model(&Participant{}).
+			Select("session_id, COUNT(*) AS count").
+			Where("session_id IN ?", sessionIDs).
+			Group("session_id").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.SessionID] = row.Count
+	}
+	return counts, nil
+}
+
+// JoinRoom 在一个事务中加入聊天室，返回加入后的成员数
+// 1. 锁定 chat_rooms 中的聊天室行，同一聊天室的并发加入依次执行，成员数不会超过上限
+// 2. 被踢出的用户不能加入；退出前尚未到期的禁言恢复到新的成员记录上
+func (r *repositoryImpl) JoinRoom(p *Participant, maxMembers int64) (int64, error) {
+	var count int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var rooms []Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ?", p.SessionID).Find(&rooms).Error; err != nil {
+			return err
+		}
+		if len(rooms) == 0 {
+			return errRoomNotFound // 查到邀请码后聊天室已被解散
+		}
+
+		var restrictions []RoomRestriction
+		if err := tx.Where("session_id = ? AND user_id = ?", p.SessionID, p.UserID).
+			Find(&restrictions).Error; err != nil {
+			return err
+		}
+		if len(restrictions) > 0 && restrictions[0].Kicked {
+			return errKickedFromRoom
+		}
+
+		var existing int64
+		if err := tx.Model(&Participant{}).
+			Where("session_id = ? AND user_id = ?", p.SessionID, p.UserID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errAlreadyInRoom
+		}
+		if err := tx.Model(&Participant{}).Where("session_id = ?", p.SessionID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxMembers {
+			return errRoomFull
+		}
+
+		if len(restrictions) > 0 {
+			if until := restrictions[0].MutedUntil; until != nil && until.After(time.Now()) {
+				p.MutedUntil = until
+			}
+			if err := tx.Where("session_id = ? AND user_id = ?", p.SessionID, p.UserID).
+				Delete(&RoomRestriction{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// UpdateParticipant 更新成员的角色和禁言状态
+func (r *repositoryImpl) UpdateParticipant(p *Participant) error {
+	return r.db.Model(&Participant{}).
+		Where("session_id = ? AND user_id = ?", p.SessionID, p.UserID).
+		Updates(map[string]interface{}{"role": p.Role, "muted_until": p.MutedUntil}).Error
+}
+
+// RemoveParticipant 在一个事务中移除会话成员，返回该成员是否存在
+// 被踢出或退出时仍在禁言中的成员记录到 chat_room_restrictions，重新加入时检查
+func (r *repositoryImpl) RemoveParticipant(sessionID, userID uint, kicked bool) (bool, error) {
+	removed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var participants []Participant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ? AND user_id = ?", sessionID, userID).
+			Find(&participants).Error; err != nil {
+			return err
+		}
+		if len(participants) == 0 {
+			return nil
+		}
+		if err := tx.Where("session_id = ? AND user_id = ?", sessionID, userID).Delete(&Participant{}).Error; err != nil {
+			return err
+		}
+		removed = true
+
+		restriction := RoomRestriction{SessionID: sessionID, UserID: userID, Kicked: kicked}
+		if participants[0].IsMuted(time.Now()) {
+			restriction.MutedUntil = participants[0].MutedUntil
+		}
+		if !restriction.Kicked && restriction.MutedUntil == nil {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&restriction).Error
+	})
+	return removed, err
+}
+
+// TransferOwnership 在一个事务中转让群主（原群主降为管理员）
+func (r *repositoryImpl) TransferOwnership(sessionID, fromUserID, toUserID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Participant{}).Where("session_id = ? AND user_id = ?", sessionID, fromUserID).
+			Update("role", RoleModerator).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Participant{}).Where("session_id = ? AND user_id = ?", sessionID, toUserID).
+			Updates(map[string]interface{}{"role": RoleOwner, "muted_until": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).Where("id = ?", sessionID).UpdateColumn("user_id", toUserID).Error
+	})
+}
+
+// DeleteRoom 在一个事务中删除聊天室及其消息和成员
+func (r *repositoryImpl) DeleteRoom(sessionID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", sessionID).Delete(&Message{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", sessionID).Delete(&Participant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", sessionID).Delete(&RoomRestriction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", sessionID).Delete(&Room{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Session{}, sessionID).Error
+	})
+}
+
+// DeleteMessagesByIDs 删除会话中的指定消息（不限发送者），返回实际删除的消息ID
+func (r *repositoryImpl) DeleteMessagesByIDs(sessionID uint, messageIDs []uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Message{}).
+			Where("session_id = ? AND id IN ?", sessionID, messageIDs).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", ids).Delete(&Message{}).Error
+	})
+	return ids, err
+}
+
+// PurgeUsers 在tx中删除用户的聊天数据，返回转让了群主的聊天室（聊天室ID -> 新群主）
+// 1. AI会话和私聊整体删除（私聊的另一方也不再能看到该会话）
+// 2. 用户是群主的聊天室转让给剩余成员中角色最高、加入最早的一位，没有剩余成员时删除聊天室
+// 3. 删除用户在其他聊天室中的成员记录、限制记录和发送的消息
+func (r *repositoryImpl) PurgeUsers(tx *gorm.DB, userIDs []uint) (map[uint]uint, error) {
+	var sessionIDs []uint
+	if err := tx.Model(&Session{}).
+		Where("(user_id IN ? AND type <> ?) OR (type = ? AND id IN (?))", userIDs, SessionTypeRoom, SessionTypeDirect,
+			tx.Model(&Participant{}).Select("session_id").Where("user_id IN ?", userIDs)).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var rooms []*Session
+	if err := tx.Where("user_id IN ? AND type = ?", userIDs, SessionTypeRoom).Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	transferred := make(map[uint]uint)
+	for _, room := range rooms {
+		var heir Participant
+		err := tx.Where("session_id = ? AND user_id NOT IN ?", room.ID, userIDs).
+			Order(fmt.Sprintf("CASE role WHEN '%s' THEN 0 ELSE 1 END, joined_at ASC, user_id ASC", RoleModerator)).
+			First(&heir).Error
+		if err == gorm.ErrRecordNotFound {
+			sessionIDs = append(sessionIDs, room.ID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&Participant{}).Where("session_id = ? AND user_id = ?", room.ID, heir.UserID).
+			Updates(map[string]interface{}{"role": RoleOwner, "muted_until": nil}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&Session{}).Where("id = ?", room.ID).UpdateColumn("user_id", heir.UserID).Error; err != nil {
+			return nil, err
+		}
+		transferred[room.ID] = heir.UserID
+	}
+
+	if len(sessionIDs) > 0 {
+		if err := tx.Where("session_id IN ?", sessionIDs).Delete(&Message{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("session_id IN ?", sessionIDs).Delete(&Participant{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("session_id IN ?", sessionIDs).Delete(&RoomRestriction{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("session_id IN ?", sessionIDs).Delete(&Room{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id IN ?", sessionIDs).Delete(&Session{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Where("user_id IN ?", userIDs).Delete(&Participant{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id IN ?", userIDs).Delete(&RoomRestriction{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("sender_id IN ?", userIDs).Delete(&Message{}).Error; err != nil {
+		return nil, err
+	}
+	return transferred, nil
+}
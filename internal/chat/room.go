@@ -0,0 +1,730 @@
+// Package chat - 聊天室/公会频道
+// 功能：多人聊天室，成员角色（群主/管理员/成员）、邀请码、禁言和踢人，消息通过WebSocket推送给所有成员，可选AI NPC
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/user"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 聊天室
+// 存储：会话（type=4，标题即聊天室名称，user_id为当前群主）+ chat_rooms 附加信息 + chat_participants 成员
+// 权限：群主 > 管理员 > 成员；管理员可以禁言、踢出角色低于自己的成员并撤回任意消息，
+//       只有群主可以设置角色、转让群主和解散聊天室
+// 限制：被踢出的用户不能再通过邀请码加入；禁言中的成员退出后重新加入，禁言继续生效（chat_room_restrictions）
+// 历史：复用会话消息接口，不传offset时返回最新一页，响应中的total/offset用于向前翻页
+// NPC：启用后消息中包含“@NPC名称”时，NPC结合最近的聊天记录异步回复（sender_id为0），
+//      同一聊天室同时只处理一个NPC回复，期间的@会被忽略
+// 推送事件（RoomEvent）：
+//   room_message         新消息（含NPC回复）
+//   room_member_joined   新成员加入
+//   room_member_left     成员退出或被踢出（被踢出的成员也会收到）
+//   room_member_updated  成员角色或禁言状态变化
+//   room_dissolved       聊天室被解散
+//   message_recalled     消息被撤回
+// ============================================================
+
+const (
+	maxRoomMembers       = 200         // 聊天室成员上限
+	maxRoomMessageLength = 1000        // 聊天室消息最大长度（字符数）
+	defaultNPCName       = "小助手"       // 未指定NPC名称时的默认名称
+	npcContextMessages   = 20          // NPC回复时参考的最近消息数
+	npcReplyTimeout      = time.Minute // NPC调用AI的超时时间
+	inviteCodeLength     = 8
+	inviteCodeAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // 去掉了易混淆的 I/O/0/1
+)
+
+// CreateRoom 创建聊天室（创建者成为群主）
+func (s *Service) CreateRoom(userID uint, req CreateRoomRequest) (*RoomVO, error) {
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		UserID: userID,
+		Title:  req.Name,
+		Type:   SessionTypeRoom,
+	}
+	room := &Room{
+		Description: req.Description,
+		InviteCode:  code,
+		NPCEnabled:  req.NPCEnabled,
+		NPCName:     req.NPCName,
+	}
+	if room.NPCEnabled && room.NPCName == "" {
+		room.NPCName = defaultNPCName
+	}
+	if err := s.repo.CreateRoom(session, room); err != nil {
+		logger.Error("[chat.CreateRoom] 创建聊天室失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("[chat.CreateRoom] 聊天室已创建",
+		zap.Uint("session_id", session.ID),
+		zap.Uint("user_id", userID),
+		zap.String("name", req.Name))
+	vo := toRoomVO(session, room, &Participant{Role: RoleOwner}, 1)
+	return &vo, nil
+}
+
+// ListRooms 用户加入的聊天室（附带成员数和未读数）
+func (s *Service) ListRooms(userID uint) ([]RoomVO, error) {
+	sessions, err := s.repo.ListRoomSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return []RoomVO{}, nil
+	}
+
+	ids := make([]uint, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	rooms, err := s.repo.FindRooms(ids)
+	if err != nil {
+		return nil, err
+	}
+	roomMap := make(map[uint]*Room, len(rooms))
+	for _, room := range rooms {
+		roomMap[room.SessionID] = room
+	}
+	participants, err := s.repo.ListParticipants(ids)
+	if err != nil {
+		return nil, err
+	}
+	me := make(map[uint]*Participant, len(ids))
+	for _, p := range participants {
+		if p.UserID == userID {
+			me[p.SessionID] = p
+		}
+	}
+	counts, err := s.repo.CountParticipants(ids)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.repo.CountUnread(userID, ids)
+	if err != nil {
+		logger.Warn("[chat.ListRooms] 未读数查询失败", zap.Uint("user_id", userID), zap.Error(err))
+	}
+
+	vos := make([]RoomVO, 0, len(sessions))
+	for _, session := range sessions {
+		room, ok := roomMap[session.ID]
+		if !ok || me[session.ID] == nil {
+			continue
+		}
+		vo := toRoomVO(session, room, me[session.ID], counts[session.ID])
+		vo.UnreadCount = unread[session.ID]
+		vos = append(vos, vo)
+	}
+	return vos, nil
+}
+
+// GetRoom 聊天室详情（含成员列表，群主和管理员在前）
+func (s *Service) GetRoom(userID, sessionID uint) (*RoomDetailVO, error) {
+	session, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	room, err := s.findRoom(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.roomMembers(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RoomDetailVO{
+		Room:    toRoomVO(session, room, me, int64(len(members))),
+		Members: members,
+	}, nil
+}
+
+// RoomMemberIDs 聊天室成员的用户ID（供聊天室排行榜使用；聊天室不存在时返回SessionNotFound，不是成员时返回PermissionDenied）
+func (s *Service) RoomMemberIDs(userID, sessionID uint) ([]uint, error) {
+	if _, _, err := s.roomMember(userID, sessionID); err != nil {
+		if strings.Contains(err.Error(), "会话不存在") {
+			return nil, errcode.New(errcode.SessionNotFound)
+		}
+		if strings.Contains(err.Error(), "未授权") {
+			return nil, errcode.New(errcode.PermissionDenied)
+		}
+		return nil, err
+	}
+	participants, err := s.repo.ListParticipants([]uint{sessionID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(participants))
+	for i, p := range participants {
+		ids[i] = p.UserID
+	}
+	return ids, nil
+}
+
+// UpdateRoom 修改聊天室名称、简介和NPC设置（管理员及以上）
+func (s *Service) UpdateRoom(userID, sessionID uint, req UpdateRoomRequest) (*RoomVO, error) {
+	session, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if roleRank(me.Role) < roleRank(RoleModerator) {
+		return nil, errcode.New(errcode.PermissionDenied)
+	}
+	room, err := s.findRoom(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" && req.Name != session.Title {
+		session.Title = req.Name
+		if err := s.repo.UpdateSession(session); err != nil {
+			return nil, err
+		}
+	}
+	if req.Description != nil {
+		room.Description = *req.Description
+	}
+	if req.NPCEnabled != nil {
+		room.NPCEnabled = *req.NPCEnabled
+	}
+	if req.NPCName != "" {
+		room.NPCName = req.NPCName
+	}
+	if room.NPCEnabled && room.NPCName == "" {
+		room.NPCName = defaultNPCName
+	}
+	if err := s.repo.UpdateRoom(room); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repo.CountParticipants([]uint{sessionID})
+	if err != nil {
+		return nil, err
+	}
+	vo := toRoomVO(session, room, me, counts[sessionID])
+	return &vo, nil
+}
+
+// RegenerateInviteCode 重新生成邀请码，旧邀请码立即失效（管理员及以上）
+func (s *Service) RegenerateInviteCode(userID, sessionID uint) (string, error) {
+	_, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return "", err
+	}
+	if roleRank(me.Role) < roleRank(RoleModerator) {
+		return "", errcode.New(errcode.PermissionDenied)
+	}
+	room, err := s.findRoom(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return "", err
+	}
+	room.InviteCode = code
+	if err := s.repo.UpdateRoom(room); err != nil {
+		return "", err
+	}
+
+	logger.Info("[chat.RegenerateInviteCode] 邀请码已重新生成", zap.Uint("session_id", sessionID), zap.Uint("user_id", userID))
+	return code, nil
+}
+
+// JoinRoom 通过邀请码加入聊天室（被踢出的用户不能加入，退出前未到期的禁言继续生效）
+func (s *Service) JoinRoom(userID uint, inviteCode string) (*RoomVO, error) {
+	room, err := s.repo.FindRoomByInviteCode(strings.ToUpper(strings.TrimSpace(inviteCode)))
+	if err != nil {
+		return nil, err
+	}
+	if room == nil {
+		return nil, errcode.New(errcode.InviteCodeInvalid)
+	}
+	session, err := s.repo.FindSessionByID(room.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Participant{SessionID: room.SessionID, UserID: userID, Role: RoleMember}
+	count, err := s.repo.JoinRoom(p, maxRoomMembers)
+	switch {
+	case stderrors.Is(err, errRoomNotFound):
+		return nil, errcode.New(errcode.InviteCodeInvalid)
+	case stderrors.Is(err, errKickedFromRoom):
+		return nil, errcode.New(errcode.KickedFromRoom)
+	case stderrors.Is(err, errAlreadyInRoom):
+		return nil, errcode.New(errcode.AlreadyInRoom)
+	case stderrors.Is(err, errRoomFull):
+		return nil, errcode.New(errcode.RoomFull)
+	case err != nil:
+		return nil, err
+	}
+
+	member := s.toRoomMemberVO(p, s.briefs([]uint{userID})[userID])
+	s.pushToParticipants(room.SessionID, 0, RoomEvent{
+		Type:      "room_member_joined",
+		SessionID: room.SessionID,
+		Member:    &member,
+	})
+
+	logger.Info("[chat.JoinRoom] 加入聊天室", zap.Uint("session_id", room.SessionID), zap.Uint("user_id", userID))
+	vo := toRoomVO(session, room, p, count)
+	return &vo, nil
+}
+
+// LeaveRoom 退出聊天室（群主需要先转让群主或解散聊天室）
+func (s *Service) LeaveRoom(userID, sessionID uint) error {
+	_, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if me.Role == RoleOwner {
+		return errcode.New(errcode.OwnerCannotLeave)
+	}
+	return s.removeMember(sessionID, userID, "leave")
+}
+
+// KickMember 踢出成员（管理员及以上，且角色高于对方）
+func (s *Service) KickMember(userID, sessionID, targetID uint) error {
+	_, target, err := s.moderate(userID, sessionID, targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.removeMember(sessionID, target.UserID, "kick"); err != nil {
+		return err
+	}
+
+	logger.Warn("[audit] 聊天室成员被踢出",
+		zap.Uint("session_id", sessionID),
+		zap.Uint("operator_id", userID),
+		zap.Uint("user_id", targetID))
+	return nil
+}
+
+// MuteMember 禁言成员（minutes为0时解除禁言）
+func (s *Service) MuteMember(userID, sessionID, targetID uint, minutes int) (*RoomMemberVO, error) {
+	_, target, err := s.moderate(userID, sessionID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if minutes > 0 {
+		until := time.Now().Add(time.Duration(minutes) * time.Minute)
+		target.MutedUntil = &until
+	} else {
+		target.MutedUntil = nil
+	}
+	if err := s.repo.UpdateParticipant(target); err != nil {
+		return nil, err
+	}
+
+	logger.Warn("[audit] 聊天室禁言状态变更",
+		zap.Uint("session_id", sessionID),
+		zap.Uint("operator_id", userID),
+		zap.Uint("user_id", targetID),
+		zap.Int("minutes", minutes))
+	return s.pushMemberUpdated(target), nil
+}
+
+// SetMemberRole 设置成员角色（仅群主；设置为owner时转让群主，自己降为管理员）
+func (s *Service) SetMemberRole(userID, sessionID, targetID uint, role string) (*RoomMemberVO, error) {
+	_, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if me.Role != RoleOwner || targetID == userID {
+		return nil, errcode.New(errcode.PermissionDenied)
+	}
+	target, err := s.repo.FindParticipant(sessionID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, errcode.New(errcode.UserNotFound)
+	}
+
+	if role == RoleOwner {
+		if err := s.repo.TransferOwnership(sessionID, userID, targetID); err != nil {
+			return nil, err
+		}
+		me.Role = RoleModerator
+		target.Role = RoleOwner
+		target.MutedUntil = nil
+		s.pushMemberUpdated(me)
+	} else {
+		target.Role = role
+		if err := s.repo.UpdateParticipant(target); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Warn("[audit] 聊天室成员角色变更",
+		zap.Uint("session_id", sessionID),
+		zap.Uint("operator_id", userID),
+		zap.Uint("user_id", targetID),
+		zap.String("role", role))
+	return s.pushMemberUpdated(target), nil
+}
+
+// DissolveRoom 解散聊天室（仅群主，删除所有消息和成员）
+func (s *Service) DissolveRoom(userID, sessionID uint) error {
+	_, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if me.Role != RoleOwner {
+		return errcode.New(errcode.PermissionDenied)
+	}
+
+	// 先取成员再删除，用于通知
+	participants, err := s.repo.ListParticipants([]uint{sessionID})
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteRoom(sessionID); err != nil {
+		logger.Error("[chat.DissolveRoom] 解散失败", zap.Uint("session_id", sessionID), zap.Error(err))
+		return err
+	}
+
+	event := RoomEvent{Type: "room_dissolved", SessionID: sessionID}
+	for _, p := range participants {
+		s.push(p.UserID, event)
+	}
+	logger.Info("[chat.DissolveRoom] 聊天室已解散", zap.Uint("session_id", sessionID), zap.Uint("user_id", userID))
+	return nil
+}
+
+// sendRoom 发送聊天室消息：保存后推送给所有成员，@NPC时触发NPC回复
+func (s *Service) sendRoom(userID uint, session *Session, content string) (*Message, int, error) {
+	p, err := s.repo.FindParticipant(session.ID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p == nil {
+		return nil, 0, fmt.Errorf("未授权")
+	}
+	if p.IsMuted(time.Now()) {
+		return nil, 0, errcode.NewWithData(errcode.RoomMuted, map[string]interface{}{"muted_until": p.MutedUntil})
+	}
+	if utf8.RuneCountInString(content) > maxRoomMessageLength {
+		return nil, 0, errcode.New(errcode.MessageTooLong)
+	}
+
+	msg := &Message{
+		SessionID: session.ID,
+		SenderID:  userID,
+		Content:   content,
+	}
+	count, err := s.saveRoomMessage(msg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := s.repo.UpdateLastRead(session.ID, userID, msg.ID); err != nil {
+		logger.Warn("[chat.sendRoom] 更新已读位置失败", zap.Uint("session_id", session.ID), zap.Error(err))
+	}
+
+	room, err := s.findRoom(session.ID)
+	if err != nil {
+		logger.Warn("[chat.sendRoom] 聊天室信息查询失败", zap.Uint("session_id", session.ID), zap.Error(err))
+		return msg, count, nil
+	}
+	if room.NPCEnabled && strings.Contains(content, "@"+room.NPCName) {
+		go s.replyAsNPC(session.ID, room.NPCName)
+	}
+	return msg, count, nil
+}
+
+// saveRoomMessage 保存聊天室消息并推送给所有成员，返回消息序号
+func (s *Service) saveRoomMessage(msg *Message) (int, error) {
+	if err := s.repo.CreateMessage(msg); err != nil {
+		return 0, err
+	}
+	if err := s.repo.TouchSession(msg.SessionID, msg.CreatedAt); err != nil {
+		logger.Warn("[chat.saveRoomMessage] 更新会话活跃时间失败", zap.Uint("session_id", msg.SessionID), zap.Error(err))
+	}
+	count, err := s.repo.CountMessagesBySessionID(msg.SessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	vo := toMessageVO(msg, int(count))
+	s.pushToParticipants(msg.SessionID, 0, RoomEvent{
+		Type:      "room_message",
+		SessionID: msg.SessionID,
+		Message:   &vo,
+	})
+	return int(count), nil
+}
+
+// replyAsNPC NPC结合最近的聊天记录回复（异步调用，同一聊天室同时只处理一个）
+func (s *Service) replyAsNPC(sessionID uint, npcName string) {
+	if _, busy := s.npcBusy.LoadOrStore(sessionID, struct{}{}); busy {
+		logger.Debug("[chat.replyAsNPC] NPC正在回复，忽略本次@", zap.Uint("session_id", sessionID))
+		return
+	}
+	defer s.npcBusy.Delete(sessionID)
+
+	total, err := s.repo.CountMessagesBySessionID(sessionID)
+	if err != nil {
+		logger.Error("[chat.replyAsNPC] 统计消息失败", zap.Uint("session_id", sessionID), zap.Error(err))
+		return
+	}
+	messages, err := s.repo.FindMessagesBySessionID(sessionID, max(0, int(total)-npcContextMessages), npcContextMessages)
+	if err != nil {
+		logger.Error("[chat.replyAsNPC] 获取历史消息失败", zap.Uint("session_id", sessionID), zap.Error(err))
+		return
+	}
+
+	// 成员发言带上昵称，NPC自己的消息作为assistant
+	senderIDs := make([]uint, 0, len(messages))
+	for _, msg := range messages {
+		if msg.SenderID != 0 {
+			senderIDs = append(senderIDs, msg.SenderID)
+		}
+	}
+	briefs := s.briefs(senderIDs)
+	aiMessages := make([]map[string]string, 0, len(messages)+1)
+	aiMessages = append(aiMessages, map[string]string{
+		"role":    "system",
+		"content": fmt.Sprintf("你是游戏聊天室中的NPC「%s」。成员的发言格式为“昵称: 内容”。有人@你时，用简短友好的语气回复，不要替其他成员发言。", npcName),
+	})
+	for _, msg := range messages {
+		if msg.SenderID == 0 {
+			aiMessages = append(aiMessages, map[string]string{"role": "assistant", "content": msg.Content})
+			continue
+		}
+		name := briefs[msg.SenderID].DisplayName
+		if name == "" {
+			name = fmt.Sprintf("玩家%d", msg.SenderID)
+		}
+		aiMessages = append(aiMessages, map[string]string{"role": "user", "content": name + ": " + msg.Content})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), npcReplyTimeout)
+	defer cancel()
+	reply, err := s.aiClient.Chat(ctx, aiMessages)
+	if err != nil || reply == "" {
+		logger.Warn("[chat.replyAsNPC] AI调用失败", zap.Uint("session_id", sessionID), zap.Error(err))
+		return
+	}
+
+	if _, err := s.saveRoomMessage(&Message{SessionID: sessionID, Content: reply}); err != nil {
+		logger.Error("[chat.replyAsNPC] 保存NPC消息失败", zap.Uint("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// recallRoom 撤回聊天室消息（成员只能撤回自己的消息，管理员及以上可以撤回任意消息）
+func (s *Service) recallRoom(userID uint, session *Session, messageIDs []uint) error {
+	p, err := s.repo.FindParticipant(session.ID, userID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("未授权")
+	}
+
+	var deleted []uint
+	if roleRank(p.Role) >= roleRank(RoleModerator) {
+		deleted, err = s.repo.DeleteMessagesByIDs(session.ID, messageIDs)
+	} else {
+		deleted, err = s.repo.DeleteMessagesBySender(session.ID, userID, messageIDs)
+	}
+	if err != nil {
+		logger.Error("[chat.recallRoom] 撤回失败", zap.Uint("session_id", session.ID), zap.Error(err))
+		return err
+	}
+	if len(deleted) == 0 {
+		return fmt.Errorf("未授权")
+	}
+
+	s.pushToParticipants(session.ID, 0, MessageRecalledEvent{
+		Type:       "message_recalled",
+		SessionID:  session.ID,
+		MessageIDs: deleted,
+	})
+	logger.Info("[chat.recallRoom] 撤回成功",
+		zap.Uint("session_id", session.ID),
+		zap.Uint("user_id", userID),
+		zap.Int("count", len(deleted)))
+	return nil
+}
+
+// roomMember 加载聊天室会话和当前用户的成员记录（不是聊天室时视为会话不存在，不是成员时返回未授权）
+func (s *Service) roomMember(userID, sessionID uint) (*Session, *Participant, error) {
+	session, err := s.repo.FindSessionByID(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !session.IsRoom() {
+		return nil, nil, fmt.Errorf("会话不存在")
+	}
+	p, err := s.repo.FindParticipant(sessionID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p == nil {
+		return nil, nil, fmt.Errorf("未授权")
+	}
+	return session, p, nil
+}
+
+// moderate 校验当前用户能否管理目标成员，返回双方的成员记录
+func (s *Service) moderate(userID, sessionID, targetID uint) (*Participant, *Participant, error) {
+	_, me, err := s.roomMember(userID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := s.repo.FindParticipant(sessionID, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target == nil {
+		return nil, nil, errcode.New(errcode.UserNotFound)
+	}
+	if !me.CanModerate(target) {
+		return nil, nil, errcode.New(errcode.PermissionDenied)
+	}
+	return me, target, nil
+}
+
+// findRoom 查找聊天室附加信息（不存在时视为会话不存在）
+func (s *Service) findRoom(sessionID uint) (*Room, error) {
+	room, err := s.repo.FindRoom(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if room == nil {
+		return nil, fmt.Errorf("会话不存在")
+	}
+	return room, nil
+}
+
+// removeMember 移除成员并通知（被移除的成员也会收到，用于客户端关闭聊天室）
+// reason为kick时记录踢出，之后不能再通过邀请码加入
+func (s *Service) removeMember(sessionID, userID uint, reason string) error {
+	removed, err := s.repo.RemoveParticipant(sessionID, userID, reason == "kick")
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return errcode.New(errcode.UserNotFound)
+	}
+
+	event := RoomEvent{
+		Type:      "room_member_left",
+		SessionID: sessionID,
+		UserID:    userID,
+		Reason:    reason,
+	}
+	s.pushToParticipants(sessionID, 0, event)
+	s.push(userID, event)
+
+	logger.Info("[chat.removeMember] 成员离开聊天室",
+		zap.Uint("session_id", sessionID),
+		zap.Uint("user_id", userID),
+		zap.String("reason", reason))
+	return nil
+}
+
+// roomMembers 聊天室成员列表（群主、管理员在前，同角色按加入时间）
+func (s *Service) roomMembers(sessionID uint) ([]RoomMemberVO, error) {
+	participants, err := s.repo.ListParticipants([]uint{sessionID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(participants))
+	for i, p := range participants {
+		ids[i] = p.UserID
+	}
+	briefs := s.briefs(ids)
+
+	members := make([]RoomMemberVO, 0, len(participants))
+	for rank := roleRank(RoleOwner); rank >= roleRank(RoleMember); rank-- {
+		for _, p := range participants {
+			if roleRank(p.Role) == rank {
+				members = append(members, s.toRoomMemberVO(p, briefs[p.UserID]))
+			}
+		}
+	}
+	return members, nil
+}
+
+// pushMemberUpdated 通知成员角色或禁言状态变化，返回该成员的信息
+func (s *Service) pushMemberUpdated(p *Participant) *RoomMemberVO {
+	member := s.toRoomMemberVO(p, s.briefs([]uint{p.UserID})[p.UserID])
+	s.pushToParticipants(p.SessionID, 0, RoomEvent{
+		Type:      "room_member_updated",
+		SessionID: p.SessionID,
+		Member:    &member,
+	})
+	return &member
+}
+
+// toRoomMemberVO 转换聊天室成员（已过期的禁言不返回）
+func (s *Service) toRoomMemberVO(p *Participant, brief user.Brief) RoomMemberVO {
+	vo := RoomMemberVO{
+		UserID:      p.UserID,
+		Username:    brief.Username,
+		DisplayName: brief.DisplayName,
+		Avatar:      brief.Avatar,
+		Role:        p.Role,
+		Online:      s.wsManager != nil && s.wsManager.IsOnline(p.UserID),
+		JoinedAt:    p.JoinedAt,
+	}
+	if p.IsMuted(time.Now()) {
+		vo.MutedUntil = p.MutedUntil
+	}
+	return vo
+}
+
+// toRoomVO 转换聊天室信息（邀请码只对群主和管理员可见）
+func toRoomVO(session *Session, room *Room, me *Participant, memberCount int64) RoomVO {
+	vo := RoomVO{
+		SessionID:   session.ID,
+		Name:        session.Title,
+		Description: room.Description,
+		OwnerID:     session.UserID,
+		NPCEnabled:  room.NPCEnabled,
+		MemberCount: memberCount,
+		MyRole:      me.Role,
+		CreatedAt:   session.CreatedAt,
+		UpdatedAt:   session.UpdatedAt,
+	}
+	if room.NPCEnabled {
+		vo.NPCName = room.NPCName
+	}
+	if roleRank(me.Role) >= roleRank(RoleModerator) {
+		vo.InviteCode = room.InviteCode
+	}
+	return vo
+}
+
+// newInviteCode 生成随机邀请码
+func newInviteCode() (string, error) {
+	raw := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("生成邀请码失败: %w", err)
+	}
+	code := make([]byte, inviteCodeLength)
+	for i, b := range raw {
+		code[i] = inviteCodeAlphabet[int(b)%len(inviteCodeAlphabet)]
+	}
+	return string(code), nil
+}
@@ -6,13 +6,17 @@ package chat
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"faulty_in_culture/go_back/internal/infra/logger"
 	"faulty_in_culture/go_back/internal/infra/ws"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/user"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // ============================================================
@@ -36,24 +40,43 @@ type Cache interface {
 	Delete(key string) error
 }
 
+// UserService 用户服务接口（私聊需要校验对方存在并展示对方信息）
+type UserService interface {
+	GetUsernames(userIDs []uint) (map[uint]user.Brief, error)
+}
+
+// BlockChecker 屏蔽关系检查（由好友模块实现，被屏蔽时不能私聊）
+type BlockChecker interface {
+	IsBlocked(userID, otherID uint) (bool, error)
+}
+
 // Service 聊天服务
 type Service struct {
-	repo      Repository
-	aiClient  AIClient
-	wsManager *ws.Manager
-	cache     Cache
+	repo        Repository
+	aiClient    AIClient
+	wsManager   *ws.Manager
+	cache       Cache
+	userService UserService
+	blocks      BlockChecker // 可为nil，此时不检查屏蔽
+	npcBusy     sync.Map     // 正在生成NPC回复的聊天室（session_id -> struct{}）
 }
 
 // NewService 创建聊天服务实例（依赖注入）
-func NewService(repo Repository, aiClient AIClient, wsManager *ws.Manager, cache Cache) *Service {
+func NewService(repo Repository, aiClient AIClient, wsManager *ws.Manager, cache Cache, userService UserService) *Service {
 	return &Service{
-		repo:      repo,
-		aiClient:  aiClient,
-		wsManager: wsManager,
-		cache:     cache,
+		repo:        repo,
+		aiClient:    aiClient,
+		wsManager:   wsManager,
+		cache:       cache,
+		userService: userService,
 	}
 }
 
+// SetBlockChecker 设置屏蔽关系检查
+func (s *Service) SetBlockChecker(blocks BlockChecker) {
+	s.blocks = blocks
+}
+
 // StartChat 开始新对话
 func (s *Service) StartChat(userID uint, title string) (*Session, error) {
 	logger.Info("[chat.StartChat] 创建新会话", zap.Uint("user_id", userID), zap.String("title", title))
@@ -88,6 +111,12 @@ func (s *Service) SendMessage(userID, sessionID uint, content string) (*Message,
 	if err != nil {
 		return nil, 0, err
 	}
+	if session.IsDirect() {
+		return s.sendDirect(userID, session, content)
+	}
+	if session.IsRoom() {
+		return s.sendRoom(userID, session, content)
+	}
 	if session.UserID != userID {
 		return nil, 0, fmt.Errorf("未授权")
 	}
@@ -188,14 +217,26 @@ func (s *Service) GetHistory(userID, sessionID uint, offset, limit int) (*Histor
 			zap.Error(err))
 		return nil, err
 	}
-	if session.UserID != userID {
+	if err := s.authorize(userID, session); err != nil {
 		logger.Warn("[chat.GetHistory] 权限验证失败",
 			zap.Uint("user_id", userID),
 			zap.Uint("session_id", sessionID))
-		return nil, fmt.Errorf("未授权")
+		return nil, err
 	}
 
-	// 2. 获取消息列表
+	// 2. 获取消息列表（offset为负数时从头开始，聊天室则返回最新一页）
+	var total int64
+	if session.IsRoom() {
+		if total, err = s.repo.CountMessagesBySessionID(sessionID); err != nil {
+			return nil, err
+		}
+		if offset < 0 {
+			offset = max(0, int(total)-limit)
+		}
+	}
+	if offset < 0 {
+		offset = 0
+	}
 	messages, err := s.repo.FindMessagesBySessionID(sessionID, offset, limit)
 	if err != nil {
 		logger.Error("[chat.GetHistory] 消息查询失败",
@@ -211,16 +252,19 @@ func (s *Service) GetHistory(userID, sessionID uint, offset, limit int) (*Histor
 		Title:     session.Title,
 		Type:      session.Type,
 		CreatedAt: session.CreatedAt,
+		UpdatedAt: session.UpdatedAt,
 	}
 
 	messageVOs := make([]MessageVO, len(messages))
 	for i, msg := range messages {
-		messageVOs[i] = MessageVO{
-			ID:           msg.ID,
-			SessionID:    msg.SessionID,
-			MessageIndex: offset + i + 1,
-			Content:      msg.Content,
-			CreatedAt:    msg.CreatedAt,
+		messageVOs[i] = toMessageVO(msg, offset+i+1)
+	}
+
+	// 4. 私聊附带双方的已读位置（已读回执）
+	var participants []PeerVO
+	if session.IsDirect() {
+		if participants, err = s.participantViews(session.ID); err != nil {
+			logger.Warn("[chat.GetHistory] 参与者查询失败", zap.Uint("session_id", sessionID), zap.Error(err))
 		}
 	}
 
@@ -228,8 +272,11 @@ func (s *Service) GetHistory(userID, sessionID uint, offset, limit int) (*Histor
 		zap.Uint("session_id", sessionID),
 		zap.Int("count", len(messages)))
 	return &HistoryResponse{
-		Session:  sessionVO,
-		Messages: messageVOs,
+		Session:      sessionVO,
+		Participants: participants,
+		Messages:     messageVOs,
+		Total:        total,
+		Offset:       offset,
 	}, nil
 }
 
@@ -267,11 +314,11 @@ func (s *Service) GetSession(userID, sessionID uint) (*Session, error) {
 			zap.Error(err))
 		return nil, err
 	}
-	if session.UserID != userID {
+	if err := s.authorize(userID, session); err != nil {
 		logger.Warn("[chat.GetSession] 权限验证失败",
 			zap.Uint("user_id", userID),
 			zap.Uint("session_id", sessionID))
-		return nil, fmt.Errorf("未授权")
+		return nil, err
 	}
 	return session, nil
 }
@@ -291,6 +338,12 @@ func (s *Service) UpdateSession(userID, sessionID uint, title string) (*Session,
 			zap.Error(err))
 		return nil, err
 	}
+	if session.IsDirect() {
+		return nil, errcode.New(errcode.DirectChatReadOnly)
+	}
+	if session.IsRoom() {
+		return nil, errcode.NewWithMessage(errcode.DirectChatReadOnly, "请通过聊天室接口修改聊天室")
+	}
 	if session.UserID != userID {
 		logger.Warn("[chat.UpdateSession] 权限验证失败",
 			zap.Uint("user_id", userID),
@@ -327,6 +380,12 @@ func (s *Service) DeleteSession(userID, sessionID uint) error {
 			zap.Error(err))
 		return err
 	}
+	if session.IsDirect() {
+		return errcode.New(errcode.DirectChatReadOnly)
+	}
+	if session.IsRoom() {
+		return errcode.NewWithMessage(errcode.DirectChatReadOnly, "请通过聊天室接口退出或解散聊天室")
+	}
 	if session.UserID != userID {
 		logger.Warn("[chat.DeleteSession] 权限验证失败",
 			zap.Uint("user_id", userID),
@@ -362,11 +421,17 @@ func (s *Service) RecallMessages(userID, sessionID uint, messageIDs []uint) erro
 			zap.Error(err))
 		return err
 	}
-	if session.UserID != userID {
+	if err := s.authorize(userID, session); err != nil {
 		logger.Warn("[chat.RecallMessages] 权限验证失败",
 			zap.Uint("user_id", userID),
 			zap.Uint("session_id", sessionID))
-		return fmt.Errorf("未授权")
+		return err
+	}
+	if session.IsDirect() {
+		return s.recallDirect(userID, session, messageIDs)
+	}
+	if session.IsRoom() {
+		return s.recallRoom(userID, session, messageIDs)
 	}
 
 	// 2. 删除消息
@@ -385,10 +450,10 @@ func (s *Service) RecallMessages(userID, sessionID uint, messageIDs []uint) erro
 	return nil
 }
 
-// ConnectWebSocket 连接WebSocket
-func (s *Service) ConnectWebSocket(userID uint, conn *websocket.Conn) *ws.Client {
+// ConnectWebSocket 连接WebSocket（用户被禁言等情况下返回错误）
+func (s *Service) ConnectWebSocket(userID uint, conn *websocket.Conn) (*ws.Client, error) {
 	if s.wsManager == nil {
-		return nil
+		return nil, fmt.Errorf("WebSocket服务未启用")
 	}
 	return s.wsManager.Register(userID, conn)
 }
@@ -399,3 +464,20 @@ func (s *Service) DisconnectWebSocket(client *ws.Client) {
 		s.wsManager.Unregister(client)
 	}
 }
+
+// PurgeUsers 删除用户时清理聊天数据（注册为 user.Service 的清理钩子，在删除用户的事务中执行）
+// 用户是群主的聊天室转让给其他成员而不是解散，其他成员的聊天记录保留
+func (s *Service) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	transferred, err := s.repo.PurgeUsers(tx, userIDs)
+	if err != nil {
+		logger.Error("[chat.PurgeUsers] 清理聊天数据失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		return err
+	}
+	for sessionID, ownerID := range transferred {
+		logger.Warn("[audit] 群主账号删除，聊天室转让给其他成员",
+			zap.String("event", "room_ownership_transferred"),
+			zap.Uint("session_id", sessionID),
+			zap.Uint("owner_id", ownerID))
+	}
+	return nil
+}
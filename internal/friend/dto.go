@@ -0,0 +1,80 @@
+// Package friend - 好友模块数据传输对象
+// 功能：定义API请求、响应和WebSocket推送的数据结构
+package friend
+
+import "time"
+
+// ============ 请求DTO ============
+
+// SendRequestRequest 发送好友请求（user_id 和 username 二选一）
+type SendRequestRequest struct {
+	UserID   uint   `json:"user_id" example:"2"`
+	Username string `json:"username" example:"player2"`
+	Message  string `json:"message" binding:"max=100" example:"一起组队吧"` // 附言
+}
+
+// BlockRequest 屏蔽用户请求
+type BlockRequest struct {
+	UserID uint `json:"user_id" binding:"required" example:"2"`
+}
+
+// ============ 响应VO ============
+
+// UserBriefVO 用户展示信息
+type UserBriefVO struct {
+	UserID      uint   `json:"user_id" example:"2"`
+	Username    string `json:"username" example:"player2"`
+	DisplayName string `json:"display_name" example:"小明"`
+	Avatar      string `json:"avatar" example:"/api/files/avatars/2/a1b2c3.png"`
+	AvatarThumb string `json:"avatar_thumb" example:"/api/files/avatars/2/a1b2c3_thumb.png"`
+}
+
+// FriendVO 好友
+type FriendVO struct {
+	UserBriefVO
+	Online bool      `json:"online" example:"true"` // 是否在线（有活跃的WebSocket连接）
+	Since  time.Time `json:"since" example:"2026-01-01T10:00:00Z"`
+}
+
+// FriendRequestVO 好友请求
+type FriendRequestVO struct {
+	ID         uint        `json:"id" example:"1"`
+	FromUserID uint        `json:"from_user_id" example:"2"`
+	ToUserID   uint        `json:"to_user_id" example:"1"`
+	User       UserBriefVO `json:"user"` // 对方的信息（收到的请求为发送方，发出的请求为接收方）
+	Message    string      `json:"message" example:"一起组队吧"`
+	CreatedAt  time.Time   `json:"created_at" example:"2026-01-01T10:00:00Z"`
+}
+
+// SendRequestResult 发送好友请求结果
+// 对方已向自己发送过请求时直接成为好友，此时 Friend 不为空
+type SendRequestResult struct {
+	Request *FriendRequestVO `json:"request,omitempty"`
+	Friend  *FriendVO        `json:"friend,omitempty"`
+}
+
+// BlockVO 屏蔽记录
+type BlockVO struct {
+	UserBriefVO
+	BlockedAt time.Time `json:"blocked_at" example:"2026-01-01T10:00:00Z"`
+}
+
+// ============ WebSocket推送 ============
+
+// RequestEvent 收到好友请求
+type RequestEvent struct {
+	Type    string          `json:"type"` // "friend_request"
+	Request FriendRequestVO `json:"request"`
+}
+
+// AcceptedEvent 好友请求被接受
+type AcceptedEvent struct {
+	Type   string   `json:"type"` // "friend_accepted"
+	Friend FriendVO `json:"friend"`
+}
+
+// PresenceEvent 好友上线/下线
+type PresenceEvent struct {
+	Type   string `json:"type"` // "friend_online" / "friend_offline"
+	UserID uint   `json:"user_id"`
+}
@@ -0,0 +1,49 @@
+// Package friend - 好友模块
+// 功能：好友请求（发送/接受/拒绝）、好友列表、屏蔽、在线状态推送
+// 架构：好友关系 + 好友请求 + 屏蔽名单
+package friend
+
+import (
+	"time"
+)
+
+// 好友请求状态
+const (
+	StatusPending  = "pending"  // 等待对方处理
+	StatusAccepted = "accepted" // 已接受
+	StatusDeclined = "declined" // 已拒绝
+)
+
+// Request 好友请求实体
+// 每对 (发送方, 接收方) 只保留一条记录，重新发送时复用该记录
+type Request struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	FromUserID  uint       `gorm:"uniqueIndex:idx_friend_request_pair;not null" json:"from_user_id"`
+	ToUserID    uint       `gorm:"uniqueIndex:idx_friend_request_pair;index:idx_friend_request_to_status;not null" json:"to_user_id"`
+	Status      string     `gorm:"type:varchar(16);index:idx_friend_request_to_status;not null;default:pending" json:"status"`
+	Message     string     `gorm:"type:varchar(100)" json:"message"` // 附言
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
+
+func (Request) TableName() string { return "friend_requests" }
+
+// Friendship 好友关系实体
+// 双向存储：A和B成为好友时写入 (A,B) 和 (B,A) 两条记录，查询好友列表只需按 user_id 查找
+type Friendship struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	FriendID  uint      `gorm:"primaryKey;autoIncrement:false;index" json:"friend_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Friendship) TableName() string { return "friendships" }
+
+// Block 屏蔽记录实体（UserID 屏蔽了 BlockedID）
+// 屏蔽后双方不能互相发送好友请求，已有的好友关系和待处理请求会被删除
+type Block struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	BlockedID uint      `gorm:"primaryKey;autoIncrement:false;index" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Block) TableName() string { return "user_blocks" }
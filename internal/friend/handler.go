@@ -0,0 +1,251 @@
+// Package friend - 好友模块HTTP处理层
+// 功能：处理好友请求、好友列表、屏蔽名单相关的HTTP请求
+package friend
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/shared/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 好友处理器
+type Handler struct {
+	service *Service
+}
+
+// NewHandler 创建好友处理器
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListFriends 好友列表
+// @Summary 好友列表
+// @Description 获取好友列表，附带在线状态（在线的在前）
+// @Tags 好友
+// @Produce json
+// @Success 200 {object} response.Response{data=[]FriendVO}
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/friends [get]
+func (h *Handler) ListFriends(c *gin.Context) {
+	friends, err := h.service.ListFriends(c.GetUint("user_id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	response.Success(c, friends)
+}
+
+// RemoveFriend 删除好友
+// @Summary 删除好友
+// @Tags 好友
+// @Produce json
+// @Param user_id path int true "好友的用户ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "对方不是你的好友"
+// @Router /api/friends/{user_id} [delete]
+func (h *Handler) RemoveFriend(c *gin.Context) {
+	friendID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+	if err := h.service.RemoveFriend(c.GetUint("user_id"), friendID); err != nil {
+		handleError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "已删除好友", nil)
+}
+
+// ListRequests 待处理的好友请求
+// @Summary 好友请求列表
+// @Tags 好友
+// @Produce json
+// @Param direction query string false "incoming(收到的，默认) 或 outgoing(发出的)"
+// @Success 200 {object} response.Response{data=[]FriendRequestVO}
+// @Router /api/friends/requests [get]
+func (h *Handler) ListRequests(c *gin.Context) {
+	direction := c.DefaultQuery("direction", "incoming")
+	if direction != "incoming" && direction != "outgoing" {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	reqs, err := h.service.ListRequests(c.GetUint("user_id"), direction == "incoming")
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	response.Success(c, reqs)
+}
+
+// SendRequest 发送好友请求
+// @Summary 发送好友请求
+// @Description 通过 user_id 或 username 指定对方；对方已向自己发送过请求时直接成为好友。对方在线时会收到 friend_request 推送
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Param request body SendRequestRequest true "目标用户"
+// @Success 200 {object} response.Response{data=SendRequestResult}
+// @Failure 400 {object} response.Response "好友数量已达上限"
+// @Failure 403 {object} response.Response "对方拒绝接收好友请求"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Failure 409 {object} response.Response "已是好友/已发送过请求"
+// @Router /api/friends/requests [post]
+func (h *Handler) SendRequest(c *gin.Context) {
+	var req SendRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	targetID, err := h.service.ResolveUserID(req.UserID, req.Username)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	sent, friend, err := h.service.SendRequest(c.GetUint("user_id"), targetID, req.Message)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if friend != nil {
+		response.SuccessWithMessage(c, "对方也向你发送了请求，已成为好友", SendRequestResult{Friend: friend})
+		return
+	}
+	response.SuccessWithMessage(c, "好友请求已发送", SendRequestResult{Request: sent})
+}
+
+// AcceptRequest 接受好友请求
+// @Summary 接受好友请求
+// @Description 接受后发送方会收到 friend_accepted 推送
+// @Tags 好友
+// @Produce json
+// @Param id path int true "好友请求ID"
+// @Success 200 {object} response.Response{data=FriendVO}
+// @Failure 404 {object} response.Response "好友请求不存在"
+// @Router /api/friends/requests/{id}/accept [post]
+func (h *Handler) AcceptRequest(c *gin.Context) {
+	requestID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	friend, err := h.service.AcceptRequest(c.GetUint("user_id"), requestID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	response.Success(c, friend)
+}
+
+// DeclineRequest 拒绝好友请求
+// @Summary 拒绝好友请求
+// @Tags 好友
+// @Produce json
+// @Param id path int true "好友请求ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response "好友请求不存在"
+// @Router /api/friends/requests/{id}/decline [post]
+func (h *Handler) DeclineRequest(c *gin.Context) {
+	requestID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.DeclineRequest(c.GetUint("user_id"), requestID); err != nil {
+		handleError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "已拒绝", nil)
+}
+
+// ListBlocks 屏蔽名单
+// @Summary 屏蔽名单
+// @Tags 好友
+// @Produce json
+// @Success 200 {object} response.Response{data=[]BlockVO}
+// @Router /api/friends/blocks [get]
+func (h *Handler) ListBlocks(c *gin.Context) {
+	blocks, err := h.service.ListBlocks(c.GetUint("user_id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	response.Success(c, blocks)
+}
+
+// BlockUser 屏蔽用户
+// @Summary 屏蔽用户
+// @Description 屏蔽后双方不能互相发送好友请求，已有的好友关系和待处理请求会被删除
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Param request body BlockRequest true "要屏蔽的用户"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/friends/blocks [post]
+func (h *Handler) BlockUser(c *gin.Context) {
+	var req BlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	if err := h.service.BlockUser(c.GetUint("user_id"), req.UserID); err != nil {
+		handleError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "已屏蔽", nil)
+}
+
+// UnblockUser 解除屏蔽
+// @Summary 解除屏蔽
+// @Tags 好友
+// @Produce json
+// @Param user_id path int true "被屏蔽的用户ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response "未屏蔽该用户"
+// @Router /api/friends/blocks/{user_id} [delete]
+func (h *Handler) UnblockUser(c *gin.Context) {
+	blockedID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+	if err := h.service.UnblockUser(c.GetUint("user_id"), blockedID); err != nil {
+		handleError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "已解除屏蔽", nil)
+}
+
+// uintParam 解析路径中的ID参数（无效时直接返回400）
+func uintParam(c *gin.Context, name string) (uint, bool) {
+	id, _ := strconv.ParseUint(c.Param(name), 10, 64)
+	if id == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// handleError 将业务错误转换为HTTP响应
+func handleError(c *gin.Context, err error) {
+	var e *errcode.Error
+	if !stderrors.As(err, &e) {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	switch e.Code {
+	case errcode.UserNotFound, errcode.FriendRequestNotFound, errcode.NotFound:
+		response.ErrorWithMessage(c, http.StatusNotFound, e.Code, e.Message)
+	case errcode.FriendBlocked:
+		response.Error(c, http.StatusForbidden, e.Code)
+	case errcode.AlreadyFriends, errcode.FriendRequestExists:
+		response.Error(c, http.StatusConflict, e.Code)
+	default:
+		response.ErrorWithMessage(c, http.StatusBadRequest, e.Code, e.Message)
+	}
+}
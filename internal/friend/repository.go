@@ -0,0 +1,202 @@
+// Package friend - 好友模块数据访问层
+// 功能：封装好友请求、好友关系、屏蔽名单的数据操作
+// 设计模式：Repository模式
+package friend
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository 好友仓储接口
+type Repository interface {
+	// FindRequestByID 根据ID查找好友请求（不存在时返回nil, nil）
+	FindRequestByID(id uint) (*Request, error)
+	// FindRequest 查找 from → to 的好友请求（不存在时返回nil, nil）
+	FindRequest(fromUserID, toUserID uint) (*Request, error)
+	// SaveRequest 创建或更新好友请求
+	SaveRequest(req *Request) error
+	// ListPendingRequests 待处理的好友请求（incoming为true时查收到的，否则查发出的）
+	ListPendingRequests(userID uint, incoming bool) ([]*Request, error)
+	// AcceptRequest 在一个事务中接受好友请求并建立双向好友关系
+	AcceptRequest(req *Request, now time.Time) error
+
+	// ListFriends 用户的好友关系列表（按成为好友的时间排序）
+	ListFriends(userID uint) ([]*Friendship, error)
+	// FriendIDs 用户的所有好友ID
+	FriendIDs(userID uint) ([]uint, error)
+	// IsFriend 两个用户是否是好友
+	IsFriend(userID, friendID uint) (bool, error)
+	// CountFriends 用户的好友数量
+	CountFriends(userID uint) (int64, error)
+	// DeleteFriendship 删除双向好友关系，返回是否存在该关系
+	DeleteFriendship(userID, friendID uint) (bool, error)
+
+	// IsBlockedEither 两个用户之间是否存在任一方向的屏蔽
+	IsBlockedEither(userID, otherID uint) (bool, error)
+	// CreateBlock 屏蔽用户（同时删除双方的好友关系和待处理请求）
+	CreateBlock(userID, blockedID uint) error
+	// DeleteBlock 解除屏蔽，返回是否存在该屏蔽
+	DeleteBlock(userID, blockedID uint) (bool, error)
+	// ListBlocks 用户的屏蔽名单
+	ListBlocks(userID uint) ([]*Block, error)
+
+	// PurgeUsers 在tx中删除用户作为任一方的好友请求、好友关系和屏蔽记录
+	PurgeUsers(tx *gorm.DB, userIDs []uint) error
+}
+
+// repositoryImpl Repository的GORM实现
+type repositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建好友仓储实例
+func NewRepository(db *gorm.DB) Repository {
+	return &repositoryImpl{db: db}
+}
+
+// FindRequestByID 根据ID查找好友请求（不存在时返回nil, nil）
+func (r *repositoryImpl) FindRequestByID(id uint) (*Request, error) {
+	var req Request
+	err := r.db.First(&req, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// FindRequest 查找 from → to 的好友请求（不存在时返回nil, nil）
+func (r *repositoryImpl) FindRequest(fromUserID, toUserID uint) (*Request, error) {
+	var req Request
+	err := r.db.Where("from_user_id = ? AND to_user_id = ?", fromUserID, toUserID).First(&req).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// SaveRequest 创建或更新好友请求
+func (r *repositoryImpl) SaveRequest(req *Request) error {
+	return r.db.Save(req).Error
+}
+
+// ListPendingRequests 待处理的好友请求（最新的在前）
+func (r *repositoryImpl) ListPendingRequests(userID uint, incoming bool) ([]*Request, error) {
+	column := "from_user_id"
+	if incoming {
+		column = "to_user_id"
+	}
+	var reqs []*Request
+	err := r.db.Where(column+" = ? AND status = ?", userID, StatusPending).
+		Order("created_at DESC").
+		Find(&reqs).Error
+	return reqs, err
+}
+
+// AcceptRequest 在一个事务中接受好友请求并建立双向好友关系
+func (r *repositoryImpl) AcceptRequest(req *Request, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Request{}).Where("id = ?", req.ID).
+			Updates(map[string]interface{}{"status": StatusAccepted, "responded_at": now}).Error; err != nil {
+			return err
+		}
+		rows := []Friendship{
+			{UserID: req.FromUserID, FriendID: req.ToUserID, CreatedAt: now},
+			{UserID: req.ToUserID, FriendID: req.FromUserID, CreatedAt: now},
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+}
+
+// ListFriends 用户的好友关系列表（按成为好友的时间排序）
+func (r *repositoryImpl) ListFriends(userID uint) ([]*Friendship, error) {
+	var friends []*Friendship
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&friends).Error
+	return friends, err
+}
+
+// FriendIDs 用户的所有好友ID
+func (r *repositoryImpl) FriendIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&Friendship{}).Where("user_id = ?", userID).Pluck("friend_id", &ids).Error
+	return ids, err
+}
+
+// IsFriend 两个用户是否是好友
+func (r *repositoryImpl) IsFriend(userID, friendID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&Friendship{}).Where("user_id = ? AND friend_id = ?", userID, friendID).Count(&count).Error
+	return count > 0, err
+}
+
+// CountFriends 用户的好友数量
+func (r *repositoryImpl) CountFriends(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&Friendship{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// DeleteFriendship 删除双向好友关系，返回是否存在该关系
+func (r *repositoryImpl) DeleteFriendship(userID, friendID uint) (bool, error) {
+	result := r.db.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)",
+		userID, friendID, friendID, userID).Delete(&Friendship{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// IsBlockedEither 两个用户之间是否存在任一方向的屏蔽
+func (r *repositoryImpl) IsBlockedEither(userID, otherID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&Block{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)",
+			userID, otherID, otherID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CreateBlock 屏蔽用户（同时删除双方的好友关系和待处理请求）
+func (r *repositoryImpl) CreateBlock(userID, blockedID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		block := &Block{UserID: userID, BlockedID: blockedID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(block).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)",
+			userID, blockedID, blockedID, userID).Delete(&Friendship{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)) AND status = ?",
+			userID, blockedID, blockedID, userID, StatusPending).Delete(&Request{}).Error
+	})
+}
+
+// DeleteBlock 解除屏蔽，返回是否存在该屏蔽
+func (r *repositoryImpl) DeleteBlock(userID, blockedID uint) (bool, error) {
+	result := r.db.Where("user_id = ? AND blocked_id = ?", userID, blockedID).Delete(&Block{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListBlocks 用户的屏蔽名单（最近屏蔽的在前）
+func (r *repositoryImpl) ListBlocks(userID uint) ([]*Block, error) {
+	var blocks []*Block
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&blocks).Error
+	return blocks, err
+}
+
+// PurgeUsers 在tx中删除用户作为任一方的好友请求、好友关系和屏蔽记录
+func (r *repositoryImpl) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	if err := tx.Where("from_user_id IN ? OR to_user_id IN ?", userIDs, userIDs).Delete(&Request{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id IN ? OR friend_id IN ?", userIDs, userIDs).Delete(&Friendship{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id IN ? OR blocked_id IN ?", userIDs, userIDs).Delete(&Block{}).Error
+}
@@ -0,0 +1,426 @@
+// Package friend - 好友模块业务逻辑层
+// 功能：好友请求、好友列表、屏蔽名单，以及通过WebSocket推送好友事件
+package friend
+
+import (
+	"sort"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/user"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ============================================================
+// Service层 - 业务逻辑层
+// 依赖：user模块（用户展示信息、按用户名查找）、WebSocket管理器（在线状态和推送）
+// 推送事件：
+//   friend_request   收到好友请求
+//   friend_accepted  发出的好友请求被接受
+//   friend_online    好友上线（第一个WebSocket连接建立）
+//   friend_offline   好友下线（最后一个WebSocket连接断开）
+// ============================================================
+
+// maxFriends 每个用户的好友数量上限
+const maxFriends = 200
+
+// UserService 用户服务接口（friend → user 单向依赖）
+type UserService interface {
+	// GetUsernames 批量获取用户展示信息（用户名、昵称、头像）
+	GetUsernames(userIDs []uint) (map[uint]user.Brief, error)
+	// FindUserIDByUsername 根据用户名查找用户ID
+	FindUserIDByUsername(username string) (uint, error)
+}
+
+// Presence 在线状态和实时推送（由 ws.Manager 实现）
+type Presence interface {
+	IsOnline(userID uint) bool
+	SendToUser(userID uint, message interface{}) error
+}
+
+// Service 好友服务
+type Service struct {
+	repo        Repository
+	userService UserService
+	presence    Presence
+}
+
+// NewService 创建好友服务实例（依赖注入）
+func NewService(repo Repository, userService UserService, presence Presence) *Service {
+	return &Service{
+		repo:        repo,
+		userService: userService,
+		presence:    presence,
+	}
+}
+
+// ResolveUserID 解析请求中的目标用户（user_id 优先，否则按用户名查找）
+func (s *Service) ResolveUserID(userID uint, username string) (uint, error) {
+	if userID != 0 {
+		return userID, nil
+	}
+	if username == "" {
+		return 0, errors.New(errors.InvalidParams)
+	}
+	return s.userService.FindUserIDByUsername(username)
+}
+
+// SendRequest 发送好友请求
+// 对方已向自己发送过待处理的请求时直接接受该请求，返回新的好友
+func (s *Service) SendRequest(fromUserID, toUserID uint, message string) (*FriendRequestVO, *FriendVO, error) {
+	if fromUserID == toUserID {
+		return nil, nil, errors.New(errors.CannotFriendSelf)
+	}
+	briefs, err := s.userService.GetUsernames([]uint{fromUserID, toUserID})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := briefs[toUserID]; !ok {
+		return nil, nil, errors.New(errors.UserNotFound)
+	}
+
+	blocked, err := s.repo.IsBlockedEither(fromUserID, toUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blocked {
+		return nil, nil, errors.New(errors.FriendBlocked)
+	}
+	isFriend, err := s.repo.IsFriend(fromUserID, toUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isFriend {
+		return nil, nil, errors.New(errors.AlreadyFriends)
+	}
+
+	// 对方已经向自己发出请求：直接成为好友
+	reverse, err := s.repo.FindRequest(toUserID, fromUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reverse != nil && reverse.Status == StatusPending {
+		friend, err := s.accept(reverse, briefs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, friend, nil
+	}
+
+	if err := s.checkFriendLimit(fromUserID); err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.repo.FindRequest(fromUserID, toUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req != nil && req.Status == StatusPending {
+		return nil, nil, errors.New(errors.FriendRequestExists)
+	}
+	if req == nil {
+		req = &Request{FromUserID: fromUserID, ToUserID: toUserID}
+	}
+	// 被拒绝或曾经是好友的请求记录重新变为待处理
+	req.Status = StatusPending
+	req.Message = message
+	req.CreatedAt = time.Now()
+	req.RespondedAt = nil
+	if err := s.repo.SaveRequest(req); err != nil {
+		logger.Error("[friend.SendRequest] 保存好友请求失败", zap.Uint("from", fromUserID), zap.Uint("to", toUserID), zap.Error(err))
+		return nil, nil, err
+	}
+
+	logger.Info("[friend.SendRequest] 已发送好友请求", zap.Uint("from", fromUserID), zap.Uint("to", toUserID))
+
+	// 接收方看到的是发送方的信息
+	s.push(toUserID, RequestEvent{Type: "friend_request", Request: toRequestVO(req, fromUserID, briefs[fromUserID])})
+
+	vo := toRequestVO(req, toUserID, briefs[toUserID])
+	return &vo, nil, nil
+}
+
+// AcceptRequest 接受收到的好友请求，返回新的好友
+func (s *Service) AcceptRequest(userID, requestID uint) (*FriendVO, error) {
+	req, err := s.pendingIncoming(userID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	briefs, err := s.userService.GetUsernames([]uint{req.FromUserID, req.ToUserID})
+	if err != nil {
+		return nil, err
+	}
+	return s.accept(req, briefs)
+}
+
+// accept 接受好友请求：检查双方好友上限，建立好友关系并通知发送方
+func (s *Service) accept(req *Request, briefs map[uint]user.Brief) (*FriendVO, error) {
+	if err := s.checkFriendLimit(req.ToUserID); err != nil {
+		return nil, err
+	}
+	if err := s.checkFriendLimit(req.FromUserID); err != nil {
+		return nil, errors.NewWithMessage(errors.FriendLimitExceeded, "对方的好友数量已达上限")
+	}
+
+	now := time.Now()
+	if err := s.repo.AcceptRequest(req, now); err != nil {
+		logger.Error("[friend.accept] 接受好友请求失败", zap.Uint("request_id", req.ID), zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("[friend.accept] 已成为好友", zap.Uint("user_id", req.ToUserID), zap.Uint("friend_id", req.FromUserID))
+
+	// 通知发送方：接受方成为了好友
+	s.push(req.FromUserID, AcceptedEvent{Type: "friend_accepted", Friend: s.toFriendVO(req.ToUserID, briefs[req.ToUserID], now)})
+
+	friend := s.toFriendVO(req.FromUserID, briefs[req.FromUserID], now)
+	return &friend, nil
+}
+
+// DeclineRequest 拒绝收到的好友请求（不通知发送方）
+func (s *Service) DeclineRequest(userID, requestID uint) error {
+	req, err := s.pendingIncoming(userID, requestID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	req.Status = StatusDeclined
+	req.RespondedAt = &now
+	return s.repo.SaveRequest(req)
+}
+
+// pendingIncoming 查找发给userID的待处理请求
+func (s *Service) pendingIncoming(userID, requestID uint) (*Request, error) {
+	req, err := s.repo.FindRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.ToUserID != userID || req.Status != StatusPending {
+		return nil, errors.New(errors.FriendRequestNotFound)
+	}
+	return req, nil
+}
+
+// checkFriendLimit 检查好友数量上限
+func (s *Service) checkFriendLimit(userID uint) error {
+	count, err := s.repo.CountFriends(userID)
+	if err != nil {
+		return err
+	}
+	if count >= maxFriends {
+		return errors.New(errors.FriendLimitExceeded)
+	}
+	return nil
+}
+
+// ListRequests 待处理的好友请求（incoming为true时查收到的，否则查发出的）
+func (s *Service) ListRequests(userID uint, incoming bool) ([]FriendRequestVO, error) {
+	reqs, err := s.repo.ListPendingRequests(userID, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	otherOf := func(r *Request) uint {
+		if incoming {
+			return r.FromUserID
+		}
+		return r.ToUserID
+	}
+	ids := make([]uint, len(reqs))
+	for i, r := range reqs {
+		ids[i] = otherOf(r)
+	}
+	briefs := s.briefs(ids)
+
+	vos := make([]FriendRequestVO, len(reqs))
+	for i, r := range reqs {
+		vos[i] = toRequestVO(r, otherOf(r), briefs[otherOf(r)])
+	}
+	return vos, nil
+}
+
+// ListFriends 好友列表（在线的在前）
+func (s *Service) ListFriends(userID uint) ([]FriendVO, error) {
+	friendships, err := s.repo.ListFriends(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(friendships))
+	for i, f := range friendships {
+		ids[i] = f.FriendID
+	}
+	briefs := s.briefs(ids)
+
+	vos := make([]FriendVO, len(friendships))
+	for i, f := range friendships {
+		vos[i] = s.toFriendVO(f.FriendID, briefs[f.FriendID], f.CreatedAt)
+	}
+	sort.SliceStable(vos, func(i, j int) bool {
+		return vos[i].Online && !vos[j].Online
+	})
+	return vos, nil
+}
+
+// FriendIDs 用户的所有好友ID（供好友排行榜等模块使用）
+func (s *Service) FriendIDs(userID uint) ([]uint, error) {
+	return s.repo.FriendIDs(userID)
+}
+
+// IsBlocked 两个用户之间是否存在任一方向的屏蔽（供私聊等模块使用）
+func (s *Service) IsBlocked(userID, otherID uint) (bool, error) {
+	return s.repo.IsBlockedEither(userID, otherID)
+}
+
+// RemoveFriend 删除好友（双向删除，不通知对方）
+func (s *Service) RemoveFriend(userID, friendID uint) error {
+	existed, err := s.repo.DeleteFriendship(userID, friendID)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return errors.New(errors.NotFriends)
+	}
+	logger.Info("[friend.RemoveFriend] 已删除好友", zap.Uint("user_id", userID), zap.Uint("friend_id", friendID))
+	return nil
+}
+
+// BlockUser 屏蔽用户（同时解除好友关系并删除双方之间的待处理请求）
+func (s *Service) BlockUser(userID, blockedID uint) error {
+	if userID == blockedID {
+		return errors.NewWithMessage(errors.InvalidParams, "不能屏蔽自己")
+	}
+	briefs, err := s.userService.GetUsernames([]uint{blockedID})
+	if err != nil {
+		return err
+	}
+	if _, ok := briefs[blockedID]; !ok {
+		return errors.New(errors.UserNotFound)
+	}
+	if err := s.repo.CreateBlock(userID, blockedID); err != nil {
+		logger.Error("[friend.BlockUser] 屏蔽失败", zap.Uint("user_id", userID), zap.Uint("blocked_id", blockedID), zap.Error(err))
+		return err
+	}
+	logger.Info("[friend.BlockUser] 已屏蔽用户", zap.Uint("user_id", userID), zap.Uint("blocked_id", blockedID))
+	return nil
+}
+
+// UnblockUser 解除屏蔽
+func (s *Service) UnblockUser(userID, blockedID uint) error {
+	existed, err := s.repo.DeleteBlock(userID, blockedID)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return errors.NewWithMessage(errors.NotFound, "未屏蔽该用户")
+	}
+	return nil
+}
+
+// ListBlocks 屏蔽名单
+func (s *Service) ListBlocks(userID uint) ([]BlockVO, error) {
+	blocks, err := s.repo.ListBlocks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(blocks))
+	for i, b := range blocks {
+		ids[i] = b.BlockedID
+	}
+	briefs := s.briefs(ids)
+
+	vos := make([]BlockVO, len(blocks))
+	for i, b := range blocks {
+		vos[i] = BlockVO{UserBriefVO: toBriefVO(b.BlockedID, briefs[b.BlockedID]), BlockedAt: b.CreatedAt}
+	}
+	return vos, nil
+}
+
+// PurgeUsers 删除用户时清理好友数据（注册为 user.Service 的清理钩子，在删除用户的事务中执行）
+func (s *Service) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	if err := s.repo.PurgeUsers(tx, userIDs); err != nil {
+		logger.Error("[friend.PurgeUsers] 清理好友数据失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// OnPresenceChange 用户上线/下线时通知其在线的好友（注册为 ws.Manager 的在线状态回调）
+func (s *Service) OnPresenceChange(userID uint, online bool) {
+	// 回调是异步的，快速重连时可能乱序到达：以当前实际状态为准，过期的通知直接丢弃
+	if s.presence.IsOnline(userID) != online {
+		return
+	}
+
+	friendIDs, err := s.repo.FriendIDs(userID)
+	if err != nil {
+		logger.Warn("[friend.OnPresenceChange] 查询好友失败", zap.Uint("user_id", userID), zap.Error(err))
+		return
+	}
+
+	event := PresenceEvent{Type: "friend_offline", UserID: userID}
+	if online {
+		event.Type = "friend_online"
+	}
+	for _, friendID := range friendIDs {
+		if s.presence.IsOnline(friendID) {
+			s.push(friendID, event)
+		}
+	}
+}
+
+// push 通过WebSocket推送事件（失败只记录日志）
+func (s *Service) push(userID uint, event interface{}) {
+	if err := s.presence.SendToUser(userID, event); err != nil {
+		logger.Warn("[friend.push] WebSocket推送失败", zap.Uint("user_id", userID), zap.Error(err))
+	}
+}
+
+// briefs 批量获取用户展示信息（失败时降级为只返回用户ID）
+func (s *Service) briefs(userIDs []uint) map[uint]user.Brief {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	briefs, err := s.userService.GetUsernames(userIDs)
+	if err != nil {
+		logger.Warn("[friend.briefs] 批量查询用户信息失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		return nil
+	}
+	return briefs
+}
+
+// toFriendVO 转换为好友VO（附带在线状态）
+func (s *Service) toFriendVO(userID uint, brief user.Brief, since time.Time) FriendVO {
+	return FriendVO{
+		UserBriefVO: toBriefVO(userID, brief),
+		Online:      s.presence.IsOnline(userID),
+		Since:       since,
+	}
+}
+
+// toBriefVO 转换用户展示信息
+func toBriefVO(userID uint, brief user.Brief) UserBriefVO {
+	return UserBriefVO{
+		UserID:      userID,
+		Username:    brief.Username,
+		DisplayName: brief.DisplayName,
+		Avatar:      brief.Avatar,
+		AvatarThumb: brief.AvatarThumb,
+	}
+}
+
+// toRequestVO 转换好友请求（otherID为对方的用户ID）
+func toRequestVO(r *Request, otherID uint, other user.Brief) FriendRequestVO {
+	return FriendRequestVO{
+		ID:         r.ID,
+		FromUserID: r.FromUserID,
+		ToUserID:   r.ToUserID,
+		User:       toBriefVO(otherID, other),
+		Message:    r.Message,
+		CreatedAt:  r.CreatedAt,
+	}
+}
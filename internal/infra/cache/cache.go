@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"faulty_in_culture/go_back/internal/infra/config"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -87,6 +88,16 @@ func (c *Cache) Get(key string, dest interface{}) error {
 	return json.Unmarshal([]byte(data), dest)
 }
 
+// GetDel 原子地获取并删除缓存值（用于一次性凭证，并发读取时只有一个成功）
+// 返回 error 如果键不存在或反序列化失败
+func (c *Cache) GetDel(key string, dest interface{}) error {
+	data, err := c.client.GetDel(c.ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), dest)
+}
+
 // Delete 删除缓存键
 func (c *Cache) Delete(key string) error {
 	return c.client.Del(c.ctx, key).Err()
@@ -119,3 +130,328 @@ func (c *Cache) ClearAll() error {
 func (c *Cache) SetExpiration(key string, expiration time.Duration) error {
 	return c.client.Expire(c.ctx, key, expiration).Err()
 }
+
+// AddToWindow 滑动窗口计数：记录一次事件，并返回窗口内的事件总数
+// 实现：使用有序集合，score为事件时间戳，超出窗口的事件会被清理
+func (c *Cache) AddToWindow(key string, window time.Duration) (int64, error) {
+	now := time.Now()
+	var card *redis.IntCmd
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(c.ctx, key, "-inf", fmt.Sprintf("%d", now.Add(-window).UnixNano()))
+		pipe.ZAdd(c.ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: now.UnixNano()})
+		card = pipe.ZCard(c.ctx, key)
+		pipe.Expire(c.ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return card.Val(), nil
+}
+
+// CountWindow 返回滑动窗口内的事件数
+func (c *Cache) CountWindow(key string, window time.Duration) (int64, error) {
+	min := fmt.Sprintf("%d", time.Now().Add(-window).UnixNano())
+	return c.client.ZCount(c.ctx, key, min, "+inf").Result()
+}
+
+// TTL 获取键的剩余过期时间（键不存在时返回负值）
+func (c *Cache) TTL(key string) (time.Duration, error) {
+	return c.client.TTL(c.ctx, key).Result()
+}
+
+// WindowLimit 滑动窗口及其事件数上限
+type WindowLimit struct {
+	Key string
+	Max int64
+}
+
+// WindowReservation ReserveWindow 的结果
+type WindowReservation struct {
+	BlockTTLs  []time.Duration // 有阻止键存在时为各阻止键的剩余时间（按传入顺序），否则为nil
+	Full       bool            // 是否有窗口已达上限
+	RetryAfter time.Duration   // 窗口已达上限时，最早的事件滑出窗口前的等待时间
+	Counts     []int64         // 预占成功时各窗口记录后的事件数（按传入顺序）
+}
+
+// Reserved 是否已预占成功
+func (r *WindowReservation) Reserved() bool {
+	return r.BlockTTLs == nil && !r.Full
+}
+
+// reserveWindowScript 原子地检查阻止键和窗口上限，全部通过时在每个窗口中记录一次事件
+// KEYS[1..n]=阻止键 KEYS[n+1..]=窗口键
+// ARGV[1]=阻止键个数n ARGV[2]=当前时间 ARGV[3]=窗口起点 ARGV[4]=窗口长度（毫秒） ARGV[5]=成员 ARGV[6..]=各窗口上限
+// 返回 {1, 各阻止键PTTL...} 表示被阻止键拒绝，{2, 等待纳秒} 表示窗口已满，{0, 各窗口事件数...} 表示已记录
+var reserveWindowScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local ttls = {1}
+local blocked = false
+for i = 1, n do
+	local ttl = redis.call('PTTL', KEYS[i])
+	if ttl > 0 then blocked = true end
+	ttls[#ttls + 1] = ttl
+end
+if blocked then
+	return ttls
+end
+local counts = {0}
+for i = n + 1, #KEYS do
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', ARGV[3])
+	local c = redis.call('ZCARD', KEYS[i])
+	if c >= tonumber(ARGV[5 + i - n]) then
+		local oldest = redis.call('ZRANGE', KEYS[i], 0, 0, 'WITHSCORES')
+		return {2, tostring(tonumber(oldest[2]) - tonumber(ARGV[3]))}
+	end
+	counts[#counts + 1] = c + 1
+end
+for i = n + 1, #KEYS do
+	redis.call('ZADD', KEYS[i], ARGV[2], ARGV[5])
+	redis.call('PEXPIRE', KEYS[i], ARGV[4])
+end
+return counts
+`)
+
+// ReserveWindow 原子地预占一次事件：任一阻止键存在或任一窗口已达上限时不记录，否则以member在每个窗口中各记录一次
+// 用于先占位再执行耗时操作的场景（并发请求不会同时通过上限检查），撤销预占使用 RemoveFromWindow
+func (c *Cache) ReserveWindow(blockKeys []string, windows []WindowLimit, window time.Duration, member string) (*WindowReservation, error) {
+	now := time.Now()
+	keys := append([]string{}, blockKeys...)
+	args := []interface{}{
+		len(blockKeys),
+		now.UnixNano(),
+		now.Add(-window).UnixNano(),
+		window.Milliseconds(),
+		member,
+	}
+	for _, w := range windows {
+		keys = append(keys, w.Key)
+		args = append(args, w.Max)
+	}
+
+	values, err := reserveWindowScript.Run(c.ctx, c.client, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	res := &WindowReservation{}
+	switch values[0].(int64) {
+	case 1:
+		res.BlockTTLs = make([]time.Duration, 0, len(values)-1)
+		for _, v := range values[1:] {
+			ms, _ := v.(int64)
+			res.BlockTTLs = append(res.BlockTTLs, time.Duration(ms)*time.Millisecond)
+		}
+	case 2:
+		s, _ := values[1].(string)
+		ns, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("解析窗口等待时间失败: %w", err)
+		}
+		res.Full, res.RetryAfter = true, time.Duration(ns)
+	default:
+		for _, v := range values[1:] {
+			n, _ := v.(int64)
+			res.Counts = append(res.Counts, n)
+		}
+	}
+	return res, nil
+}
+
+// RemoveFromWindow 从滑动窗口中删除ReserveWindow记录的事件（撤销预占）
+func (c *Cache) RemoveFromWindow(member string, keys ...string) error {
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.ZRem(c.ctx, key, member)
+		}
+		return nil
+	})
+	return err
+}
+
+// ============================================================
+// 带索引的有序集合
+// 有序集合的成员由调用方编码（例如把时间戳编进成员用于同分排序），
+// 另用一个哈希记录 id -> 当前成员，更新时原子地替换旧成员
+// ============================================================
+
+// ZMember 有序集合成员
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// IndexedMember 带索引的有序集合成员（ID为索引哈希中的字段）
+type IndexedMember struct {
+	ID     string
+	Member string
+	Score  float64
+}
+
+// ZAddIndexed 的写入条件（ID已有成员时与旧成员比较，不满足时不写入）
+const (
+	ZAddAlways   = ""   // 总是写入
+	ZAddGT       = "gt" // 新分数大于旧分数
+	ZAddLT       = "lt" // 新分数小于旧分数
+	ZAddMemberLE = "le" // 新成员按字典序不大于旧成员
+)
+
+// zAddIndexedScript 原子替换某个ID在有序集合中的成员（比较和写入在同一脚本中，并发写入时不会用旧值覆盖新值）
+// KEYS[1]=有序集合 KEYS[2]=索引哈希 ARGV[1]=ID ARGV[2]=分数 ARGV[3]=新成员 ARGV[4]=写入条件
+// 返回1表示已写入，0表示不满足条件
+var zAddIndexedScript = redis.NewScript(`
+local old = redis.call('HGET', KEYS[2], ARGV[1])
+if old then
+	local cur = tonumber(redis.call('ZSCORE', KEYS[1], old))
+	local score = tonumber(ARGV[2])
+	if cur and ((ARGV[4] == 'gt' and score <= cur) or (ARGV[4] == 'lt' and score >= cur)) then
+		return 0
+	end
+	if ARGV[4] == 'le' and ARGV[3] > old then
+		return 0
+	end
+	if old ~= ARGV[3] then
+		redis.call('ZREM', KEYS[1], old)
+	end
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+return 1
+`)
+
+// zRemIndexedScript 原子删除若干ID在有序集合中的成员
+// KEYS[1]=有序集合 KEYS[2]=索引哈希 ARGV=ID列表
+var zRemIndexedScript = redis.NewScript(`
+for _, id in ipairs(ARGV) do
+	local old = redis.call('HGET', KEYS[2], id)
+	if old then
+		redis.call('ZREM', KEYS[1], old)
+		redis.call('HDEL', KEYS[2], id)
+	end
+end
+return 1
+`)
+
+// ZAddIndexed 添加或替换ID对应的有序集合成员（cond为写入条件），返回是否已写入
+func (c *Cache) ZAddIndexed(zsetKey, indexKey string, m IndexedMember, cond string) (bool, error) {
+	n, err := zAddIndexedScript.Run(c.ctx, c.client, []string{zsetKey, indexKey}, m.ID, m.Score, m.Member, cond).Int()
+	return n == 1, err
+}
+
+// ZRemIndexed 删除ID对应的有序集合成员
+func (c *Cache) ZRemIndexed(zsetKey, indexKey string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return zRemIndexedScript.Run(c.ctx, c.client, []string{zsetKey, indexKey}, args...).Err()
+}
+
+// ZAddIndexedBatch 批量写入成员（不检查旧成员，用于向空的临时键中重建数据）
+func (c *Cache) ZAddIndexedBatch(zsetKey, indexKey string, members []IndexedMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	zs := make([]*redis.Z, len(members))
+	fields := make([]interface{}, 0, len(members)*2)
+	for i, m := range members {
+		zs[i] = &redis.Z{Score: m.Score, Member: m.Member}
+		fields = append(fields, m.ID, m.Member)
+	}
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(c.ctx, zsetKey, zs...)
+		pipe.HSet(c.ctx, indexKey, fields...)
+		return nil
+	})
+	return err
+}
+
+// ZRevRangeWithScores 按分数从高到低获取排名区间内的成员（start/stop从0开始，包含两端）
+func (c *Cache) ZRevRangeWithScores(key string, start, stop int64) ([]ZMember, error) {
+	zs, err := c.client.ZRevRangeWithScores(c.ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ZMember, len(zs))
+	for i, z := range zs {
+		member, _ := z.Member.(string)
+		members[i] = ZMember{Member: member, Score: z.Score}
+	}
+	return members, nil
+}
+
+// IndexedRank ID在有序集合中的位置
+type IndexedRank struct {
+	Rank   int64 // 按分数从高到低的排名（从0开始）
+	Member string
+	Score  float64
+	Total  int64 // 有序集合的成员总数
+}
+
+// zRevRankIndexedScript 原子查询ID对应成员的排名、分数和集合大小
+// KEYS[1]=有序集合 KEYS[2]=索引哈希 ARGV[1]=ID；ID不存在时返回空
+var zRevRankIndexedScript = redis.NewScript(`
+local member = redis.call('HGET', KEYS[2], ARGV[1])
+if not member then
+	return false
+end
+local rank = redis.call('ZREVRANK', KEYS[1], member)
+if not rank then
+	return false
+end
+local score = redis.call('ZSCORE', KEYS[1], member)
+return {rank, member, score, redis.call('ZCARD', KEYS[1])}
+`)
+
+// ZRevRankIndexed 查询ID对应成员的排名（按分数从高到低），ID不存在时返回nil
+func (c *Cache) ZRevRankIndexed(zsetKey, indexKey, id string) (*IndexedRank, error) {
+	res, err := zRevRankIndexedScript.Run(c.ctx, c.client, []string{zsetKey, indexKey}, id).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("排名查询结果格式错误: %v", res)
+	}
+	rank, _ := values[0].(int64)
+	member, _ := values[1].(string)
+	scoreText, _ := values[2].(string)
+	score, err := strconv.ParseFloat(scoreText, 64)
+	if err != nil {
+		return nil, err
+	}
+	total, _ := values[3].(int64)
+	return &IndexedRank{Rank: rank, Member: member, Score: score, Total: total}, nil
+}
+
+// ZCard 有序集合的成员数
+func (c *Cache) ZCard(key string) (int64, error) {
+	return c.client.ZCard(c.ctx, key).Result()
+}
+
+// replaceKeysScript 原子地用新键替换目标键，新键不存在（没有数据）时删除目标键
+// KEYS=新键1, 目标键1, 新键2, 目标键2, ...
+var replaceKeysScript = redis.NewScript(`
+for i = 1, #KEYS, 2 do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		redis.call('RENAME', KEYS[i], KEYS[i + 1])
+	else
+		redis.call('DEL', KEYS[i + 1])
+	end
+end
+return 1
+`)
+
+// ReplaceKeys 原子地用新键替换目标键（pairs为 [新键, 目标键]），新键不存在时删除目标键
+func (c *Cache) ReplaceKeys(pairs ...[2]string) error {
+	keys := make([]string, 0, len(pairs)*2)
+	for _, p := range pairs {
+		keys = append(keys, p[0], p[1])
+	}
+	return replaceKeysScript.Run(c.ctx, c.client, keys).Err()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -51,19 +52,141 @@ type AIConfig struct {
 }
 
 type JWTConfig struct {
-	Secret      string `yaml:"secret"`       // JWT 签名密钥
-	ExpireHours int    `yaml:"expire_hours"` // Token 过期时间（小时）
+	Secret       string         `yaml:"secret"`         // HS256 签名密钥
+	ExpireHours  int            `yaml:"expire_hours"`   // Token 过期时间（小时）
+	Algorithm    string         `yaml:"algorithm"`      // 签名算法：HS256（默认）/RS256/EdDSA
+	Issuer       string         `yaml:"issuer"`         // Token的iss声明（为空时不设置也不校验）
+	SigningKeyID string         `yaml:"signing_key_id"` // 当前用于签名的密钥kid（RS256/EdDSA）
+	Keys         []JWTKeyConfig `yaml:"keys"`           // 密钥列表（RS256/EdDSA），全部用于验证，轮换时保留旧密钥直到其签发的Token过期
+}
+
+// JWTKeyConfig JWT非对称密钥配置（PEM文件）
+type JWTKeyConfig struct {
+	ID             string `yaml:"id"`               // kid
+	PrivateKeyFile string `yaml:"private_key_file"` // 私钥（PKCS#8，RSA也支持PKCS#1），签名密钥必须提供
+	PublicKeyFile  string `yaml:"public_key_file"`  // 公钥（PKIX），仅用于验证的旧密钥可只提供公钥
+}
+
+// LoginConfig 登录防暴力破解配置
+type LoginConfig struct {
+	WindowMinutes        int `yaml:"window_minutes"`         // 失败次数统计的滑动窗口（分钟）
+	MaxFailuresPerUser   int `yaml:"max_failures_per_user"`  // 同一用户名窗口内最大失败次数，超过后锁定
+	MaxFailuresPerIP     int `yaml:"max_failures_per_ip"`    // 同一IP窗口内最大失败次数，超过后锁定
+	DelayAfterFailures   int `yaml:"delay_after_failures"`   // 失败多少次后开始指数退避
+	BaseDelaySeconds     int `yaml:"base_delay_seconds"`     // 退避基础时长（秒），每次失败翻倍
+	MaxDelaySeconds      int `yaml:"max_delay_seconds"`      // 退避最大时长（秒）
+	LockoutMinutes       int `yaml:"lockout_minutes"`        // 锁定时长（分钟）
+	CaptchaAfterFailures int `yaml:"captcha_after_failures"` // 失败多少次后要求验证码（0表示不启用）
+}
+
+// PasswordConfig 密码策略配置
+type PasswordConfig struct {
+	MinLength      int      `yaml:"min_length"`       // 最小长度
+	MaxLength      int      `yaml:"max_length"`       // 最大长度（字节）
+	MinCharClasses int      `yaml:"min_char_classes"` // 至少包含的字符类别数（小写/大写/数字/符号）
+	CheckUsername  bool     `yaml:"check_username"`   // 是否禁止与用户名相似
+	Blacklist      []string `yaml:"blacklist"`        // 额外的弱密码黑名单
+}
+
+// HashingConfig 密码哈希配置
+type HashingConfig struct {
+	Algorithm         string `yaml:"algorithm"`          // 哈希算法：bcrypt/argon2id
+	BcryptCost        int    `yaml:"bcrypt_cost"`        // bcrypt 代价因子
+	Argon2MemoryKB    uint32 `yaml:"argon2_memory_kb"`   // argon2id 内存（KiB）
+	Argon2Iterations  uint32 `yaml:"argon2_iterations"`  // argon2id 迭代次数
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism"` // argon2id 并行度
+	Workers           int    `yaml:"workers"`            // 同时进行哈希计算的最大数量（0表示CPU核数）
+}
+
+// GuestConfig 游客账号配置
+type GuestConfig struct {
+	InactiveDays         int `yaml:"inactive_days"`          // 游客多少天不活跃后被清理
+	CleanupIntervalHours int `yaml:"cleanup_interval_hours"` // 清理任务执行间隔（小时）
+}
+
+// StorageConfig 文件存储配置
+type StorageConfig struct {
+	LocalDir string `yaml:"local_dir"` // 本地存储根目录（头像等上传文件）
+}
+
+// ProfileConfig 玩家资料配置
+type ProfileConfig struct {
+	AvatarPresets int      `yaml:"avatar_presets"` // 预设头像数量（preset_1 ~ preset_N，0表示默认12个）
+	AvatarHosts   []string `yaml:"avatar_hosts"`   // 资料中允许引用的外部头像图片主机（只允许https）
+}
+
+// AdminConfig 管理后台配置
+type AdminConfig struct {
+	BootstrapUsernames []string `yaml:"bootstrap_usernames"` // 启动时授予管理员角色的用户名（用于初始化第一个管理员）
+}
+
+// AccountConfig 账号注销配置
+type AccountConfig struct {
+	DeletionGraceDays            int `yaml:"deletion_grace_days"`             // 申请注销后保留账号的天数（期间重新登录可撤销）
+	DeletionCheckIntervalMinutes int `yaml:"deletion_check_interval_minutes"` // 删除到期账号的任务执行间隔（分钟）
+}
+
+// RankingConfig 排行榜配置
+type RankingConfig struct {
+	Timezone                string                 `yaml:"timezone"`                  // 日榜/周榜/月榜划分周期使用的时区（如 Asia/Shanghai，默认服务器时区）
+	PeriodRetentionDays     int                    `yaml:"period_retention_days"`     // 日榜/周榜/月榜数据在周期结束后保留的天数
+	SubmissionRetentionDays int                    `yaml:"submission_retention_days"` // 成绩提交记录保留的天数
+	Season                  RankingSeasonConfig    `yaml:"season"`
+	AntiCheat               RankingAntiCheatConfig `yaml:"anti_cheat"`
+}
+
+// RankingAntiCheatConfig 成绩提交校验配置（每种排行榜的分数上限和增幅上限在排行榜定义中设置）
+type RankingAntiCheatConfig struct {
+	RequireSignature    bool   `yaml:"require_signature"`     // 是否要求提交成绩时携带对局签名（对局由 POST /api/rankings/runs 签发）
+	SigningSecret       string `yaml:"signing_secret"`        // 对局签名密钥（只保存在服务端，可用环境变量 RANKING_SIGNING_SECRET 覆盖）
+	RunTTLMinutes       int    `yaml:"run_ttl_minutes"`       // 对局的有效期（分钟）
+	SubmitLimit         int    `yaml:"submit_limit"`          // 每个用户在窗口内最多提交的次数（0表示不限制）
+	SubmitWindowSeconds int    `yaml:"submit_window_seconds"` // 提交次数限制的窗口（秒）
+}
+
+// RankingSeasonConfig 赛季配置
+type RankingSeasonConfig struct {
+	FirstStart           string `yaml:"first_start"`            // 第一个赛季的开始时间（RFC3339，为空时不启用赛季）
+	LengthDays           int    `yaml:"length_days"`            // 每个赛季的天数，赛季结束后自动开始下一赛季
+	ArchiveTop           int    `yaml:"archive_top"`            // 赛季结束时归档前多少名的最终排名
+	CheckIntervalMinutes int    `yaml:"check_interval_minutes"` // 检查赛季结束的任务执行间隔（分钟）
+}
+
+// OIDCConfig 第三方登录（OpenID Connect）配置
+type OIDCConfig struct {
+	Providers         []OIDCProviderConfig `yaml:"providers"`
+	AllowedReturnURLs []string             `yaml:"allowed_return_urls"` // 登录完成后允许跳转回的客户端地址前缀（Token通过URL片段传递）
+}
+
+// OIDCProviderConfig 身份提供方配置
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`          // 名称（出现在登录路由中，如 google）
+	Issuer       string   `yaml:"issuer"`        // issuer地址（需支持服务发现）
+	ClientID     string   `yaml:"client_id"`     // 客户端ID
+	ClientSecret string   `yaml:"client_secret"` // 客户端密钥（可用环境变量 OIDC_<NAME>_CLIENT_SECRET 覆盖）
+	RedirectURL  string   `yaml:"redirect_url"`  // 回调地址，如 http://localhost:8080/api/auth/oidc/google/callback
+	Scopes       []string `yaml:"scopes"`        // 申请的scope（默认 openid profile email）
 }
 
 // Config 应用总配置
 type Config struct {
-	App      App           `yaml:"app"`
-	Database DBConfig      `yaml:"database"`
-	Redis    RedisConfig   `yaml:"redis"`
-	Message  MessageConfig `yaml:"message"`
-	Server   ServerConfig  `yaml:"server"`
-	AI       AIConfig      `yaml:"ai"`
-	JWT      JWTConfig     `yaml:"jwt"`
+	App      App            `yaml:"app"`
+	Database DBConfig       `yaml:"database"`
+	Redis    RedisConfig    `yaml:"redis"`
+	Message  MessageConfig  `yaml:"message"`
+	Server   ServerConfig   `yaml:"server"`
+	AI       AIConfig       `yaml:"ai"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Login    LoginConfig    `yaml:"login"`
+	Password PasswordConfig `yaml:"password"`
+	Hashing  HashingConfig  `yaml:"hashing"`
+	Guest    GuestConfig    `yaml:"guest"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Profile  ProfileConfig  `yaml:"profile"`
+	Admin    AdminConfig    `yaml:"admin"`
+	Account  AccountConfig  `yaml:"account"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
+	Ranking  RankingConfig  `yaml:"ranking"`
 }
 
 // GlobalConfig 全局配置实例
@@ -125,12 +248,39 @@ func overrideWithEnv() {
 	if v := os.Getenv("JWT_SECRET"); v != "" {
 		GlobalConfig.JWT.Secret = v
 	}
+	if v := os.Getenv("JWT_ALGORITHM"); v != "" {
+		GlobalConfig.JWT.Algorithm = v
+	}
+	if v := os.Getenv("JWT_SIGNING_KEY_ID"); v != "" {
+		GlobalConfig.JWT.SigningKeyID = v
+	}
 
 	// AI配置
 	if v := os.Getenv("HUNYUAN_API_KEY"); v != "" {
 		GlobalConfig.AI.APIKey = v
 	}
 
+	// 服务与存储配置
+	if v := os.Getenv("PUBLIC_BASE_URL"); v != "" {
+		GlobalConfig.Server.PublicBaseURL = v
+	}
+	if v := os.Getenv("STORAGE_LOCAL_DIR"); v != "" {
+		GlobalConfig.Storage.LocalDir = v
+	}
+
+	// 排行榜对局签名密钥
+	if v := os.Getenv("RANKING_SIGNING_SECRET"); v != "" {
+		GlobalConfig.Ranking.AntiCheat.SigningSecret = v
+	}
+
+	// 第三方登录客户端密钥
+	for i := range GlobalConfig.OIDC.Providers {
+		p := &GlobalConfig.OIDC.Providers[i]
+		if v := os.Getenv("OIDC_" + strings.ToUpper(p.Name) + "_CLIENT_SECRET"); v != "" {
+			p.ClientSecret = v
+		}
+	}
+
 	// 应用配置
 	if v := os.Getenv("APP_ENV"); v != "" {
 		GlobalConfig.App.Environment = v
@@ -2,6 +2,7 @@ package db
 
 import (
 	"faulty_in_culture/go_back/internal/chat"
+	"faulty_in_culture/go_back/internal/friend"
 	"faulty_in_culture/go_back/internal/infra/config"
 	"faulty_in_culture/go_back/internal/infra/logger"
 	"faulty_in_culture/go_back/internal/ranking"
@@ -53,10 +54,27 @@ func InitDatabase() error {
 	// 自动迁移（简化MVC架构 - 使用各domain的Entity）
 	err = DB.AutoMigrate(
 		&user.Entity{},
+		&user.DeviceSession{},
+		&user.PasswordResetToken{},
+		&user.Profile{},
+		&user.Ban{},
+		&user.Identity{},
 		&ranking.Entity{},
+		&ranking.Board{},
+		&ranking.Season{},
+		&ranking.PeriodScore{},
+		&ranking.SeasonStanding{},
+		&ranking.Submission{},
+		&ranking.Run{},
 		&savegame.Entity{},
 		&chat.Session{},
 		&chat.Message{},
+		&chat.Participant{},
+		&chat.Room{},
+		&chat.RoomRestriction{},
+		&friend.Request{},
+		&friend.Friendship{},
+		&friend.Block{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
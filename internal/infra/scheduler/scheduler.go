@@ -0,0 +1,81 @@
+// Package scheduler 提供进程内的定时任务调度
+// 功能：按固定间隔执行后台任务（清理过期数据等），单个任务panic不影响其他任务
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+
+	"go.uber.org/zap"
+)
+
+// job 定时任务
+type job struct {
+	name     string
+	interval time.Duration
+	fn       func()
+}
+
+// Scheduler 定时任务调度器
+type Scheduler struct {
+	jobs []job
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New 创建调度器
+func New() *Scheduler {
+	return &Scheduler{stop: make(chan struct{})}
+}
+
+// Every 注册一个按固定间隔执行的任务（需在Start之前调用）
+func (s *Scheduler) Every(name string, interval time.Duration, fn func()) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start 启动所有任务（每个任务一个goroutine，启动后先等待一个间隔再执行）
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+	logger.Info("定时任务已启动", zap.Int("jobs", len(s.jobs)))
+}
+
+// Stop 停止所有任务并等待正在执行的任务结束
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// loop 任务循环
+func (s *Scheduler) loop(j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.run(j)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// run 执行一次任务（捕获panic）
+func (s *Scheduler) run(j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("定时任务执行异常", zap.String("job", j.name), zap.Any("panic", r))
+		}
+	}()
+
+	start := time.Now()
+	j.fn()
+	logger.Debug("定时任务执行完成", zap.String("job", j.name), zap.Duration("cost", time.Since(start)))
+}
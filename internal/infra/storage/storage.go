@@ -0,0 +1,110 @@
+// Package storage 提供文件（Blob）存储抽象
+// 功能：统一的存取接口，当前实现本地文件系统存储，后续可扩展S3兼容存储
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage 文件存储接口
+type Storage interface {
+	// Put 写入文件（key为相对路径，如 avatars/1/abc.png）
+	Put(key string, r io.Reader, contentType string) error
+	// Delete 删除文件（文件不存在时不报错）
+	Delete(key string) error
+	// URL 返回文件的公开访问地址
+	URL(key string) string
+}
+
+// LocalStorage 本地文件系统存储
+type LocalStorage struct {
+	root    string // 存储根目录
+	baseURL string // 公开访问前缀，如 http://localhost:8080/api/files
+}
+
+// NewLocalStorage 创建本地存储实例（自动创建根目录）
+func NewLocalStorage(root, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// Put 写入文件（先写临时文件再重命名，避免读到写了一半的文件）
+func (s *LocalStorage) Put(key string, r io.Reader, contentType string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("设置文件权限失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+// Delete 删除文件
+func (s *LocalStorage) Delete(key string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// URL 返回文件的公开访问地址
+func (s *LocalStorage) URL(key string) string {
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// Handler 返回提供文件下载的HTTP处理器（不列出目录）
+func (s *LocalStorage) Handler() http.Handler {
+	fileServer := http.FileServer(http.Dir(s.root))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// path 将key转换为本地路径（拒绝绝对路径和目录穿越）
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("非法的存储key: %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
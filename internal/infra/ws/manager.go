@@ -15,16 +15,26 @@ import (
 
 // Client WebSocket客户端连接
 type Client struct {
-	UserID uint
-	Conn   *websocket.Conn
-	Send   chan []byte // 发送消息通道
-	mu     sync.Mutex
+	UserID   uint
+	Conn     *websocket.Conn
+	Send     chan []byte // 发送消息通道
+	mu       sync.Mutex
+	kick     chan []byte // 强制断开通道（携带断开前的最后一条消息）
+	kickOnce sync.Once
 }
 
+// AdmissionFunc 连接准入检查（返回错误时拒绝连接，如用户被禁言）
+type AdmissionFunc func(userID uint) error
+
+// PresenceFunc 在线状态变化回调（用户的第一个连接建立时online为true，最后一个连接断开时为false）
+type PresenceFunc func(userID uint, online bool)
+
 // Manager WebSocket连接管理器
 type Manager struct {
-	clients map[uint][]*Client // userID -> clients
-	mu      sync.RWMutex
+	clients   map[uint][]*Client // userID -> clients
+	mu        sync.RWMutex
+	admission AdmissionFunc
+	presence  PresenceFunc
 }
 
 // NewManager 创建WebSocket管理器
@@ -34,27 +44,70 @@ func NewManager() *Manager {
 	}
 }
 
-// Register 注册新客户端
-func (m *Manager) Register(userID uint, conn *websocket.Conn) *Client {
+// SetAdmission 设置连接准入检查
+func (m *Manager) SetAdmission(fn AdmissionFunc) {
+	m.admission = fn
+}
+
+// SetPresenceHandler 设置在线状态变化回调（在独立goroutine中调用，不阻塞连接注册和注销）
+func (m *Manager) SetPresenceHandler(fn PresenceFunc) {
+	m.presence = fn
+}
+
+// Register 注册新客户端（未通过准入检查时返回错误，连接由调用方关闭）
+func (m *Manager) Register(userID uint, conn *websocket.Conn) (*Client, error) {
+	if m.admission != nil {
+		if err := m.admission(userID); err != nil {
+			logger.Warn("WebSocket连接被拒绝", zap.Uint("user_id", userID), zap.Error(err))
+			return nil, err
+		}
+	}
+
 	client := &Client{
 		UserID: userID,
 		Conn:   conn,
 		Send:   make(chan []byte, 256),
+		kick:   make(chan []byte, 1),
 	}
 
 	m.mu.Lock()
 	m.clients[userID] = append(m.clients[userID], client)
+	cameOnline := len(m.clients[userID]) == 1
 	m.mu.Unlock()
 
 	logger.Info("WebSocket客户端已连接", zap.Uint("user_id", userID))
-	return client
+	if cameOnline {
+		m.notifyPresence(userID, true)
+	}
+	return client, nil
+}
+
+// KickUser 强制断开用户的所有连接（先推送message再发送关闭帧），返回断开的连接数
+func (m *Manager) KickUser(userID uint, message interface{}) int {
+	data, err := json.Marshal(message)
+	if err != nil {
+		logger.Error("WebSocket断开消息序列化失败", zap.Uint("user_id", userID), zap.Error(err))
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := m.clients[userID]
+	for _, client := range clients {
+		client.kickOnce.Do(func() {
+			client.kick <- data
+		})
+	}
+
+	if len(clients) > 0 {
+		logger.Info("WebSocket客户端被强制断开", zap.Uint("user_id", userID), zap.Int("count", len(clients)))
+	}
+	return len(clients)
 }
 
 // Unregister 注销客户端
 func (m *Manager) Unregister(client *Client) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	clients := m.clients[client.UserID]
 	for i, c := range clients {
 		if c == client {
@@ -63,12 +116,34 @@ func (m *Manager) Unregister(client *Client) {
 		}
 	}
 
+	wentOffline := false
 	if len(m.clients[client.UserID]) == 0 {
 		delete(m.clients, client.UserID)
+		wentOffline = true
 	}
 
 	close(client.Send)
+	m.mu.Unlock()
+
 	logger.Info("WebSocket客户端已断开", zap.Uint("user_id", client.UserID))
+	if wentOffline {
+		m.notifyPresence(client.UserID, false)
+	}
+}
+
+// notifyPresence 异步通知在线状态变化
+func (m *Manager) notifyPresence(userID uint, online bool) {
+	if m.presence == nil {
+		return
+	}
+	go m.presence(userID, online)
+}
+
+// IsOnline 用户是否有活跃的WebSocket连接
+func (m *Manager) IsOnline(userID uint) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.clients[userID]) > 0
 }
 
 // SendToUser 向指定用户的所有客户端推送消息
@@ -128,6 +203,15 @@ func (c *Client) WritePump() {
 			if err := w.Close(); err != nil {
 				return
 			}
+		case message := <-c.kick:
+			// 被强制断开：推送最后一条消息后发送关闭帧
+			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if message != nil {
+				c.Conn.WriteMessage(websocket.TextMessage, message)
+			}
+			c.Conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "kicked"))
+			return
 		case <-ticker.C:
 			// 发送心跳
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
@@ -0,0 +1,357 @@
+// Package ranking - 成绩提交校验
+// 功能：提交频率限制、对局签名校验（每局签发一次性的对局密钥）、分数上下限和增幅上限检查
+// 特点：超出分数上下限/增幅上限或重复提交同一局的成绩被隔离，不计入排行榜，由管理员审核后再计入
+package ranking
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 对局签名
+// 1. 开始一局游戏时调用 POST /api/rankings/runs 获取 run_id 和 run_key
+//    run_key = hex(HMAC-SHA256(签名密钥, "run_id:nonce"))，签名密钥（ranking.anti_cheat.signing_secret）只保存在服务端
+// 2. 提交成绩时 signature = hex(HMAC-SHA256(run_key, "run_id:rank_type:score"))
+// 3. 每个对局只能提交一次，过期后不能再提交
+// 签名只保证成绩对应服务端签发的、未过期且未使用过的对局（不能跨用户、跨排行榜或重复使用），
+// run_key 由客户端持有，不能证明分数真实；防作弊依赖分数上下限、增幅上限检查和隔离审核
+// ============================================================
+
+// 校验相关常量
+const (
+	defaultRunTTL       = 2 * time.Hour
+	defaultSubmitWindow = time.Minute
+	defaultDeltaWindow  = time.Hour
+	runRetention        = 24 * time.Hour // 对局过期后保留的时间（便于排查）
+	defaultScoreCap     = 1000000        // 未设置分数上限的排行榜，超过该分数的提交被隔离
+	defaultScoreFloor   = 1              // 分数越低越好且未设置分数下限的排行榜，低于该分数的提交被隔离
+)
+
+// WindowCounter 滑动窗口计数接口（由Redis缓存实现）
+type WindowCounter interface {
+	AddToWindow(key string, window time.Duration) (int64, error)
+}
+
+// AntiCheatConfig 成绩提交校验配置
+type AntiCheatConfig struct {
+	RequireSignature bool          // 是否要求对局签名
+	SigningSecret    string        // 对局签名密钥（只保存在服务端，用于派生每局的run_key）
+	RunTTL           time.Duration // 对局有效期
+	SubmitLimit      int           // 每个用户在窗口内最多提交的次数（0表示不限制）
+	SubmitWindow     time.Duration // 提交次数限制的窗口
+}
+
+// SetAntiCheat 设置成绩提交校验（counter为nil时不限制提交频率）
+// 要求签名但未配置签名密钥时返回错误，拒绝启动；不要求签名时使用进程内的随机密钥（重启后已签发的对局不能再签名提交）
+func (s *Service) SetAntiCheat(cfg AntiCheatConfig, counter WindowCounter) error {
+	if cfg.SigningSecret == "" {
+		if cfg.RequireSignature {
+			return errors.New("要求对局签名时必须配置签名密钥（ranking.anti_cheat.signing_secret 或环境变量 RANKING_SIGNING_SECRET）")
+		}
+		secret, err := randomHex(32)
+		if err != nil {
+			return err
+		}
+		cfg.SigningSecret = secret
+		logger.Warn("[ranking.SetAntiCheat] 未配置对局签名密钥，使用进程内的随机密钥")
+	}
+	if cfg.RunTTL <= 0 {
+		cfg.RunTTL = defaultRunTTL
+	}
+	if cfg.SubmitWindow <= 0 {
+		cfg.SubmitWindow = defaultSubmitWindow
+	}
+	s.antiCheat = cfg
+	s.counter = counter
+	return nil
+}
+
+// IssueRun 开始一局游戏，签发对局ID和签名用的对局密钥
+func (s *Service) IssueRun(userID uint, rankType int) (*RunVO, error) {
+	def := s.boardDef(rankType)
+	if def == nil {
+		return nil, errcode.New(errcode.InvalidRankType)
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	ttl := s.antiCheat.RunTTL
+	if ttl <= 0 {
+		ttl = defaultRunTTL
+	}
+	run := &Run{
+		ID:        id,
+		UserID:    userID,
+		RankType:  def.ID,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.repo.CreateRun(run); err != nil {
+		logger.Error("[ranking.IssueRun] 创建对局失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	return &RunVO{
+		RunID:     run.ID,
+		RunKey:    s.runKey(run),
+		RankType:  run.RankType,
+		ExpiresAt: run.ExpiresAt,
+	}, nil
+}
+
+// checkSubmitRate 检查提交频率（计数失败时放行）
+func (s *Service) checkSubmitRate(userID uint) error {
+	if s.counter == nil || s.antiCheat.SubmitLimit <= 0 {
+		return nil
+	}
+	count, err := s.counter.AddToWindow(fmt.Sprintf("ranking:submit:%d", userID), s.antiCheat.SubmitWindow)
+	if err != nil {
+		logger.Error("[ranking.checkSubmitRate] 提交频率计数失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil
+	}
+	if count > int64(s.antiCheat.SubmitLimit) {
+		logger.Warn("[ranking.checkSubmitRate] 提交成绩过于频繁",
+			zap.Uint("user_id", userID),
+			zap.Int64("count", count))
+		return errcode.New(errcode.SubmitTooFrequent)
+	}
+	return nil
+}
+
+// verifyRun 校验对局签名并标记对局已使用，返回拒绝原因（为空表示通过或无需校验）
+func (s *Service) verifyRun(def *Board, sub *Submission, signature string) (string, error) {
+	if signature == "" && !s.antiCheat.RequireSignature {
+		return "", nil
+	}
+	if sub.RunID == "" || signature == "" {
+		return "missing_signature", errcode.New(errcode.SignatureInvalid)
+	}
+
+	run, err := s.repo.FindRun(sub.RunID)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if run == nil || run.UserID != sub.UserID || run.RankType != def.ID || run.UsedAt != nil || !now.Before(run.ExpiresAt) {
+		return "invalid_run", errcode.New(errcode.RunInvalid)
+	}
+	if !hmac.Equal([]byte(signScore(s.runKey(run), run.ID, def.ID, sub.Score)), []byte(signature)) {
+		return "bad_signature", errcode.New(errcode.SignatureInvalid)
+	}
+
+	// 并发提交同一局时只有一次成功
+	used, err := s.repo.UseRun(run.ID, now)
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "invalid_run", errcode.New(errcode.RunInvalid)
+	}
+	sub.Signed = true
+	return "", nil
+}
+
+// runKey 派生对局密钥（签发时返回给客户端，校验时重新计算，不保存）
+func (s *Service) runKey(run *Run) string {
+	mac := hmac.New(sha256.New, []byte(s.antiCheat.SigningSecret))
+	mac.Write([]byte(run.ID + ":" + run.Nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signScore 计算成绩签名
+func signScore(runKey, runID string, rankType, score int) string {
+	mac := hmac.New(sha256.New, []byte(runKey))
+	mac.Write([]byte(runID + ":" + strconv.Itoa(rankType) + ":" + strconv.Itoa(score)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// plausibilityFlags 检查分数上下限和窗口内的增幅上限（查询失败时不标记；签名的提交同样检查）
+// 增幅相对窗口开始前最后一次计入排行榜后的成绩计算；窗口开始前没有计入的提交（如提交记录表之前的成绩）时
+// 相对当前成绩计算，没有成绩时不检查增幅
+func (s *Service) plausibilityFlags(def *Board, sub *Submission, prev *Entity) []string {
+	var flags []string
+	if scoreOutOfRange(def, sub.Score) {
+		flags = append(flags, FlagScoreLimit)
+	}
+	if def.MaxDelta <= 0 {
+		return flags
+	}
+
+	window := time.Duration(def.DeltaWindowMinutes) * time.Minute
+	if window <= 0 {
+		window = defaultDeltaWindow
+	}
+	base, found, err := s.repo.BestScoreBefore(sub.UserID, def.ID, time.Now().Add(-window))
+	if err != nil {
+		logger.Error("[ranking.plausibilityFlags] 查询窗口开始时的成绩失败", zap.Uint("user_id", sub.UserID), zap.Error(err))
+		return flags
+	}
+
+	if !found {
+		if prev == nil {
+			return flags
+		}
+		base = prev.Score
+	}
+
+	var gain int
+	switch {
+	case def.Aggregation == AggregateSum:
+		current := 0
+		if prev != nil {
+			current = prev.Score
+		}
+		gain = current + sub.Score - base
+	case def.Aggregation == AggregateMin || def.Ascending():
+		gain = base - sub.Score
+	default:
+		gain = sub.Score - base
+	}
+	if gain > def.MaxDelta {
+		flags = append(flags, FlagDeltaLimit)
+	}
+	return flags
+}
+
+// scoreOutOfRange 分数是否超出排行榜的上限，或低于分数越低越好的排行榜的下限（未设置时使用默认值）
+func scoreOutOfRange(def *Board, score int) bool {
+	ceiling := def.MaxScore
+	if ceiling <= 0 {
+		ceiling = defaultScoreCap
+	}
+	if score > ceiling {
+		return true
+	}
+	if !def.Ascending() {
+		return false
+	}
+	floor := def.MinScore
+	if floor <= 0 {
+		floor = defaultScoreFloor
+	}
+	return score < floor
+}
+
+// shouldQuarantine 是否有需要隔离的标记
+func shouldQuarantine(flags []string) bool {
+	for _, f := range flags {
+		if quarantineFlags[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminReviewSubmission 审核隔离的提交：通过后按提交时间计入总榜、赛季榜和日榜/周榜/月榜，拒绝后不计入
+func (s *Service) AdminReviewSubmission(operatorID, id uint, approve bool) (*AdminSubmissionVO, error) {
+	sub, err := s.repo.FindSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, errcode.New(errcode.SubmissionNotFound)
+	}
+	if sub.Status != SubmissionQuarantined {
+		return nil, errcode.New(errcode.SubmissionReviewed)
+	}
+	def := s.boardDef(sub.RankType)
+	if def == nil {
+		return nil, errcode.New(errcode.InvalidRankType)
+	}
+
+	status := SubmissionRejected
+	if approve {
+		status = SubmissionAccepted
+	}
+	now := time.Now()
+	ok, err := s.repo.ReviewSubmission(id, status, operatorID, now)
+	if err != nil {
+		logger.Error("[ranking.AdminReviewSubmission] 更新提交记录失败", zap.Uint("submission_id", id), zap.Error(err))
+		return nil, err
+	}
+	if !ok {
+		return nil, errcode.New(errcode.SubmissionReviewed)
+	}
+	sub.Status = status
+	sub.ReviewedBy = operatorID
+	sub.ReviewedAt = &now
+
+	if approve {
+		// 赛季已归档时不再计入赛季榜
+		var season *Season
+		if sub.SeasonID != 0 {
+			if season, err = s.repo.FindSeason(sub.SeasonID); err != nil {
+				logger.Error("[ranking.AdminReviewSubmission] 查询赛季失败", zap.Uint("season_id", sub.SeasonID), zap.Error(err))
+				season = nil
+			} else if season != nil && season.Status == SeasonStatusClosed {
+				season = nil
+			}
+		}
+		applied, err := s.applySubmission(def, sub, season, sub.CreatedAt)
+		if err != nil {
+			if restoreErr := s.repo.SetSubmissionStatus(id, SubmissionQuarantined); restoreErr != nil {
+				logger.Error("[ranking.AdminReviewSubmission] 恢复隔离状态失败", zap.Uint("submission_id", id), zap.Error(restoreErr))
+			}
+			return nil, errcode.New(errcode.UpdateScoreFailed)
+		}
+		sub.BestScore = applied.Ranking.Score
+		if err := s.repo.UpdateSubmissionBest(id, sub.BestScore); err != nil {
+			logger.Error("[ranking.AdminReviewSubmission] 更新提交后的成绩失败", zap.Uint("submission_id", id), zap.Error(err))
+		}
+	}
+
+	logger.Warn("[audit] 审核隔离的成绩",
+		zap.String("event", "ranking_submission_reviewed"),
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("submission_id", id),
+		zap.Uint("user_id", sub.UserID),
+		zap.Int("rank_type", sub.RankType),
+		zap.Int("score", sub.Score),
+		zap.String("status", status))
+
+	briefs, err := s.userService.GetUsernames([]uint{sub.UserID})
+	if err != nil {
+		logger.Error("[ranking.AdminReviewSubmission] 获取用户名失败", zap.Error(err))
+	}
+	vo := toAdminSubmissionVO(sub, briefs[sub.UserID].Username)
+	return &vo, nil
+}
+
+// PurgeExpiredRuns 清理过期的对局（由定时任务调用）
+func (s *Service) PurgeExpiredRuns() {
+	count, err := s.repo.DeleteRunsBefore(time.Now().Add(-runRetention))
+	if err != nil {
+		logger.Error("[ranking.PurgeExpiredRuns] 清理过期对局失败", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		logger.Info("[ranking.PurgeExpiredRuns] 已清理过期对局", zap.Int64("count", count))
+	}
+}
+
+// randomHex 生成n字节的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
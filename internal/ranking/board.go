@@ -0,0 +1,308 @@
+// Package ranking - 排行榜定义
+// 功能：管理排行榜定义（标识、名称、排序方向、聚合方式、可见性），排行榜的读写都按定义进行
+// 特点：定义保存在 ranking_boards 表，各实例在内存中缓存，管理员修改后最多延迟一分钟在其他实例生效
+package ranking
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// boardCacheTTL 排行榜定义的缓存时间
+const boardCacheTTL = time.Minute
+
+// boardKeyPattern 排行榜标识格式（字母开头，避免与数字ID混淆）
+var boardKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// reservedBoardKeys 与排行榜路由冲突的标识
+var reservedBoardKeys = map[string]bool{"seasons": true, "boards": true}
+
+// boardRegistry 排行榜定义缓存
+type boardRegistry struct {
+	mu       sync.RWMutex
+	byID     map[int]*Board
+	byKey    map[string]*Board
+	list     []*Board // 按ID排序
+	loadedAt time.Time
+}
+
+// EnsureDefaultBoards 排行榜定义为空时创建默认的1-9号排行榜并加载定义（启动时调用）
+func (s *Service) EnsureDefaultBoards() error {
+	boards, err := s.repo.ListBoards()
+	if err != nil {
+		return err
+	}
+	if len(boards) == 0 {
+		if err := s.repo.CreateBoards(defaultBoards()); err != nil {
+			return err
+		}
+		logger.Info("[ranking.EnsureDefaultBoards] 已创建默认排行榜定义")
+	}
+	return s.reloadBoards()
+}
+
+// reloadBoards 从数据库重新加载排行榜定义
+func (s *Service) reloadBoards() error {
+	boards, err := s.repo.ListBoards()
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]*Board, len(boards))
+	byKey := make(map[string]*Board, len(boards))
+	for _, b := range boards {
+		byID[b.ID] = b
+		byKey[b.Key] = b
+	}
+	sort.Slice(boards, func(i, j int) bool { return boards[i].ID < boards[j].ID })
+
+	s.boards.mu.Lock()
+	s.boards.byID = byID
+	s.boards.byKey = byKey
+	s.boards.list = boards
+	s.boards.loadedAt = time.Now()
+	s.boards.mu.Unlock()
+	return nil
+}
+
+// refreshBoards 缓存过期时重新加载（加载失败时继续使用旧的定义，一分钟后再试）
+func (s *Service) refreshBoards() {
+	s.boards.mu.RLock()
+	fresh := time.Since(s.boards.loadedAt) < boardCacheTTL
+	s.boards.mu.RUnlock()
+	if fresh {
+		return
+	}
+	if err := s.reloadBoards(); err != nil {
+		logger.Error("[ranking.refreshBoards] 加载排行榜定义失败", zap.Error(err))
+		s.boards.mu.Lock()
+		s.boards.loadedAt = time.Now()
+		s.boards.mu.Unlock()
+	}
+}
+
+// boardDef 获取排行榜定义（不存在时返回nil）
+func (s *Service) boardDef(rankType int) *Board {
+	s.refreshBoards()
+	s.boards.mu.RLock()
+	defer s.boards.mu.RUnlock()
+	return s.boards.byID[rankType]
+}
+
+// allBoards 获取所有排行榜定义（包括隐藏的）
+func (s *Service) allBoards() []*Board {
+	s.refreshBoards()
+	s.boards.mu.RLock()
+	defer s.boards.mu.RUnlock()
+	return s.boards.list
+}
+
+// ResolveRankType 将路由中的排行榜类型或标识解析为排行榜类型（无法解析时返回0）
+func (s *Service) ResolveRankType(param string) int {
+	if rankType, err := strconv.Atoi(param); err == nil {
+		return rankType
+	}
+	s.refreshBoards()
+	s.boards.mu.RLock()
+	defer s.boards.mu.RUnlock()
+	if b, ok := s.boards.byKey[param]; ok {
+		return b.ID
+	}
+	return 0
+}
+
+// publicBoard 获取可以查询排名的排行榜定义（隐藏的排行榜视为不存在）
+func (s *Service) publicBoard(rankType int) (*Board, error) {
+	def := s.boardDef(rankType)
+	if def == nil || !def.IsPublic() {
+		return nil, errcode.New(errcode.InvalidRankType)
+	}
+	return def, nil
+}
+
+// ListBoards 获取公开的排行榜
+func (s *Service) ListBoards() []BoardVO {
+	items := make([]BoardVO, 0)
+	for _, b := range s.allBoards() {
+		if b.IsPublic() {
+			items = append(items, toBoardVO(b))
+		}
+	}
+	return items
+}
+
+// AdminListBoards 获取所有排行榜定义（包括隐藏的）
+func (s *Service) AdminListBoards() ([]BoardVO, error) {
+	boards, err := s.repo.ListBoards()
+	if err != nil {
+		logger.Error("[ranking.AdminListBoards] 查询排行榜定义失败", zap.Error(err))
+		return nil, err
+	}
+	items := make([]BoardVO, len(boards))
+	for i, b := range boards {
+		items[i] = toBoardVO(b)
+	}
+	return items, nil
+}
+
+// AdminCreateBoard 创建排行榜
+func (s *Service) AdminCreateBoard(operatorID uint, req *CreateBoardRequest) (*BoardVO, error) {
+	if !boardKeyPattern.MatchString(req.Key) || reservedBoardKeys[req.Key] {
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, "排行榜标识必须以小写字母开头，只能包含小写字母、数字和下划线")
+	}
+	b := &Board{
+		ID:          req.ID,
+		Key:         req.Key,
+		DisplayName: req.DisplayName,
+		SortOrder:   req.SortOrder,
+		Aggregation: req.Aggregation,
+		Visibility:  req.Visibility,
+
+		MaxScore:           req.MaxScore,
+		MinScore:           req.MinScore,
+		MaxDelta:           req.MaxDelta,
+		DeltaWindowMinutes: req.DeltaWindowMinutes,
+	}
+	if b.DeltaWindowMinutes == 0 {
+		b.DeltaWindowMinutes = int(defaultDeltaWindow / time.Minute)
+	}
+	if b.SortOrder == "" {
+		b.SortOrder = SortDesc
+	}
+	if b.Aggregation == "" {
+		b.Aggregation = AggregateMax
+	}
+	if b.Visibility == "" {
+		b.Visibility = VisibilityPublic
+	}
+
+	if err := s.reloadBoards(); err != nil {
+		logger.Error("[ranking.AdminCreateBoard] 加载排行榜定义失败", zap.Error(err))
+		return nil, err
+	}
+	s.boards.mu.RLock()
+	_, idTaken := s.boards.byID[b.ID]
+	_, keyTaken := s.boards.byKey[b.Key]
+	s.boards.mu.RUnlock()
+	if idTaken || keyTaken {
+		return nil, errcode.New(errcode.BoardExists)
+	}
+
+	if err := s.repo.CreateBoard(b); err != nil {
+		logger.Error("[ranking.AdminCreateBoard] 创建排行榜失败", zap.Int("rank_type", b.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := s.reloadBoards(); err != nil {
+		logger.Error("[ranking.AdminCreateBoard] 加载排行榜定义失败", zap.Error(err))
+	}
+
+	logger.Warn("[audit] 创建排行榜",
+		zap.String("event", "ranking_board_created"),
+		zap.Uint("operator_id", operatorID),
+		zap.Int("rank_type", b.ID),
+		zap.String("key", b.Key),
+		zap.String("sort_order", b.SortOrder),
+		zap.String("aggregation", b.Aggregation),
+		zap.String("visibility", b.Visibility),
+		zap.Int("max_score", b.MaxScore),
+		zap.Int("min_score", b.MinScore),
+		zap.Int("max_delta", b.MaxDelta))
+
+	vo := toBoardVO(b)
+	return &vo, nil
+}
+
+// AdminUpdateBoard 修改排行榜定义
+// 修改排序方向后Redis排行榜会重新从MySQL加载；修改聚合方式只影响之后提交的成绩
+func (s *Service) AdminUpdateBoard(operatorID uint, rankType int, req *UpdateBoardRequest) (*BoardVO, error) {
+	if err := s.reloadBoards(); err != nil {
+		logger.Error("[ranking.AdminUpdateBoard] 加载排行榜定义失败", zap.Error(err))
+		return nil, err
+	}
+	current := s.boardDef(rankType)
+	if current == nil {
+		return nil, errcode.New(errcode.InvalidRankType)
+	}
+
+	b := *current
+	if req.DisplayName != "" {
+		b.DisplayName = req.DisplayName
+	}
+	if req.SortOrder != "" {
+		b.SortOrder = req.SortOrder
+	}
+	if req.Aggregation != "" {
+		b.Aggregation = req.Aggregation
+	}
+	if req.Visibility != "" {
+		b.Visibility = req.Visibility
+	}
+	if req.MaxScore != nil {
+		b.MaxScore = *req.MaxScore
+	}
+	if req.MinScore != nil {
+		b.MinScore = *req.MinScore
+	}
+	if req.MaxDelta != nil {
+		b.MaxDelta = *req.MaxDelta
+	}
+	if req.DeltaWindowMinutes != nil {
+		b.DeltaWindowMinutes = *req.DeltaWindowMinutes
+	}
+	if err := s.repo.UpdateBoard(&b); err != nil {
+		logger.Error("[ranking.AdminUpdateBoard] 更新排行榜失败", zap.Int("rank_type", rankType), zap.Error(err))
+		return nil, err
+	}
+	if err := s.reloadBoards(); err != nil {
+		logger.Error("[ranking.AdminUpdateBoard] 加载排行榜定义失败", zap.Error(err))
+	}
+	if b.SortOrder != current.SortOrder && s.board != nil {
+		// Redis中按排序方向存储分数，需要按新方向重建（键中带排序方向，仍缓存旧定义的实例只写入旧方向的键）；
+		// 旧方向的键不再更新，标记重建，以后改回原方向时重新加载
+		s.board.Invalidate(rankType)
+		if _, err := s.board.Rebuild(&b); err != nil {
+			logger.Error("[ranking.AdminUpdateBoard] 重建Redis排行榜失败", zap.Int("rank_type", rankType), zap.Error(err))
+			s.board.Invalidate(rankType)
+		}
+	}
+
+	logger.Warn("[audit] 修改排行榜",
+		zap.String("event", "ranking_board_updated"),
+		zap.Uint("operator_id", operatorID),
+		zap.Int("rank_type", rankType),
+		zap.String("display_name", b.DisplayName),
+		zap.String("sort_order", b.SortOrder),
+		zap.String("aggregation", b.Aggregation),
+		zap.String("visibility", b.Visibility),
+		zap.Int("max_score", b.MaxScore),
+		zap.Int("min_score", b.MinScore),
+		zap.Int("max_delta", b.MaxDelta),
+		zap.Int("delta_window_minutes", b.DeltaWindowMinutes))
+
+	vo := toBoardVO(&b)
+	return &vo, nil
+}
+
+// toBoardVO 转换为排行榜VO
+func toBoardVO(b *Board) BoardVO {
+	return BoardVO{
+		ID:          b.ID,
+		Key:         b.Key,
+		DisplayName: b.DisplayName,
+		SortOrder:   b.SortOrder,
+		Aggregation: b.Aggregation,
+		Visibility:  b.Visibility,
+
+		MaxScore:           b.MaxScore,
+		MinScore:           b.MinScore,
+		MaxDelta:           b.MaxDelta,
+		DeltaWindowMinutes: b.DeltaWindowMinutes,
+	}
+}
@@ -0,0 +1,159 @@
+// Package ranking - 游标分页
+// 功能：按 (score, updated_at, user_id) 游标分页查询排行榜，深度翻页不受OFFSET影响，翻页期间成绩变化也不会重复或遗漏
+package ranking
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 游标分页
+// 游标：base64url("rank:score:updated_at毫秒:user_id")，为上一页最后一条记录，rank用于计算下一页的名次
+// 查询：直接查询MySQL，WHERE (score, updated_at, user_id) 排在游标之后 ORDER BY ... LIMIT，
+//      总榜使用 idx_rank_keyset 索引，周期榜使用 idx_period_board 索引
+// 名次：按游标中的名次递增，翻页期间有成绩变化时名次可能与排名查询略有出入
+// page分页（总榜读取Redis）也返回next_cursor：Redis成员的同分排序与这里的SQL排序一致（见 leaderboard.go），可以从任意一页接着用游标翻页
+// 已归档的赛季榜只保留前N名，只支持page分页
+// ============================================================
+
+// encodeCursor 编码游标（rank为e的名次）
+func encodeCursor(e boardEntry, rank int) string {
+	raw := fmt.Sprintf("%d:%d:%d:%d", rank, e.Score, e.UpdatedAt.UnixMilli(), e.UserID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor 解码游标，返回游标记录和它的名次
+func decodeCursor(cursor string) (*boardEntry, int, error) {
+	invalid := errcode.NewWithMessage(errcode.InvalidParams, "游标无效")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, invalid
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 4 {
+		return nil, 0, invalid
+	}
+	rank, err1 := strconv.Atoi(parts[0])
+	score, err2 := strconv.Atoi(parts[1])
+	millis, err3 := strconv.ParseInt(parts[2], 10, 64)
+	userID, err4 := strconv.ParseUint(parts[3], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || rank < 1 {
+		return nil, 0, invalid
+	}
+	return &boardEntry{UserID: uint(userID), Score: score, UpdatedAt: time.UnixMilli(millis)}, rank, nil
+}
+
+// nextCursor 下一页的游标（本页不满limit时没有下一页）
+func nextCursor(entries []boardEntry, offset, limit int) string {
+	if len(entries) == 0 || len(entries) < limit {
+		return ""
+	}
+	return encodeCursor(entries[len(entries)-1], offset+len(entries))
+}
+
+// GetRankingsByCursor 游标分页获取排行榜（cursor为空时从第一名开始）
+func (s *Service) GetRankingsByCursor(rankType, limit int, cursor string, q BoardQuery) (*RankingListResponse, error) {
+	def, err := s.publicBoard(rankType)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidatePeriod(q.Period) {
+		return nil, errcode.New(errcode.InvalidPeriod)
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var (
+		after  *boardEntry
+		offset int
+	)
+	if cursor != "" {
+		if after, offset, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &RankingListResponse{
+		RankType: rankType,
+		Period:   q.Period,
+		Limit:    limit,
+	}
+	period, key, err := s.cursorWindow(q, result)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.entriesAfter(def, period, key, after, limit)
+	if err != nil {
+		logger.Error("[ranking.GetRankingsByCursor] 数据库查询失败",
+			zap.Int("rank_type", rankType),
+			zap.String("period", period),
+			zap.String("period_key", key),
+			zap.Error(err))
+		return nil, err
+	}
+	result.Rankings = s.toRankingItems(entries, offset)
+	result.NextCursor = nextCursor(entries, offset, limit)
+	return result, nil
+}
+
+// cursorWindow 游标分页查询的周期（总榜时period为空），同时填写响应中的周期信息
+func (s *Service) cursorWindow(q BoardQuery, result *RankingListResponse) (string, string, error) {
+	switch q.Period {
+	case "":
+		return "", "", nil
+	case PeriodSeason:
+		season, err := s.findBoardSeason(q.SeasonID)
+		if err != nil {
+			return "", "", err
+		}
+		if season.Status == SeasonStatusClosed {
+			return "", "", errcode.NewWithMessage(errcode.InvalidParams, "已归档的赛季榜不支持游标分页")
+		}
+		vo := toSeasonVO(season, time.Now())
+		result.Season = &vo
+		result.PeriodKey = seasonKey(season.ID)
+		return PeriodSeason, result.PeriodKey, nil
+	default:
+		w, err := s.queryWindow(q)
+		if err != nil {
+			return "", "", err
+		}
+		result.PeriodKey = w.Key
+		return w.Period, w.Key, nil
+	}
+}
+
+// entriesAfter 查询排在after之后的limit条记录（period为空时为总榜）
+func (s *Service) entriesAfter(def *Board, period, key string, after *boardEntry, limit int) ([]boardEntry, error) {
+	if period == "" {
+		rankings, err := s.repo.GetRankingsAfter(def, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]boardEntry, len(rankings))
+		for i, r := range rankings {
+			entries[i] = boardEntry{UserID: r.UserID, Score: r.Score, UpdatedAt: r.UpdatedAt}
+		}
+		return entries, nil
+	}
+
+	scores, err := s.repo.GetPeriodRankingsAfter(def, period, key, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]boardEntry, len(scores))
+	for i, ps := range scores {
+		entries[i] = boardEntry{UserID: ps.UserID, Score: ps.Score, UpdatedAt: ps.UpdatedAt}
+	}
+	return entries, nil
+}
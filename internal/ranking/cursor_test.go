@@ -0,0 +1,89 @@
+package ranking
+
+import (
+	"encoding/base64"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+)
+
+// rawCursor 直接编码游标内容（用于构造非法游标）
+func rawCursor(raw string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry boardEntry
+		rank  int
+	}{
+		{"第一名", boardEntry{UserID: 1, Score: 9800, UpdatedAt: time.UnixMilli(1766200000123)}, 1},
+		{"负分", boardEntry{UserID: 42, Score: -15, UpdatedAt: time.UnixMilli(1766200000000)}, 100},
+		{"零值时间", boardEntry{UserID: 7, Score: 0, UpdatedAt: time.UnixMilli(0)}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := encodeCursor(tt.entry, tt.rank)
+			got, rank, err := decodeCursor(cursor)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) err = %v", cursor, err)
+			}
+			if rank != tt.rank || got.UserID != tt.entry.UserID || got.Score != tt.entry.Score || !got.UpdatedAt.Equal(tt.entry.UpdatedAt) {
+				t.Errorf("decodeCursor() = %+v, %d, want %+v, %d", *got, rank, tt.entry, tt.rank)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"不是base64", "!!!"},
+		{"标准base64带填充", base64.URLEncoding.EncodeToString([]byte("1:100:1766200000000:42"))},
+		{"段数不足", rawCursor("1:100:1766200000000")},
+		{"段数过多", rawCursor("1:100:1766200000000:42:0")},
+		{"名次为0", rawCursor("0:100:1766200000000:42")},
+		{"名次为负", rawCursor("-1:100:1766200000000:42")},
+		{"名次不是数字", rawCursor("a:100:1766200000000:42")},
+		{"分数不是数字", rawCursor("1:1e3:1766200000000:42")},
+		{"时间不是数字", rawCursor("1:100:now:42")},
+		{"user_id为负", rawCursor("1:100:1766200000000:-42")},
+		{"空内容", rawCursor("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeCursor(tt.cursor)
+			var appErr *errcode.Error
+			if !stderrors.As(err, &appErr) || appErr.Code != errcode.InvalidParams {
+				t.Errorf("decodeCursor(%q) err = %v, 期望 InvalidParams", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	entries := []boardEntry{
+		{UserID: 1, Score: 300, UpdatedAt: time.UnixMilli(1766200000000)},
+		{UserID: 2, Score: 200, UpdatedAt: time.UnixMilli(1766200000001)},
+	}
+
+	if got := nextCursor(entries, 10, 3); got != "" {
+		t.Errorf("不满一页时 nextCursor() = %q, want \"\"", got)
+	}
+	if got := nextCursor(nil, 0, 2); got != "" {
+		t.Errorf("空页 nextCursor() = %q, want \"\"", got)
+	}
+
+	after, rank, err := decodeCursor(nextCursor(entries, 10, 2))
+	if err != nil {
+		t.Fatalf("decodeCursor() err = %v", err)
+	}
+	if rank != 12 || after.UserID != 2 {
+		t.Errorf("下一页游标 = %+v, 名次 %d, want user 2, 名次 12", *after, rank)
+	}
+}
@@ -8,33 +8,225 @@ import "time"
 
 // UpdateScoreRequest 更新分数请求
 type UpdateScoreRequest struct {
-	RankType int `json:"rank_type" binding:"required,min=1,max=9" example:"1"` // 排行榜类型1-9
-	Score    int `json:"score" binding:"required,min=0" example:"100"`         // 分数
+	RankType int  `json:"rank_type" binding:"required,min=1" example:"1"` // 排行榜类型（排行榜定义的ID）
+	Score    int  `json:"score" binding:"required,min=0" example:"100"`   // 分数
+	Season   uint `json:"season" example:"3"`                             // 可选：成绩所属赛季，与当前赛季不一致时拒绝（避免跨赛季提交）
+
+	ClientVersion string `json:"client_version" binding:"max=32" example:"1.4.2"`                                                                               // 可选：客户端版本
+	RunID         string `json:"run_id" binding:"max=64" example:"c1f3e2a0-6b1d-4a57-9a0e-3f5d2b7c8e91"`                                                        // 可选：对局/会话ID（同一局重复提交会被标记）
+	Signature     string `json:"signature" binding:"omitempty,len=64,hexadecimal" example:"5d41402abc4b2a76b9719d911017c5925d41402abc4b2a76b9719d911017c592"`   // 对局签名：hex(HMAC-SHA256(run_key, "run_id:rank_type:score"))，开启签名校验时必填
+	ReplayHash    string `json:"replay_hash" binding:"omitempty,len=64,hexadecimal" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // 可选：录像数据的SHA-256（十六进制）
+}
+
+// CreateRunRequest 开始对局请求
+type CreateRunRequest struct {
+	RankType int `json:"rank_type" binding:"required,min=1" example:"1"` // 本局成绩要提交到的排行榜
+}
+
+// UpdateSeasonRequest 修改赛季请求（字段为空时不修改）
+type UpdateSeasonRequest struct {
+	Name   string     `json:"name" binding:"max=64" example:"第3赛季·冰雪"`
+	EndsAt *time.Time `json:"ends_at" example:"2026-10-01T00:00:00+08:00"` // 只能修改为晚于当前时间
+}
+
+// CreateBoardRequest 创建排行榜请求
+type CreateBoardRequest struct {
+	ID          int    `json:"id" binding:"required,min=1" example:"10"`                               // 排行榜类型（提交分数时的rank_type）
+	Key         string `json:"key" binding:"required,max=32" example:"speedrun"`                       // 标识（小写字母开头，只含小写字母、数字和下划线；可代替ID出现在路由中）
+	DisplayName string `json:"display_name" binding:"required,max=64" example:"速通榜"`                   // 显示名称
+	SortOrder   string `json:"sort_order" binding:"omitempty,oneof=desc asc" example:"asc"`            // desc=分数越高越好（默认）, asc=越低越好
+	Aggregation string `json:"aggregation" binding:"omitempty,oneof=max min latest sum" example:"min"` // 成绩聚合方式（默认max）
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public hidden" example:"public"`    // 可见性（默认public）
+
+	MaxScore           int `json:"max_score" binding:"min=0" example:"100000"`        // 单次提交的分数上限（0表示使用默认上限1000000），超出的提交被隔离
+	MinScore           int `json:"min_score" binding:"min=0" example:"30"`            // 单次提交的分数下限（只用于分数越低越好的排行榜，0表示使用默认下限1），低于的提交被隔离
+	MaxDelta           int `json:"max_delta" binding:"min=0" example:"5000"`          // 窗口内成绩的最大提升（0表示不限制）
+	DeltaWindowMinutes int `json:"delta_window_minutes" binding:"min=0" example:"60"` // 成绩提升的统计窗口（分钟，默认60）
+}
+
+// UpdateBoardRequest 修改排行榜请求（字段为空时不修改）
+type UpdateBoardRequest struct {
+	DisplayName string `json:"display_name" binding:"max=64" example:"速通榜"`
+	SortOrder   string `json:"sort_order" binding:"omitempty,oneof=desc asc" example:"asc"`
+	Aggregation string `json:"aggregation" binding:"omitempty,oneof=max min latest sum" example:"min"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public hidden" example:"hidden"`
+
+	MaxScore           *int `json:"max_score" binding:"omitempty,min=0" example:"100000"`
+	MinScore           *int `json:"min_score" binding:"omitempty,min=0" example:"30"`
+	MaxDelta           *int `json:"max_delta" binding:"omitempty,min=0" example:"5000"`
+	DeltaWindowMinutes *int `json:"delta_window_minutes" binding:"omitempty,min=1" example:"60"`
 }
 
 // ============ 响应VO ============
 
 // RankingItem 排行榜项
 type RankingItem struct {
-	Rank      int       `json:"rank" example:"1"`                          // 排名
-	UserID    uint      `json:"user_id" example:"1"`                       // 用户ID
-	Username  string    `json:"username" example:"player1"`                // 用户名
-	Score     int       `json:"score" example:"100"`                       // 分数
-	UpdatedAt time.Time `json:"updated_at" example:"2023-12-20T10:00:00Z"` // 更新时间
+	Rank        int       `json:"rank" example:"1"`                          // 排名
+	UserID      uint      `json:"user_id" example:"1"`                       // 用户ID
+	Username    string    `json:"username" example:"player1"`                // 用户名
+	DisplayName string    `json:"display_name" example:"小明"`                 // 昵称（未设置时为用户名）
+	Avatar      string    `json:"avatar" example:"preset_3"`                 // 头像
+	Score       int       `json:"score" example:"100"`                       // 分数
+	UpdatedAt   time.Time `json:"updated_at" example:"2023-12-20T10:00:00Z"` // 更新时间
 }
 
 // RankingListResponse 排行榜列表响应
 type RankingListResponse struct {
-	RankType int           `json:"rank_type" example:"1"` // 排行榜类型
-	Page     int           `json:"page" example:"1"`      // 当前页
-	Limit    int           `json:"limit" example:"10"`    // 每页数量
-	Rankings []RankingItem `json:"rankings"`              // 排行榜数据
+	RankType   int           `json:"rank_type" example:"1"`                                           // 排行榜类型
+	Period     string        `json:"period,omitempty" example:"weekly"`                               // 周期（总榜为空）
+	PeriodKey  string        `json:"period_key,omitempty" example:"2026-W42"`                         // 周期键
+	Season     *SeasonVO     `json:"season,omitempty"`                                                // 赛季榜的赛季
+	Archived   bool          `json:"archived,omitempty" example:"false"`                              // 已结束赛季的归档排名（只保留前N名）
+	Page       int           `json:"page,omitempty" example:"1"`                                      // 当前页（游标分页时为空）
+	Limit      int           `json:"limit" example:"10"`                                              // 每页数量
+	Rankings   []RankingItem `json:"rankings"`                                                        // 排行榜数据
+	NextCursor string        `json:"next_cursor,omitempty" example:"MTA6OTUwOjE3NjA3NzQ0MDAwMDA6NDI"` // 下一页的游标（没有下一页或已归档的赛季榜为空）
+}
+
+// GroupRankingResponse 好友榜/聊天室榜/指定玩家榜响应
+type GroupRankingResponse struct {
+	RankType  int           `json:"rank_type" example:"1"`
+	Scope     string        `json:"scope" example:"friends"`                 // friends=好友, room=聊天室成员, users=指定玩家
+	Period    string        `json:"period,omitempty" example:"weekly"`       // 周期（总榜为空）
+	PeriodKey string        `json:"period_key,omitempty" example:"2026-W42"` // 周期键
+	Season    *SeasonVO     `json:"season,omitempty"`                        // 赛季榜的赛季
+	Archived  bool          `json:"archived,omitempty" example:"false"`      // 已结束赛季的归档排名（只包含进入前N名的玩家）
+	Members   int           `json:"members" example:"25"`                    // 组内人数
+	MyRank    int           `json:"my_rank,omitempty" example:"3"`           // 我在组内的名次（没有成绩时为0）
+	Rankings  []RankingItem `json:"rankings"`                                // 组内有成绩的玩家，名次为组内的相对名次
+}
+
+// MyRankResponse 我的排名响应
+type MyRankResponse struct {
+	RankType   int       `json:"rank_type" example:"1"`
+	Rank       int       `json:"rank" example:"42"`         // 排名（从1开始）
+	Score      int       `json:"score" example:"100"`       // 分数
+	Total      int64     `json:"total" example:"1000"`      // 排行榜总人数
+	Percentile float64   `json:"percentile" example:"95.9"` // 超过了百分之多少的玩家（第1名为100）
+	UpdatedAt  time.Time `json:"updated_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// RankingAroundResponse 我附近的排名响应
+type RankingAroundResponse struct {
+	RankType int           `json:"rank_type" example:"1"`
+	Rank     int           `json:"rank" example:"42"`    // 我的排名
+	Total    int64         `json:"total" example:"1000"` // 排行榜总人数
+	Radius   int           `json:"radius" example:"5"`   // 前后各取的人数
+	Rankings []RankingItem `json:"rankings"`             // 按排名排列，包含我自己
 }
 
 // UpdateScoreResponse 更新分数响应
 type UpdateScoreResponse struct {
-	UserID    uint      `json:"user_id" example:"1"`
-	RankType  int       `json:"rank_type" example:"1"`
+	UserID       uint            `json:"user_id" example:"1"`
+	RankType     int             `json:"rank_type" example:"1"`
+	Score        int             `json:"score" example:"100"` // 总榜成绩（按排行榜的聚合方式合并后）
+	UpdatedAt    time.Time       `json:"updated_at" example:"2023-12-20T10:00:00Z"`
+	Improved     bool            `json:"improved" example:"true"`      // 本次提交是否改变了总榜成绩（如刷新了最高分）
+	Season       *SeasonVO       `json:"season,omitempty"`             // 当前赛季
+	Periods      []PeriodScoreVO `json:"periods"`                      // 本次提交所在各周期的成绩
+	Status       string          `json:"status" example:"accepted"`    // accepted=已计入排行榜, quarantined=已隔离等待审核（未计入排行榜）
+	SubmissionID uint            `json:"submission_id" example:"1024"` // 本次提交的记录ID
+}
+
+// PeriodScoreVO 周期成绩
+type PeriodScoreVO struct {
+	Period    string    `json:"period" example:"weekly"`
+	PeriodKey string    `json:"period_key" example:"2026-W42"`
 	Score     int       `json:"score" example:"100"`
-	UpdatedAt time.Time `json:"updated_at" example:"2023-12-20T10:00:00Z"`
+	EndsAt    time.Time `json:"ends_at" example:"2026-10-19T00:00:00+08:00"` // 周期结束时间
+	Improved  bool      `json:"improved" example:"true"`                     // 本次提交是否改变了该周期的成绩
+}
+
+// RunVO 对局
+type RunVO struct {
+	RunID     string    `json:"run_id" example:"3f2a9c0d8e7b4a1f9c6d5e4b3a2f1e0d"`
+	RunKey    string    `json:"run_key" example:"b7e1c0f9a8d7e6f5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1"` // 对局密钥（返回给客户端，只用于本局的成绩签名）
+	RankType  int       `json:"rank_type" example:"1"`
+	ExpiresAt time.Time `json:"expires_at" example:"2026-10-18T12:00:00+08:00"` // 须在此之前提交成绩
+}
+
+// BoardVO 排行榜定义
+type BoardVO struct {
+	ID          int    `json:"id" example:"10"`
+	Key         string `json:"key" example:"speedrun"`
+	DisplayName string `json:"display_name" example:"速通榜"`
+	SortOrder   string `json:"sort_order" example:"asc"`    // desc=分数越高越好, asc=越低越好
+	Aggregation string `json:"aggregation" example:"min"`   // max/min/latest/sum
+	Visibility  string `json:"visibility" example:"public"` // public/hidden（只在管理接口中出现hidden）
+
+	MaxScore           int `json:"max_score" example:"100000"` // 单次提交的分数上限（0表示使用默认上限1000000）
+	MinScore           int `json:"min_score" example:"30"`     // 单次提交的分数下限（只用于分数越低越好的排行榜，0表示使用默认下限1）
+	MaxDelta           int `json:"max_delta" example:"5000"`   // 窗口内成绩的最大提升（0表示不限制）
+	DeltaWindowMinutes int `json:"delta_window_minutes" example:"60"`
+}
+
+// SubmissionVO 成绩提交记录
+type SubmissionVO struct {
+	ID            uint      `json:"id" example:"1024"`
+	RankType      int       `json:"rank_type" example:"1"`
+	Score         int       `json:"score" example:"120"`      // 本次提交的分数
+	BestScore     int       `json:"best_score" example:"150"` // 提交后的总榜成绩
+	SeasonID      uint      `json:"season_id" example:"3"`
+	Status        string    `json:"status" example:"accepted"` // accepted=已计入, rejected=被拒绝
+	ClientVersion string    `json:"client_version" example:"1.4.2"`
+	RunID         string    `json:"run_id" example:"c1f3e2a0-6b1d-4a57-9a0e-3f5d2b7c8e91"`
+	CreatedAt     time.Time `json:"created_at" example:"2026-10-18T10:00:00+08:00"`
+}
+
+// SubmissionHistoryResponse 我的提交记录
+type SubmissionHistoryResponse struct {
+	RankType    int            `json:"rank_type" example:"1"`
+	Page        int            `json:"page" example:"1"`
+	Limit       int            `json:"limit" example:"20"`
+	Total       int64          `json:"total" example:"35"`
+	Submissions []SubmissionVO `json:"submissions"` // 按时间倒序
+}
+
+// AdminSubmissionVO 管理后台成绩提交记录
+type AdminSubmissionVO struct {
+	SubmissionVO
+	UserID     uint       `json:"user_id" example:"1"`
+	Username   string     `json:"username" example:"player1"`
+	ReplayHash string     `json:"replay_hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	ClientIP   string     `json:"client_ip" example:"203.0.113.7"`
+	Reason     string     `json:"reason" example:"season_ended"`            // 被拒绝的原因
+	Flags      []string   `json:"flags" example:"duplicate_run,score_jump"` // 可疑标记
+	Signed     bool       `json:"signed" example:"true"`                    // 是否通过对局签名校验
+	ReviewedBy uint       `json:"reviewed_by" example:"2"`                  // 审核的管理员
+	ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+// AdminSubmissionListVO 管理后台成绩提交记录列表
+type AdminSubmissionListVO struct {
+	Total       int64               `json:"total" example:"100"`
+	Submissions []AdminSubmissionVO `json:"submissions"`
+}
+
+// SeasonVO 赛季信息
+type SeasonVO struct {
+	ID       uint      `json:"id" example:"3"`
+	Name     string    `json:"name" example:"第3赛季"`
+	StartsAt time.Time `json:"starts_at" example:"2026-07-01T00:00:00+08:00"`
+	EndsAt   time.Time `json:"ends_at" example:"2026-09-29T00:00:00+08:00"`
+	Status   string    `json:"status" example:"open"` // open=进行中, closed=已结束并归档
+	Current  bool      `json:"current" example:"true"`
+}
+
+// ============ WebSocket推送 ============
+
+// RankChangedEvent 名次变化
+type RankChangedEvent struct {
+	Type        string       `json:"type"` // "rank_changed"
+	RankType    int          `json:"rank_type"`
+	OldRank     int          `json:"old_rank"` // 之前不在排行榜上时为0
+	NewRank     int          `json:"new_rank"`
+	Score       int          `json:"score"`
+	OvertakenBy *RankingItem `json:"overtaken_by,omitempty"` // 被超过时为超过自己的玩家
+}
+
+// LeaderboardUpdatedEvent 订阅的排行榜前N名发生变化
+type LeaderboardUpdatedEvent struct {
+	Type     string        `json:"type"` // "leaderboard_updated"
+	RankType int           `json:"rank_type"`
+	Top      []RankingItem `json:"top"`
 }
@@ -1,21 +1,22 @@
 // Package ranking - 排行榜模块
 // 功能：管理用户在不同类型排行榜中的分数记录
-// 特点：独立排行榜表，同用户同类型自动保留最高分
+// 特点：独立排行榜表，排序方向和同用户多次提交的聚合方式由排行榜定义决定
 package ranking
 
 import (
+	"fmt"
 	"time"
 )
 
 // Entity 排行榜实体
 // 设计：独立排行榜表，一个用户可以有多个排行榜类型的记录
-// 特点：同一用户同一类型只保存最高分
+// 特点：同一用户同一类型只保存一条记录（按排行榜定义的聚合方式合并）
 type Entity struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"uniqueIndex:idx_user_rank_type;not null" json:"user_id"`
-	RankType  int       `gorm:"uniqueIndex:idx_user_rank_type;not null" json:"rank_type"` // 1-9种排行榜类型
-	Score     int       `gorm:"not null;default:0;index:idx_rank_score" json:"score"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime;index:idx_rank_score" json:"updated_at"` // 用于相同分数时的排序
+	UserID    uint      `gorm:"uniqueIndex:idx_user_rank_type;index:idx_rank_keyset,priority:4;not null" json:"user_id"`
+	RankType  int       `gorm:"uniqueIndex:idx_user_rank_type;index:idx_rank_keyset,priority:1;not null" json:"rank_type"` // 排行榜类型（ranking_boards.id）
+	Score     int       `gorm:"not null;default:0;index:idx_rank_score;index:idx_rank_keyset,priority:2" json:"score"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime;index:idx_rank_score;index:idx_rank_keyset,priority:3" json:"updated_at"` // 用于相同分数时的排序
 }
 
 // TableName 指定数据库表名
@@ -23,7 +24,230 @@ func (Entity) TableName() string {
 	return "rankings"
 }
 
-// ValidateRankType 验证排行榜类型（1-9）
-func ValidateRankType(rankType int) bool {
-	return rankType >= 1 && rankType <= 9
+// 排序方向
+const (
+	SortDesc = "desc" // 分数越高越好
+	SortAsc  = "asc"  // 分数越低越好（如通关用时）
+)
+
+// 成绩聚合方式（同一用户多次提交时保留的分数）
+const (
+	AggregateMax    = "max"    // 最高分
+	AggregateMin    = "min"    // 最低分
+	AggregateLatest = "latest" // 最近一次提交
+	AggregateSum    = "sum"    // 累计
+)
+
+// 可见性
+const (
+	VisibilityPublic = "public" // 公开：出现在排行榜列表中，可以查询排名
+	VisibilityHidden = "hidden" // 隐藏：不出现在列表中，不能查询排名，仍可提交成绩（如尚未上线的活动榜）
+)
+
+// Board 排行榜定义
+// 设计：ID即排行榜类型（rankings.rank_type），排行榜的排序、聚合方式和可见性都由定义决定
+type Board struct {
+	ID          int    `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	Key         string `gorm:"type:varchar(32);uniqueIndex;not null" json:"key"` // 标识（可代替ID出现在路由中）
+	DisplayName string `gorm:"type:varchar(64);not null" json:"display_name"`
+	SortOrder   string `gorm:"type:varchar(8);not null;default:desc" json:"sort_order"`
+	Aggregation string `gorm:"type:varchar(16);not null;default:max" json:"aggregation"`
+	Visibility  string `gorm:"type:varchar(16);not null;default:public" json:"visibility"`
+	// 成绩校验：超出范围的提交被隔离，审核通过后才计入排行榜
+	// MaxScore/MinScore为0时使用默认上限/下限（见 scoreOutOfRange），MaxDelta为0时不检查提升幅度
+	MaxScore           int       `gorm:"not null;default:0" json:"max_score"`             // 单次提交的分数上限
+	MinScore           int       `gorm:"not null;default:0" json:"min_score"`             // 单次提交的分数下限（只用于分数越低越好的排行榜）
+	MaxDelta           int       `gorm:"not null;default:0" json:"max_delta"`             // 窗口内成绩的最大提升（相对窗口开始时的成绩）
+	DeltaWindowMinutes int       `gorm:"not null;default:60" json:"delta_window_minutes"` // 成绩提升的统计窗口（分钟）
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// TableName 指定数据库表名
+func (Board) TableName() string {
+	return "ranking_boards"
+}
+
+// Ascending 是否分数越低越好
+func (b *Board) Ascending() bool {
+	return b.SortOrder == SortAsc
+}
+
+// IsPublic 是否公开
+func (b *Board) IsPublic() bool {
+	return b.Visibility == VisibilityPublic
+}
+
+// OrderClause 排行榜的SQL排序（分数相同时先达到的排名靠前）
+func (b *Board) OrderClause() string {
+	if b.Ascending() {
+		return "score ASC, updated_at ASC"
+	}
+	return "score DESC, updated_at ASC"
+}
+
+// KeysetOrderClause 确定的SQL排序（在 OrderClause 的基础上按user_id区分同分同时间的记录），用于游标分页和群组榜
+func (b *Board) KeysetOrderClause() string {
+	return b.OrderClause() + ", user_id ASC"
+}
+
+// 默认排行榜的成绩校验上限（可通过管理接口按实际玩法调整）
+const (
+	defaultMaxScore = 1000000 // 单次提交的分数上限
+	defaultMaxDelta = 200000  // 一个窗口内成绩的最大提升
+)
+
+// defaultBoards 首次启动时创建的排行榜定义（与之前固定的1-9种排行榜一致）
+func defaultBoards() []*Board {
+	boards := make([]*Board, 0, 9)
+	for id := 1; id <= 9; id++ {
+		boards = append(boards, &Board{
+			ID:                 id,
+			Key:                fmt.Sprintf("board_%d", id),
+			DisplayName:        fmt.Sprintf("排行榜%d", id),
+			SortOrder:          SortDesc,
+			Aggregation:        AggregateMax,
+			Visibility:         VisibilityPublic,
+			MaxScore:           defaultMaxScore,
+			MaxDelta:           defaultMaxDelta,
+			DeltaWindowMinutes: int(defaultDeltaWindow / time.Minute),
+		})
+	}
+	return boards
+}
+
+// 成绩提交状态
+const (
+	SubmissionAccepted    = "accepted"    // 已计入排行榜
+	SubmissionRejected    = "rejected"    // 被拒绝（如提交的赛季已结束、签名无效、审核未通过）
+	SubmissionQuarantined = "quarantined" // 已隔离：未计入排行榜，等待管理员审核
+)
+
+// 可疑提交标记
+const (
+	FlagDuplicateRun = "duplicate_run" // 同一局游戏（run_id）重复提交
+	FlagScoreJump    = "score_jump"    // 成绩比之前的最好成绩提升一倍以上
+	FlagScoreLimit   = "score_limit"   // 超出排行榜的分数上限（分数越低越好的排行榜为低于分数下限）
+	FlagDeltaLimit   = "delta_limit"   // 窗口内成绩提升超过排行榜的增幅上限
+)
+
+// quarantineFlags 需要隔离等待审核的标记（其余标记只用于提示）
+var quarantineFlags = map[string]bool{
+	FlagDuplicateRun: true,
+	FlagScoreLimit:   true,
+	FlagDeltaLimit:   true,
+}
+
+// Submission 成绩提交记录
+// 设计：每次提交都保存一条记录（包括被拒绝的），用于查看玩家的成绩变化和审查可疑成绩
+type Submission struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"index:idx_submission_user,priority:1;not null" json:"user_id"`
+	RankType      int        `gorm:"index:idx_submission_user,priority:2;not null" json:"rank_type"`
+	Score         int        `gorm:"not null" json:"score"`                // 本次提交的分数
+	BestScore     int        `gorm:"not null;default:0" json:"best_score"` // 提交后的总榜成绩
+	SeasonID      uint       `gorm:"not null;default:0" json:"season_id"`  // 提交时的赛季（未启用赛季时为0）
+	Status        string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	Reason        string     `gorm:"type:varchar(64)" json:"reason"`         // 被拒绝的原因
+	Flags         string     `gorm:"type:varchar(128);index" json:"flags"`   // 可疑标记（逗号分隔，为空表示正常）
+	ClientVersion string     `gorm:"type:varchar(32)" json:"client_version"` // 客户端版本
+	RunID         string     `gorm:"type:varchar(64);index" json:"run_id"`   // 客户端的对局/会话ID
+	ReplayHash    string     `gorm:"type:varchar(64)" json:"replay_hash"`    // 录像数据的SHA-256
+	ClientIP      string     `gorm:"type:varchar(45)" json:"client_ip"`
+	Signed        bool       `gorm:"not null;default:false" json:"signed"`  // 是否通过对局签名校验
+	ReviewedBy    uint       `gorm:"not null;default:0" json:"reviewed_by"` // 审核隔离成绩的管理员
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `gorm:"index:idx_submission_user,priority:3;index" json:"created_at"`
+}
+
+// TableName 指定数据库表名
+func (Submission) TableName() string {
+	return "ranking_submissions"
+}
+
+// Run 对局
+// 设计：开始一局游戏时签发，对局密钥由服务端签名密钥和nonce派生（不保存），每个对局只能提交一次成绩
+type Run struct {
+	ID        string     `gorm:"type:varchar(32);primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	RankType  int        `gorm:"not null" json:"rank_type"`
+	Nonce     string     `gorm:"type:varchar(64);not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"` // 提交成绩的时间（未提交时为空）
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 指定数据库表名
+func (Run) TableName() string {
+	return "ranking_runs"
+}
+
+// 排行榜周期（为空表示总榜）
+const (
+	PeriodSeason  = "season"  // 赛季榜
+	PeriodDaily   = "daily"   // 日榜
+	PeriodWeekly  = "weekly"  // 周榜（周一开始）
+	PeriodMonthly = "monthly" // 月榜
+)
+
+// 赛季状态
+const (
+	SeasonStatusOpen   = "open"   // 进行中（或已到结束时间、等待归档）
+	SeasonStatusClosed = "closed" // 已结束，最终排名已归档
+)
+
+// Season 赛季
+// 设计：赛季首尾相接，当前赛季结束后自动创建下一赛季；ID即赛季编号
+type Season struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	Name      string     `gorm:"type:varchar(64);not null" json:"name"`
+	StartsAt  time.Time  `gorm:"not null;index" json:"starts_at"`
+	EndsAt    time.Time  `gorm:"not null;index" json:"ends_at"`
+	Status    string     `gorm:"type:varchar(16);not null;default:open;index" json:"status"`
+	ClosedAt  *time.Time `json:"closed_at"` // 归档完成时间
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 指定数据库表名
+func (Season) TableName() string {
+	return "ranking_seasons"
+}
+
+// Contains 时间是否在赛季内
+func (s *Season) Contains(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// PeriodScore 赛季榜/日榜/周榜/月榜成绩
+// 设计：每次提交分数时写入所在的每个周期，同一周期同一类型按排行榜定义的聚合方式合并
+type PeriodScore struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_period_user;not null" json:"user_id"`
+	RankType  int       `gorm:"uniqueIndex:idx_period_user;index:idx_period_board,priority:1;not null" json:"rank_type"`
+	Period    string    `gorm:"type:varchar(16);uniqueIndex:idx_period_user;index:idx_period_board,priority:2;not null" json:"period"`
+	PeriodKey string    `gorm:"type:varchar(16);uniqueIndex:idx_period_user;index:idx_period_board,priority:3;not null" json:"period_key"` // 赛季编号/2026-10-18/2026-W42/2026-10
+	Score     int       `gorm:"not null;default:0;index:idx_period_board,priority:4" json:"score"`
+	EndsAt    time.Time `gorm:"not null;index" json:"ends_at"` // 周期结束时间（用于清理过期数据）
+	UpdatedAt time.Time `gorm:"autoUpdateTime;index:idx_period_board,priority:5" json:"updated_at"`
+}
+
+// TableName 指定数据库表名
+func (PeriodScore) TableName() string {
+	return "ranking_period_scores"
+}
+
+// SeasonStanding 赛季最终排名（赛季结束时归档前N名）
+type SeasonStanding struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SeasonID  uint      `gorm:"uniqueIndex:idx_season_rank;not null" json:"season_id"`
+	RankType  int       `gorm:"uniqueIndex:idx_season_rank;not null" json:"rank_type"`
+	Rank      int       `gorm:"uniqueIndex:idx_season_rank;not null" json:"rank"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Score     int       `gorm:"not null" json:"score"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime:false" json:"updated_at"` // 取得该成绩的时间
+}
+
+// TableName 指定数据库表名
+func (SeasonStanding) TableName() string {
+	return "ranking_season_standings"
 }
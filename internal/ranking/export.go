@@ -0,0 +1,178 @@
+// Package ranking - 排行榜导出
+// 功能：管理员以CSV或JSON格式导出完整的排行榜，按游标分批读取并逐批写出，不把整个排行榜加载到内存
+package ranking
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// 导出格式
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// exportBatchSize 导出时每批从MySQL读取的记录数
+const exportBatchSize = 1000
+
+// BoardExport 排行榜导出（创建时校验参数，Write逐批写出）
+type BoardExport struct {
+	s           *Service
+	def         *Board
+	format      string
+	period      string
+	key         string
+	Filename    string
+	ContentType string
+}
+
+// flusher 支持立即发送已写入数据的Writer（如 http.ResponseWriter）
+type flusher interface {
+	Flush()
+}
+
+// NewBoardExport 创建排行榜导出（包括隐藏的排行榜；已归档的赛季榜不支持导出）
+func (s *Service) NewBoardExport(operatorID uint, rankType int, format string, q BoardQuery) (*BoardExport, error) {
+	def := s.boardDef(rankType)
+	if def == nil {
+		return nil, errcode.New(errcode.InvalidRankType)
+	}
+	if !ValidatePeriod(q.Period) {
+		return nil, errcode.New(errcode.InvalidPeriod)
+	}
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case "", ExportCSV:
+		format = ExportCSV
+	case ExportJSON:
+		contentType = "application/json; charset=utf-8"
+	default:
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, "导出格式只能是csv或json")
+	}
+
+	meta := &RankingListResponse{}
+	period, key, err := s.cursorWindow(q, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	name := def.Key
+	if key != "" {
+		name += "_" + key
+	}
+	logger.Warn("[audit] 导出排行榜",
+		zap.String("event", "ranking_exported"),
+		zap.Uint("operator_id", operatorID),
+		zap.Int("rank_type", rankType),
+		zap.String("period", period),
+		zap.String("period_key", key),
+		zap.String("format", format))
+
+	return &BoardExport{
+		s:           s,
+		def:         def,
+		format:      format,
+		period:      period,
+		key:         key,
+		Filename:    fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102150405"), format),
+		ContentType: contentType,
+	}, nil
+}
+
+// Write 逐批写出排行榜，返回写出的记录数（写出过程中失败时已写出的数据不完整）
+func (e *BoardExport) Write(w io.Writer) (int, error) {
+	var (
+		csvWriter *csv.Writer
+		total     int
+		after     *boardEntry
+	)
+	if e.format == ExportCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"rank", "user_id", "username", "display_name", "score", "updated_at"}); err != nil {
+			return 0, err
+		}
+	} else if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	for {
+		entries, err := e.s.entriesAfter(e.def, e.period, e.key, after, exportBatchSize)
+		if err != nil {
+			logger.Error("[ranking.BoardExport] 读取排行榜失败", zap.Int("rank_type", e.def.ID), zap.Int("written", total), zap.Error(err))
+			return total, err
+		}
+		for _, item := range e.s.toRankingItems(entries, total) {
+			if csvWriter != nil {
+				err = csvWriter.Write([]string{
+					strconv.Itoa(item.Rank),
+					strconv.FormatUint(uint64(item.UserID), 10),
+					csvText(item.Username),
+					csvText(item.DisplayName),
+					strconv.Itoa(item.Score),
+					item.UpdatedAt.Format(time.RFC3339),
+				})
+			} else {
+				err = e.writeJSON(w, item, item.Rank == 1)
+			}
+			if err != nil {
+				return total, err
+			}
+		}
+		total += len(entries)
+
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return total, err
+			}
+		}
+		if f, ok := w.(flusher); ok {
+			f.Flush()
+		}
+		if len(entries) < exportBatchSize {
+			break
+		}
+		after = &entries[len(entries)-1]
+	}
+
+	if csvWriter == nil {
+		if _, err := io.WriteString(w, "]\n"); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// csvText 转义玩家填写的文本：以 = + - @ 或制表符、回车开头的单元格会被电子表格当作公式执行，前面加单引号
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeJSON 写出JSON数组中的一项
+func (e *BoardExport) writeJSON(w io.Writer, item RankingItem, first bool) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if !first {
+		if _, err := io.WriteString(w, ",\n"); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,195 @@
+// Package ranking - 好友榜和群组榜
+// 功能：将任意排行榜限定在一组玩家（好友、聊天室成员或指定的玩家）中查询，名次为组内的相对名次
+package ranking
+
+import (
+	"fmt"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 好友榜/群组榜
+// 1. 组内成员的成绩用 user_id IN (...) 一次查询（命中 user_id + rank_type 唯一索引），在MySQL中按排行榜的排序方向排序
+// 2. 支持总榜、赛季榜（已结束的赛季只包含进入归档前N名的玩家）和日榜/周榜/月榜
+// 3. 好友榜包含自己；聊天室榜只有成员可以查询
+// ============================================================
+
+// 群组范围
+const (
+	ScopeFriends = "friends" // 好友和自己
+	ScopeRoom    = "room"    // 聊天室成员
+	ScopeUsers   = "users"   // 指定的玩家
+)
+
+// maxGroupSize 一次查询的玩家数上限
+// 好友榜最多201人（好友上限200加上自己），不会超过；聊天室没有成员上限，成员超过上限的聊天室不能查询聊天室榜
+const maxGroupSize = 500
+
+// FriendLister 好友列表（由 friend.Service 实现）
+type FriendLister interface {
+	FriendIDs(userID uint) ([]uint, error)
+}
+
+// RoomLister 聊天室成员（由 chat.Service 实现；聊天室不存在或userID不是成员时返回业务错误）
+type RoomLister interface {
+	RoomMemberIDs(userID, sessionID uint) ([]uint, error)
+}
+
+// SetGroupSources 设置好友榜和聊天室榜的成员来源（未设置时不能查询对应的排行榜）
+func (s *Service) SetGroupSources(friends FriendLister, rooms RoomLister) {
+	s.friends = friends
+	s.rooms = rooms
+}
+
+// GetFriendRankings 好友榜（好友和自己）
+func (s *Service) GetFriendRankings(userID uint, rankType int, q BoardQuery) (*GroupRankingResponse, error) {
+	if s.friends == nil {
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, "未启用好友榜")
+	}
+	friendIDs, err := s.friends.FriendIDs(userID)
+	if err != nil {
+		logger.Error("[ranking.GetFriendRankings] 查询好友失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return s.groupRankings(rankType, ScopeFriends, userID, append(friendIDs, userID), q)
+}
+
+// GetRoomRankings 聊天室榜（只有成员可以查询）
+func (s *Service) GetRoomRankings(userID, roomID uint, rankType int, q BoardQuery) (*GroupRankingResponse, error) {
+	if s.rooms == nil {
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, "未启用聊天室榜")
+	}
+	memberIDs, err := s.rooms.RoomMemberIDs(userID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupRankings(rankType, ScopeRoom, userID, memberIDs, q)
+}
+
+// GetGroupRankings 指定玩家的排行榜（最多maxGroupSize人）
+func (s *Service) GetGroupRankings(rankType int, userIDs []uint, q BoardQuery) (*GroupRankingResponse, error) {
+	return s.groupRankings(rankType, ScopeUsers, 0, userIDs, q)
+}
+
+// groupRankings 查询一组玩家的排行榜（me不为0时返回me在组内的名次）
+func (s *Service) groupRankings(rankType int, scope string, me uint, userIDs []uint, q BoardQuery) (*GroupRankingResponse, error) {
+	def, err := s.publicBoard(rankType)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidatePeriod(q.Period) {
+		return nil, errcode.New(errcode.InvalidPeriod)
+	}
+	userIDs = uniqueIDs(userIDs)
+	if len(userIDs) == 0 {
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, "玩家列表不能为空")
+	}
+	if len(userIDs) > maxGroupSize {
+		return nil, errcode.NewWithMessage(errcode.InvalidParams, fmt.Sprintf("最多查询%d名玩家", maxGroupSize))
+	}
+
+	result := &GroupRankingResponse{
+		RankType: rankType,
+		Scope:    scope,
+		Period:   q.Period,
+		Members:  len(userIDs),
+	}
+	entries, err := s.groupEntries(def, q, userIDs, result)
+	if err != nil {
+		logger.Error("[ranking.groupRankings] 查询群组排行榜失败",
+			zap.Int("rank_type", rankType),
+			zap.String("scope", scope),
+			zap.String("period", q.Period),
+			zap.Int("members", len(userIDs)),
+			zap.Error(err))
+		return nil, err
+	}
+	result.Rankings = s.toRankingItems(entries, 0)
+	if me != 0 {
+		for _, item := range result.Rankings {
+			if item.UserID == me {
+				result.MyRank = item.Rank
+			}
+		}
+	}
+	return result, nil
+}
+
+// groupEntries 按周期查询一组玩家的成绩（已按排行榜的排序方向排序）
+func (s *Service) groupEntries(def *Board, q BoardQuery, userIDs []uint, result *GroupRankingResponse) ([]boardEntry, error) {
+	switch q.Period {
+	case "":
+		rankings, err := s.repo.ListByUsers(def, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]boardEntry, len(rankings))
+		for i, r := range rankings {
+			entries[i] = boardEntry{UserID: r.UserID, Score: r.Score, UpdatedAt: r.UpdatedAt}
+		}
+		return entries, nil
+
+	case PeriodSeason:
+		season, err := s.findBoardSeason(q.SeasonID)
+		if err != nil {
+			return nil, err
+		}
+		vo := toSeasonVO(season, time.Now())
+		result.Season = &vo
+		result.PeriodKey = seasonKey(season.ID)
+		if season.Status != SeasonStatusClosed {
+			return s.groupPeriodEntries(def, PeriodSeason, result.PeriodKey, userIDs)
+		}
+
+		result.Archived = true
+		standings, err := s.repo.ListSeasonStandingsByUsers(season.ID, def.ID, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]boardEntry, len(standings))
+		for i, st := range standings {
+			entries[i] = boardEntry{UserID: st.UserID, Score: st.Score, UpdatedAt: st.UpdatedAt}
+		}
+		return entries, nil
+
+	default:
+		w, err := s.queryWindow(q)
+		if err != nil {
+			return nil, err
+		}
+		result.PeriodKey = w.Key
+		return s.groupPeriodEntries(def, w.Period, w.Key, userIDs)
+	}
+}
+
+// groupPeriodEntries 查询一组玩家的周期成绩
+func (s *Service) groupPeriodEntries(def *Board, period, key string, userIDs []uint) ([]boardEntry, error) {
+	scores, err := s.repo.ListPeriodScoresByUsers(def, period, key, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]boardEntry, len(scores))
+	for i, ps := range scores {
+		entries[i] = boardEntry{UserID: ps.UserID, Score: ps.Score, UpdatedAt: ps.UpdatedAt}
+	}
+	return entries, nil
+}
+
+// uniqueIDs 去重并去掉0
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
@@ -4,10 +4,14 @@
 package ranking
 
 import (
+	stderrors "errors"
 	errcode "faulty_in_culture/go_back/internal/shared/errors"
 	"faulty_in_culture/go_back/internal/shared/response"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -29,43 +33,632 @@ func NewHandler(service *Service) *Handler {
 
 // GetRankings 获取排行榜
 // @Summary 获取排行榜
-// @Description 获取指定类型的排行榜，按分数降序排列，支持分页查询
+// @Description 获取指定类型的排行榜，按分数降序排列，支持分页查询；默认为总榜，可指定赛季榜或日榜/周榜/月榜
 // @Tags ranking
 // @Produce json
-// @Param rank_type path int true "排行榜类型(1-9)"
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param period query string false "周期：season/daily/weekly/monthly，默认为总榜"
+// @Param season query int false "赛季编号（指定时为该赛季的赛季榜，已结束的赛季返回归档的最终排名）"
+// @Param date query string false "日榜/周榜/月榜：包含该日期（YYYY-MM-DD）的周期，默认为当前周期"
 // @Param page query int false "页码，默认为1"
 // @Param limit query int false "每页数量，默认为10，最大100"
+// @Param cursor query string false "游标分页：上一页响应中的next_cursor（传空值从第一名开始），传入时忽略page"
 // @Success 200 {object} RankingListResponse "排行榜数据"
-// @Failure 400 {object} response.Response "排行榜类型无效"
+// @Failure 400 {object} response.Response "排行榜类型或周期无效"
+// @Failure 404 {object} response.Response "赛季不存在"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Router /api/rankings/{rank_type} [get]
 func (h *Handler) GetRankings(c *gin.Context) {
 	// 解析路径参数
-	rankType, _ := strconv.Atoi(c.Param("rank_type"))
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
 
 	// 解析查询参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	q, ok := parseBoardQuery(c)
+	if !ok {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
 
-	// 调用Service层处理业务逻辑
-	rankings, err := h.service.GetRankings(rankType, page, limit)
+	// 调用Service层处理业务逻辑（传入cursor参数时使用游标分页）
+	var (
+		result *RankingListResponse
+		err    error
+	)
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		result, err = h.service.GetRankingsByCursor(rankType, limit, cursor, q)
+	} else {
+		result, err = h.service.GetRankings(rankType, page, limit, q)
+	}
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, errcode.InvalidRankType)
+		handleError(c, err)
 		return
 	}
 
 	// 返回响应
-	response.Success(c, RankingListResponse{
-		RankType: rankType,
-		Page:     page,
-		Limit:    limit,
-		Rankings: rankings,
-	})
+	response.Success(c, result)
+}
+
+// parseBoardQuery 解析周期查询参数（period/season/date），指定season时默认为赛季榜
+func parseBoardQuery(c *gin.Context) (BoardQuery, bool) {
+	q := BoardQuery{
+		Period: c.Query("period"),
+		Date:   c.Query("date"),
+	}
+	if v := c.Query("season"); v != "" {
+		seasonID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || seasonID == 0 {
+			return q, false
+		}
+		q.SeasonID = uint(seasonID)
+		if q.Period == "" {
+			q.Period = PeriodSeason
+		}
+	}
+	return q, true
+}
+
+// GetGroupRankings 获取指定玩家的排行榜
+// @Summary 获取指定玩家的排行榜
+// @Description 只在指定的玩家（最多500人）中排名，名次为组内的相对名次；周期参数与排行榜查询相同
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param user_ids query string true "用户ID，逗号分隔"
+// @Param period query string false "周期：season/daily/weekly/monthly，默认为总榜"
+// @Param season query int false "赛季编号"
+// @Param date query string false "日榜/周榜/月榜：包含该日期（YYYY-MM-DD）的周期"
+// @Success 200 {object} GroupRankingResponse "排行榜数据"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "赛季不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/{rank_type}/group [get]
+func (h *Handler) GetGroupRankings(c *gin.Context) {
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+	q, ok := parseBoardQuery(c)
+	if !ok {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	var userIDs []uint
+	for _, v := range strings.Split(c.Query("user_ids"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			response.ErrorWithMessage(c, http.StatusBadRequest, errcode.InvalidParams, "user_ids格式错误")
+			return
+		}
+		userIDs = append(userIDs, uint(id))
+	}
+
+	result, err := h.service.GetGroupRankings(rankType, userIDs, q)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// ListSeasons 获取赛季列表
+// @Summary 获取赛季列表
+// @Description 获取所有赛季（按编号倒序），current标记当前赛季
+// @Tags ranking
+// @Produce json
+// @Success 200 {array} SeasonVO "赛季列表"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/seasons [get]
+func (h *Handler) ListSeasons(c *gin.Context) {
+	seasons, err := h.service.ListSeasons()
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, seasons)
+}
+
+// ListBoards 获取排行榜列表
+// @Summary 获取排行榜列表
+// @Description 获取所有公开的排行榜定义（排序方向、聚合方式）
+// @Tags ranking
+// @Produce json
+// @Success 200 {array} BoardVO "排行榜列表"
+// @Router /api/rankings/boards [get]
+func (h *Handler) ListBoards(c *gin.Context) {
+	response.Success(c, h.service.ListBoards())
+}
+
+// AdminListBoards 获取所有排行榜定义（管理员）
+// @Summary 获取所有排行榜定义
+// @Description 获取所有排行榜定义，包括隐藏的排行榜
+// @Tags admin
+// @Produce json
+// @Success 200 {array} BoardVO "排行榜列表"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/rankings/boards [get]
+func (h *Handler) AdminListBoards(c *gin.Context) {
+	boards, err := h.service.AdminListBoards()
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, boards)
+}
+
+// AdminCreateBoard 创建排行榜（管理员）
+// @Summary 创建排行榜
+// @Description 创建排行榜定义，ID即提交分数时的rank_type；max_score为0时使用默认上限1000000，分数越低越好的排行榜min_score为0时使用默认下限1，超出范围的提交被隔离，审核通过后才计入排行榜
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body CreateBoardRequest true "创建排行榜请求"
+// @Success 200 {object} BoardVO "创建的排行榜"
+// @Failure 400 {object} response.Response "参数错误或排行榜已存在"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/rankings/boards [post]
+func (h *Handler) AdminCreateBoard(c *gin.Context) {
+	var req CreateBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	board, err := h.service.AdminCreateBoard(c.GetUint("user_id"), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, board)
+}
+
+// AdminUpdateBoard 修改排行榜（管理员）
+// @Summary 修改排行榜
+// @Description 修改排行榜的名称、排序方向、聚合方式和可见性；修改排序方向后排名立即按新方向计算，修改聚合方式只影响之后提交的成绩
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "排行榜类型"
+// @Param request body UpdateBoardRequest true "修改排行榜请求"
+// @Success 200 {object} BoardVO "修改后的排行榜"
+// @Failure 400 {object} response.Response "参数错误或排行榜不存在"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/rankings/boards/{id} [put]
+func (h *Handler) AdminUpdateBoard(c *gin.Context) {
+	rankType, _ := strconv.Atoi(c.Param("id"))
+	if rankType <= 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	var req UpdateBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	board, err := h.service.AdminUpdateBoard(c.GetUint("user_id"), rankType, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, board)
+}
+
+// AdminUpdateSeason 修改赛季（管理员）
+// @Summary 修改赛季
+// @Description 修改进行中或尚未开始的赛季的名称和结束时间，下一赛季从新的结束时间开始
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "赛季编号"
+// @Param request body UpdateSeasonRequest true "修改赛季请求"
+// @Success 200 {object} SeasonVO "修改后的赛季"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 404 {object} response.Response "赛季不存在"
+// @Failure 409 {object} response.Response "赛季已结束"
+// @Router /api/admin/rankings/seasons/{id} [put]
+func (h *Handler) AdminUpdateSeason(c *gin.Context) {
+	seasonID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if seasonID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	var req UpdateSeasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	season, err := h.service.AdminUpdateSeason(c.GetUint("user_id"), uint(seasonID), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, season)
+}
+
+// GetMyRank 获取我的排名（需要认证）
+// @Summary 获取我的排名
+// @Description 获取当前登录用户在指定排行榜中的排名、分数和百分位，同分时先达到该分数的排名靠前
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Success 200 {object} MyRankResponse "我的排名"
+// @Failure 400 {object} response.Response "排行榜类型无效"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 404 {object} response.Response "未参与该排行榜"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/{rank_type}/me [get]
+func (h *Handler) GetMyRank(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+
+	result, err := h.service.GetMyRank(userID, rankType)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// GetAround 获取我附近的排名（需要认证）
+// @Summary 获取我附近的排名
+// @Description 获取当前登录用户前后各radius名的玩家（包含自己），排序与排行榜分页一致
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param radius query int false "前后各取的人数，默认为5，最大50"
+// @Success 200 {object} RankingAroundResponse "附近的排名"
+// @Failure 400 {object} response.Response "排行榜类型无效"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 404 {object} response.Response "未参与该排行榜"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/{rank_type}/around [get]
+func (h *Handler) GetAround(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+	radius, _ := strconv.Atoi(c.DefaultQuery("radius", "5"))
+
+	result, err := h.service.GetAround(userID, rankType, radius)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// GetHistory 获取我的提交记录（需要认证）
+// @Summary 获取我的提交记录
+// @Description 获取当前登录用户在指定排行榜的每次成绩提交（按时间倒序），可查看成绩变化
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param page query int false "页码，默认为1"
+// @Param limit query int false "每页数量，默认为20，最大100"
+// @Success 200 {object} SubmissionHistoryResponse "提交记录"
+// @Failure 400 {object} response.Response "排行榜类型无效"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/{rank_type}/history [get]
+func (h *Handler) GetHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	result, err := h.service.GetHistory(userID, rankType, page, limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// GetFriendRankings 获取好友榜（需要认证）
+// @Summary 获取好友榜
+// @Description 只在自己和好友中排名，名次为组内的相对名次；周期参数与排行榜查询相同
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param period query string false "周期：season/daily/weekly/monthly，默认为总榜"
+// @Param season query int false "赛季编号"
+// @Param date query string false "日榜/周榜/月榜：包含该日期（YYYY-MM-DD）的周期"
+// @Success 200 {object} GroupRankingResponse "排行榜数据"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/{rank_type}/friends [get]
+func (h *Handler) GetFriendRankings(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+	q, ok := parseBoardQuery(c)
+	if !ok {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	result, err := h.service.GetFriendRankings(userID, rankType, q)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// GetRoomRankings 获取聊天室榜（需要认证）
+// @Summary 获取聊天室榜
+// @Description 只在聊天室（公会频道）成员中排名，名次为组内的相对名次；只有成员可以查询
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param room_id path int true "聊天室ID"
+// @Param period query string false "周期：season/daily/weekly/monthly，默认为总榜"
+// @Param season query int false "赛季编号"
+// @Param date query string false "日榜/周榜/月榜：包含该日期（YYYY-MM-DD）的周期"
+// @Success 200 {object} GroupRankingResponse "排行榜数据"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 403 {object} response.Response "不是聊天室成员"
+// @Failure 404 {object} response.Response "聊天室不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/rankings/{rank_type}/rooms/{room_id} [get]
+func (h *Handler) GetRoomRankings(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+	roomID, err := strconv.ParseUint(c.Param("room_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	q, ok := parseBoardQuery(c)
+	if !ok {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	result, err := h.service.GetRoomRankings(userID, uint(roomID), rankType, q)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Subscribe 订阅排行榜（需要认证）
+// @Summary 订阅排行榜
+// @Description 订阅排行榜前10名的变化，变化时通过WebSocket推送leaderboard_updated事件（同一排行榜最多每2秒推送一次）；需要先建立WebSocket连接，连接全部断开后订阅失效
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Success 200 {object} map[string]string "订阅成功"
+// @Failure 400 {object} response.Response "排行榜类型无效或订阅数量超过上限"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 409 {object} response.Response "未建立WebSocket连接"
+// @Router /api/rankings/{rank_type}/subscription [put]
+func (h *Handler) Subscribe(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+
+	if err := h.service.Subscribe(userID, rankType); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "订阅成功", nil)
+}
+
+// Unsubscribe 取消订阅排行榜（需要认证）
+// @Summary 取消订阅排行榜
+// @Description 取消订阅排行榜的leaderboard_updated事件（名次变化的rank_changed事件不需要订阅）
+// @Tags ranking
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Success 200 {object} map[string]string "已取消订阅"
+// @Failure 400 {object} response.Response "排行榜类型无效"
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/rankings/{rank_type}/subscription [delete]
+func (h *Handler) Unsubscribe(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+
+	if err := h.service.Unsubscribe(userID, rankType); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "已取消订阅", nil)
+}
+
+// AdminExportRankings 导出排行榜（管理员）
+// @Summary 导出排行榜
+// @Description 以CSV或JSON格式导出完整的排行榜（包括隐藏的排行榜），分批读取并以流的方式写出；周期参数与排行榜查询相同，已归档的赛季榜不支持导出
+// @Tags admin
+// @Produce text/csv
+// @Produce json
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
+// @Param format query string false "csv（默认）或json"
+// @Param period query string false "周期：season/daily/weekly/monthly，默认为总榜"
+// @Param season query int false "赛季编号"
+// @Param date query string false "日榜/周榜/月榜：包含该日期（YYYY-MM-DD）的周期"
+// @Success 200 {file} file "排行榜数据"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/rankings/{rank_type}/export [get]
+func (h *Handler) AdminExportRankings(c *gin.Context) {
+	operatorID := c.GetUint("user_id")
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
+	q, ok := parseBoardQuery(c)
+	if !ok {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	export, err := h.service.NewBoardExport(operatorID, rankType, c.Query("format"), q)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	// 开始写出后无法再返回JSON错误，失败时只能中断响应（已在Service层记录日志）
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename))
+	c.Header("Content-Type", export.ContentType)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	if _, err := export.Write(c.Writer); err != nil {
+		c.Abort()
+	}
+}
+
+// AdminSearchSubmissions 查询成绩提交记录（管理员）
+// @Summary 查询成绩提交记录
+// @Description 按玩家、排行榜、状态、对局ID和时间范围查询成绩提交记录，suspicious=true时只看有可疑标记（duplicate_run/score_jump/score_limit/delta_limit）、被隔离或被拒绝的提交
+// @Tags admin
+// @Produce json
+// @Param user_id query int false "用户ID"
+// @Param rank_type query int false "排行榜类型"
+// @Param status query string false "accepted/rejected/quarantined"
+// @Param run_id query string false "对局ID"
+// @Param suspicious query bool false "只看可疑的提交"
+// @Param since query string false "开始时间（RFC3339）"
+// @Param until query string false "结束时间（RFC3339）"
+// @Param page query int false "页码，默认为1"
+// @Param limit query int false "每页数量，默认为20，最大100"
+// @Success 200 {object} AdminSubmissionListVO "提交记录"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/rankings/submissions [get]
+func (h *Handler) AdminSearchSubmissions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	userID, _ := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	rankType, _ := strconv.Atoi(c.Query("rank_type"))
+	f := SubmissionFilter{
+		UserID:     uint(userID),
+		RankType:   rankType,
+		Status:     c.Query("status"),
+		RunID:      c.Query("run_id"),
+		Suspicious: c.Query("suspicious") == "true",
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				response.ErrorWithMessage(c, http.StatusBadRequest, errcode.InvalidParams, p.name+"必须为RFC3339格式的时间")
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	result, err := h.service.AdminSearchSubmissions(f, page, limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// CreateRun 开始对局（需要认证）
+// @Summary 开始对局
+// @Description 签发对局ID和对局密钥；提交成绩时携带run_id和signature=hex(HMAC-SHA256(run_key, "run_id:rank_type:score"))，每个对局只能提交一次。run_key会返回给客户端，签名只能证明成绩来自服务端签发的对局且只提交一次，不能证明分数真实（分数仍经过范围和提升幅度校验）
+// @Tags ranking
+// @Accept json
+// @Produce json
+// @Param request body CreateRunRequest true "开始对局请求"
+// @Success 200 {object} RunVO "对局"
+// @Failure 400 {object} response.Response "排行榜类型无效"
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/rankings/runs [post]
+func (h *Handler) CreateRun(c *gin.Context) {
+	var req CreateRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	run, err := h.service.IssueRun(c.GetUint("user_id"), req.RankType)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, run)
+}
+
+// AdminApproveSubmission 审核通过隔离的成绩（管理员）
+// @Summary 审核通过隔离的成绩
+// @Description 将隔离的提交计入总榜、提交时所在的赛季榜（赛季未归档时）和日榜/周榜/月榜
+// @Tags admin
+// @Produce json
+// @Param id path int true "提交记录ID"
+// @Success 200 {object} AdminSubmissionVO "审核后的提交记录"
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 404 {object} response.Response "提交记录不存在"
+// @Failure 409 {object} response.Response "提交记录已审核"
+// @Router /api/admin/rankings/submissions/{id}/approve [post]
+func (h *Handler) AdminApproveSubmission(c *gin.Context) {
+	h.reviewSubmission(c, true)
+}
+
+// AdminRejectSubmission 拒绝隔离的成绩（管理员）
+// @Summary 拒绝隔离的成绩
+// @Description 拒绝隔离的提交，成绩不计入排行榜
+// @Tags admin
+// @Produce json
+// @Param id path int true "提交记录ID"
+// @Success 200 {object} AdminSubmissionVO "审核后的提交记录"
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 404 {object} response.Response "提交记录不存在"
+// @Failure 409 {object} response.Response "提交记录已审核"
+// @Router /api/admin/rankings/submissions/{id}/reject [post]
+func (h *Handler) AdminRejectSubmission(c *gin.Context) {
+	h.reviewSubmission(c, false)
+}
+
+// reviewSubmission 审核隔离的成绩
+func (h *Handler) reviewSubmission(c *gin.Context, approve bool) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	sub, err := h.service.AdminReviewSubmission(c.GetUint("user_id"), uint(id), approve)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, sub)
 }
 
 // UpdateScore 更新排行榜分数（需要认证）
 // @Summary 更新排行榜分数
-// @Description 更新当前登录用户的指定排行榜分数，只在新分数更高时更新
+// @Description 更新当前登录用户的指定排行榜分数，按排行榜的聚合方式合并（如最高分只在新分数更高时更新）；同时计入当前赛季榜和日榜/周榜/月榜，每次提交都保存提交记录；超出排行榜分数上限/增幅上限或重复提交同一局的成绩被隔离（status=quarantined），审核通过后才计入排行榜
 // @Tags ranking
 // @Accept json
 // @Produce json
@@ -73,6 +666,9 @@ func (h *Handler) GetRankings(c *gin.Context) {
 // @Success 200 {object} UpdateScoreResponse "更新成功"
 // @Failure 400 {object} response.Response "参数错误"
 // @Failure 401 {object} response.Response "未认证"
+// @Failure 403 {object} response.Response "对局签名无效"
+// @Failure 409 {object} response.Response "提交的赛季已结束"
+// @Failure 429 {object} response.Response "提交过于频繁"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Router /api/rankings [post]
 func (h *Handler) UpdateScore(c *gin.Context) {
@@ -85,19 +681,14 @@ func (h *Handler) UpdateScore(c *gin.Context) {
 	}
 
 	// 调用Service层处理业务逻辑
-	ranking, err := h.service.UpdateScore(userID, req.RankType, req.Score)
+	result, err := h.service.UpdateScore(userID, &req, c.ClientIP())
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, errcode.InvalidRankType)
+		handleError(c, err)
 		return
 	}
 
 	// 返回响应
-	response.Success(c, UpdateScoreResponse{
-		UserID:    ranking.UserID,
-		RankType:  ranking.RankType,
-		Score:     ranking.Score,
-		UpdatedAt: ranking.UpdatedAt,
-	})
+	response.Success(c, result)
 }
 
 // DeleteRanking 删除指定类型的排行榜记录（需要认证）
@@ -105,7 +696,7 @@ func (h *Handler) UpdateScore(c *gin.Context) {
 // @Description 删除当前登录用户的指定类型排行榜记录
 // @Tags ranking
 // @Produce json
-// @Param rank_type path int true "排行榜类型(1-9)"
+// @Param rank_type path string true "排行榜类型（ID或标识，如1或board_1）"
 // @Success 200 {object} map[string]string "删除成功"
 // @Failure 400 {object} response.Response "参数错误"
 // @Failure 401 {object} response.Response "未认证"
@@ -113,7 +704,7 @@ func (h *Handler) UpdateScore(c *gin.Context) {
 // @Router /api/rankings/{rank_type} [delete]
 func (h *Handler) DeleteRanking(c *gin.Context) {
 	userID := c.GetUint("user_id")
-	rankType, _ := strconv.Atoi(c.Param("rank_type"))
+	rankType := h.service.ResolveRankType(c.Param("rank_type"))
 
 	if err := h.service.DeleteRanking(userID, rankType); err != nil {
 		response.Error(c, http.StatusBadRequest, errcode.InvalidRankType)
@@ -142,3 +733,29 @@ func (h *Handler) DeleteAllRankings(c *gin.Context) {
 
 	response.SuccessWithMessage(c, "删除成功", nil)
 }
+
+// handleError 将业务错误转换为HTTP响应
+func handleError(c *gin.Context, err error) {
+	var e *errcode.Error
+	if !stderrors.As(err, &e) {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	switch e.Code {
+	case errcode.RankingNotFound, errcode.SeasonNotFound, errcode.SubmissionNotFound, errcode.SessionNotFound:
+		response.Error(c, http.StatusNotFound, e.Code)
+	case errcode.PermissionDenied:
+		response.Error(c, http.StatusForbidden, e.Code)
+	case errcode.SeasonEnded, errcode.SubmissionReviewed, errcode.NotConnected:
+		response.Error(c, http.StatusConflict, e.Code)
+	case errcode.SignatureInvalid:
+		response.Error(c, http.StatusForbidden, e.Code)
+	case errcode.SubmitTooFrequent:
+		response.Error(c, http.StatusTooManyRequests, e.Code)
+	case errcode.UpdateScoreFailed:
+		response.Error(c, http.StatusInternalServerError, e.Code)
+	default:
+		response.ErrorWithMessage(c, http.StatusBadRequest, e.Code, e.Message)
+	}
+}
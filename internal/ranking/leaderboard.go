@@ -0,0 +1,333 @@
+// Package ranking - Redis排行榜
+// 功能：每种排行榜类型一个Redis有序集合，作为排行榜的主要读取路径
+// 特点：MySQL是唯一的数据来源，Redis数据丢失或不一致时可以随时从MySQL重建
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/cache"
+	"faulty_in_culture/go_back/internal/infra/logger"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// Redis排行榜
+// 键（{order}为排行榜的排序方向 desc/asc）：
+//   leaderboard:{rank_type}:{order}          有序集合，分数为玩家分数
+//   leaderboard:{rank_type}:{order}:members  哈希，user_id -> 有序集合中的成员
+//   leaderboard:{rank_type}:{order}:ready    标记该排行榜已从MySQL加载
+// 排序方向写在键中：修改排序方向后，仍缓存旧定义的实例（最多 boardCacheTTL）只会写入旧方向的键，
+//          不会把旧符号的分数写进按新方向重建的有序集合
+// 同分排序：成员编码为“反转的更新时间毫秒数:反转的user_id”（均补零到定长），分数相同时Redis按成员字典序倒序排列，
+//          更早更新、更小user_id的成员反转后更大、排在前面，与MySQL中 updated_at ASC, user_id ASC 的次级排序一致，
+//          从Redis读取的页面可以直接生成游标，与游标分页的SQL排序衔接
+// 排序方向：分数越低越好的排行榜在有序集合中保存分数的相反数，读取时再取反
+// 加载：读写前检查ready标记，不存在（首次启动、Redis被清空）时从MySQL重建；
+//      同步失败时删除ready标记，下次访问时重建
+// 写入：在Lua脚本中与已有成员比较后写入，只接受比已有成绩更新的成绩（见 zaddCondition），
+//      并发提交时后完成的同步不会用旧成绩覆盖新成绩
+// ============================================================
+
+const (
+	rebuildBatchSize = 1000           // 重建时每批从MySQL读取的记录数
+	memberTimeBase   = 9999999999999  // 13位毫秒时间戳上限，成员中保存 memberTimeBase - 更新时间
+	memberIDBase     = math.MaxUint64 // user_id上限（20位），成员中保存 memberIDBase - user_id
+)
+
+// Store 排行榜存储接口（Redis有序集合，由 infra/cache 实现）
+type Store interface {
+	Exists(key string) (bool, error)
+	SetWithoutExpiration(key string, value interface{}) error
+	Delete(key string) error
+	ZAddIndexed(zsetKey, indexKey string, m cache.IndexedMember, cond string) (bool, error)
+	ZRemIndexed(zsetKey, indexKey string, ids ...string) error
+	ZAddIndexedBatch(zsetKey, indexKey string, members []cache.IndexedMember) error
+	ZRevRangeWithScores(key string, start, stop int64) ([]cache.ZMember, error)
+	ZRevRankIndexed(zsetKey, indexKey, id string) (*cache.IndexedRank, error)
+	ReplaceKeys(pairs ...[2]string) error
+}
+
+// boardEntry 排行榜中的一条记录
+type boardEntry struct {
+	UserID    uint
+	Score     int
+	UpdatedAt time.Time
+}
+
+// boardPosition 玩家在排行榜中的位置
+type boardPosition struct {
+	Entry boardEntry
+	Rank  int   // 排名（从1开始）
+	Total int64 // 排行榜总人数
+}
+
+// leaderboard Redis排行榜
+type leaderboard struct {
+	store     Store
+	repo      Repository
+	rebuildMu sync.Mutex // 同一进程内串行重建，避免并发访问时重复加载
+}
+
+// newLeaderboard 创建Redis排行榜
+func newLeaderboard(store Store, repo Repository) *leaderboard {
+	return &leaderboard{store: store, repo: repo}
+}
+
+// sortOrders 全部排序方向（按排行榜ID移除成员或标记重建时两个方向的键都要处理）
+var sortOrders = []string{SortDesc, SortAsc}
+
+func boardKey(rankType int, order string) string {
+	return fmt.Sprintf("leaderboard:%d:%s", rankType, order)
+}
+func indexKey(rankType int, order string) string {
+	return fmt.Sprintf("leaderboard:%d:%s:members", rankType, order)
+}
+func readyKey(rankType int, order string) string {
+	return fmt.Sprintf("leaderboard:%d:%s:ready", rankType, order)
+}
+
+// sortOrderOf 排行榜在Redis键中使用的排序方向
+func sortOrderOf(b *Board) string {
+	if b.Ascending() {
+		return SortAsc
+	}
+	return SortDesc
+}
+
+// encodeMember 编码有序集合成员（反转的更新时间在前、反转的user_id在后，用于同分排序）
+func encodeMember(userID uint, updatedAt time.Time) string {
+	return fmt.Sprintf("%013d:%020d", memberTimeBase-updatedAt.UnixMilli(), uint64(memberIDBase)-uint64(userID))
+}
+
+// toIndexedMember 转换为有序集合成员
+func toIndexedMember(b *Board, r *Entity) cache.IndexedMember {
+	return cache.IndexedMember{
+		ID:     strconv.FormatUint(uint64(r.UserID), 10),
+		Member: encodeMember(r.UserID, r.UpdatedAt),
+		Score:  toZScore(b, r.Score),
+	}
+}
+
+// zaddCondition 写入有序集合的条件：总榜成绩只会朝聚合方式的方向变化，不满足条件的是过期的成绩
+// 最高分和累计（分数不小于0）只增不减，最低分只减不增；最近一次提交比较成员中的更新时间（反转后越新越小）
+func zaddCondition(b *Board) string {
+	higher, lower := cache.ZAddGT, cache.ZAddLT
+	if b.Ascending() {
+		higher, lower = lower, higher
+	}
+	switch b.Aggregation {
+	case AggregateMin:
+		return lower
+	case AggregateLatest:
+		return cache.ZAddMemberLE
+	default:
+		return higher
+	}
+}
+
+// toZScore 分数转换为有序集合中的分数（分数越低越好时取反）
+func toZScore(b *Board, score int) float64 {
+	if b.Ascending() {
+		return -float64(score)
+	}
+	return float64(score)
+}
+
+// fromZScore 有序集合中的分数转换为分数
+func fromZScore(b *Board, z float64) int {
+	if b.Ascending() {
+		return int(-z)
+	}
+	return int(z)
+}
+
+// decodeMember 解码有序集合成员
+func decodeMember(member string) (uint, time.Time, bool) {
+	inverted, id, ok := strings.Cut(member, ":")
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	millis, err := strconv.ParseInt(inverted, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	invertedID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return uint(memberIDBase - invertedID), time.UnixMilli(memberTimeBase - millis), true
+}
+
+// ensureLoaded 确保排行榜已从MySQL加载
+func (b *leaderboard) ensureLoaded(def *Board) error {
+	ready, err := b.store.Exists(readyKey(def.ID, sortOrderOf(def)))
+	if err != nil || ready {
+		return err
+	}
+
+	b.rebuildMu.Lock()
+	defer b.rebuildMu.Unlock()
+	// 等待锁期间可能已被其他请求加载
+	if ready, err := b.store.Exists(readyKey(def.ID, sortOrderOf(def))); err != nil || ready {
+		return err
+	}
+	_, err = b.rebuild(def)
+	return err
+}
+
+// Set 写入玩家的当前分数（有序集合中已有更新的成绩时忽略）
+func (b *leaderboard) Set(def *Board, r *Entity) error {
+	if err := b.ensureLoaded(def); err != nil {
+		return err
+	}
+	order := sortOrderOf(def)
+	_, err := b.store.ZAddIndexed(boardKey(def.ID, order), indexKey(def.ID, order), toIndexedMember(def, r), zaddCondition(def))
+	return err
+}
+
+// Remove 从排行榜中移除玩家（两个排序方向的键都移除）
+func (b *leaderboard) Remove(rankType int, userIDs ...uint) error {
+	ids := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	for _, order := range sortOrders {
+		if err := b.store.ZRemIndexed(boardKey(rankType, order), indexKey(rankType, order), ids...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range 按排名获取一段记录（offset从0开始）
+func (b *leaderboard) Range(def *Board, offset, limit int) ([]boardEntry, error) {
+	rankType := def.ID
+	if err := b.ensureLoaded(def); err != nil {
+		return nil, err
+	}
+	members, err := b.store.ZRevRangeWithScores(boardKey(rankType, sortOrderOf(def)), int64(offset), int64(offset+limit-1))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]boardEntry, 0, len(members))
+	for _, m := range members {
+		userID, updatedAt, ok := decodeMember(m.Member)
+		if !ok {
+			logger.Warn("[ranking.leaderboard] 无法解析的排行榜成员", zap.Int("rank_type", rankType), zap.String("member", m.Member))
+			continue
+		}
+		entries = append(entries, boardEntry{UserID: userID, Score: fromZScore(def, m.Score), UpdatedAt: updatedAt})
+	}
+	return entries, nil
+}
+
+// Locate 查询玩家的排名，不在排行榜中时返回nil
+// 排名直接取自有序集合，同分时的先后与Range一致
+func (b *leaderboard) Locate(def *Board, userID uint) (*boardPosition, error) {
+	if err := b.ensureLoaded(def); err != nil {
+		return nil, err
+	}
+	order := sortOrderOf(def)
+	r, err := b.store.ZRevRankIndexed(boardKey(def.ID, order), indexKey(def.ID, order), strconv.FormatUint(uint64(userID), 10))
+	if err != nil || r == nil {
+		return nil, err
+	}
+	_, updatedAt, ok := decodeMember(r.Member)
+	if !ok {
+		return nil, fmt.Errorf("无法解析的排行榜成员: %s", r.Member)
+	}
+	return &boardPosition{
+		Entry: boardEntry{UserID: userID, Score: fromZScore(def, r.Score), UpdatedAt: updatedAt},
+		Rank:  int(r.Rank) + 1,
+		Total: r.Total,
+	}, nil
+}
+
+// Invalidate 标记排行榜需要重建（同步失败、修改排序方向时调用，两个排序方向的键都标记）
+func (b *leaderboard) Invalidate(rankType int) {
+	for _, order := range sortOrders {
+		if err := b.store.Delete(readyKey(rankType, order)); err != nil {
+			logger.Error("[ranking.leaderboard] 标记排行榜重建失败", zap.Int("rank_type", rankType), zap.String("order", order), zap.Error(err))
+		}
+	}
+}
+
+// rebuild 从MySQL重建排行榜，返回写入的记录数
+// 先写入临时键再原子替换，重建期间读取的仍是旧数据；重建期间更新的记录在替换后补写
+func (b *leaderboard) rebuild(def *Board) (int, error) {
+	rankType, order := def.ID, sortOrderOf(def)
+	started := time.Now()
+	suffix := strconv.FormatInt(started.UnixNano(), 36)
+	tmpBoard := boardKey(rankType, order) + ":rebuild:" + suffix
+	tmpIndex := indexKey(rankType, order) + ":rebuild:" + suffix
+
+	total := 0
+	var afterID uint
+	for {
+		rows, err := b.repo.ListByRankType(rankType, afterID, rebuildBatchSize)
+		if err != nil {
+			b.store.Delete(tmpBoard)
+			b.store.Delete(tmpIndex)
+			return total, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		members := make([]cache.IndexedMember, len(rows))
+		for i, r := range rows {
+			members[i] = toIndexedMember(def, r)
+		}
+		if err := b.store.ZAddIndexedBatch(tmpBoard, tmpIndex, members); err != nil {
+			b.store.Delete(tmpBoard)
+			b.store.Delete(tmpIndex)
+			return total, err
+		}
+		total += len(rows)
+		afterID = rows[len(rows)-1].ID
+		if len(rows) < rebuildBatchSize {
+			break
+		}
+	}
+
+	if err := b.store.ReplaceKeys(
+		[2]string{tmpBoard, boardKey(rankType, order)},
+		[2]string{tmpIndex, indexKey(rankType, order)},
+	); err != nil {
+		return total, err
+	}
+	if err := b.store.SetWithoutExpiration(readyKey(rankType, order), started.Unix()); err != nil {
+		return total, err
+	}
+
+	// 补写重建期间更新过的记录（MySQL的updated_at可能只精确到秒，多回退1秒）
+	recent, err := b.repo.ListUpdatedSince(rankType, started.Add(-time.Second))
+	if err != nil {
+		return total, err
+	}
+	for _, r := range recent {
+		if _, err := b.store.ZAddIndexed(boardKey(rankType, order), indexKey(rankType, order), toIndexedMember(def, r), zaddCondition(def)); err != nil {
+			return total, err
+		}
+	}
+
+	logger.Info("[ranking.leaderboard] 排行榜已从数据库重建",
+		zap.Int("rank_type", rankType),
+		zap.Int("count", total),
+		zap.Duration("elapsed", time.Since(started)))
+	return total, nil
+}
+
+// Rebuild 强制从MySQL重建排行榜（排序方向修改后需要重建）
+func (b *leaderboard) Rebuild(def *Board) (int, error) {
+	b.rebuildMu.Lock()
+	defer b.rebuildMu.Unlock()
+	return b.rebuild(def)
+}
@@ -0,0 +1,73 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMemberRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    uint
+		updatedAt time.Time
+	}{
+		{"普通", 42, time.UnixMilli(1766200000123)},
+		{"最小user_id", 0, time.UnixMilli(1766200000123)},
+		{"最大user_id", math.MaxUint32, time.UnixMilli(1766200000123)},
+		{"Unix纪元", 1, time.UnixMilli(0)},
+		{"最大时间", 1, time.UnixMilli(memberTimeBase)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			member := encodeMember(tt.userID, tt.updatedAt)
+			if len(member) != 13+1+20 {
+				t.Errorf("encodeMember() = %q, 长度应固定为34", member)
+			}
+			userID, updatedAt, ok := decodeMember(member)
+			if !ok || userID != tt.userID || !updatedAt.Equal(tt.updatedAt) {
+				t.Errorf("decodeMember(%q) = %d, %v, %v, want %d, %v", member, userID, updatedAt, ok, tt.userID, tt.updatedAt)
+			}
+		})
+	}
+}
+
+// TestMemberOrdering 同分时有序集合按成员字典序倒序排名：更早达到的在前，同一时刻user_id小的在前
+func TestMemberOrdering(t *testing.T) {
+	base := time.UnixMilli(1766200000000)
+
+	tests := []struct {
+		name          string
+		ahead, behind string
+	}{
+		{"更早达到的在前", encodeMember(900, base), encodeMember(1, base.Add(time.Millisecond))},
+		{"相差一天", encodeMember(1, base), encodeMember(1, base.Add(24*time.Hour))},
+		{"同一时刻user_id小的在前", encodeMember(9, base), encodeMember(10, base)},
+		{"user_id位数不同", encodeMember(99999, base), encodeMember(100000, base)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.ahead <= tt.behind {
+				t.Errorf("成员 %q 应排在 %q 之前（字典序更大）", tt.ahead, tt.behind)
+			}
+		})
+	}
+}
+
+func TestDecodeMemberInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"8233799999876",
+		"8233799999876:",
+		":18446744073709551573",
+		"abc:18446744073709551573",
+		"8233799999876:abc",
+		"8233799999876:-1",
+		"42",
+	}
+	for _, member := range tests {
+		if _, _, ok := decodeMember(member); ok {
+			t.Errorf("decodeMember(%q) ok = true, want false", member)
+		}
+	}
+}
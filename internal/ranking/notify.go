@@ -0,0 +1,260 @@
+// Package ranking - 排名变化实时推送
+// 功能：成绩提升后通过WebSocket推送名次变化，向订阅排行榜的玩家广播前N名的变化
+package ranking
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 实时推送（只推送公开排行榜的总榜）
+// 1. rank_changed：名次变化时推送给提交者；被超过的前N名玩家（名次下降一位，包括被挤出前N名的第N+1名）也会收到
+// 2. leaderboard_updated：前N名变化时推送给订阅该排行榜的玩家，同一排行榜每个间隔最多推送一次，
+//    间隔内的多次变化合并到间隔结束时推送最新的前N名
+// 3. 订阅保存在内存中，只对当前实例的WebSocket连接有效，用户的最后一个连接断开时清除
+// 名次在成绩写入前后各查询一次，并发提交时推送的名次可能不精确，以查询接口为准
+// ============================================================
+
+// 推送相关常量
+const (
+	notifyTop                = 10              // 关注名次变化的前N名
+	leaderboardEventInterval = 2 * time.Second // 同一排行榜的榜单更新推送间隔
+	maxSubscriptions         = 20              // 每个用户最多订阅的排行榜数
+)
+
+// Notifier 在线状态和实时推送（由 ws.Manager 实现）
+type Notifier interface {
+	IsOnline(userID uint) bool
+	SendToUser(userID uint, message interface{}) error
+}
+
+// subscriptions 排行榜订阅和榜单更新推送的节流状态
+type subscriptions struct {
+	mu       sync.Mutex
+	byBoard  map[int]map[uint]struct{} // rank_type -> 订阅的用户
+	byUser   map[uint]map[int]struct{} // user_id -> 订阅的排行榜
+	pending  map[int]bool              // 已安排推送的排行榜
+	lastSent map[int]time.Time         // 上次推送的时间
+}
+
+// newSubscriptions 创建订阅表
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		byBoard:  make(map[int]map[uint]struct{}),
+		byUser:   make(map[uint]map[int]struct{}),
+		pending:  make(map[int]bool),
+		lastSent: make(map[int]time.Time),
+	}
+}
+
+// SetNotifier 设置实时推送（未设置时不推送名次变化，也不能订阅排行榜）
+func (s *Service) SetNotifier(n Notifier) {
+	s.notifier = n
+	s.subs = newSubscriptions()
+}
+
+// Subscribe 订阅排行榜的前N名变化（需要已建立WebSocket连接）
+func (s *Service) Subscribe(userID uint, rankType int) error {
+	def, err := s.publicBoard(rankType)
+	if err != nil {
+		return err
+	}
+	if s.notifier == nil || !s.notifier.IsOnline(userID) {
+		return errcode.New(errcode.NotConnected)
+	}
+
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+	boards := s.subs.byUser[userID]
+	if _, ok := boards[def.ID]; ok {
+		return nil
+	}
+	if len(boards) >= maxSubscriptions {
+		return errcode.NewWithMessage(errcode.InvalidParams, fmt.Sprintf("最多订阅%d个排行榜", maxSubscriptions))
+	}
+	if boards == nil {
+		boards = make(map[int]struct{})
+		s.subs.byUser[userID] = boards
+	}
+	boards[def.ID] = struct{}{}
+	if s.subs.byBoard[def.ID] == nil {
+		s.subs.byBoard[def.ID] = make(map[uint]struct{})
+	}
+	s.subs.byBoard[def.ID][userID] = struct{}{}
+	return nil
+}
+
+// Unsubscribe 取消订阅排行榜（未订阅时直接返回）
+func (s *Service) Unsubscribe(userID uint, rankType int) error {
+	if s.boardDef(rankType) == nil {
+		return errcode.New(errcode.InvalidRankType)
+	}
+	if s.subs == nil {
+		return nil
+	}
+
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+	s.subs.remove(userID, rankType)
+	return nil
+}
+
+// OnPresenceChange 用户的最后一个连接断开时清除其订阅（注册为 ws.Manager 的在线状态回调）
+func (s *Service) OnPresenceChange(userID uint, online bool) {
+	// 回调是异步的，快速重连时可能乱序到达：以当前实际状态为准
+	if online || s.subs == nil || s.notifier.IsOnline(userID) {
+		return
+	}
+
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+	for rankType := range s.subs.byUser[userID] {
+		s.subs.remove(userID, rankType)
+	}
+}
+
+// remove 删除一条订阅（调用方持有锁）
+func (t *subscriptions) remove(userID uint, rankType int) {
+	if boards := t.byUser[userID]; boards != nil {
+		delete(boards, rankType)
+		if len(boards) == 0 {
+			delete(t.byUser, userID)
+		}
+	}
+	if users := t.byBoard[rankType]; users != nil {
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(t.byBoard, rankType)
+		}
+	}
+}
+
+// positionBefore 写入成绩前查询玩家的名次（不需要推送或查询失败时watch为false）
+func (s *Service) positionBefore(def *Board, userID uint) (pos *boardPosition, watch bool) {
+	if s.notifier == nil || !def.IsPublic() {
+		return nil, false
+	}
+	pos, err := s.locate(userID, def)
+	if err != nil {
+		logger.Warn("[ranking.positionBefore] 查询名次失败，不推送本次名次变化",
+			zap.Uint("user_id", userID),
+			zap.Int("rank_type", def.ID),
+			zap.Error(err))
+		return nil, false
+	}
+	return pos, true
+}
+
+// notifyRankChange 成绩变化后推送名次变化（before为写入前的名次，之前不在排行榜上时为nil）
+func (s *Service) notifyRankChange(def *Board, userID uint, before *boardPosition) {
+	after, err := s.locate(userID, def)
+	if err != nil || after == nil {
+		logger.Warn("[ranking.notifyRankChange] 查询名次失败", zap.Uint("user_id", userID), zap.Int("rank_type", def.ID), zap.Error(err))
+		return
+	}
+	oldRank := 0
+	if before != nil {
+		oldRank = before.Rank
+	}
+
+	if oldRank != after.Rank {
+		s.push(userID, RankChangedEvent{
+			Type:     "rank_changed",
+			RankType: def.ID,
+			OldRank:  oldRank,
+			NewRank:  after.Rank,
+			Score:    after.Entry.Score,
+		})
+	}
+	if after.Rank <= notifyTop || (oldRank != 0 && oldRank <= notifyTop) {
+		s.scheduleLeaderboardEvent(def.ID)
+	}
+
+	// 被超过的玩家：名次上升时，新名次之后到原名次（之前不在排行榜上时为排行榜末尾）的玩家各下降一位
+	if after.Rank > notifyTop || (oldRank != 0 && oldRank <= after.Rank) {
+		return
+	}
+	last := notifyTop + 1 // 第N+1名是被挤出前N名的玩家
+	if oldRank != 0 && oldRank < last {
+		last = oldRank
+	}
+	entries, err := s.rangeEntries(def, after.Rank, last-after.Rank)
+	if err != nil {
+		logger.Warn("[ranking.notifyRankChange] 查询被超过的玩家失败", zap.Int("rank_type", def.ID), zap.Error(err))
+		return
+	}
+	overtaker := s.toRankingItems([]boardEntry{after.Entry}, after.Rank-1)[0]
+	for i, e := range entries {
+		if e.UserID == userID {
+			continue
+		}
+		newRank := after.Rank + 1 + i
+		s.push(e.UserID, RankChangedEvent{
+			Type:        "rank_changed",
+			RankType:    def.ID,
+			OldRank:     newRank - 1,
+			NewRank:     newRank,
+			Score:       e.Score,
+			OvertakenBy: &overtaker,
+		})
+	}
+}
+
+// scheduleLeaderboardEvent 安排向订阅者推送排行榜的前N名（距上次推送不足间隔时延迟到间隔结束）
+func (s *Service) scheduleLeaderboardEvent(rankType int) {
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+	if len(s.subs.byBoard[rankType]) == 0 || s.subs.pending[rankType] {
+		return
+	}
+	s.subs.pending[rankType] = true
+	delay := leaderboardEventInterval - time.Since(s.subs.lastSent[rankType])
+	if delay < 0 {
+		delay = 0
+	}
+	time.AfterFunc(delay, func() { s.broadcastLeaderboard(rankType) })
+}
+
+// broadcastLeaderboard 向订阅者推送排行榜当前的前N名
+func (s *Service) broadcastLeaderboard(rankType int) {
+	s.subs.mu.Lock()
+	delete(s.subs.pending, rankType)
+	s.subs.lastSent[rankType] = time.Now()
+	subscribers := make([]uint, 0, len(s.subs.byBoard[rankType]))
+	for userID := range s.subs.byBoard[rankType] {
+		subscribers = append(subscribers, userID)
+	}
+	s.subs.mu.Unlock()
+
+	def := s.boardDef(rankType)
+	if len(subscribers) == 0 || def == nil || !def.IsPublic() {
+		return
+	}
+	entries, err := s.rangeEntries(def, 0, notifyTop)
+	if err != nil {
+		logger.Warn("[ranking.broadcastLeaderboard] 查询排行榜失败", zap.Int("rank_type", rankType), zap.Error(err))
+		return
+	}
+	event := LeaderboardUpdatedEvent{
+		Type:     "leaderboard_updated",
+		RankType: rankType,
+		Top:      s.toRankingItems(entries, 0),
+	}
+	for _, userID := range subscribers {
+		s.push(userID, event)
+	}
+}
+
+// push 通过WebSocket推送事件（失败只记录日志）
+func (s *Service) push(userID uint, event interface{}) {
+	if err := s.notifier.SendToUser(userID, event); err != nil {
+		logger.Warn("[ranking.push] WebSocket推送失败", zap.Uint("user_id", userID), zap.Error(err))
+	}
+}
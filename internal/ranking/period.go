@@ -0,0 +1,71 @@
+// Package ranking - 赛季与周期排行榜
+// 功能：按赛季、自然日、自然周、自然月划分成绩，提交分数时写入所在的每个周期
+// 特点：总榜仍由 rankings 表和Redis排行榜提供，周期榜只查询MySQL
+package ranking
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ============================================================
+// 周期划分
+// 日榜：2026-10-18；周榜（ISO周，周一开始）：2026-W42；月榜：2026-10；赛季榜：赛季编号
+// 日榜/周榜/月榜按配置的时区划分，赛季按赛季的起止时间划分
+// ============================================================
+
+// BoardQuery 排行榜范围（Period为空时为总榜）
+type BoardQuery struct {
+	Period   string // season/daily/weekly/monthly
+	SeasonID uint   // 赛季榜：指定赛季（为0时为当前赛季）
+	Date     string // 日榜/周榜/月榜：包含该日期（YYYY-MM-DD）的周期（为空时为当前周期）
+}
+
+// periodWindow 一个周期
+type periodWindow struct {
+	Period string
+	Key    string
+	EndsAt time.Time
+}
+
+// ValidatePeriod 验证排行榜周期（为空表示总榜）
+func ValidatePeriod(period string) bool {
+	switch period {
+	case "", PeriodSeason, PeriodDaily, PeriodWeekly, PeriodMonthly:
+		return true
+	}
+	return false
+}
+
+// calendarPeriods 日榜、周榜、月榜
+var calendarPeriods = []string{PeriodDaily, PeriodWeekly, PeriodMonthly}
+
+// calendarWindow 包含时间t的自然日/周/月
+func calendarWindow(period string, t time.Time, loc *time.Location) periodWindow {
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	switch period {
+	case PeriodWeekly:
+		year, week := t.ISOWeek()
+		// 周一为一周的第一天（time.Sunday == 0）
+		start := day.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return periodWindow{Period: period, Key: fmt.Sprintf("%d-W%02d", year, week), EndsAt: start.AddDate(0, 0, 7)}
+	case PeriodMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		return periodWindow{Period: period, Key: t.Format("2006-01"), EndsAt: start.AddDate(0, 1, 0)}
+	default:
+		return periodWindow{Period: PeriodDaily, Key: t.Format("2006-01-02"), EndsAt: day.AddDate(0, 0, 1)}
+	}
+}
+
+// seasonWindow 赛季对应的周期
+func seasonWindow(s *Season) periodWindow {
+	return periodWindow{Period: PeriodSeason, Key: seasonKey(s.ID), EndsAt: s.EndsAt}
+}
+
+// seasonKey 赛季榜的周期键
+func seasonKey(seasonID uint) string {
+	return strconv.FormatUint(uint64(seasonID), 10)
+}
@@ -4,9 +4,13 @@
 package ranking
 
 import (
-	"fmt"
+	"errors"
+	"math/rand"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ============================================================
@@ -16,16 +20,103 @@ import (
 
 // Repository 排行榜仓储接口
 type Repository interface {
-	// UpsertScore 创建或更新分数（只在新分数更高时更新）
-	UpsertScore(userID uint, rankType, score int) (*Entity, error)
-	// GetRankings 获取排行榜（分页）
-	GetRankings(rankType, offset, limit int) ([]*Entity, error)
-	// DeleteByUserAndType 删除指定用户和类型的排行榜记录
+	// UpsertScore 创建或按排行榜的聚合方式更新分数（原子操作），返回成绩是否变化
+	UpsertScore(b *Board, userID uint, score int) (*Entity, bool, error)
+	// GetRankings 获取排行榜（分页，按排行榜的排序方向）
+	GetRankings(b *Board, offset, limit int) ([]*Entity, error)
+	// DeleteByUserAndType 删除指定用户和类型的排行榜记录（包括赛季榜和周期榜成绩）
 	DeleteByUserAndType(userID uint, rankType int) error
-	// DeleteAllByUser 删除指定用户的所有排行榜记录
+	// DeleteAllByUser 删除指定用户的所有排行榜记录（包括赛季榜和周期榜成绩）
 	DeleteAllByUser(userID uint) error
-	// FindByUserAndType 查找指定用户和类型的排行榜记录
+	// FindByUserAndType 查找指定用户和类型的排行榜记录（不存在时返回nil）
 	FindByUserAndType(userID uint, rankType int) (*Entity, error)
+	// CountAhead 统计排在指定记录之前的记录数
+	CountAhead(b *Board, score int, updatedAt time.Time, userID uint) (int64, error)
+	// CountByRankType 统计指定类型的记录数
+	CountByRankType(rankType int) (int64, error)
+	// ListByRankType 按ID顺序分批获取指定类型的所有记录（用于重建Redis排行榜）
+	ListByRankType(rankType int, afterID uint, limit int) ([]*Entity, error)
+	// ListUpdatedSince 获取指定时间之后更新过的记录
+	ListUpdatedSince(rankType int, since time.Time) ([]*Entity, error)
+	// GetRankingsAfter 获取排在after之后的limit条记录（游标分页，after为nil时从第一名开始）
+	GetRankingsAfter(b *Board, after *boardEntry, limit int) ([]*Entity, error)
+	// ListByUsers 获取一组用户在排行榜中的记录（按排行榜的排序方向，同分同时间按user_id）
+	ListByUsers(b *Board, userIDs []uint) ([]*Entity, error)
+
+	// UpsertPeriodScore 创建或按排行榜的聚合方式更新周期成绩（原子操作），返回成绩是否变化
+	UpsertPeriodScore(b *Board, userID uint, period, key string, endsAt time.Time, score int) (*PeriodScore, bool, error)
+	// GetPeriodRankings 获取周期排行榜（分页）
+	GetPeriodRankings(b *Board, period, key string, offset, limit int) ([]*PeriodScore, error)
+	// GetPeriodRankingsAfter 获取周期排行榜中排在after之后的limit条记录（游标分页）
+	GetPeriodRankingsAfter(b *Board, period, key string, after *boardEntry, limit int) ([]*PeriodScore, error)
+	// ListPeriodScoresByUsers 获取一组用户的周期成绩（按排行榜的排序方向，同分同时间按user_id）
+	ListPeriodScoresByUsers(b *Board, period, key string, userIDs []uint) ([]*PeriodScore, error)
+	// DeleteExpiredPeriodScores 删除结束时间早于before的日榜/周榜/月榜成绩（赛季榜成绩保留）
+	DeleteExpiredPeriodScores(before time.Time) (int64, error)
+
+	// FindSeasonAt 查找包含指定时间的赛季（不存在时返回nil）
+	FindSeasonAt(t time.Time) (*Season, error)
+	// FindLatestSeason 查找编号最大的赛季（不存在时返回nil）
+	FindLatestSeason() (*Season, error)
+	// FindSeason 根据编号查找赛季（不存在时返回nil）
+	FindSeason(id uint) (*Season, error)
+	// ListSeasons 获取所有赛季（按编号倒序）
+	ListSeasons() ([]*Season, error)
+	// CreateSeason 创建赛季
+	CreateSeason(season *Season) error
+	// UpdateSeason 更新赛季名称和结束时间
+	UpdateSeason(season *Season) error
+	// ListEndedSeasons 获取已到结束时间但尚未归档的赛季
+	ListEndedSeasons(now time.Time) ([]*Season, error)
+	// CloseSeason 归档赛季各排行榜前topN名的最终排名并标记赛季结束
+	// 返回false表示赛季已被其他实例归档
+	CloseSeason(seasonID uint, boards []*Board, topN int, closedAt time.Time) (bool, error)
+	// GetSeasonStandings 获取赛季归档的最终排名（分页）
+	GetSeasonStandings(seasonID uint, rankType, offset, limit int) ([]*SeasonStanding, error)
+	// ListSeasonStandingsByUsers 获取一组用户在赛季归档中的最终排名（按名次排序）
+	ListSeasonStandingsByUsers(seasonID uint, rankType int, userIDs []uint) ([]*SeasonStanding, error)
+
+	// ListBoards 获取所有排行榜定义（按ID排序）
+	ListBoards() ([]*Board, error)
+	// CreateBoards 创建排行榜定义（ID或标识已存在的跳过）
+	CreateBoards(boards []*Board) error
+	// CreateBoard 创建排行榜定义
+	CreateBoard(b *Board) error
+	// UpdateBoard 更新排行榜定义
+	UpdateBoard(b *Board) error
+
+	// CreateSubmission 保存成绩提交记录
+	CreateSubmission(sub *Submission) error
+	// HasRunSubmission 同一局游戏（runID）是否已经提交过（不包括被拒绝的提交）
+	HasRunSubmission(userID uint, rankType int, runID string) (bool, error)
+	// ListSubmissions 获取用户在指定类型的提交记录（按时间倒序，分页）
+	ListSubmissions(userID uint, rankType, offset, limit int) ([]*Submission, int64, error)
+	// SearchSubmissions 按条件查询提交记录（按时间倒序，分页）
+	SearchSubmissions(f SubmissionFilter, offset, limit int) ([]*Submission, int64, error)
+	// DeleteSubmissionsBefore 删除指定时间之前的提交记录，返回删除的记录数
+	DeleteSubmissionsBefore(before time.Time) (int64, error)
+	// FindSubmission 查找提交记录（不存在时返回nil）
+	FindSubmission(id uint) (*Submission, error)
+	// ReviewSubmission 审核隔离的提交（只更新仍处于隔离状态的记录），返回false表示已被审核
+	ReviewSubmission(id uint, status string, reviewerID uint, reviewedAt time.Time) (bool, error)
+	// UpdateSubmissionBest 更新提交后的总榜成绩
+	UpdateSubmissionBest(id uint, bestScore int) error
+	// SetSubmissionStatus 修改提交记录的状态
+	SetSubmissionStatus(id uint, status string) error
+	// BestScoreBefore 获取指定时间之前最后一次计入排行榜的提交后的总榜成绩（没有时返回false）
+	BestScoreBefore(userID uint, rankType int, before time.Time) (int, bool, error)
+
+	// CreateRun 创建对局
+	CreateRun(run *Run) error
+	// FindRun 查找对局（不存在时返回nil）
+	FindRun(id string) (*Run, error)
+	// UseRun 标记对局已提交成绩（只更新未使用且未过期的对局），返回false表示对局已使用或已过期
+	UseRun(id string, usedAt time.Time) (bool, error)
+	// DeleteRunsBefore 删除指定时间之前过期的对局，返回删除的记录数
+	DeleteRunsBefore(before time.Time) (int64, error)
+
+	// PurgeUsers 在tx中删除用户的成绩、周期成绩、赛季归档排名、提交记录和对局
+	PurgeUsers(tx *gorm.DB, userIDs []uint) error
 }
 
 // repositoryImpl Repository的GORM实现
@@ -38,46 +129,94 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repositoryImpl{db: db}
 }
 
-// UpsertScore 创建或更新分数（只在新分数更高时更新）
-func (r *repositoryImpl) UpsertScore(userID uint, rankType, score int) (*Entity, error) {
-	var ranking Entity
-
-	// 先查询是否存在
-	err := r.db.Where("user_id = ? AND rank_type = ?", userID, rankType).First(&ranking).Error
-
-	if err == gorm.ErrRecordNotFound {
-		// 不存在，创建新记录
-		ranking = Entity{
-			UserID:   userID,
-			RankType: rankType,
-			Score:    score,
-		}
-		if err := r.db.Create(&ranking).Error; err != nil {
-			return nil, err
+// UpsertScore 创建或按排行榜的聚合方式更新分数，返回更新后的记录和成绩是否变化
+// 使用一条 INSERT ... ON DUPLICATE KEY UPDATE 完成，并发提交时不会违反唯一索引，也不会用较差的成绩覆盖较好的成绩；
+// 合并后的记录在同一事务中读取，返回的是本次写入的结果；记录不存在时的并发插入可能死锁，由 upsertTransaction 重试
+func (r *repositoryImpl) UpsertScore(b *Board, userID uint, score int) (*Entity, bool, error) {
+	var (
+		ranking Entity
+		changed bool
+	)
+	err := r.upsertTransaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoUpdates: aggregateAssignments(b)}).
+			Create(&Entity{UserID: userID, RankType: b.ID, Score: score})
+		if result.Error != nil {
+			return result.Error
 		}
-		return &ranking, nil
-	}
+		changed = upsertChanged(result.RowsAffected)
 
+		// 读取合并后的成绩（插入时不会回填已存在记录的ID，需要重新查询）
+		return lockedRead(tx).Where("user_id = ? AND rank_type = ?", userID, b.ID).First(&ranking).Error
+	})
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	return &ranking, changed, nil
+}
 
-	// 存在，只在新分数更高时更新
-	if score > ranking.Score {
-		ranking.Score = score
-		if err := r.db.Save(&ranking).Error; err != nil {
-			return nil, err
+// 死锁重试
+const (
+	upsertRetries = 5                     // 死锁或锁等待超时后的最多重试次数
+	upsertBackoff = 10 * time.Millisecond // 重试前等待的基础时间（按次数递增并加随机抖动）
+)
+
+// upsertTransaction 执行upsert事务，遇到死锁（1213）或锁等待超时（1205）时整体重试
+// 同一行不存在时并发的 INSERT ... ON DUPLICATE KEY UPDATE 会争抢间隙锁，InnoDB选中其中一个事务回滚；
+// 重试时记录已经插入，只会按行锁排队，不会再次死锁
+func (r *repositoryImpl) upsertTransaction(fn func(tx *gorm.DB) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = r.db.Transaction(fn)
+		if err == nil || attempt >= upsertRetries || !isLockConflict(err) {
+			return err
 		}
+		time.Sleep(time.Duration(attempt+1)*upsertBackoff + time.Duration(rand.Int63n(int64(upsertBackoff))))
 	}
+}
 
-	return &ranking, nil
+// isLockConflict 是否是可以重试的锁冲突（死锁或锁等待超时）
+func isLockConflict(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+}
+
+// lockedRead 在upsert的事务中加锁读取同一行：upsert已持有该行的排他锁直到提交，
+// 读到的就是本次upsert合并后的结果，不会混入并发提交的其他写入
+func lockedRead(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// aggregateAssignments 按排行榜的聚合方式生成 ON DUPLICATE KEY UPDATE 的赋值
+// MySQL按顺序执行赋值，先根据原分数决定是否更新updated_at，再合并分数
+func aggregateAssignments(b *Board) clause.Set {
+	var cond, merged string
+	switch b.Aggregation {
+	case AggregateMin:
+		cond, merged = "VALUES(score) < score", "VALUES(score)"
+	case AggregateLatest:
+		cond, merged = "TRUE", "VALUES(score)"
+	case AggregateSum:
+		cond, merged = "VALUES(score) <> 0", "score + VALUES(score)"
+	default:
+		cond, merged = "VALUES(score) > score", "VALUES(score)"
+	}
+	return clause.Set{
+		{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("IF(" + cond + ", VALUES(updated_at), updated_at)")},
+		{Column: clause.Column{Name: "score"}, Value: gorm.Expr("IF(" + cond + ", " + merged + ", score)")},
+	}
+}
+
+// upsertChanged 根据 INSERT ... ON DUPLICATE KEY UPDATE 的影响行数判断记录是否变化
+// MySQL：插入为1，更新为2，值未变化为0（连接未开启clientFoundRows）
+func upsertChanged(rowsAffected int64) bool {
+	return rowsAffected > 0
 }
 
-// GetRankings 获取排行榜（按分数降序，分数相同按更新时间升序）
-func (r *repositoryImpl) GetRankings(rankType, offset, limit int) ([]*Entity, error) {
+// GetRankings 获取排行榜（按排行榜的排序方向，分数相同按更新时间升序）
+func (r *repositoryImpl) GetRankings(b *Board, offset, limit int) ([]*Entity, error) {
 	var rankings []*Entity
-	err := r.db.Where("rank_type = ?", rankType).
-		Order("score DESC, updated_at ASC").
+	err := r.db.Where("rank_type = ?", b.ID).
+		Order(b.OrderClause()).
 		Limit(limit).
 		Offset(offset).
 		Find(&rankings).Error
@@ -85,25 +224,509 @@ func (r *repositoryImpl) GetRankings(rankType, offset, limit int) ([]*Entity, er
 	return rankings, err
 }
 
-// DeleteByUserAndType 删除指定用户和类型的排行榜记录
+// GetRankingsAfter 获取排在after之后的limit条记录（游标分页，使用 rank_type + score + updated_at + user_id 索引，不受页数影响）
+func (r *repositoryImpl) GetRankingsAfter(b *Board, after *boardEntry, limit int) ([]*Entity, error) {
+	var rankings []*Entity
+	query := r.db.Where("rank_type = ?", b.ID)
+	if after != nil {
+		cond, args := keysetCondition(b, after)
+		query = query.Where(cond, args...)
+	}
+	err := query.Order(b.KeysetOrderClause()).Limit(limit).Find(&rankings).Error
+	return rankings, err
+}
+
+// keysetCondition 排在after之后的条件（与 KeysetOrderClause 的排序一致）
+func keysetCondition(b *Board, after *boardEntry) (string, []interface{}) {
+	worse := "score < ?"
+	if b.Ascending() {
+		worse = "score > ?"
+	}
+	cond := "(" + worse + " OR (score = ? AND (updated_at > ? OR (updated_at = ? AND user_id > ?))))"
+	return cond, []interface{}{after.Score, after.Score, after.UpdatedAt, after.UpdatedAt, after.UserID}
+}
+
+// ListByUsers 获取一组用户在排行榜中的记录（按排行榜的排序方向，同分同时间按user_id；使用 user_id + rank_type 唯一索引）
+func (r *repositoryImpl) ListByUsers(b *Board, userIDs []uint) ([]*Entity, error) {
+	var rankings []*Entity
+	if len(userIDs) == 0 {
+		return rankings, nil
+	}
+	err := r.db.Where("user_id IN ? AND rank_type = ?", userIDs, b.ID).
+		Order(b.KeysetOrderClause()).
+		Find(&rankings).Error
+	return rankings, err
+}
+
+// DeleteByUserAndType 删除指定用户和类型的排行榜记录（包括赛季榜和周期榜成绩）
 func (r *repositoryImpl) DeleteByUserAndType(userID uint, rankType int) error {
-	return r.db.Where("user_id = ? AND rank_type = ?", userID, rankType).Delete(&Entity{}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND rank_type = ?", userID, rankType).Delete(&PeriodScore{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND rank_type = ?", userID, rankType).Delete(&Entity{}).Error
+	})
 }
 
-// DeleteAllByUser 删除指定用户的所有排行榜记录
+// DeleteAllByUser 删除指定用户的所有排行榜记录（包括赛季榜和周期榜成绩）
 func (r *repositoryImpl) DeleteAllByUser(userID uint) error {
-	return r.db.Where("user_id = ?", userID).Delete(&Entity{}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&PeriodScore{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&Entity{}).Error
+	})
+}
+
+// PurgeUsers 在tx中删除用户的成绩、周期成绩、赛季归档排名、提交记录和对局
+func (r *repositoryImpl) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	for _, model := range []interface{}{&PeriodScore{}, &SeasonStanding{}, &Submission{}, &Run{}, &Entity{}} {
+		if err := tx.Where("user_id IN ?", userIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// FindByUserAndType 查找指定用户和类型的排行榜记录
+// FindByUserAndType 查找指定用户和类型的排行榜记录（不存在时返回nil）
 func (r *repositoryImpl) FindByUserAndType(userID uint, rankType int) (*Entity, error) {
 	var ranking Entity
 	err := r.db.Where("user_id = ? AND rank_type = ?", userID, rankType).First(&ranking).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("排行榜记录不存在")
+			return nil, nil
 		}
 		return nil, err
 	}
 	return &ranking, nil
 }
+
+// CountAhead 统计排在指定记录之前的记录数（与 KeysetOrderClause 和Redis成员编码的排序一致，同分同时间时按user_id区分）
+func (r *repositoryImpl) CountAhead(b *Board, score int, updatedAt time.Time, userID uint) (int64, error) {
+	better := "score > ?"
+	if b.Ascending() {
+		better = "score < ?"
+	}
+	var count int64
+	err := r.db.Model(&Entity{}).
+		Where("rank_type = ? AND ("+better+" OR (score = ? AND updated_at < ?) OR (score = ? AND updated_at = ? AND user_id < ?))",
+			b.ID, score, score, updatedAt, score, updatedAt, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountByRankType 统计指定类型的记录数
+func (r *repositoryImpl) CountByRankType(rankType int) (int64, error) {
+	var count int64
+	err := r.db.Model(&Entity{}).Where("rank_type = ?", rankType).Count(&count).Error
+	return count, err
+}
+
+// ListByRankType 按ID顺序分批获取指定类型的所有记录（用于重建Redis排行榜）
+func (r *repositoryImpl) ListByRankType(rankType int, afterID uint, limit int) ([]*Entity, error) {
+	var rankings []*Entity
+	err := r.db.Where("rank_type = ? AND id > ?", rankType, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&rankings).Error
+	return rankings, err
+}
+
+// ListUpdatedSince 获取指定时间之后更新过的记录
+func (r *repositoryImpl) ListUpdatedSince(rankType int, since time.Time) ([]*Entity, error) {
+	var rankings []*Entity
+	err := r.db.Where("rank_type = ? AND updated_at >= ?", rankType, since).Find(&rankings).Error
+	return rankings, err
+}
+
+// UpsertPeriodScore 创建或按排行榜的聚合方式更新周期成绩，返回更新后的记录和成绩是否变化（与 UpsertScore 一样是原子操作）
+func (r *repositoryImpl) UpsertPeriodScore(b *Board, userID uint, period, key string, endsAt time.Time, score int) (*PeriodScore, bool, error) {
+	var (
+		ps      PeriodScore
+		changed bool
+	)
+	err := r.upsertTransaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoUpdates: aggregateAssignments(b)}).Create(&PeriodScore{
+			UserID:    userID,
+			RankType:  b.ID,
+			Period:    period,
+			PeriodKey: key,
+			Score:     score,
+			EndsAt:    endsAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		changed = upsertChanged(result.RowsAffected)
+
+		return lockedRead(tx).Where("user_id = ? AND rank_type = ? AND period = ? AND period_key = ?", userID, b.ID, period, key).
+			First(&ps).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &ps, changed, nil
+}
+
+// GetPeriodRankings 获取周期排行榜（按排行榜的排序方向，分数相同按更新时间升序）
+func (r *repositoryImpl) GetPeriodRankings(b *Board, period, key string, offset, limit int) ([]*PeriodScore, error) {
+	var scores []*PeriodScore
+	err := r.db.Where("rank_type = ? AND period = ? AND period_key = ?", b.ID, period, key).
+		Order(b.OrderClause()).
+		Limit(limit).
+		Offset(offset).
+		Find(&scores).Error
+	return scores, err
+}
+
+// GetPeriodRankingsAfter 获取周期排行榜中排在after之后的limit条记录（游标分页）
+func (r *repositoryImpl) GetPeriodRankingsAfter(b *Board, period, key string, after *boardEntry, limit int) ([]*PeriodScore, error) {
+	var scores []*PeriodScore
+	query := r.db.Where("rank_type = ? AND period = ? AND period_key = ?", b.ID, period, key)
+	if after != nil {
+		cond, args := keysetCondition(b, after)
+		query = query.Where(cond, args...)
+	}
+	err := query.Order(b.KeysetOrderClause()).Limit(limit).Find(&scores).Error
+	return scores, err
+}
+
+// ListPeriodScoresByUsers 获取一组用户的周期成绩（按排行榜的排序方向，同分同时间按user_id）
+func (r *repositoryImpl) ListPeriodScoresByUsers(b *Board, period, key string, userIDs []uint) ([]*PeriodScore, error) {
+	var scores []*PeriodScore
+	if len(userIDs) == 0 {
+		return scores, nil
+	}
+	err := r.db.Where("user_id IN ? AND rank_type = ? AND period = ? AND period_key = ?", userIDs, b.ID, period, key).
+		Order(b.KeysetOrderClause()).
+		Find(&scores).Error
+	return scores, err
+}
+
+// DeleteExpiredPeriodScores 删除结束时间早于before的日榜/周榜/月榜成绩（赛季榜成绩保留）
+func (r *repositoryImpl) DeleteExpiredPeriodScores(before time.Time) (int64, error) {
+	result := r.db.Where("period <> ? AND ends_at < ?", PeriodSeason, before).Delete(&PeriodScore{})
+	return result.RowsAffected, result.Error
+}
+
+// FindSeasonAt 查找包含指定时间的赛季（不存在时返回nil）
+func (r *repositoryImpl) FindSeasonAt(t time.Time) (*Season, error) {
+	var season Season
+	err := r.db.Where("starts_at <= ? AND ends_at > ?", t, t).Order("id DESC").First(&season).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// FindLatestSeason 查找编号最大的赛季（不存在时返回nil）
+func (r *repositoryImpl) FindLatestSeason() (*Season, error) {
+	var season Season
+	err := r.db.Order("id DESC").First(&season).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// FindSeason 根据编号查找赛季（不存在时返回nil）
+func (r *repositoryImpl) FindSeason(id uint) (*Season, error) {
+	var season Season
+	err := r.db.First(&season, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// ListSeasons 获取所有赛季（按编号倒序）
+func (r *repositoryImpl) ListSeasons() ([]*Season, error) {
+	var seasons []*Season
+	err := r.db.Order("id DESC").Find(&seasons).Error
+	return seasons, err
+}
+
+// CreateSeason 创建赛季
+func (r *repositoryImpl) CreateSeason(season *Season) error {
+	return r.db.Create(season).Error
+}
+
+// UpdateSeason 更新赛季名称和结束时间
+func (r *repositoryImpl) UpdateSeason(season *Season) error {
+	return r.db.Model(season).Updates(map[string]interface{}{
+		"name":    season.Name,
+		"ends_at": season.EndsAt,
+	}).Error
+}
+
+// ListEndedSeasons 获取已到结束时间但尚未归档的赛季
+func (r *repositoryImpl) ListEndedSeasons(now time.Time) ([]*Season, error) {
+	var seasons []*Season
+	err := r.db.Where("status = ? AND ends_at <= ?", SeasonStatusOpen, now).Order("id ASC").Find(&seasons).Error
+	return seasons, err
+}
+
+// CloseSeason 归档赛季各排行榜前topN名的最终排名并标记赛季结束
+// 条件更新赛季状态会锁定赛季记录，多个实例同时执行时只有一个能完成归档
+func (r *repositoryImpl) CloseSeason(seasonID uint, boards []*Board, topN int, closedAt time.Time) (bool, error) {
+	closed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Season{}).
+			Where("id = ? AND status = ?", seasonID, SeasonStatusOpen).
+			Updates(map[string]interface{}{"status": SeasonStatusClosed, "closed_at": closedAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		key := seasonKey(seasonID)
+		for _, b := range boards {
+			var scores []*PeriodScore
+			if err := tx.Where("rank_type = ? AND period = ? AND period_key = ?", b.ID, PeriodSeason, key).
+				Order(b.OrderClause()).
+				Limit(topN).
+				Find(&scores).Error; err != nil {
+				return err
+			}
+			if len(scores) == 0 {
+				continue
+			}
+			standings := make([]SeasonStanding, len(scores))
+			for i, ps := range scores {
+				standings[i] = SeasonStanding{
+					SeasonID:  seasonID,
+					RankType:  b.ID,
+					Rank:      i + 1,
+					UserID:    ps.UserID,
+					Score:     ps.Score,
+					UpdatedAt: ps.UpdatedAt,
+				}
+			}
+			if err := tx.CreateInBatches(standings, 500).Error; err != nil {
+				return err
+			}
+		}
+		closed = true
+		return nil
+	})
+	return closed, err
+}
+
+// GetSeasonStandings 获取赛季归档的最终排名（分页）
+func (r *repositoryImpl) GetSeasonStandings(seasonID uint, rankType, offset, limit int) ([]*SeasonStanding, error) {
+	var standings []*SeasonStanding
+	err := r.db.Where("season_id = ? AND rank_type = ?", seasonID, rankType).
+		Order("`rank` ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&standings).Error
+	return standings, err
+}
+
+// ListSeasonStandingsByUsers 获取一组用户在赛季归档中的最终排名（按名次排序）
+func (r *repositoryImpl) ListSeasonStandingsByUsers(seasonID uint, rankType int, userIDs []uint) ([]*SeasonStanding, error) {
+	var standings []*SeasonStanding
+	if len(userIDs) == 0 {
+		return standings, nil
+	}
+	err := r.db.Where("season_id = ? AND rank_type = ? AND user_id IN ?", seasonID, rankType, userIDs).
+		Order("`rank` ASC").
+		Find(&standings).Error
+	return standings, err
+}
+
+// ListBoards 获取所有排行榜定义（按ID排序）
+func (r *repositoryImpl) ListBoards() ([]*Board, error) {
+	var boards []*Board
+	err := r.db.Order("id ASC").Find(&boards).Error
+	return boards, err
+}
+
+// CreateBoards 创建排行榜定义（ID或标识已存在的跳过）
+func (r *repositoryImpl) CreateBoards(boards []*Board) error {
+	if len(boards) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&boards).Error
+}
+
+// CreateBoard 创建排行榜定义
+func (r *repositoryImpl) CreateBoard(b *Board) error {
+	return r.db.Create(b).Error
+}
+
+// UpdateBoard 更新排行榜定义
+func (r *repositoryImpl) UpdateBoard(b *Board) error {
+	return r.db.Model(b).Updates(map[string]interface{}{
+		"display_name":         b.DisplayName,
+		"sort_order":           b.SortOrder,
+		"aggregation":          b.Aggregation,
+		"visibility":           b.Visibility,
+		"max_score":            b.MaxScore,
+		"min_score":            b.MinScore,
+		"max_delta":            b.MaxDelta,
+		"delta_window_minutes": b.DeltaWindowMinutes,
+	}).Error
+}
+
+// CreateSubmission 保存成绩提交记录
+func (r *repositoryImpl) CreateSubmission(sub *Submission) error {
+	return r.db.Create(sub).Error
+}
+
+// HasRunSubmission 同一局游戏（runID）是否已经提交过（不包括被拒绝的提交）
+func (r *repositoryImpl) HasRunSubmission(userID uint, rankType int, runID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Submission{}).
+		Where("user_id = ? AND rank_type = ? AND run_id = ? AND status <> ?", userID, rankType, runID, SubmissionRejected).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListSubmissions 获取用户在指定类型的提交记录（按时间倒序，分页）
+func (r *repositoryImpl) ListSubmissions(userID uint, rankType, offset, limit int) ([]*Submission, int64, error) {
+	query := r.db.Model(&Submission{}).Where("user_id = ? AND rank_type = ?", userID, rankType)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var subs []*Submission
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&subs).Error
+	return subs, total, err
+}
+
+// SearchSubmissions 按条件查询提交记录（按时间倒序，分页）
+func (r *repositoryImpl) SearchSubmissions(f SubmissionFilter, offset, limit int) ([]*Submission, int64, error) {
+	query := r.db.Model(&Submission{})
+	if f.UserID != 0 {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	if f.RankType != 0 {
+		query = query.Where("rank_type = ?", f.RankType)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.RunID != "" {
+		query = query.Where("run_id = ?", f.RunID)
+	}
+	if f.Suspicious {
+		query = query.Where("(flags <> '' OR status IN ?)", []string{SubmissionRejected, SubmissionQuarantined})
+	}
+	if !f.Since.IsZero() {
+		query = query.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		query = query.Where("created_at < ?", f.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var subs []*Submission
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&subs).Error
+	return subs, total, err
+}
+
+// DeleteSubmissionsBefore 删除指定时间之前的提交记录，返回删除的记录数
+func (r *repositoryImpl) DeleteSubmissionsBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&Submission{})
+	return result.RowsAffected, result.Error
+}
+
+// FindSubmission 查找提交记录（不存在时返回nil）
+func (r *repositoryImpl) FindSubmission(id uint) (*Submission, error) {
+	var sub Submission
+	err := r.db.First(&sub, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ReviewSubmission 审核隔离的提交（只更新仍处于隔离状态的记录），返回false表示已被审核
+func (r *repositoryImpl) ReviewSubmission(id uint, status string, reviewerID uint, reviewedAt time.Time) (bool, error) {
+	result := r.db.Model(&Submission{}).
+		Where("id = ? AND status = ?", id, SubmissionQuarantined).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewerID,
+			"reviewed_at": reviewedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateSubmissionBest 更新提交后的总榜成绩
+func (r *repositoryImpl) UpdateSubmissionBest(id uint, bestScore int) error {
+	return r.db.Model(&Submission{}).Where("id = ?", id).Update("best_score", bestScore).Error
+}
+
+// SetSubmissionStatus 修改提交记录的状态
+func (r *repositoryImpl) SetSubmissionStatus(id uint, status string) error {
+	return r.db.Model(&Submission{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// BestScoreBefore 获取指定时间之前最后一次计入排行榜的提交后的总榜成绩（没有时返回false）
+func (r *repositoryImpl) BestScoreBefore(userID uint, rankType int, before time.Time) (int, bool, error) {
+	var sub Submission
+	err := r.db.Select("best_score").
+		Where("user_id = ? AND rank_type = ? AND status = ? AND created_at < ?", userID, rankType, SubmissionAccepted, before).
+		Order("created_at DESC, id DESC").
+		First(&sub).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return sub.BestScore, true, nil
+}
+
+// CreateRun 创建对局
+func (r *repositoryImpl) CreateRun(run *Run) error {
+	return r.db.Create(run).Error
+}
+
+// FindRun 查找对局（不存在时返回nil）
+func (r *repositoryImpl) FindRun(id string) (*Run, error) {
+	var run Run
+	err := r.db.Where("id = ?", id).First(&run).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// UseRun 标记对局已提交成绩（只更新未使用且未过期的对局），返回false表示对局已使用或已过期
+func (r *repositoryImpl) UseRun(id string, usedAt time.Time) (bool, error) {
+	result := r.db.Model(&Run{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, usedAt).
+		Update("used_at", usedAt)
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteRunsBefore 删除指定时间之前过期的对局，返回删除的记录数
+func (r *repositoryImpl) DeleteRunsBefore(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&Run{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,214 @@
+package ranking
+
+import (
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// 并发测试参数
+const (
+	testUserID    = 900000001 // 测试用户（测试前后清理该用户在测试排行榜中的记录）
+	testBoardBase = 9000      // 测试排行榜ID的起始值（不会与实际排行榜冲突）
+	upsertWorkers = 32        // 并发提交的goroutine数
+)
+
+// openTestDB 连接测试用的MySQL（TEST_MYSQL_DSN，如 root:123456@tcp(127.0.0.1:3306)/game_test?parseTime=True&loc=Local），未设置时跳过
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("未设置TEST_MYSQL_DSN，跳过需要MySQL的测试")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("连接MySQL失败: %v", err)
+	}
+	if err := db.AutoMigrate(&Entity{}, &PeriodScore{}); err != nil {
+		t.Fatalf("迁移表结构失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取连接池失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(upsertWorkers)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// upsertCall 一次并发提交的结果
+type upsertCall struct {
+	submitted int  // 提交的分数
+	result    int  // 返回的合并后成绩
+	changed   bool // 返回的成绩是否变化
+}
+
+// upsertFunc 被测试的upsert（总榜或周期榜）
+type upsertFunc func(b *Board, score int) (int, bool, error)
+
+// TestUpsertConcurrent 同一用户同一排行榜并发提交：最终成绩正确，且只有确实改变了成绩的提交返回changed，
+// 返回的成绩是本次写入后的结果（不会读到并发提交的其他写入）
+func TestUpsertConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRepository(db)
+	endsAt := time.Now().Add(24 * time.Hour)
+
+	targets := map[string]upsertFunc{
+		"total": func(b *Board, score int) (int, bool, error) {
+			r, changed, err := repo.UpsertScore(b, testUserID, score)
+			if err != nil {
+				return 0, false, err
+			}
+			return r.Score, changed, nil
+		},
+		"period": func(b *Board, score int) (int, bool, error) {
+			ps, changed, err := repo.UpsertPeriodScore(b, testUserID, PeriodDaily, "test", endsAt, score)
+			if err != nil {
+				return 0, false, err
+			}
+			return ps.Score, changed, nil
+		},
+	}
+
+	// 提交1..N（打乱顺序）；seed为已有记录时并发提交前写入的初始成绩
+	// 记录不存在时N个goroutine同时插入同一行，会触发InnoDB的间隙锁死锁，由upsert重试
+	cases := []struct {
+		aggregation string
+		seed        int
+		check       func(t *testing.T, calls []upsertCall, final int)
+	}{
+		{AggregateMax, 0, func(t *testing.T, calls []upsertCall, final int) {
+			if final != upsertWorkers {
+				t.Errorf("最终成绩 = %d, 期望 %d", final, upsertWorkers)
+			}
+			for _, c := range calls {
+				// 提交的分数互不相同：提升了成绩的提交读到的就是自己的分数，否则读到更高的分数
+				if c.changed != (c.result == c.submitted) || c.result < c.submitted {
+					t.Errorf("提交 %d: changed = %v, 返回成绩 = %d", c.submitted, c.changed, c.result)
+				}
+			}
+		}},
+		{AggregateMin, upsertWorkers + 1, func(t *testing.T, calls []upsertCall, final int) {
+			if final != 1 {
+				t.Errorf("最终成绩 = %d, 期望 1", final)
+			}
+			for _, c := range calls {
+				if c.changed != (c.result == c.submitted) || c.result > c.submitted {
+					t.Errorf("提交 %d: changed = %v, 返回成绩 = %d", c.submitted, c.changed, c.result)
+				}
+			}
+		}},
+		{AggregateLatest, 0, func(t *testing.T, calls []upsertCall, final int) {
+			if final < 1 || final > upsertWorkers {
+				t.Errorf("最终成绩 = %d, 期望为提交的分数之一", final)
+			}
+			for _, c := range calls {
+				if !c.changed || c.result != c.submitted {
+					t.Errorf("提交 %d: changed = %v, 返回成绩 = %d", c.submitted, c.changed, c.result)
+				}
+			}
+		}},
+		{AggregateSum, 0, func(t *testing.T, calls []upsertCall, final int) {
+			want := upsertWorkers * (upsertWorkers + 1) / 2
+			if final != want {
+				t.Errorf("最终成绩 = %d, 期望 %d", final, want)
+			}
+			// 每次提交都累加成绩，返回的是各自累加后的结果，互不相同
+			seen := make(map[int]bool, len(calls))
+			for _, c := range calls {
+				if !c.changed || c.result < c.submitted || seen[c.result] {
+					t.Errorf("提交 %d: changed = %v, 返回成绩 = %d", c.submitted, c.changed, c.result)
+				}
+				seen[c.result] = true
+			}
+		}},
+	}
+
+	for i, tc := range cases {
+		for name, upsert := range targets {
+			for _, seeded := range []bool{false, true} {
+				b := &Board{ID: testBoardBase + i, SortOrder: SortDesc, Aggregation: tc.aggregation}
+				row := "new"
+				if seeded {
+					row = "existing"
+				}
+				t.Run(tc.aggregation+"/"+name+"/"+row, func(t *testing.T) {
+					cleanupTestBoard(t, db, b.ID)
+					t.Cleanup(func() { cleanupTestBoard(t, db, b.ID) })
+
+					if seeded {
+						if _, _, err := upsert(b, tc.seed); err != nil {
+							t.Fatalf("写入初始成绩失败: %v", err)
+						}
+					}
+					calls := runConcurrentUpserts(t, b, upsert)
+					tc.check(t, calls, storedScore(t, db, b.ID, name))
+				})
+			}
+		}
+	}
+}
+
+// runConcurrentUpserts 并发提交1..upsertWorkers（打乱顺序），返回每次提交的结果
+func runConcurrentUpserts(t *testing.T, b *Board, upsert upsertFunc) []upsertCall {
+	t.Helper()
+	scores := rand.Perm(upsertWorkers)
+	calls := make([]upsertCall, upsertWorkers)
+	errs := make([]error, upsertWorkers)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := range scores {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			score := scores[i] + 1
+			result, changed, err := upsert(b, score)
+			calls[i] = upsertCall{submitted: score, result: result, changed: changed}
+			errs[i] = err
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("并发提交失败: %v", err)
+		}
+	}
+	return calls
+}
+
+// storedScore 读取测试用户在数据库中的最终成绩
+func storedScore(t *testing.T, db *gorm.DB, rankType int, target string) int {
+	t.Helper()
+	var scores []int
+	query := db.Model(&Entity{})
+	if target == "period" {
+		query = db.Model(&PeriodScore{})
+	}
+	if err := query.Where("user_id = ? AND rank_type = ?", testUserID, rankType).Pluck("score", &scores).Error; err != nil {
+		t.Fatalf("读取最终成绩失败: %v", err)
+	}
+	if len(scores) != 1 {
+		t.Fatalf("测试用户有 %d 条成绩记录, 期望 1", len(scores))
+	}
+	return scores[0]
+}
+
+// cleanupTestBoard 删除测试用户在测试排行榜中的记录
+func cleanupTestBoard(t *testing.T, db *gorm.DB, rankType int) {
+	t.Helper()
+	for _, model := range []interface{}{&Entity{}, &PeriodScore{}} {
+		if err := db.Where("user_id = ? AND rank_type = ?", testUserID, rankType).Delete(model).Error; err != nil {
+			t.Fatalf("清理测试数据失败: %v", err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package ranking - 赛季管理
+// 功能：赛季自动轮换、赛季结束时归档最终排名、清理过期的周期榜数据
+package ranking
+
+import (
+	"fmt"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 赛季
+// 1. 赛季首尾相接：第一个赛季从配置的开始时间开始，每个赛季固定时长
+// 2. 当前赛季结束后，第一次访问或定时任务会创建下一赛季（服务停机跨过整个赛季时跳过空缺的时段）
+// 3. 定时任务归档已结束赛季各类型的前N名；多实例部署时只有一个实例完成归档
+// 4. 管理员可以修改进行中赛季的名称和结束时间
+// ============================================================
+
+// 赛季相关常量
+const (
+	defaultSeasonLength = 90 * 24 * time.Hour // 默认赛季时长
+	defaultArchiveTop   = 100                 // 默认归档名次数
+	seasonCacheTTL      = time.Minute         // 当前赛季的缓存时间（管理员修改结束时间后最多延迟一分钟生效）
+)
+
+// SeasonConfig 赛季配置
+type SeasonConfig struct {
+	FirstStart time.Time     // 第一个赛季的开始时间（零值表示不启用赛季）
+	Length     time.Duration // 每个赛季的时长
+	ArchiveTop int           // 赛季结束时归档的名次数
+}
+
+// SetSeasonConfig 设置赛季配置（未设置时不启用赛季）
+func (s *Service) SetSeasonConfig(cfg SeasonConfig) {
+	if cfg.Length <= 0 {
+		cfg.Length = defaultSeasonLength
+	}
+	if cfg.ArchiveTop <= 0 {
+		cfg.ArchiveTop = defaultArchiveTop
+	}
+	s.season = cfg
+}
+
+// SetPeriodLocation 设置划分日榜/周榜/月榜的时区（默认服务器时区）
+func (s *Service) SetPeriodLocation(loc *time.Location) {
+	if loc != nil {
+		s.loc = loc
+	}
+}
+
+// CurrentSeason 获取当前赛季（未启用赛季或第一个赛季尚未开始时返回nil）
+func (s *Service) CurrentSeason() (*Season, error) {
+	if s.season.FirstStart.IsZero() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	s.seasonMu.Lock()
+	defer s.seasonMu.Unlock()
+	if s.current != nil && s.current.Contains(now) && now.Sub(s.currentCheckedAt) < seasonCacheTTL {
+		return s.current, nil
+	}
+
+	season, err := s.ensureSeason(now)
+	if err != nil {
+		return nil, err
+	}
+	s.current = season
+	s.currentCheckedAt = now
+	return season, nil
+}
+
+// ensureSeason 查找包含now的赛季，上一赛季已结束时创建下一赛季
+func (s *Service) ensureSeason(now time.Time) (*Season, error) {
+	season, err := s.repo.FindSeasonAt(now)
+	if err != nil || season != nil {
+		return season, err
+	}
+
+	latest, err := s.repo.FindLatestSeason()
+	if err != nil {
+		return nil, err
+	}
+	next := &Season{ID: 1, StartsAt: s.season.FirstStart, Status: SeasonStatusOpen}
+	if latest != nil {
+		if latest.EndsAt.After(now) {
+			return nil, nil // 最新的赛季尚未开始
+		}
+		next.ID = latest.ID + 1
+		next.StartsAt = latest.EndsAt
+	}
+	if now.Before(next.StartsAt) {
+		return nil, nil
+	}
+	for !now.Before(next.StartsAt.Add(s.season.Length)) {
+		next.StartsAt = next.StartsAt.Add(s.season.Length)
+	}
+	next.EndsAt = next.StartsAt.Add(s.season.Length)
+	next.Name = fmt.Sprintf("第%d赛季", next.ID)
+
+	if err := s.repo.CreateSeason(next); err != nil {
+		// 可能已被其他实例创建
+		if existing, findErr := s.repo.FindSeasonAt(now); findErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	logger.Info("[ranking.ensureSeason] 新赛季开始",
+		zap.Uint("season_id", next.ID),
+		zap.Time("starts_at", next.StartsAt),
+		zap.Time("ends_at", next.EndsAt))
+	return next, nil
+}
+
+// RolloverSeasons 归档已结束的赛季并开始下一赛季（由定时任务调用）
+func (s *Service) RolloverSeasons() {
+	if s.season.FirstStart.IsZero() {
+		return
+	}
+
+	now := time.Now()
+	ended, err := s.repo.ListEndedSeasons(now)
+	if err != nil {
+		logger.Error("[ranking.RolloverSeasons] 查询已结束的赛季失败", zap.Error(err))
+		return
+	}
+	for _, season := range ended {
+		closed, err := s.repo.CloseSeason(season.ID, s.allBoards(), s.season.ArchiveTop, now)
+		if err != nil {
+			logger.Error("[ranking.RolloverSeasons] 归档赛季失败", zap.Uint("season_id", season.ID), zap.Error(err))
+			continue
+		}
+		if closed {
+			logger.Info("[ranking.RolloverSeasons] 赛季已结束，最终排名已归档",
+				zap.Uint("season_id", season.ID),
+				zap.Int("archive_top", s.season.ArchiveTop))
+		}
+	}
+
+	s.seasonMu.Lock()
+	defer s.seasonMu.Unlock()
+	season, err := s.ensureSeason(now)
+	if err != nil {
+		logger.Error("[ranking.RolloverSeasons] 创建新赛季失败", zap.Error(err))
+		return
+	}
+	s.current = season
+	s.currentCheckedAt = now
+}
+
+// PurgeExpiredPeriods 清理周期结束超过retention的日榜/周榜/月榜成绩（由定时任务调用）
+func (s *Service) PurgeExpiredPeriods(retention time.Duration) {
+	count, err := s.repo.DeleteExpiredPeriodScores(time.Now().Add(-retention))
+	if err != nil {
+		logger.Error("[ranking.PurgeExpiredPeriods] 清理过期周期成绩失败", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		logger.Info("[ranking.PurgeExpiredPeriods] 已清理过期周期成绩", zap.Int64("count", count))
+	}
+}
+
+// ListSeasons 获取所有赛季（按编号倒序）
+func (s *Service) ListSeasons() ([]SeasonVO, error) {
+	// 确保当前赛季已创建
+	if _, err := s.CurrentSeason(); err != nil {
+		logger.Error("[ranking.ListSeasons] 获取当前赛季失败", zap.Error(err))
+		return nil, err
+	}
+	seasons, err := s.repo.ListSeasons()
+	if err != nil {
+		logger.Error("[ranking.ListSeasons] 查询赛季失败", zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	items := make([]SeasonVO, len(seasons))
+	for i, season := range seasons {
+		items[i] = toSeasonVO(season, now)
+	}
+	return items, nil
+}
+
+// AdminUpdateSeason 修改进行中或尚未开始的赛季（名称、结束时间）
+func (s *Service) AdminUpdateSeason(operatorID, seasonID uint, req *UpdateSeasonRequest) (*SeasonVO, error) {
+	season, err := s.repo.FindSeason(seasonID)
+	if err != nil {
+		return nil, err
+	}
+	if season == nil {
+		return nil, errcode.New(errcode.SeasonNotFound)
+	}
+	now := time.Now()
+	if season.Status == SeasonStatusClosed || !season.EndsAt.After(now) {
+		return nil, errcode.New(errcode.SeasonEnded)
+	}
+
+	if req.Name != "" {
+		season.Name = req.Name
+	}
+	if req.EndsAt != nil {
+		if !req.EndsAt.After(now) || !req.EndsAt.After(season.StartsAt) {
+			return nil, errcode.NewWithMessage(errcode.InvalidParams, "结束时间必须晚于当前时间和赛季开始时间")
+		}
+		season.EndsAt = *req.EndsAt
+	}
+	if err := s.repo.UpdateSeason(season); err != nil {
+		logger.Error("[ranking.AdminUpdateSeason] 更新赛季失败", zap.Uint("season_id", seasonID), zap.Error(err))
+		return nil, err
+	}
+
+	s.seasonMu.Lock()
+	s.current = nil
+	s.seasonMu.Unlock()
+
+	logger.Warn("[audit] 修改赛季",
+		zap.String("event", "season_updated"),
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("season_id", seasonID),
+		zap.String("name", season.Name),
+		zap.Time("ends_at", season.EndsAt))
+
+	vo := toSeasonVO(season, now)
+	return &vo, nil
+}
+
+// findBoardSeason 查找赛季榜对应的赛季（seasonID为0时为当前赛季）
+func (s *Service) findBoardSeason(seasonID uint) (*Season, error) {
+	var (
+		season *Season
+		err    error
+	)
+	if seasonID == 0 {
+		season, err = s.CurrentSeason()
+	} else {
+		season, err = s.repo.FindSeason(seasonID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if season == nil {
+		return nil, errcode.New(errcode.SeasonNotFound)
+	}
+	return season, nil
+}
+
+// toSeasonVO 转换为赛季VO
+func toSeasonVO(season *Season, now time.Time) SeasonVO {
+	return SeasonVO{
+		ID:       season.ID,
+		Name:     season.Name,
+		StartsAt: season.StartsAt,
+		EndsAt:   season.EndsAt,
+		Status:   season.Status,
+		Current:  season.Status == SeasonStatusOpen && season.Contains(now),
+	}
+}
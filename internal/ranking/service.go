@@ -1,14 +1,21 @@
 ﻿// Package ranking - 排行榜模块业务逻辑层
 // 功能：实现排行榜相关的业务规则
-// 特点：自动保留最高分，支持缓存
+// 特点：自动保留最高分，MySQL保存数据，Redis有序集合提供排名查询；另有赛季榜和日榜/周榜/月榜
 package ranking
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/user"
+
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // ============================================================
@@ -20,87 +27,255 @@ import (
 // UserService 用户服务接口（Service层组合调用）
 // 设计模式：依赖倒置 - ranking依赖user的抽象接口，而非具体实现
 // 优点：
-// 1. 依赖单向（ranking → user，user不感知ranking）
+// 1. 依赖单向（ranking → user，user不感知ranking，仅共享user.Brief值类型）
 // 2. 符合单一职责，每个Service只负责自己模块的业务逻辑
 // 3. 易于单元测试（可Mock UserService）
 type UserService interface {
 	GetUsername(userID uint) (string, error)
-	GetUsernames(userIDs []uint) (map[uint]string, error)
-}
-
-// Cache 缓存接口
-type Cache interface {
-	Get(key string, dest interface{}) error
-	Set(key string, value interface{}, expiration time.Duration) error
-	Delete(key string) error
+	// GetUsernames 批量获取用户展示信息（用户名、昵称、头像）
+	GetUsernames(userIDs []uint) (map[uint]user.Brief, error)
 }
 
 // Service 排行榜业务服务
 type Service struct {
 	repo        Repository
 	userService UserService
-	cache       Cache
+	board       *leaderboard   // Redis排行榜（为nil时直接查询MySQL）
+	loc         *time.Location // 划分日榜/周榜/月榜的时区
+	season      SeasonConfig
+	boards      boardRegistry // 排行榜定义缓存
+	antiCheat   AntiCheatConfig
+	counter     WindowCounter // 提交频率计数（为nil时不限制）
+	notifier    Notifier      // 实时推送（为nil时不推送）
+	subs        *subscriptions
+	friends     FriendLister // 好友榜的成员来源
+	rooms       RoomLister   // 聊天室榜的成员来源
+
+	seasonMu         sync.Mutex
+	current          *Season // 当前赛季缓存
+	currentCheckedAt time.Time
 }
 
-// NewService 创建排行榜服务实例（依赖注入）
-func NewService(repo Repository, userService UserService, cache Cache) *Service {
-	return &Service{
+// NewService 创建排行榜服务实例（依赖注入，store为nil时不使用Redis排行榜）
+func NewService(repo Repository, userService UserService, store Store) *Service {
+	s := &Service{
 		repo:        repo,
 		userService: userService,
-		cache:       cache,
+		loc:         time.Local,
+	}
+	if store != nil {
+		s.board = newLeaderboard(store, repo)
 	}
+	return s
 }
 
-// UpdateScore 更新用户分数（只在新分数更高时更新）
-func (s *Service) UpdateScore(userID uint, rankType, score int) (*Entity, error) {
+// UpdateScore 更新用户分数（按排行榜的聚合方式合并，如最高分只在新分数更高时更新）
+// 同时写入当前赛季榜、日榜、周榜、月榜；req.Season不为0时必须与当前赛季一致
+// 提交依次经过频率限制、赛季检查、对局签名校验和分数合理性检查，可疑的成绩被隔离等待审核
+// 每次提交（包括被拒绝和被隔离的）都保存提交记录，超过频率限制的除外
+func (s *Service) UpdateScore(userID uint, req *UpdateScoreRequest, clientIP string) (*UpdateScoreResponse, error) {
+	rankType, score, seasonID := req.RankType, req.Score, req.Season
 	logger.Info("[ranking.UpdateScore] 开始更新分数",
 		zap.Uint("user_id", userID),
 		zap.Int("rank_type", rankType),
 		zap.Int("score", score))
-	
-	if !ValidateRankType(rankType) {
+
+	def := s.boardDef(rankType)
+	if def == nil {
 		logger.Warn("[ranking.UpdateScore] 排行榜类型无效",
 			zap.Uint("user_id", userID),
 			zap.Int("rank_type", rankType))
-		return nil, fmt.Errorf("排行榜类型无效")
+		return nil, errcode.New(errcode.InvalidRankType)
 	}
 	if score < 0 {
 		logger.Warn("[ranking.UpdateScore] 分数无效",
 			zap.Uint("user_id", userID),
 			zap.Int("score", score))
-		return nil, fmt.Errorf("分数无效")
+		return nil, errcode.New(errcode.InvalidScore)
+	}
+	if err := s.checkSubmitRate(userID); err != nil {
+		return nil, err
 	}
 
-	ranking, err := s.repo.UpsertScore(userID, rankType, score)
+	season, err := s.CurrentSeason()
 	if err != nil {
-		logger.Error("[ranking.UpdateScore] 更新分数失败",
+		logger.Error("[ranking.UpdateScore] 获取当前赛季失败", zap.Error(err))
+		return nil, errcode.New(errcode.UpdateScoreFailed)
+	}
+	sub := newSubmission(userID, def, req, clientIP)
+	if season != nil {
+		sub.SeasonID = season.ID
+	}
+	if seasonID != 0 && (season == nil || season.ID != seasonID) {
+		logger.Warn("[ranking.UpdateScore] 提交的赛季不是当前赛季",
+			zap.Uint("user_id", userID),
+			zap.Uint("season_id", seasonID))
+		s.rejectSubmission(sub, "season_ended")
+		return nil, errcode.New(errcode.SeasonEnded)
+	}
+
+	// 先检查同一局是否提交过，再校验签名（校验通过后对局被标记为已使用）
+	flags := s.submissionFlags(def, sub)
+	reason, err := s.verifyRun(def, sub, req.Signature)
+	if err != nil {
+		if reason == "" {
+			logger.Error("[ranking.UpdateScore] 校验对局签名失败", zap.Uint("user_id", userID), zap.Error(err))
+			return nil, errcode.New(errcode.UpdateScoreFailed)
+		}
+		logger.Warn("[ranking.UpdateScore] 对局签名校验未通过",
+			zap.Uint("user_id", userID),
+			zap.String("run_id", sub.RunID),
+			zap.String("reason", reason))
+		s.rejectSubmission(sub, reason)
+		return nil, err
+	}
+	sub.Flags = strings.Join(flags, ",")
+
+	if shouldQuarantine(flags) {
+		sub.Status = SubmissionQuarantined
+		s.recordSubmission(sub)
+		logger.Warn("[ranking.UpdateScore] 成绩已隔离，等待审核",
 			zap.Uint("user_id", userID),
 			zap.Int("rank_type", rankType),
-			zap.Error(err))
-		return nil, fmt.Errorf("更新分数失败")
+			zap.Int("score", score),
+			zap.String("flags", sub.Flags))
+		return s.quarantinedResponse(def, sub, season), nil
 	}
 
-	if s.cache != nil {
-		s.clearRankingCache(rankType)
+	applied, err := s.applySubmission(def, sub, season, time.Now())
+	if err != nil {
+		return nil, errcode.New(errcode.UpdateScoreFailed)
 	}
-	
+	ranking := applied.Ranking
+	sub.Status = SubmissionAccepted
+	sub.BestScore = ranking.Score
+	s.recordSubmission(sub)
+
+	result := &UpdateScoreResponse{
+		UserID:    ranking.UserID,
+		RankType:  ranking.RankType,
+		Score:     ranking.Score,
+		UpdatedAt: ranking.UpdatedAt,
+		Improved:  applied.Improved,
+		Periods:   applied.Periods,
+
+		Status:       SubmissionAccepted,
+		SubmissionID: sub.ID,
+	}
+	if season != nil {
+		vo := toSeasonVO(season, time.Now())
+		result.Season = &vo
+	}
+
 	logger.Info("[ranking.UpdateScore] 分数更新成功",
 		zap.Uint("user_id", userID),
 		zap.Int("rank_type", rankType),
 		zap.Int("score", score))
-	return ranking, nil
+	return result, nil
+}
+
+// appliedScore 计入排行榜的结果
+type appliedScore struct {
+	Ranking  *Entity
+	Improved bool // 总榜成绩是否变化
+	Periods  []PeriodScoreVO
+}
+
+// applySubmission 将提交的分数计入总榜（成绩变化时同步Redis排行榜并推送名次变化）和at所在的赛季榜、日榜、周榜、月榜
+func (s *Service) applySubmission(def *Board, sub *Submission, season *Season, at time.Time) (*appliedScore, error) {
+	before, watch := s.positionBefore(def, sub.UserID)
+	ranking, improved, err := s.repo.UpsertScore(def, sub.UserID, sub.Score)
+	if err != nil {
+		logger.Error("[ranking.applySubmission] 更新分数失败",
+			zap.Uint("user_id", sub.UserID),
+			zap.Int("rank_type", def.ID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	if improved {
+		s.syncBoard(def, ranking)
+		if watch {
+			go s.notifyRankChange(def, sub.UserID, before)
+		}
+	}
+	return &appliedScore{
+		Ranking:  ranking,
+		Improved: improved,
+		Periods:  s.recordPeriods(def, sub.UserID, sub.Score, season, at),
+	}, nil
+}
+
+// quarantinedResponse 被隔离的提交的响应（总榜成绩为之前的成绩）
+func (s *Service) quarantinedResponse(def *Board, sub *Submission, season *Season) *UpdateScoreResponse {
+	result := &UpdateScoreResponse{
+		UserID:   sub.UserID,
+		RankType: def.ID,
+		Periods:  []PeriodScoreVO{},
+
+		Status:       SubmissionQuarantined,
+		SubmissionID: sub.ID,
+	}
+	if prev, err := s.repo.FindByUserAndType(sub.UserID, def.ID); err == nil && prev != nil {
+		result.Score = prev.Score
+		result.UpdatedAt = prev.UpdatedAt
+	}
+	if season != nil {
+		vo := toSeasonVO(season, time.Now())
+		result.Season = &vo
+	}
+	return result
+}
+
+// recordPeriods 将分数写入赛季榜和at所在的日榜、周榜、月榜，返回各周期的成绩
+// 总榜已经更新成功，周期榜写入失败只记录日志
+func (s *Service) recordPeriods(def *Board, userID uint, score int, season *Season, at time.Time) []PeriodScoreVO {
+	windows := make([]periodWindow, 0, len(calendarPeriods)+1)
+	if season != nil {
+		windows = append(windows, seasonWindow(season))
+	}
+	for _, period := range calendarPeriods {
+		windows = append(windows, calendarWindow(period, at, s.loc))
+	}
+
+	periods := make([]PeriodScoreVO, 0, len(windows))
+	for _, w := range windows {
+		ps, improved, err := s.repo.UpsertPeriodScore(def, userID, w.Period, w.Key, w.EndsAt, score)
+		if err != nil {
+			logger.Error("[ranking.recordPeriods] 更新周期成绩失败",
+				zap.Uint("user_id", userID),
+				zap.Int("rank_type", def.ID),
+				zap.String("period", w.Period),
+				zap.String("period_key", w.Key),
+				zap.Error(err))
+			continue
+		}
+		periods = append(periods, PeriodScoreVO{
+			Period:    ps.Period,
+			PeriodKey: ps.PeriodKey,
+			Score:     ps.Score,
+			EndsAt:    ps.EndsAt,
+			Improved:  improved,
+		})
+	}
+	return periods
 }
 
-// GetRankings 获取排行榜
-func (s *Service) GetRankings(rankType, page, limit int) ([]RankingItem, error) {
+// GetRankings 获取排行榜（q.Period为空时为总榜，否则为赛季榜或日榜/周榜/月榜）
+func (s *Service) GetRankings(rankType, page, limit int, q BoardQuery) (*RankingListResponse, error) {
 	logger.Info("[ranking.GetRankings] 获取排行榜",
 		zap.Int("rank_type", rankType),
+		zap.String("period", q.Period),
 		zap.Int("page", page),
 		zap.Int("limit", limit))
-	
-	if !ValidateRankType(rankType) {
+
+	def, err := s.publicBoard(rankType)
+	if err != nil {
 		logger.Warn("[ranking.GetRankings] 排行榜类型无效", zap.Int("rank_type", rankType))
-		return nil, fmt.Errorf("排行榜类型无效")
+		return nil, err
+	}
+	if !ValidatePeriod(q.Period) {
+		return nil, errcode.New(errcode.InvalidPeriod)
 	}
 
 	if page < 1 {
@@ -111,70 +286,278 @@ func (s *Service) GetRankings(rankType, page, limit int) ([]RankingItem, error)
 	}
 
 	offset := (page - 1) * limit
-	cacheKey := fmt.Sprintf("rankings:type:%d:page:%d:limit:%d", rankType, page, limit)
-
-	// 尝试从缓存获取
-	if s.cache != nil {
-		var cachedRankings []RankingItem
-		if err := s.cache.Get(cacheKey, &cachedRankings); err == nil && len(cachedRankings) > 0 {
-			logger.Debug("[ranking.GetRankings] 从缓存获取",
-				zap.Int("rank_type", rankType),
-				zap.Int("count", len(cachedRankings)))
-			return cachedRankings, nil
+	result := &RankingListResponse{
+		RankType: rankType,
+		Period:   q.Period,
+		Page:     page,
+		Limit:    limit,
+	}
+
+	var entries []boardEntry
+	switch q.Period {
+	case "":
+		entries, err = s.rangeEntries(def, offset, limit)
+	case PeriodSeason:
+		entries, err = s.seasonEntries(def, offset, limit, q.SeasonID, result)
+	default:
+		entries, err = s.calendarEntries(def, offset, limit, q, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Rankings = s.toRankingItems(entries, offset)
+	if !result.Archived {
+		result.NextCursor = nextCursor(entries, offset, limit)
+	}
+
+	logger.Info("[ranking.GetRankings] 成功获取排行榜",
+		zap.Int("rank_type", rankType),
+		zap.String("period", q.Period),
+		zap.String("period_key", result.PeriodKey),
+		zap.Int("page", page),
+		zap.Int("count", len(result.Rankings)))
+	return result, nil
+}
+
+// seasonEntries 获取赛季榜（已结束的赛季读取归档的最终排名）
+func (s *Service) seasonEntries(def *Board, offset, limit int, seasonID uint, result *RankingListResponse) ([]boardEntry, error) {
+	season, err := s.findBoardSeason(seasonID)
+	if err != nil {
+		return nil, err
+	}
+	vo := toSeasonVO(season, time.Now())
+	result.Season = &vo
+	result.PeriodKey = seasonKey(season.ID)
+
+	if season.Status == SeasonStatusClosed {
+		result.Archived = true
+		standings, err := s.repo.GetSeasonStandings(season.ID, def.ID, offset, limit)
+		if err != nil {
+			logger.Error("[ranking.seasonEntries] 查询赛季归档排名失败", zap.Uint("season_id", season.ID), zap.Error(err))
+			return nil, err
+		}
+		entries := make([]boardEntry, len(standings))
+		for i, st := range standings {
+			entries[i] = boardEntry{UserID: st.UserID, Score: st.Score, UpdatedAt: st.UpdatedAt}
+		}
+		return entries, nil
+	}
+	return s.periodEntries(def, PeriodSeason, result.PeriodKey, offset, limit)
+}
+
+// calendarEntries 获取日榜/周榜/月榜（q.Date为空时为当前周期）
+func (s *Service) calendarEntries(def *Board, offset, limit int, q BoardQuery, result *RankingListResponse) ([]boardEntry, error) {
+	w, err := s.queryWindow(q)
+	if err != nil {
+		return nil, err
+	}
+	result.PeriodKey = w.Key
+	return s.periodEntries(def, w.Period, w.Key, offset, limit)
+}
+
+// queryWindow 查询的日榜/周榜/月榜周期（q.Date为空时为当前周期）
+func (s *Service) queryWindow(q BoardQuery) (periodWindow, error) {
+	at := time.Now()
+	if q.Date != "" {
+		date, err := time.ParseInLocation("2006-01-02", q.Date, s.loc)
+		if err != nil {
+			return periodWindow{}, errcode.NewWithMessage(errcode.InvalidParams, "日期格式应为YYYY-MM-DD")
+		}
+		at = date
+	}
+	return calendarWindow(q.Period, at, s.loc), nil
+}
+
+// periodEntries 查询周期排行榜
+func (s *Service) periodEntries(def *Board, period, key string, offset, limit int) ([]boardEntry, error) {
+	scores, err := s.repo.GetPeriodRankings(def, period, key, offset, limit)
+	if err != nil {
+		logger.Error("[ranking.periodEntries] 数据库查询失败",
+			zap.Int("rank_type", def.ID),
+			zap.String("period", period),
+			zap.String("period_key", key),
+			zap.Error(err))
+		return nil, err
+	}
+	entries := make([]boardEntry, len(scores))
+	for i, ps := range scores {
+		entries[i] = boardEntry{UserID: ps.UserID, Score: ps.Score, UpdatedAt: ps.UpdatedAt}
+	}
+	return entries, nil
+}
+
+// GetMyRank 获取玩家在排行榜中的排名、分数和百分位
+func (s *Service) GetMyRank(userID uint, rankType int) (*MyRankResponse, error) {
+	def, err := s.publicBoard(rankType)
+	if err != nil {
+		return nil, err
+	}
+
+	pos, err := s.locate(userID, def)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return nil, errcode.New(errcode.RankingNotFound)
+	}
+
+	return &MyRankResponse{
+		RankType:   rankType,
+		Rank:       pos.Rank,
+		Score:      pos.Entry.Score,
+		Total:      pos.Total,
+		Percentile: percentile(pos.Rank, pos.Total),
+		UpdatedAt:  pos.Entry.UpdatedAt,
+	}, nil
+}
+
+// GetAround 获取玩家前后各radius名的玩家（包含玩家自己）
+func (s *Service) GetAround(userID uint, rankType, radius int) (*RankingAroundResponse, error) {
+	def, err := s.publicBoard(rankType)
+	if err != nil {
+		return nil, err
+	}
+	if radius < 1 || radius > 50 {
+		radius = 5
+	}
+
+	pos, err := s.locate(userID, def)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return nil, errcode.New(errcode.RankingNotFound)
+	}
+
+	// 排名区间 [rank-radius, rank+radius]，按排名直接截取，同分时的先后与排行榜分页一致
+	offset := pos.Rank - 1 - radius
+	if offset < 0 {
+		offset = 0
+	}
+	limit := pos.Rank + radius - offset
+	entries, err := s.rangeEntries(def, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RankingAroundResponse{
+		RankType: rankType,
+		Rank:     pos.Rank,
+		Total:    pos.Total,
+		Radius:   radius,
+		Rankings: s.toRankingItems(entries, offset),
+	}, nil
+}
+
+// locate 查询玩家在排行榜中的位置（优先读取Redis排行榜，Redis不可用时查询MySQL），不在排行榜中时返回nil
+func (s *Service) locate(userID uint, def *Board) (*boardPosition, error) {
+	rankType := def.ID
+	if s.board != nil {
+		pos, err := s.board.Locate(def, userID)
+		if err == nil {
+			return pos, nil
+		}
+		logger.Warn("[ranking.locate] Redis排行榜读取失败，改为查询数据库",
+			zap.Uint("user_id", userID),
+			zap.Int("rank_type", rankType),
+			zap.Error(err))
+	}
+
+	r, err := s.repo.FindByUserAndType(userID, rankType)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	ahead, err := s.repo.CountAhead(def, r.Score, r.UpdatedAt, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.repo.CountByRankType(rankType)
+	if err != nil {
+		return nil, err
+	}
+	return &boardPosition{
+		Entry: boardEntry{UserID: r.UserID, Score: r.Score, UpdatedAt: r.UpdatedAt},
+		Rank:  int(ahead) + 1,
+		Total: total,
+	}, nil
+}
+
+// percentile 超过的玩家百分比（第1名为100，最后一名为0，保留两位小数）
+func percentile(rank int, total int64) float64 {
+	if total <= 1 {
+		return 100
+	}
+	p := float64(total-int64(rank)) / float64(total-1) * 100
+	return math.Round(p*100) / 100
+}
+
+// rangeEntries 按排名获取一段记录（优先读取Redis排行榜，Redis不可用时查询MySQL）
+func (s *Service) rangeEntries(def *Board, offset, limit int) ([]boardEntry, error) {
+	rankType := def.ID
+	if s.board != nil {
+		entries, err := s.board.Range(def, offset, limit)
+		if err == nil {
+			return entries, nil
 		}
+		logger.Warn("[ranking.rangeEntries] Redis排行榜读取失败，改为查询数据库",
+			zap.Int("rank_type", rankType),
+			zap.Error(err))
 	}
 
-	// 从数据库查询
-	rankings, err := s.repo.GetRankings(rankType, offset, limit)
+	rankings, err := s.repo.GetRankings(def, offset, limit)
 	if err != nil {
-		logger.Error("[ranking.GetRankings] 数据库查询失败",
+		logger.Error("[ranking.rangeEntries] 数据库查询失败",
 			zap.Int("rank_type", rankType),
 			zap.Error(err))
 		return nil, err
 	}
+	entries := make([]boardEntry, len(rankings))
+	for i, r := range rankings {
+		entries[i] = boardEntry{UserID: r.UserID, Score: r.Score, UpdatedAt: r.UpdatedAt}
+	}
+	return entries, nil
+}
 
+// toRankingItems 转换为排行榜项（offset为第一条记录的排名偏移量）
+func (s *Service) toRankingItems(entries []boardEntry, offset int) []RankingItem {
 	// 收集所有 userID 并批量查询用户名（优化N+1查询）
-	userIDs := make([]uint, len(rankings))
-	for i, r := range rankings {
-		userIDs[i] = r.UserID
+	userIDs := make([]uint, len(entries))
+	for i, e := range entries {
+		userIDs[i] = e.UserID
 	}
 
-	// 批量获取用户名
-	usernames := make(map[uint]string)
+	// 批量获取用户展示信息
+	users := make(map[uint]user.Brief)
 	if s.userService != nil && len(userIDs) > 0 {
-		if usernameMap, err := s.userService.GetUsernames(userIDs); err == nil {
-			usernames = usernameMap
+		if briefMap, err := s.userService.GetUsernames(userIDs); err == nil {
+			users = briefMap
 		}
 	}
 
 	// 转换为VO并计算排名
-	items := make([]RankingItem, len(rankings))
-	for i, r := range rankings {
-		// 从批量查询结果中获取用户名
+	items := make([]RankingItem, len(entries))
+	for i, r := range entries {
+		// 从批量查询结果中获取用户名、昵称和头像
 		username := fmt.Sprintf("user_%d", r.UserID)
-		if name, ok := usernames[r.UserID]; ok && name != "" {
-			username = name
+		displayName := username
+		avatar := ""
+		if brief, ok := users[r.UserID]; ok && brief.Username != "" {
+			username = brief.Username
+			displayName = brief.DisplayName
+			avatar = brief.AvatarThumb // 排行榜使用缩略图
 		}
 
 		items[i] = RankingItem{
-			Rank:      offset + i + 1, // 排名 = 偏移量 + 当前索引 + 1
-			UserID:    r.UserID,
-			Username:  username,
-			Score:     r.Score,
-			UpdatedAt: r.UpdatedAt,
+			Rank:        offset + i + 1, // 排名 = 偏移量 + 当前索引 + 1
+			UserID:      r.UserID,
+			Username:    username,
+			DisplayName: displayName,
+			Avatar:      avatar,
+			Score:       r.Score,
+			UpdatedAt:   r.UpdatedAt,
 		}
 	}
-
-	// 写入缓存（缓存10分钟）
-	if s.cache != nil {
-		s.cache.Set(cacheKey, items, 10*time.Minute)
-	}
-
-	logger.Info("[ranking.GetRankings] 成功获取排行榜",
-		zap.Int("rank_type", rankType),
-		zap.Int("page", page),
-		zap.Int("count", len(items)))
-	return items, nil
+	return items
 }
 
 // DeleteRanking 删除指定类型的排行榜记录
@@ -182,8 +565,8 @@ func (s *Service) DeleteRanking(userID uint, rankType int) error {
 	logger.Info("[ranking.DeleteRanking] 删除排行榜记录",
 		zap.Uint("user_id", userID),
 		zap.Int("rank_type", rankType))
-	
-	if !ValidateRankType(rankType) {
+
+	if s.boardDef(rankType) == nil {
 		logger.Warn("[ranking.DeleteRanking] 排行榜类型无效",
 			zap.Uint("user_id", userID),
 			zap.Int("rank_type", rankType))
@@ -198,10 +581,8 @@ func (s *Service) DeleteRanking(userID uint, rankType int) error {
 		return err
 	}
 
-	if s.cache != nil {
-		s.clearRankingCache(rankType)
-	}
-	
+	s.removeFromBoard(rankType, userID)
+
 	logger.Info("[ranking.DeleteRanking] 删除成功",
 		zap.Uint("user_id", userID),
 		zap.Int("rank_type", rankType))
@@ -211,29 +592,89 @@ func (s *Service) DeleteRanking(userID uint, rankType int) error {
 // DeleteAllRankings 删除用户的所有排行榜记录
 func (s *Service) DeleteAllRankings(userID uint) error {
 	logger.Info("[ranking.DeleteAllRankings] 删除用户所有排行榜记录", zap.Uint("user_id", userID))
-	
+
 	if err := s.repo.DeleteAllByUser(userID); err != nil {
 		logger.Error("[ranking.DeleteAllRankings] 删除失败", zap.Uint("user_id", userID), zap.Error(err))
 		return err
 	}
 
-	// 清除所有排行榜缓存
-	if s.cache != nil {
-		for i := 1; i <= 9; i++ {
-			s.clearRankingCache(i)
-		}
+	for _, def := range s.allBoards() {
+		s.removeFromBoard(def.ID, userID)
 	}
 
 	logger.Info("[ranking.DeleteAllRankings] 删除成功", zap.Uint("user_id", userID))
 	return nil
 }
 
-// clearRankingCache 清除排行榜缓存
-func (s *Service) clearRankingCache(rankType int) {
-	for page := 1; page <= 10; page++ {
-		for limit := 10; limit <= 100; limit += 10 {
-			key := fmt.Sprintf("rankings:type:%d:page:%d:limit:%d", rankType, page, limit)
-			s.cache.Delete(key)
+// PurgeUsers 删除用户时清理排行榜数据（注册为 user.Service 的清理钩子，在删除用户的事务中执行）
+// Redis排行榜在事务提交后由 RemoveUsers 清理
+func (s *Service) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	if err := s.repo.PurgeUsers(tx, userIDs); err != nil {
+		logger.Error("[ranking.PurgeUsers] 清理排行榜数据失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// RemoveUsers 从所有Redis排行榜中移除已删除的用户（数据库记录已随用户一并删除）
+func (s *Service) RemoveUsers(userIDs []uint) {
+	for _, def := range s.allBoards() {
+		s.removeFromBoard(def.ID, userIDs...)
+	}
+}
+
+// RebuildLeaderboards 从MySQL重建Redis排行榜（rankTypes为空时重建全部），返回各类型写入的记录数
+func (s *Service) RebuildLeaderboards(rankTypes []int) (map[int]int, error) {
+	if s.board == nil {
+		return nil, fmt.Errorf("未配置Redis排行榜")
+	}
+	defs := s.allBoards()
+	if len(rankTypes) > 0 {
+		defs = make([]*Board, 0, len(rankTypes))
+		for _, rankType := range rankTypes {
+			def := s.boardDef(rankType)
+			if def == nil {
+				return nil, fmt.Errorf("排行榜类型无效: %d", rankType)
+			}
+			defs = append(defs, def)
+		}
+	}
+
+	counts := make(map[int]int, len(defs))
+	for _, def := range defs {
+		count, err := s.board.Rebuild(def)
+		if err != nil {
+			logger.Error("[ranking.RebuildLeaderboards] 重建失败", zap.Int("rank_type", def.ID), zap.Error(err))
+			return counts, err
 		}
+		counts[def.ID] = count
+	}
+	return counts, nil
+}
+
+// syncBoard 将MySQL中的最新分数同步到Redis排行榜（失败时标记重建，不影响本次更新）
+func (s *Service) syncBoard(def *Board, r *Entity) {
+	if s.board == nil {
+		return
+	}
+	if err := s.board.Set(def, r); err != nil {
+		logger.Warn("[ranking.syncBoard] 同步Redis排行榜失败，下次访问时重建",
+			zap.Uint("user_id", r.UserID),
+			zap.Int("rank_type", r.RankType),
+			zap.Error(err))
+		s.board.Invalidate(r.RankType)
+	}
+}
+
+// removeFromBoard 从Redis排行榜中移除用户（失败时标记重建）
+func (s *Service) removeFromBoard(rankType int, userIDs ...uint) {
+	if s.board == nil {
+		return
+	}
+	if err := s.board.Remove(rankType, userIDs...); err != nil {
+		logger.Warn("[ranking.removeFromBoard] 从Redis排行榜移除失败，下次访问时重建",
+			zap.Int("rank_type", rankType),
+			zap.Error(err))
+		s.board.Invalidate(rankType)
 	}
 }
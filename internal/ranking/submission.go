@@ -0,0 +1,208 @@
+// Package ranking - 成绩提交记录
+// 功能：保存每次成绩提交（客户端版本、对局ID、录像哈希），提供玩家的成绩历史和管理员审查可疑成绩
+// 特点：总榜和周期榜只保存聚合后的成绩，提交记录保留原始分数；记录写入失败不影响成绩更新
+package ranking
+
+import (
+	"strings"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+
+	"go.uber.org/zap"
+)
+
+// scoreJumpFactor 成绩提升超过该倍数时标记为可疑
+const scoreJumpFactor = 2
+
+// SubmissionFilter 管理员查询提交记录的条件（零值表示不限）
+type SubmissionFilter struct {
+	UserID     uint
+	RankType   int
+	Status     string
+	RunID      string
+	Suspicious bool // 只看有可疑标记或被拒绝的提交
+	Since      time.Time
+	Until      time.Time
+}
+
+// newSubmission 根据提交请求创建提交记录
+func newSubmission(userID uint, def *Board, req *UpdateScoreRequest, clientIP string) *Submission {
+	return &Submission{
+		UserID:        userID,
+		RankType:      def.ID,
+		Score:         req.Score,
+		ClientVersion: req.ClientVersion,
+		RunID:         req.RunID,
+		ReplayHash:    strings.ToLower(req.ReplayHash),
+		ClientIP:      clientIP,
+	}
+}
+
+// submissionFlags 检查提交是否可疑（与之前的最好成绩、提交记录和排行榜的分数限制比较），查询失败时不标记
+func (s *Service) submissionFlags(def *Board, sub *Submission) []string {
+	var flags []string
+
+	if sub.RunID != "" {
+		dup, err := s.repo.HasRunSubmission(sub.UserID, def.ID, sub.RunID)
+		if err != nil {
+			logger.Error("[ranking.submissionFlags] 查询对局提交记录失败", zap.Uint("user_id", sub.UserID), zap.Error(err))
+		} else if dup {
+			flags = append(flags, FlagDuplicateRun)
+		}
+	}
+
+	prev, err := s.repo.FindByUserAndType(sub.UserID, def.ID)
+	if err != nil {
+		logger.Error("[ranking.submissionFlags] 查询之前的成绩失败", zap.Uint("user_id", sub.UserID), zap.Error(err))
+		return flags
+	}
+	// 只有最高分/最低分榜的成绩可以和之前的最好成绩比较
+	if (def.Aggregation == AggregateMax || def.Aggregation == AggregateMin) &&
+		prev != nil && prev.Score > 0 && scoreJumped(def, prev.Score, sub.Score) {
+		flags = append(flags, FlagScoreJump)
+	}
+	return append(flags, s.plausibilityFlags(def, sub, prev)...)
+}
+
+// scoreJumped 成绩是否比之前的最好成绩提升了scoreJumpFactor倍以上
+func scoreJumped(def *Board, best, score int) bool {
+	if def.Ascending() {
+		return score*scoreJumpFactor < best
+	}
+	return score > best*scoreJumpFactor
+}
+
+// rejectSubmission 保存被拒绝的提交记录
+func (s *Service) rejectSubmission(sub *Submission, reason string) {
+	sub.Status = SubmissionRejected
+	sub.Reason = reason
+	s.recordSubmission(sub)
+}
+
+// recordSubmission 保存提交记录（失败只记录日志）
+func (s *Service) recordSubmission(sub *Submission) {
+	if err := s.repo.CreateSubmission(sub); err != nil {
+		logger.Error("[ranking.recordSubmission] 保存提交记录失败",
+			zap.Uint("user_id", sub.UserID),
+			zap.Int("rank_type", sub.RankType),
+			zap.Int("score", sub.Score),
+			zap.Error(err))
+		return
+	}
+	if sub.Flags != "" {
+		logger.Warn("[ranking.recordSubmission] 可疑的成绩提交",
+			zap.Uint("submission_id", sub.ID),
+			zap.Uint("user_id", sub.UserID),
+			zap.Int("rank_type", sub.RankType),
+			zap.Int("score", sub.Score),
+			zap.String("flags", sub.Flags))
+	}
+}
+
+// GetHistory 获取我在指定排行榜的提交记录（按时间倒序）
+func (s *Service) GetHistory(userID uint, rankType, page, limit int) (*SubmissionHistoryResponse, error) {
+	def, err := s.publicBoard(rankType)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	subs, total, err := s.repo.ListSubmissions(userID, def.ID, (page-1)*limit, limit)
+	if err != nil {
+		logger.Error("[ranking.GetHistory] 查询提交记录失败",
+			zap.Uint("user_id", userID),
+			zap.Int("rank_type", rankType),
+			zap.Error(err))
+		return nil, err
+	}
+
+	items := make([]SubmissionVO, len(subs))
+	for i, sub := range subs {
+		items[i] = toSubmissionVO(sub)
+	}
+	return &SubmissionHistoryResponse{
+		RankType:    def.ID,
+		Page:        page,
+		Limit:       limit,
+		Total:       total,
+		Submissions: items,
+	}, nil
+}
+
+// AdminSearchSubmissions 按条件查询提交记录（管理员审查可疑成绩）
+func (s *Service) AdminSearchSubmissions(f SubmissionFilter, page, limit int) (*AdminSubmissionListVO, error) {
+	subs, total, err := s.repo.SearchSubmissions(f, (page-1)*limit, limit)
+	if err != nil {
+		logger.Error("[ranking.AdminSearchSubmissions] 查询提交记录失败", zap.Error(err))
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		userIDs = append(userIDs, sub.UserID)
+	}
+	briefs, err := s.userService.GetUsernames(userIDs)
+	if err != nil {
+		logger.Error("[ranking.AdminSearchSubmissions] 批量获取用户名失败", zap.Error(err))
+		return nil, err
+	}
+
+	items := make([]AdminSubmissionVO, len(subs))
+	for i, sub := range subs {
+		items[i] = toAdminSubmissionVO(sub, briefs[sub.UserID].Username)
+	}
+	return &AdminSubmissionListVO{Total: total, Submissions: items}, nil
+}
+
+// PurgeSubmissions 清理超过retention的提交记录（由定时任务调用）
+func (s *Service) PurgeSubmissions(retention time.Duration) {
+	count, err := s.repo.DeleteSubmissionsBefore(time.Now().Add(-retention))
+	if err != nil {
+		logger.Error("[ranking.PurgeSubmissions] 清理提交记录失败", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		logger.Info("[ranking.PurgeSubmissions] 已清理过期提交记录", zap.Int64("count", count))
+	}
+}
+
+// toAdminSubmissionVO 转换为管理后台提交记录VO
+func toAdminSubmissionVO(sub *Submission, username string) AdminSubmissionVO {
+	flags := []string{}
+	if sub.Flags != "" {
+		flags = strings.Split(sub.Flags, ",")
+	}
+	return AdminSubmissionVO{
+		SubmissionVO: toSubmissionVO(sub),
+		UserID:       sub.UserID,
+		Username:     username,
+		ReplayHash:   sub.ReplayHash,
+		ClientIP:     sub.ClientIP,
+		Reason:       sub.Reason,
+		Flags:        flags,
+		Signed:       sub.Signed,
+		ReviewedBy:   sub.ReviewedBy,
+		ReviewedAt:   sub.ReviewedAt,
+	}
+}
+
+// toSubmissionVO 转换为提交记录VO
+func toSubmissionVO(sub *Submission) SubmissionVO {
+	return SubmissionVO{
+		ID:            sub.ID,
+		RankType:      sub.RankType,
+		Score:         sub.Score,
+		BestScore:     sub.BestScore,
+		SeasonID:      sub.SeasonID,
+		Status:        sub.Status,
+		ClientVersion: sub.ClientVersion,
+		RunID:         sub.RunID,
+		CreatedAt:     sub.CreatedAt,
+	}
+}
@@ -4,11 +4,16 @@
 package routes
 
 import (
+	"net/http"
+
+	"faulty_in_culture/go_back/internal/account"
 	"faulty_in_culture/go_back/internal/chat"
+	"faulty_in_culture/go_back/internal/friend"
 	"faulty_in_culture/go_back/internal/infra/logger"
 	"faulty_in_culture/go_back/internal/ranking"
 	"faulty_in_culture/go_back/internal/savegame"
 	"faulty_in_culture/go_back/internal/shared/middleware"
+	"faulty_in_culture/go_back/internal/shared/security"
 	"faulty_in_culture/go_back/internal/user"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +28,9 @@ type Handlers struct {
 	Chat     *chat.Handler
 	SaveGame *savegame.Handler
 	Ranking  *ranking.Handler
+	Account  *account.Handler
+	Friend   *friend.Handler
+	Files    http.Handler // 上传文件下载（可为nil）
 }
 
 // SetupRoutes 设置所有路由
@@ -34,37 +42,152 @@ func SetupRoutes(router *gin.Engine, h *Handlers) {
 		// ========== 用户认证（公开接口）==========
 		api.POST("/register", h.User.Register)
 		api.POST("/login", h.User.Login)
+		api.POST("/password/reset", h.User.ResetPassword) // 使用重置令牌设置新密码
+		api.POST("/guest", h.User.GuestLogin)             // 游客登录
+
+		// 第三方登录（OIDC授权码模式）
+		api.GET("/auth/oidc/providers", h.User.OIDCProviders)
+		api.GET("/auth/oidc/:provider/login", h.User.OIDCLogin)       // 跳转到身份提供方
+		api.GET("/auth/oidc/:provider/callback", h.User.OIDCCallback) // 身份提供方回调
+
+		// ========== 当前用户（需要认证）==========
+		meGroup := api.Group("/me")
+		meGroup.Use(middleware.AuthMiddleware())
+		{
+			meGroup.GET("/sessions", h.User.ListSessions)              // 登录设备列表
+			meGroup.DELETE("/sessions/:id", h.User.RevokeSession)      // 踢下线设备
+			meGroup.PUT("/password", h.User.ChangePassword)            // 修改密码
+			meGroup.POST("/upgrade", h.User.UpgradeGuest)              // 游客升级为正式账号
+			meGroup.GET("/profile", h.User.GetMyProfile)               // 获取我的资料
+			meGroup.PUT("/profile", h.User.UpdateMyProfile)            // 更新我的资料
+			meGroup.POST("/avatar", h.User.UploadAvatar)               // 上传头像
+			meGroup.GET("/export", h.Account.Export)                   // 导出个人数据
+			meGroup.DELETE("", h.User.DeleteAccount)                   // 注销账号
+			meGroup.GET("/identities", h.User.ListIdentities)          // 绑定的第三方身份
+			meGroup.POST("/identities/:provider", h.User.LinkIdentity) // 绑定第三方身份
+			meGroup.DELETE("/identities/:id", h.User.UnlinkIdentity)   // 解绑第三方身份
+		}
+
+		// ========== 用户公开资料（公开接口）==========
+		api.GET("/users/:id", h.User.GetPublicProfile)
+
+		// ========== 管理后台（需要认证和相应权限）==========
+		adminGroup := api.Group("/admin")
+		adminGroup.Use(
+			middleware.AuthMiddleware(),
+			middleware.AdminAudit(),
+			middleware.RequireRole(security.RoleModerator, security.RoleAdmin),
+		)
+		{
+			canView := middleware.RequirePermission(security.PermUserView)
+			canBan := middleware.RequirePermission(security.PermUserBan)
+			canResetPassword := middleware.RequirePermission(security.PermUserResetPassword)
+			canManageRole := middleware.RequirePermission(security.PermUserManageRole)
+			canSupport := middleware.RequirePermission(security.PermSupportView)
+			canManageRanking := middleware.RequirePermission(security.PermRankingManage)
+
+			adminGroup.GET("/users", canView, h.User.AdminSearchUsers)                                               // 搜索用户
+			adminGroup.GET("/users/:id", canView, h.User.AdminGetUser)                                               // 用户详情
+			adminGroup.POST("/users/:id/ban", canBan, h.User.AdminBanUser)                                           // 封禁
+			adminGroup.DELETE("/users/:id/ban", canBan, h.User.AdminUnbanUser)                                       // 解除封禁
+			adminGroup.POST("/users/:id/password-reset", canResetPassword, h.User.AdminIssuePasswordReset)           // 签发密码重置令牌
+			adminGroup.PUT("/users/:id/role", canManageRole, h.User.AdminSetRole)                                    // 修改角色
+			adminGroup.GET("/users/:id/savegames", canSupport, h.SaveGame.AdminQueryAll)                             // 玩家存档
+			adminGroup.GET("/users/:id/chat/sessions", canSupport, h.Chat.AdminListSessions)                         // 玩家聊天会话
+			adminGroup.GET("/users/:id/chat/sessions/:session_id/messages", canSupport, h.Chat.AdminGetHistory)      // 玩家聊天消息
+			adminGroup.PUT("/rankings/seasons/:id", canManageRanking, h.Ranking.AdminUpdateSeason)                   // 修改赛季
+			adminGroup.GET("/rankings/boards", canManageRanking, h.Ranking.AdminListBoards)                          // 排行榜定义（含隐藏）
+			adminGroup.POST("/rankings/boards", canManageRanking, h.Ranking.AdminCreateBoard)                        // 创建排行榜
+			adminGroup.PUT("/rankings/boards/:id", canManageRanking, h.Ranking.AdminUpdateBoard)                     // 修改排行榜
+			adminGroup.GET("/rankings/:rank_type/export", canManageRanking, h.Ranking.AdminExportRankings)           // 导出排行榜（CSV/JSON）
+			adminGroup.GET("/rankings/submissions", canManageRanking, h.Ranking.AdminSearchSubmissions)              // 成绩提交记录（审查可疑成绩）
+			adminGroup.POST("/rankings/submissions/:id/approve", canManageRanking, h.Ranking.AdminApproveSubmission) // 审核通过隔离的成绩
+			adminGroup.POST("/rankings/submissions/:id/reject", canManageRanking, h.Ranking.AdminRejectSubmission)   // 拒绝隔离的成绩
+		}
+
+		// ========== 好友（需要认证）==========
+		friendGroup := api.Group("/friends")
+		friendGroup.Use(middleware.AuthMiddleware())
+		{
+			friendGroup.GET("", h.Friend.ListFriends)                          // 好友列表（含在线状态）
+			friendGroup.DELETE("/:user_id", h.Friend.RemoveFriend)             // 删除好友
+			friendGroup.GET("/requests", h.Friend.ListRequests)                // 好友请求 ?direction=incoming|outgoing
+			friendGroup.POST("/requests", h.Friend.SendRequest)                // 发送好友请求
+			friendGroup.POST("/requests/:id/accept", h.Friend.AcceptRequest)   // 接受
+			friendGroup.POST("/requests/:id/decline", h.Friend.DeclineRequest) // 拒绝
+			friendGroup.GET("/blocks", h.Friend.ListBlocks)                    // 屏蔽名单
+			friendGroup.POST("/blocks", h.Friend.BlockUser)                    // 屏蔽用户
+			friendGroup.DELETE("/blocks/:user_id", h.Friend.UnblockUser)       // 解除屏蔽
+		}
+
+		// ========== 上传文件（公开接口）==========
+		if h.Files != nil {
+			api.GET("/files/*filepath", gin.WrapH(http.StripPrefix("/api/files", h.Files)))
+		}
 
 		// ========== 排行榜模块 ==========
 		// 查询排行榜（公开接口）
 		api.GET("/rankings/:rank_type", h.Ranking.GetRankings)
+		api.GET("/rankings/seasons", h.Ranking.ListSeasons)
+		api.GET("/rankings/boards", h.Ranking.ListBoards)
+		api.GET("/rankings/:rank_type/group", h.Ranking.GetGroupRankings) // 指定玩家的排行榜（组内相对名次）
 
 		// 排行榜管理（需要认证）
 		rankingGroup := api.Group("/rankings")
 		rankingGroup.Use(middleware.AuthMiddleware())
 		{
-			rankingGroup.POST("", h.Ranking.UpdateScore)                // 更新分数
-			rankingGroup.DELETE("/:rank_type", h.Ranking.DeleteRanking) // 删除指定类型
-			rankingGroup.DELETE("", h.Ranking.DeleteAllRankings)        // 删除所有
+			notRankingBanned := middleware.RequireNotBanned(security.BanScopeRanking) // 被禁止参与排行榜的用户不能提交成绩
+
+			rankingGroup.POST("", notRankingBanned, h.Ranking.UpdateScore)            // 更新分数
+			rankingGroup.POST("/runs", notRankingBanned, h.Ranking.CreateRun)         // 开始对局（签发成绩签名用的nonce）
+			rankingGroup.GET("/:rank_type/me", h.Ranking.GetMyRank)                   // 我的排名
+			rankingGroup.GET("/:rank_type/around", h.Ranking.GetAround)               // 我附近的排名
+			rankingGroup.GET("/:rank_type/friends", h.Ranking.GetFriendRankings)      // 好友榜
+			rankingGroup.GET("/:rank_type/rooms/:room_id", h.Ranking.GetRoomRankings) // 聊天室（公会）榜
+			rankingGroup.GET("/:rank_type/history", h.Ranking.GetHistory)             // 我的提交记录
+			rankingGroup.PUT("/:rank_type/subscription", h.Ranking.Subscribe)         // 订阅排行榜变化（WebSocket推送）
+			rankingGroup.DELETE("/:rank_type/subscription", h.Ranking.Unsubscribe)    // 取消订阅
+			rankingGroup.DELETE("/:rank_type", h.Ranking.DeleteRanking)               // 删除指定类型
+			rankingGroup.DELETE("", h.Ranking.DeleteAllRankings)                      // 删除所有
 		}
 
 		// ========== 聊天模块（需要认证）==========
 		// WebSocket实时消息流（使用支持query参数的认证中间件，因为浏览器WebSocket API不支持自定义headers）
+		// 全部封禁的用户由WebSocket管理器在注册连接时拒绝；禁言用户可以连接，发送消息的接口由notMuted拒绝
 		api.GET("/chat/ws", middleware.AuthMiddlewareWithQuery(), h.Chat.WebSocketHandler)
 		chatGroup := api.Group("/chat")
 		chatGroup.Use(middleware.AuthMiddleware())
 		{
+			notMuted := middleware.RequireNotBanned(security.BanScopeChat) // 禁言用户不能创建会话和发送消息
+
 			// 会话管理
 			chatGroup.GET("/sessions", h.Chat.ListSessions)         // 会话列表
-			chatGroup.POST("/sessions", h.Chat.StartChat)           // 创建会话
+			chatGroup.POST("/sessions", notMuted, h.Chat.StartChat) // 创建会话
 			chatGroup.GET("/sessions/:id", h.Chat.GetSession)       // 会话详情
 			chatGroup.PUT("/sessions/:id", h.Chat.UpdateSession)    // 更新会话
 			chatGroup.DELETE("/sessions/:id", h.Chat.DeleteSession) // 删除会话
+			chatGroup.POST("/direct", notMuted, h.Chat.StartDirect) // 发起私聊
 
 			// 消息管理
-			chatGroup.GET("/sessions/:id/messages", h.Chat.GetHistory)   // 消息历史
-			chatGroup.POST("/sessions/:id/messages", h.Chat.SendMessage) // 发送消息
-			chatGroup.DELETE("/messages/:id", h.Chat.RecallMessages)     // 撤回消息
+			chatGroup.GET("/sessions/:id/messages", h.Chat.GetHistory)             // 消息历史
+			chatGroup.POST("/sessions/:id/messages", notMuted, h.Chat.SendMessage) // 发送消息
+			chatGroup.DELETE("/messages/:id", h.Chat.RecallMessages)               // 撤回消息
+			chatGroup.POST("/sessions/:id/read", h.Chat.MarkRead)                  // 标记已读（私聊/聊天室）
+			chatGroup.GET("/unread", h.Chat.GetUnread)                             // 未读数（私聊/聊天室）
+
+			// 聊天室（消息收发和历史复用上面的会话消息接口）
+			chatGroup.GET("/rooms", h.Chat.ListRooms)                                 // 我加入的聊天室
+			chatGroup.POST("/rooms", notMuted, h.Chat.CreateRoom)                     // 创建聊天室
+			chatGroup.POST("/rooms/join", notMuted, h.Chat.JoinRoom)                  // 通过邀请码加入
+			chatGroup.GET("/rooms/:id", h.Chat.GetRoom)                               // 聊天室详情和成员
+			chatGroup.PUT("/rooms/:id", h.Chat.UpdateRoom)                            // 修改聊天室（管理员）
+			chatGroup.DELETE("/rooms/:id", h.Chat.DissolveRoom)                       // 解散聊天室（群主）
+			chatGroup.POST("/rooms/:id/leave", h.Chat.LeaveRoom)                      // 退出聊天室
+			chatGroup.POST("/rooms/:id/invite-code", h.Chat.RegenerateInviteCode)     // 重新生成邀请码（管理员）
+			chatGroup.PUT("/rooms/:id/members/:user_id/role", h.Chat.SetMemberRole)   // 设置角色/转让群主（群主）
+			chatGroup.POST("/rooms/:id/members/:user_id/mute", h.Chat.MuteMember)     // 禁言（管理员）
+			chatGroup.DELETE("/rooms/:id/members/:user_id/mute", h.Chat.UnmuteMember) // 解除禁言（管理员）
+			chatGroup.DELETE("/rooms/:id/members/:user_id", h.Chat.KickMember)        // 踢出成员（管理员）
 		}
 
 		// ========== 存档模块（需要认证）==========
@@ -81,12 +204,18 @@ func SetupRoutes(router *gin.Engine, h *Handlers) {
 	// Swagger文档
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// JWT验证公钥（供其他游戏服务验证本服务签发的Token）
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, security.JWKS())
+	})
+
 	// 健康检查
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok", "message": "RESTful API运行中"})
 	})
 
 	logger.Info("路由设置完成",
-		zap.Strings("modules", []string{"user", "ranking", "chat", "savegame"}),
+		zap.Strings("modules", []string{"user", "account", "friend", "ranking", "chat", "savegame"}),
 	)
 }
@@ -79,6 +79,36 @@ func (h *Handler) QueryAll(c *gin.Context) {
 		return
 	}
 
+	response.Success(c, toListResponse(saves))
+}
+
+// AdminQueryAll 查询指定玩家的所有存档（客服排查问题用）
+// @Summary 查询玩家存档（管理后台）
+// @Description 需要 support:view 权限
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response{data=SaveGameListResponse}
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/users/{id}/savegames [get]
+func (h *Handler) AdminQueryAll(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if userID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	saves, err := h.service.QueryAll(uint(userID))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	response.Success(c, toListResponse(saves))
+}
+
+// toListResponse 转换存档列表为响应
+func toListResponse(saves []*Entity) SaveGameListResponse {
 	vos := make([]SaveGameVO, len(saves))
 	for i, s := range saves {
 		vos[i] = SaveGameVO{
@@ -88,8 +118,7 @@ func (h *Handler) QueryAll(c *gin.Context) {
 			SavedAt:    s.SavedAt,
 		}
 	}
-
-	response.Success(c, SaveGameListResponse{Total: len(vos), List: vos})
+	return SaveGameListResponse{Total: len(vos), List: vos}
 }
 
 // CreateOrUpdate 创建或更新存档
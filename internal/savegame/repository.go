@@ -19,6 +19,8 @@ type Repository interface {
 	FindAllByUserID(userID uint) ([]*Entity, error)
 	// Delete 删除存档
 	Delete(userID uint, slotNumber int) error
+	// PurgeUsers 在tx中删除用户的所有存档
+	PurgeUsers(tx *gorm.DB, userIDs []uint) error
 }
 
 // repositoryImpl Repository的GORM实现
@@ -70,3 +72,8 @@ func (r *repositoryImpl) Delete(userID uint, slotNumber int) error {
 	}
 	return nil
 }
+
+// PurgeUsers 在tx中删除用户的所有存档
+func (r *repositoryImpl) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	return tx.Where("user_id IN ?", userIDs).Delete(&Entity{}).Error
+}
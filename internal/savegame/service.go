@@ -10,6 +10,7 @@ import (
 	"faulty_in_culture/go_back/internal/infra/logger"
 
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // ============================================================
@@ -131,3 +132,12 @@ func (s *Service) Delete(userID uint, slotNumber int) error {
 		zap.Int("slot_number", slotNumber))
 	return nil
 }
+
+// PurgeUsers 删除用户时清理存档（注册为 user.Service 的清理钩子，在删除用户的事务中执行）
+func (s *Service) PurgeUsers(tx *gorm.DB, userIDs []uint) error {
+	if err := s.repo.PurgeUsers(tx, userIDs); err != nil {
+		logger.Error("[savegame.PurgeUsers] 清理存档失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -18,23 +18,74 @@ const (
 	TokenExpired      = 20005
 	TokenInvalid      = 20006
 
+	LoginSessionNotFound = 20007
+	AccountLocked        = 20008
+	LoginTooFrequent     = 20009
+	WeakPassword         = 20010
+	OldPasswordIncorrect = 20011
+	ResetTokenInvalid    = 20012
+	NotGuestAccount      = 20013
+	GuestSecretInvalid   = 20014
+	InvalidProfile       = 20015
+	InvalidAvatar        = 20016
+	PermissionDenied     = 20017
+	AccountBanned        = 20018
+	InvalidRole          = 20019
+	ChatBanned           = 20020
+	RankingBanned        = 20021
+	PasswordIncorrect    = 20022
+	OIDCProviderNotFound = 20023
+	OIDCStateInvalid     = 20024
+	OIDCLoginFailed      = 20025
+	IdentityLinked       = 20026
+	IdentityNotFound     = 20027
+	LastLoginMethod      = 20028
+
 	// 排行榜相关错误 30000-30999
-	InvalidRankType   = 30001
-	RankingNotFound   = 30002
-	InvalidScore      = 30003
-	UpdateScoreFailed = 30004
+	InvalidRankType    = 30001
+	RankingNotFound    = 30002
+	InvalidScore       = 30003
+	UpdateScoreFailed  = 30004
+	InvalidPeriod      = 30005
+	SeasonNotFound     = 30006
+	SeasonEnded        = 30007
+	BoardExists        = 30008
+	SubmitTooFrequent  = 30009
+	RunInvalid         = 30010
+	SignatureInvalid   = 30011
+	SubmissionNotFound = 30012
+	SubmissionReviewed = 30013
+	NotConnected       = 30014
 
 	// 聊天相关错误 40000-40999
 	SessionNotFound    = 40001
 	MessageTooLong     = 40002
 	SessionLimitExceed = 40003
 	AIServiceError     = 40004
+	CannotChatSelf     = 40005
+	DirectChatBlocked  = 40006
+	DirectChatReadOnly = 40007
+	InviteCodeInvalid  = 40008
+	RoomFull           = 40009
+	RoomMuted          = 40010
+	AlreadyInRoom      = 40011
+	OwnerCannotLeave   = 40012
+	KickedFromRoom     = 40013
 
 	// 存档相关错误 50000-50999
 	InvalidSlotNumber = 50001
 	SaveGameNotFound  = 50002
 	SaveGameExists    = 50003
 	SlotNotAvailable  = 50004
+
+	// 好友相关错误 60000-60999
+	FriendRequestNotFound = 60001
+	AlreadyFriends        = 60002
+	FriendRequestExists   = 60003
+	FriendBlocked         = 60004
+	FriendLimitExceeded   = 60005
+	CannotFriendSelf      = 60006
+	NotFriends            = 60007
 )
 
 // messages 错误码对应的消息映射
@@ -51,20 +102,70 @@ var messages = map[int]string{
 	TokenExpired:      "Token已过期",
 	TokenInvalid:      "Token无效",
 
-	InvalidRankType:   "排行榜类型无效",
-	RankingNotFound:   "排行榜记录不存在",
-	InvalidScore:      "分数无效",
-	UpdateScoreFailed: "更新分数失败",
+	LoginSessionNotFound: "登录设备不存在",
+	AccountLocked:        "登录失败次数过多，账号已临时锁定",
+	LoginTooFrequent:     "登录尝试过于频繁，请稍后再试",
+	WeakPassword:         "密码强度不足",
+	OldPasswordIncorrect: "原密码错误",
+	ResetTokenInvalid:    "重置令牌无效或已过期",
+	NotGuestAccount:      "当前账号不是游客账号",
+	GuestSecretInvalid:   "游客凭证无效",
+	InvalidProfile:       "资料格式错误",
+	InvalidAvatar:        "头像文件无效",
+	PermissionDenied:     "权限不足",
+	AccountBanned:        "账号已被封禁",
+	InvalidRole:          "角色无效",
+	ChatBanned:           "已被禁言",
+	RankingBanned:        "已被禁止参与排行榜",
+	PasswordIncorrect:    "密码错误",
+	OIDCProviderNotFound: "不支持该登录方式",
+	OIDCStateInvalid:     "登录请求无效或已过期，请重新登录",
+	OIDCLoginFailed:      "第三方登录失败",
+	IdentityLinked:       "该第三方账号已绑定其他用户",
+	IdentityNotFound:     "绑定的第三方账号不存在",
+	LastLoginMethod:      "不能解绑唯一的登录方式",
+
+	InvalidRankType:    "排行榜类型无效",
+	RankingNotFound:    "排行榜记录不存在",
+	InvalidScore:       "分数无效",
+	UpdateScoreFailed:  "更新分数失败",
+	InvalidPeriod:      "排行榜周期无效",
+	SeasonNotFound:     "赛季不存在",
+	SeasonEnded:        "赛季已结束",
+	BoardExists:        "排行榜已存在",
+	SubmitTooFrequent:  "提交成绩过于频繁",
+	RunInvalid:         "对局不存在、已过期或已提交过成绩",
+	SignatureInvalid:   "成绩签名无效",
+	SubmissionNotFound: "提交记录不存在",
+	SubmissionReviewed: "提交记录已审核",
+	NotConnected:       "请先建立WebSocket连接",
 
 	SessionNotFound:    "会话不存在",
 	MessageTooLong:     "消息内容过长",
 	SessionLimitExceed: "会话数量超过限制",
 	AIServiceError:     "AI服务调用失败",
+	CannotChatSelf:     "不能和自己私聊",
+	DirectChatBlocked:  "对方拒绝接收你的消息",
+	DirectChatReadOnly: "私聊会话不能修改或删除",
+	InviteCodeInvalid:  "邀请码无效",
+	RoomFull:           "聊天室人数已满",
+	RoomMuted:          "你已在该聊天室被禁言",
+	AlreadyInRoom:      "已经在聊天室中",
+	OwnerCannotLeave:   "群主不能退出聊天室，请先转让群主或解散聊天室",
+	KickedFromRoom:     "你已被移出该聊天室，不能重新加入",
 
 	InvalidSlotNumber: "存档槽位号无效",
 	SaveGameNotFound:  "存档不存在",
 	SaveGameExists:    "存档已存在",
 	SlotNotAvailable:  "存档槽位不可用",
+
+	FriendRequestNotFound: "好友请求不存在",
+	AlreadyFriends:        "已经是好友",
+	FriendRequestExists:   "已发送过好友请求",
+	FriendBlocked:         "对方拒绝接收好友请求",
+	FriendLimitExceeded:   "好友数量已达上限",
+	CannotFriendSelf:      "不能添加自己为好友",
+	NotFriends:            "对方不是你的好友",
 }
 
 // GetMessage 根据错误码获取对应的错误消息
@@ -77,8 +178,9 @@ func GetMessage(code int) string {
 
 // Error 标准错误结构
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"` // 附加数据（如封禁到期时间），随错误一起返回给客户端
 }
 
 // Error 实现error接口
@@ -101,3 +203,12 @@ func NewWithMessage(code int, message string) *Error {
 		Message: message,
 	}
 }
+
+// NewWithData 创建携带附加数据的错误
+func NewWithData(code int, data interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: GetMessage(code),
+		Data:    data,
+	}
+}
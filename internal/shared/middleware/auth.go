@@ -1,16 +1,94 @@
 package middleware
 
 import (
+	stderrors "errors"
 	"net/http"
 	"strings"
 
 	"faulty_in_culture/go_back/internal/infra/logger"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/shared/response"
 	"faulty_in_culture/go_back/internal/shared/security"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// SessionChecker 登录会话校验接口（由user模块实现）
+// 用于在Token签名有效的前提下，进一步检查对应会话是否已被注销（踢下线）
+type SessionChecker interface {
+	CheckSession(userID uint, tokenID string) error
+}
+
+// sessionChecker 全局会话校验器（启动时注入，未注入时跳过校验）
+var sessionChecker SessionChecker
+
+// SetSessionChecker 注入登录会话校验器
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+// checkSession 校验会话，未注入校验器时直接通过
+func checkSession(claims *security.Claims) error {
+	if sessionChecker == nil {
+		return nil
+	}
+	return sessionChecker.CheckSession(claims.UserID, claims.ID)
+}
+
+// BanChecker 封禁校验接口（由user模块实现）
+// 用户在 scope 范围内被封禁时返回 *errors.Error（Data 中携带封禁原因和到期时间）
+type BanChecker interface {
+	CheckBan(userID uint, scope string) error
+}
+
+// banChecker 全局封禁校验器（启动时注入，未注入时跳过校验）
+var banChecker BanChecker
+
+// SetBanChecker 注入封禁校验器
+func SetBanChecker(checker BanChecker) {
+	banChecker = checker
+}
+
+// checkBan 校验封禁，未注入校验器时直接通过
+func checkBan(userID uint, scope string) error {
+	if banChecker == nil {
+		return nil
+	}
+	return banChecker.CheckBan(userID, scope)
+}
+
+// abortBanned 返回封禁错误（携带错误码和封禁信息，客户端据此展示解封时间）
+// 无法确认封禁状态（ServerError）时返回503
+func abortBanned(c *gin.Context, err error) {
+	var e *errcode.Error
+	if stderrors.As(err, &e) && e.Code == errcode.ServerError {
+		response.ErrorWithMessage(c, http.StatusServiceUnavailable, e.Code, e.Message)
+	} else if stderrors.As(err, &e) {
+		response.ErrorWithData(c, http.StatusForbidden, e.Code, e.Data)
+	} else {
+		response.Error(c, http.StatusForbidden, errcode.AccountBanned)
+	}
+	c.Abort()
+}
+
+// RequireNotBanned 功能封禁校验中间件（需在认证中间件之后使用）
+// 用户被禁止使用 scope 对应的功能（如禁言、禁止提交成绩）时拒绝请求
+func RequireNotBanned(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := checkBan(c.GetUint("user_id"), scope); err != nil {
+			logger.Warn("middleware.RequireNotBanned: 用户被禁止使用该功能",
+				zap.Uint("user_id", c.GetUint("user_id")),
+				zap.String("scope", scope),
+				zap.String("path", c.Request.URL.Path),
+			)
+			abortBanned(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
 // AuthMiddleware 用户认证中间件（使用 JWT 框架验证）
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -55,9 +133,28 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 校验账号是否被封禁（先于会话校验，封禁时会话已被注销，需返回封禁信息）
+		if err := checkBan(claims.UserID, security.BanScopeFull); err != nil {
+			logger.Warn("middleware.AuthMiddleware: 账号已被封禁",
+				zap.Uint("user_id", claims.UserID),
+			)
+			abortBanned(c, err)
+			return
+		}
+
+		// 校验登录会话是否已被注销
+		if err := checkSession(claims); err != nil {
+			logger.Warn("middleware.AuthMiddleware: 登录会话已失效",
+				zap.Uint("user_id", claims.UserID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息设置到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
+		setAuthContext(c, claims)
 
 		logger.Info("middleware.AuthMiddleware: 认证成功（JWT）",
 			zap.Uint("user_id", claims.UserID),
@@ -119,9 +216,26 @@ func AuthMiddlewareWithQuery() gin.HandlerFunc {
 			return
 		}
 
-		// 5. 将用户信息设置到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
+		// 5. 校验账号是否被封禁，以及登录会话是否已被注销
+		if err := checkBan(claims.UserID, security.BanScopeFull); err != nil {
+			logger.Warn("middleware.AuthMiddlewareWithQuery: 账号已被封禁",
+				zap.Uint("user_id", claims.UserID),
+			)
+			abortBanned(c, err)
+			return
+		}
+		if err := checkSession(claims); err != nil {
+			logger.Warn("middleware.AuthMiddlewareWithQuery: 登录会话已失效",
+				zap.Uint("user_id", claims.UserID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
+		// 6. 将用户信息设置到上下文中
+		setAuthContext(c, claims)
 
 		logger.Info("middleware.AuthMiddlewareWithQuery: 认证成功",
 			zap.Uint("user_id", claims.UserID),
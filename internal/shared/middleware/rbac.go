@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/shared/security"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequireRole 角色校验中间件（需在认证中间件之后使用）
+// 当前用户的角色属于 roles 之一时放行
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !slices.Contains(roles, role) {
+			logger.Warn("middleware.RequireRole: 角色不满足",
+				zap.Uint("user_id", c.GetUint("user_id")),
+				zap.String("role", role),
+				zap.Strings("required", roles),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 权限校验中间件（需在认证中间件之后使用）
+// 权限取自Token中的Claims
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			logger.Warn("middleware.RequirePermission: 缺少权限",
+				zap.Uint("user_id", c.GetUint("user_id")),
+				zap.String("role", c.GetString("role")),
+				zap.String("permission", permission),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission 当前用户是否拥有指定权限
+func HasPermission(c *gin.Context, permission string) bool {
+	return slices.Contains(c.GetStringSlice("permissions"), permission)
+}
+
+// setAuthContext 将Token中的用户信息设置到上下文中
+func setAuthContext(c *gin.Context, claims *security.Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("token_id", claims.ID)
+	c.Set("role", claims.EffectiveRole())
+	c.Set("permissions", claims.Permissions)
+}
+
+// AdminAudit 管理接口审计日志（记录操作人、请求路径和结果）
+// 包括只读的查询接口，便于追溯客服查看了哪些玩家数据
+func AdminAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		logger.Warn("[audit] 管理接口调用",
+			zap.String("event", "admin_request"),
+			zap.Uint("operator_id", c.GetUint("user_id")),
+			zap.String("role", c.GetString("role")),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", c.Writer.Status()),
+			zap.String("ip", c.ClientIP()),
+		)
+	}
+}
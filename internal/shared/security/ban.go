@@ -0,0 +1,33 @@
+package security
+
+import (
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+)
+
+// 封禁范围
+const (
+	BanScopeFull    = "full"    // 全部功能（无法登录）
+	BanScopeChat    = "chat"    // 禁言：无法发起聊天和发送消息（仍可建立实时连接接收推送）
+	BanScopeRanking = "ranking" // 禁止提交排行榜成绩
+)
+
+// banScopeCodes 封禁范围对应的错误码
+var banScopeCodes = map[string]int{
+	BanScopeFull:    errcode.AccountBanned,
+	BanScopeChat:    errcode.ChatBanned,
+	BanScopeRanking: errcode.RankingBanned,
+}
+
+// ValidBanScope 是否为已定义的封禁范围
+func ValidBanScope(scope string) bool {
+	_, ok := banScopeCodes[scope]
+	return ok
+}
+
+// BanErrorCode 返回封禁范围对应的错误码
+func BanErrorCode(scope string) int {
+	if code, ok := banScopeCodes[scope]; ok {
+		return code
+	}
+	return errcode.AccountBanned
+}
@@ -1,71 +1,94 @@
 package security
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"faulty_in_culture/go_back/internal/infra/config"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // ============================================================
-// 安全工具包 - 密码哈希、JWT Token 生成（使用专业框架）
+// 安全工具包 - JWT Token 生成（使用专业框架，密码哈希见 password.go）
 // 职责：提供认证和加密相关的安全功能
 // ============================================================
 
 // Claims JWT 自定义声明
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID      uint     `json:"user_id"`
+	Username    string   `json:"username"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// HashPassword 密码加密（使用 bcrypt 框架）
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+// NewTokenID 生成随机的 Token ID（写入 jti，用于关联登录会话）
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
-// CheckPassword 密码验证（使用 bcrypt 框架）
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	if err != nil {
-		// 密码不匹配
-		return false
-	}
-	return true
+// TokenTTL Token 有效期
+func TokenTTL() time.Duration {
+	return time.Hour * time.Duration(config.GlobalConfig.JWT.ExpireHours)
 }
 
 // GenerateToken 生成 JWT Token（使用 golang-jwt 框架）
-func GenerateToken(userID uint, username string) (string, error) {
-	cfg := config.GlobalConfig.JWT
+// role 及其权限写入 Claims；tokenID 写入 jti 声明，为空时不设置
+// 使用非对称密钥签名时在头部写入当前签名密钥的 kid
+func GenerateToken(userID uint, username, role, tokenID string) (string, error) {
+	keys, err := currentKeyRing()
+	if err != nil {
+		return "", err
+	}
 
 	// 创建 Claims
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:      userID,
+		Username:    username,
+		Role:        role,
+		Permissions: PermissionsOf(role),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(cfg.ExpireHours))),
+			ID:        tokenID,
+			Issuer:    keys.issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	// 生成 Token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.Secret))
+	token := jwt.NewWithClaims(keys.method, claims)
+	if keys.signingKID != "" {
+		token.Header["kid"] = keys.signingKID
+	}
+	return token.SignedString(keys.signingKey)
 }
 
 // ParseToken 解析 JWT Token（使用 golang-jwt 框架）
+// 只接受当前配置的签名算法（防止算法混淆攻击），按 kid 选择验证密钥
 func ParseToken(tokenString string) (*Claims, error) {
-	cfg := config.GlobalConfig.JWT
+	keys, err := currentKeyRing()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{keys.method.Alg()})}
+	if keys.issuer != "" {
+		options = append(options, jwt.WithIssuer(keys.issuer))
+	}
 
 	// 解析 Token
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.Secret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		return keys.verifyKey(kid)
+	}, options...)
 
 	if err != nil {
 		return nil, err
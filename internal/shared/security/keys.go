@@ -0,0 +1,272 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"faulty_in_culture/go_back/internal/infra/config"
+	"faulty_in_culture/go_back/internal/infra/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ============================================================
+// JWT 密钥管理
+// 支持 HS256（共享密钥）和 RS256/EdDSA（非对称密钥，带kid）
+// 密钥轮换：新增密钥并设为 signing_key_id，旧密钥保留在 keys 中继续用于验证，
+// 待旧密钥签发的Token全部过期后（expire_hours）再从配置中移除
+// ============================================================
+
+// defaultJWTSecrets 仓库中出现过的示例密钥，生产环境禁止使用
+var defaultJWTSecrets = []string{
+	"faulty_in_culture_secret_key_2026",
+	"your-production-secret-key-change-this",
+	"your-production-secret-key-change-this-to-random-string",
+}
+
+// minJWTSecretLength 生产环境 HS256 密钥最小长度
+const minJWTSecretLength = 32
+
+// minRSAKeyBits RSA密钥最小位数
+const minRSAKeyBits = 2048
+
+// keyRing 当前的签名密钥和全部验证密钥
+type keyRing struct {
+	method     jwt.SigningMethod
+	issuer     string
+	signingKID string                 // 为空表示不写入kid（HS256）
+	signingKey interface{}            // []byte / *rsa.PrivateKey / ed25519.PrivateKey
+	verifyKeys map[string]interface{} // kid -> []byte / *rsa.PublicKey / ed25519.PublicKey
+	jwks       oidc.JSONWebKeySet     // 对外发布的验证公钥
+}
+
+var (
+	ringMu sync.RWMutex
+	ring   *keyRing
+)
+
+// InitJWT 根据配置加载JWT密钥（启动时调用）
+// 生产环境使用默认或过短的 HS256 密钥时返回错误，拒绝启动
+func InitJWT() error {
+	r, err := loadKeyRing(config.GlobalConfig.JWT, config.GlobalConfig.App.Environment)
+	if err != nil {
+		return err
+	}
+	ringMu.Lock()
+	ring = r
+	ringMu.Unlock()
+	return nil
+}
+
+// SigningAlgorithm 当前的签名算法
+func SigningAlgorithm() string {
+	r, err := currentKeyRing()
+	if err != nil {
+		return ""
+	}
+	return r.method.Alg()
+}
+
+// JWKS 返回用于验证本服务Token的公钥集合（HS256时为空，共享密钥不能公开）
+func JWKS() oidc.JSONWebKeySet {
+	r, err := currentKeyRing()
+	if err != nil {
+		return oidc.JSONWebKeySet{Keys: []oidc.JSONWebKey{}}
+	}
+	return r.jwks
+}
+
+// currentKeyRing 获取已加载的密钥（未调用 InitJWT 时按当前配置加载）
+func currentKeyRing() (*keyRing, error) {
+	ringMu.RLock()
+	r := ring
+	ringMu.RUnlock()
+	if r != nil {
+		return r, nil
+	}
+	if err := InitJWT(); err != nil {
+		return nil, err
+	}
+	ringMu.RLock()
+	defer ringMu.RUnlock()
+	return ring, nil
+}
+
+// verifyKey 按kid查找验证密钥（Token未带kid且只有一个密钥时使用该密钥）
+func (r *keyRing) verifyKey(kid string) (interface{}, error) {
+	if key, ok := r.verifyKeys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(r.verifyKeys) == 1 {
+		for _, key := range r.verifyKeys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("未知的签名密钥: %q", kid)
+}
+
+// loadKeyRing 根据配置构建密钥
+func loadKeyRing(cfg config.JWTConfig, environment string) (*keyRing, error) {
+	r := &keyRing{
+		issuer:     cfg.Issuer,
+		verifyKeys: make(map[string]interface{}),
+		jwks:       oidc.JSONWebKeySet{Keys: []oidc.JSONWebKey{}},
+	}
+
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "", "HS256":
+		if err := checkSecret(cfg.Secret, environment); err != nil {
+			return nil, err
+		}
+		r.method = jwt.SigningMethodHS256
+		r.signingKey = []byte(cfg.Secret)
+		r.verifyKeys[""] = []byte(cfg.Secret)
+		return r, nil
+	case "RS256":
+		r.method = jwt.SigningMethodRS256
+	case "EDDSA":
+		r.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("不支持的JWT签名算法: %s", cfg.Algorithm)
+	}
+
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("使用%s签名时必须配置 jwt.keys", r.method.Alg())
+	}
+	signingKID := cfg.SigningKeyID
+	if signingKID == "" {
+		signingKID = cfg.Keys[0].ID
+	}
+
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("jwt.keys 中的密钥必须设置id")
+		}
+		if _, dup := r.verifyKeys[kc.ID]; dup {
+			return nil, fmt.Errorf("jwt.keys 中的密钥id重复: %s", kc.ID)
+		}
+
+		private, public, err := loadKeyPair(r.method, kc)
+		if err != nil {
+			return nil, fmt.Errorf("加载JWT密钥%s失败: %w", kc.ID, err)
+		}
+		if kc.ID == signingKID {
+			if private == nil {
+				return nil, fmt.Errorf("签名密钥%s未配置私钥", kc.ID)
+			}
+			r.signingKID = kc.ID
+			r.signingKey = private
+		}
+
+		r.verifyKeys[kc.ID] = public
+		jwk, err := oidc.NewJSONWebKey(kc.ID, r.method.Alg(), public)
+		if err != nil {
+			return nil, err
+		}
+		r.jwks.Keys = append(r.jwks.Keys, jwk)
+	}
+
+	if r.signingKey == nil {
+		return nil, fmt.Errorf("jwt.signing_key_id 对应的密钥不存在: %s", signingKID)
+	}
+	return r, nil
+}
+
+// checkSecret 校验 HS256 密钥（生产环境禁止使用默认密钥）
+func checkSecret(secret, environment string) error {
+	if secret == "" {
+		return errors.New("未配置JWT密钥（jwt.secret 或环境变量 JWT_SECRET）")
+	}
+	if environment != "production" {
+		return nil
+	}
+	for _, s := range defaultJWTSecrets {
+		if secret == s {
+			return errors.New("生产环境禁止使用默认JWT密钥，请通过环境变量 JWT_SECRET 设置随机密钥")
+		}
+	}
+	if len(secret) < minJWTSecretLength {
+		return fmt.Errorf("生产环境JWT密钥长度不能少于%d字节", minJWTSecretLength)
+	}
+	return nil
+}
+
+// loadKeyPair 读取PEM格式的私钥和/或公钥（只配置私钥时从私钥推导公钥）
+func loadKeyPair(method jwt.SigningMethod, kc config.JWTKeyConfig) (private, public interface{}, err error) {
+	if kc.PrivateKeyFile == "" && kc.PublicKeyFile == "" {
+		return nil, nil, errors.New("未配置私钥或公钥文件")
+	}
+
+	var privatePEM, publicPEM []byte
+	if kc.PrivateKeyFile != "" {
+		if privatePEM, err = os.ReadFile(kc.PrivateKeyFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if kc.PublicKeyFile != "" {
+		if publicPEM, err = os.ReadFile(kc.PublicKeyFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch method {
+	case jwt.SigningMethodRS256:
+		var rsaPublic *rsa.PublicKey
+		if privatePEM != nil {
+			rsaPrivate, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, nil, err
+			}
+			private, rsaPublic = rsaPrivate, &rsaPrivate.PublicKey
+		}
+		if publicPEM != nil {
+			parsed, err := jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+			if err != nil {
+				return nil, nil, err
+			}
+			if rsaPublic != nil && !rsaPublic.Equal(parsed) {
+				return nil, nil, errors.New("公钥与私钥不匹配")
+			}
+			rsaPublic = parsed
+		}
+		if rsaPublic.N.BitLen() < minRSAKeyBits {
+			return nil, nil, fmt.Errorf("RSA密钥长度不能少于%d位", minRSAKeyBits)
+		}
+		return private, rsaPublic, nil
+
+	case jwt.SigningMethodEdDSA:
+		var edPublic ed25519.PublicKey
+		if privatePEM != nil {
+			key, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, nil, err
+			}
+			edPrivate, ok := key.(ed25519.PrivateKey)
+			if !ok {
+				return nil, nil, errors.New("不是Ed25519私钥")
+			}
+			private, edPublic = edPrivate, edPrivate.Public().(ed25519.PublicKey)
+		}
+		if publicPEM != nil {
+			key, err := jwt.ParseEdPublicKeyFromPEM(publicPEM)
+			if err != nil {
+				return nil, nil, err
+			}
+			parsed, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return nil, nil, errors.New("不是Ed25519公钥")
+			}
+			if edPublic != nil && !edPublic.Equal(parsed) {
+				return nil, nil, errors.New("公钥与私钥不匹配")
+			}
+			edPublic = parsed
+		}
+		return private, edPublic, nil
+	}
+	return nil, nil, fmt.Errorf("不支持的签名算法: %s", method.Alg())
+}
@@ -0,0 +1,224 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"faulty_in_culture/go_back/internal/infra/config"
+)
+
+// keyFiles 测试用的PEM密钥文件
+type keyFiles struct {
+	private string
+	public  string
+}
+
+// writeKeyFiles 将密钥对写入临时目录的PEM文件
+func writeKeyFiles(t *testing.T, name string, private interface{}, public interface{}) keyFiles {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("编码私钥失败: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("编码公钥失败: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := keyFiles{
+		private: filepath.Join(dir, name+".key"),
+		public:  filepath.Join(dir, name+".pub"),
+	}
+	writePEM(t, files.private, "PRIVATE KEY", privateDER)
+	writePEM(t, files.public, "PUBLIC KEY", publicDER)
+	return files
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("写入密钥文件失败: %v", err)
+	}
+}
+
+func newEdKeyFiles(t *testing.T, name string) keyFiles {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成Ed25519密钥失败: %v", err)
+	}
+	return writeKeyFiles(t, name, private, public)
+}
+
+func newRSAKeyFiles(t *testing.T, name string, bits int) keyFiles {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("生成RSA密钥失败: %v", err)
+	}
+	return writeKeyFiles(t, name, private, &private.PublicKey)
+}
+
+func TestLoadKeyRingHS256(t *testing.T) {
+	strong := strings.Repeat("k", minJWTSecretLength)
+
+	tests := []struct {
+		name        string
+		cfg         config.JWTConfig
+		environment string
+		wantErr     bool
+	}{
+		{"未配置密钥", config.JWTConfig{}, "development", true},
+		{"开发环境允许默认密钥", config.JWTConfig{Secret: defaultJWTSecrets[0]}, "development", false},
+		{"开发环境允许短密钥", config.JWTConfig{Secret: "dev"}, "development", false},
+		{"生产环境禁止默认密钥", config.JWTConfig{Secret: defaultJWTSecrets[0]}, "production", true},
+		{"生产环境禁止短密钥", config.JWTConfig{Secret: strong[1:]}, "production", true},
+		{"生产环境足够长的密钥", config.JWTConfig{Secret: strong}, "production", false},
+		{"算法名不区分大小写", config.JWTConfig{Algorithm: "hs256", Secret: "dev"}, "development", false},
+		{"不支持的算法", config.JWTConfig{Algorithm: "ES256", Secret: strong}, "development", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := loadKeyRing(tt.cfg, tt.environment)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadKeyRing() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if r.method.Alg() != "HS256" || r.signingKID != "" || len(r.jwks.Keys) != 0 {
+				t.Errorf("loadKeyRing() alg = %s, kid = %q, jwks = %d", r.method.Alg(), r.signingKID, len(r.jwks.Keys))
+			}
+			if _, err := r.verifyKey(""); err != nil {
+				t.Errorf("verifyKey(\"\") err = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadKeyRingAsymmetric(t *testing.T) {
+	edOld := newEdKeyFiles(t, "old")
+	edNew := newEdKeyFiles(t, "new")
+	rsaKey := newRSAKeyFiles(t, "rsa", minRSAKeyBits)
+	rsaWeak := newRSAKeyFiles(t, "weak", 1024)
+
+	tests := []struct {
+		name       string
+		cfg        config.JWTConfig
+		wantErr    bool
+		wantKID    string
+		wantVerify int
+	}{
+		{
+			name:    "未配置密钥",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA"},
+			wantErr: true,
+		},
+		{
+			name:       "默认使用第一个密钥签名",
+			cfg:        config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{{ID: "new", PrivateKeyFile: edNew.private}}},
+			wantKID:    "new",
+			wantVerify: 1,
+		},
+		{
+			name: "轮换时旧密钥只保留公钥",
+			cfg: config.JWTConfig{Algorithm: "EdDSA", SigningKeyID: "new", Keys: []config.JWTKeyConfig{
+				{ID: "old", PublicKeyFile: edOld.public},
+				{ID: "new", PrivateKeyFile: edNew.private, PublicKeyFile: edNew.public},
+			}},
+			wantKID:    "new",
+			wantVerify: 2,
+		},
+		{
+			name: "签名密钥只有公钥",
+			cfg: config.JWTConfig{Algorithm: "EdDSA", SigningKeyID: "old", Keys: []config.JWTKeyConfig{
+				{ID: "old", PublicKeyFile: edOld.public},
+				{ID: "new", PrivateKeyFile: edNew.private},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "签名密钥不存在",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA", SigningKeyID: "missing", Keys: []config.JWTKeyConfig{{ID: "new", PrivateKeyFile: edNew.private}}},
+			wantErr: true,
+		},
+		{
+			name:    "密钥缺少id",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{{PrivateKeyFile: edNew.private}}},
+			wantErr: true,
+		},
+		{
+			name: "密钥id重复",
+			cfg: config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{
+				{ID: "k1", PrivateKeyFile: edNew.private},
+				{ID: "k1", PublicKeyFile: edOld.public},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "未配置密钥文件",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{{ID: "k1"}}},
+			wantErr: true,
+		},
+		{
+			name:    "密钥文件不存在",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{{ID: "k1", PrivateKeyFile: edNew.private + ".missing"}}},
+			wantErr: true,
+		},
+		{
+			name:    "公钥与私钥不匹配",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{{ID: "k1", PrivateKeyFile: edNew.private, PublicKeyFile: edOld.public}}},
+			wantErr: true,
+		},
+		{
+			name:    "算法与密钥类型不符",
+			cfg:     config.JWTConfig{Algorithm: "EdDSA", Keys: []config.JWTKeyConfig{{ID: "k1", PrivateKeyFile: rsaKey.private}}},
+			wantErr: true,
+		},
+		{
+			name:       "RS256",
+			cfg:        config.JWTConfig{Algorithm: "RS256", Keys: []config.JWTKeyConfig{{ID: "rsa", PrivateKeyFile: rsaKey.private, PublicKeyFile: rsaKey.public}}},
+			wantKID:    "rsa",
+			wantVerify: 1,
+		},
+		{
+			name:    "RSA密钥过短",
+			cfg:     config.JWTConfig{Algorithm: "RS256", Keys: []config.JWTKeyConfig{{ID: "weak", PrivateKeyFile: rsaWeak.private}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := loadKeyRing(tt.cfg, "production")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadKeyRing() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if r.signingKID != tt.wantKID || r.signingKey == nil {
+				t.Errorf("loadKeyRing() signingKID = %q, want %q", r.signingKID, tt.wantKID)
+			}
+			if len(r.verifyKeys) != tt.wantVerify || len(r.jwks.Keys) != tt.wantVerify {
+				t.Errorf("loadKeyRing() verifyKeys = %d, jwks = %d, want %d", len(r.verifyKeys), len(r.jwks.Keys), tt.wantVerify)
+			}
+			for _, kc := range tt.cfg.Keys {
+				if _, err := r.verifyKey(kc.ID); err != nil {
+					t.Errorf("verifyKey(%q) err = %v", kc.ID, err)
+				}
+			}
+			if _, err := r.verifyKey("unknown"); err == nil {
+				t.Error("verifyKey(\"unknown\") 应返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"faulty_in_culture/go_back/internal/infra/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ============================================================
+// 密码哈希 - 可配置算法（bcrypt / argon2id）
+// 编码格式自带算法前缀，验证时按前缀识别算法：
+//   bcrypt:   $2a$<cost>$<salt+hash>
+//   argon2id: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+// ============================================================
+
+// 支持的哈希算法
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// argon2idPrefix argon2id 编码前缀
+const argon2idPrefix = "$argon2id$"
+
+// argon2id 编码中可接受的参数范围（超出范围的哈希视为无效，避免按异常参数计算时panic或分配过多内存）
+const (
+	argon2MinSaltLength = 16
+	argon2MinKeyLength  = 16
+	argon2MaxMemoryKB   = 1024 * 1024 // 1 GiB
+)
+
+// HashParams 密码哈希参数
+type HashParams struct {
+	Algorithm   string
+	BcryptCost  int
+	Memory      uint32 // argon2id 内存（KiB）
+	Iterations  uint32 // argon2id 迭代次数
+	Parallelism uint8  // argon2id 并行度
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// CurrentHashParams 从配置读取当前的哈希参数（未配置的项使用默认值）
+func CurrentHashParams() HashParams {
+	cfg := config.GlobalConfig.Hashing
+
+	p := HashParams{
+		Algorithm:   cfg.Algorithm,
+		BcryptCost:  cfg.BcryptCost,
+		Memory:      cfg.Argon2MemoryKB,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	if p.Algorithm == "" {
+		p.Algorithm = AlgorithmBcrypt
+	}
+	if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+		p.BcryptCost = bcrypt.DefaultCost
+	}
+	if p.Memory == 0 {
+		p.Memory = 64 * 1024
+	}
+	if p.Memory > argon2MaxMemoryKB {
+		p.Memory = argon2MaxMemoryKB
+	}
+	if p.Iterations == 0 {
+		p.Iterations = 3
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = 2
+	}
+	return p
+}
+
+// HashPassword 使用当前配置的算法加密密码
+func HashPassword(password string) (string, error) {
+	p := CurrentHashParams()
+
+	switch p.Algorithm {
+	case AlgorithmArgon2id:
+		salt := make([]byte, p.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2idPrefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	case AlgorithmBcrypt:
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(bytes), err
+	default:
+		return "", fmt.Errorf("不支持的密码哈希算法: %s", p.Algorithm)
+	}
+}
+
+// CheckPassword 密码验证（按编码前缀识别算法）
+func CheckPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		// 密码不匹配
+		return false
+	}
+	return true
+}
+
+// NeedsRehash 判断已存储的哈希是否需要按当前配置重新计算
+// 规则：算法不同，或参数弱于当前配置时需要升级
+func NeedsRehash(hash string) bool {
+	p := CurrentHashParams()
+
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		if p.Algorithm != AlgorithmArgon2id {
+			return true
+		}
+		params, _, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return params.Memory < p.Memory ||
+			params.Iterations < p.Iterations ||
+			params.Parallelism < p.Parallelism ||
+			uint32(len(key)) < p.KeyLength
+	}
+
+	if p.Algorithm != AlgorithmBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost < p.BcryptCost
+}
+
+// decodeArgon2id 解析 argon2id 编码字符串
+func decodeArgon2id(encoded string) (HashParams, []byte, []byte, error) {
+	var p HashParams
+	parts := strings.Split(encoded, "$")
+	// ["", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash]
+	if len(parts) != 6 {
+		return p, nil, nil, fmt.Errorf("argon2id 哈希格式错误")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("argon2id 版本不支持")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("argon2id 参数格式错误: %w", err)
+	}
+	if p.Iterations < 1 || p.Parallelism < 1 || p.Memory > argon2MaxMemoryKB {
+		return p, nil, nil, fmt.Errorf("argon2id 参数超出范围")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("argon2id salt 解码失败: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("argon2id hash 解码失败: %w", err)
+	}
+	if len(salt) < argon2MinSaltLength || len(key) < argon2MinKeyLength {
+		return p, nil, nil, fmt.Errorf("argon2id salt 或 hash 过短")
+	}
+
+	p.Algorithm = AlgorithmArgon2id
+	return p, salt, key, nil
+}
@@ -0,0 +1,139 @@
+package security
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"faulty_in_culture/go_back/internal/infra/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useHashing 测试期间替换哈希配置
+func useHashing(t *testing.T, cfg config.HashingConfig) {
+	t.Helper()
+	old := config.GlobalConfig.Hashing
+	config.GlobalConfig.Hashing = cfg
+	t.Cleanup(func() { config.GlobalConfig.Hashing = old })
+}
+
+// argon2idHash 按给定参数拼出 argon2id 编码（salt/hash 只用于长度）
+func argon2idHash(memory, iterations uint32, parallelism uint8, keyLen int) string {
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, keyLen))
+	return fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s", memory, iterations, parallelism, salt, key)
+}
+
+// bcryptHash 以指定代价生成 bcrypt 哈希
+func bcryptHash(t *testing.T, cost int) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), cost)
+	if err != nil {
+		t.Fatalf("生成bcrypt哈希失败: %v", err)
+	}
+	return string(hash)
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	valid := argon2idHash(65536, 3, 2, 32)
+	salt := strings.Split(valid, "$")[4]
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"有效", valid, false},
+		{"段数不足", "$argon2id$v=19$m=65536,t=3,p=2$" + salt, true},
+		{"段数过多", valid + "$extra", true},
+		{"版本不支持", strings.Replace(valid, "v=19", "v=16", 1), true},
+		{"版本缺失", strings.Replace(valid, "v=19", "19", 1), true},
+		{"参数格式错误", strings.Replace(valid, "m=65536,t=3,p=2", "m=x,t=3,p=2", 1), true},
+		{"参数缺失", strings.Replace(valid, "m=65536,t=3,p=2", "m=65536", 1), true},
+		{"salt解码失败", strings.Replace(valid, salt, "!!!", 1), true},
+		{"hash解码失败", valid[:strings.LastIndex(valid, "$")+1] + "***", true},
+		{"空字符串", "", true},
+		{"hash为空", argon2idHash(65536, 3, 2, 0), true},
+		{"hash过短", argon2idHash(65536, 3, 2, 15), true},
+		{"salt过短", strings.Replace(valid, salt, base64.RawStdEncoding.EncodeToString(make([]byte, 8)), 1), true},
+		{"迭代次数为0", argon2idHash(65536, 0, 2, 32), true},
+		{"并行度为0", argon2idHash(65536, 3, 0, 32), true},
+		{"内存过大", argon2idHash(argon2MaxMemoryKB+1, 3, 2, 32), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, salt, key, err := decodeArgon2id(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeArgon2id() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.Algorithm != AlgorithmArgon2id || p.Memory != 65536 || p.Iterations != 3 || p.Parallelism != 2 {
+				t.Errorf("decodeArgon2id() params = %+v", p)
+			}
+			if len(salt) != 16 || len(key) != 32 {
+				t.Errorf("decodeArgon2id() len(salt) = %d, len(key) = %d", len(salt), len(key))
+			}
+		})
+	}
+}
+
+func TestNeedsRehashArgon2id(t *testing.T) {
+	useHashing(t, config.HashingConfig{
+		Algorithm:         AlgorithmArgon2id,
+		Argon2MemoryKB:    65536,
+		Argon2Iterations:  3,
+		Argon2Parallelism: 2,
+	})
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"参数相同", argon2idHash(65536, 3, 2, 32), false},
+		{"参数更强", argon2idHash(131072, 4, 4, 64), false},
+		{"内存更小", argon2idHash(32768, 3, 2, 32), true},
+		{"迭代更少", argon2idHash(65536, 2, 2, 32), true},
+		{"并行度更低", argon2idHash(65536, 3, 1, 32), true},
+		{"hash更短", argon2idHash(65536, 3, 2, 16), true},
+		{"格式错误", "$argon2id$v=19$m=65536,t=3,p=2", true},
+		{"版本不支持", strings.Replace(argon2idHash(65536, 3, 2, 32), "v=19", "v=16", 1), true},
+		{"bcrypt哈希", bcryptHash(t, bcrypt.MinCost), true},
+		{"空字符串", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehashBcrypt(t *testing.T) {
+	useHashing(t, config.HashingConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 6})
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"代价相同", bcryptHash(t, 6), false},
+		{"代价更高", bcryptHash(t, 7), false},
+		{"代价更低", bcryptHash(t, bcrypt.MinCost), true},
+		{"argon2id哈希", argon2idHash(65536, 3, 2, 32), true},
+		{"格式错误", "$2a$xx$invalid", true},
+		{"空字符串", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package security
+
+// ============================================================
+// 角色与权限（RBAC）
+// 用户只保存角色，权限由角色决定；签发Token时一并写入Claims
+// ============================================================
+
+// 角色
+const (
+	RolePlayer    = "player"    // 普通玩家
+	RoleModerator = "moderator" // 运营/客服：查询用户、封禁、查看玩家数据
+	RoleAdmin     = "admin"     // 管理员：拥有全部权限
+)
+
+// 权限
+const (
+	PermUserView          = "user:view"           // 查询用户信息
+	PermUserBan           = "user:ban"            // 封禁/解封用户
+	PermUserResetPassword = "user:reset_password" // 强制重置密码
+	PermUserManageRole    = "user:manage_role"    // 修改用户角色
+	PermSupportView       = "support:view"        // 查看玩家存档和聊天记录
+	PermRankingManage     = "ranking:manage"      // 管理排行榜（赛季等）
+)
+
+// rolePermissions 角色拥有的权限
+var rolePermissions = map[string][]string{
+	RolePlayer: {},
+	RoleModerator: {
+		PermUserView,
+		PermUserBan,
+		PermSupportView,
+	},
+	RoleAdmin: {
+		PermUserView,
+		PermUserBan,
+		PermUserResetPassword,
+		PermUserManageRole,
+		PermSupportView,
+		PermRankingManage,
+	},
+}
+
+// ValidRole 是否为已定义的角色
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// PermissionsOf 返回角色拥有的权限（未知角色没有任何权限）
+func PermissionsOf(role string) []string {
+	perms := rolePermissions[role]
+	result := make([]string, len(perms))
+	copy(result, perms)
+	return result
+}
+
+// EffectiveRole 返回Token中的角色（不含角色的旧Token视为普通玩家）
+func (c *Claims) EffectiveRole() string {
+	if c.Role == "" {
+		return RolePlayer
+	}
+	return c.Role
+}
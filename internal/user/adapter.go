@@ -1,9 +1,22 @@
 // Package user - 用户模块适配器
-// 功能：提供密码加密器、Token生成器等基础设施适配器
+// 功能：提供密码加密器、Token生成器、登录限流器、第三方身份提供方等基础设施适配器
 package user
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/cache"
+	"faulty_in_culture/go_back/internal/infra/config"
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/infra/oidc"
 	"faulty_in_culture/go_back/internal/shared/security"
+
+	"go.uber.org/zap"
 )
 
 // ============================================================
@@ -11,23 +24,44 @@ import (
 // ============================================================
 
 // PasswordHasherAdapter 密码哈希器适配器
-type PasswordHasherAdapter struct{}
+// 哈希计算是CPU密集型操作，通过有界工作池（信号量）限制同时计算的数量，
+// 注册/登录高峰时多余的请求排队等待，避免耗尽CPU
+type PasswordHasherAdapter struct {
+	workers chan struct{}
+}
 
-// NewPasswordHasher 创建密码哈希器实例
+// NewPasswordHasher 创建密码哈希器实例（算法和工作池大小来自 hashing 配置）
 func NewPasswordHasher() PasswordHasher {
-	return &PasswordHasherAdapter{}
+	size := config.GlobalConfig.Hashing.Workers
+	if size <= 0 {
+		size = runtime.NumCPU()
+	}
+	return &PasswordHasherAdapter{workers: make(chan struct{}, size)}
+}
+
+// acquire 占用一个工作槽位，返回释放函数
+func (p *PasswordHasherAdapter) acquire() func() {
+	p.workers <- struct{}{}
+	return func() { <-p.workers }
 }
 
 // Hash 加密密码
 func (p *PasswordHasherAdapter) Hash(password string) (string, error) {
+	defer p.acquire()()
 	return security.HashPassword(password)
 }
 
 // Check 验证密码
 func (p *PasswordHasherAdapter) Check(password, hash string) bool {
+	defer p.acquire()()
 	return security.CheckPassword(password, hash)
 }
 
+// NeedsRehash 判断哈希是否使用了较弱的算法或参数
+func (p *PasswordHasherAdapter) NeedsRehash(hash string) bool {
+	return security.NeedsRehash(hash)
+}
+
 // TokenGeneratorAdapter Token生成器适配器
 type TokenGeneratorAdapter struct{}
 
@@ -36,7 +70,335 @@ func NewTokenGenerator() TokenGenerator {
 	return &TokenGeneratorAdapter{}
 }
 
-// Generate 生成JWT Token
-func (t *TokenGeneratorAdapter) Generate(userID uint, username string) (string, error) {
-	return security.GenerateToken(userID, username)
+// Generate 生成JWT Token（角色写入Claims，tokenID写入jti，对应登录会话）
+func (t *TokenGeneratorAdapter) Generate(userID uint, username, role, tokenID string) (string, error) {
+	return security.GenerateToken(userID, username, role, tokenID)
+}
+
+// NewTokenID 生成新的Token ID
+func (t *TokenGeneratorAdapter) NewTokenID() (string, error) {
+	return security.NewTokenID()
+}
+
+// TTL Token有效期
+func (t *TokenGeneratorAdapter) TTL() time.Duration {
+	return security.TokenTTL()
+}
+
+// ============================================================
+// 登录限流适配器 - 基于Redis滑动窗口实现LoginLimiter接口
+// ============================================================
+
+// LoginLimiterAdapter 登录限流器适配器
+type LoginLimiterAdapter struct {
+	cache *cache.Cache
+	cfg   config.LoginConfig
+}
+
+// NewLoginLimiter 创建登录限流器实例（未配置Redis时返回nil，即不限流）
+func NewLoginLimiter(c *cache.Cache) LoginLimiter {
+	if c == nil {
+		return nil
+	}
+
+	cfg := config.GlobalConfig.Login
+	if cfg.WindowMinutes <= 0 {
+		cfg.WindowMinutes = 15
+	}
+	if cfg.MaxFailuresPerUser <= 0 {
+		cfg.MaxFailuresPerUser = 5
+	}
+	if cfg.MaxFailuresPerIP <= 0 {
+		cfg.MaxFailuresPerIP = 20
+	}
+	if cfg.DelayAfterFailures <= 0 {
+		cfg.DelayAfterFailures = 3
+	}
+	if cfg.BaseDelaySeconds <= 0 {
+		cfg.BaseDelaySeconds = 1
+	}
+	if cfg.MaxDelaySeconds <= 0 {
+		cfg.MaxDelaySeconds = 30
+	}
+	if cfg.LockoutMinutes <= 0 {
+		cfg.LockoutMinutes = 15
+	}
+
+	return &LoginLimiterAdapter{cache: c, cfg: cfg}
+}
+
+// 缓存Key
+func loginFailKey(scope, id string) string  { return fmt.Sprintf("login:fail:%s:%s", scope, id) }
+func loginLockKey(scope, id string) string  { return fmt.Sprintf("login:lock:%s:%s", scope, id) }
+func loginDelayKey(scope, id string) string { return fmt.Sprintf("login:delay:%s:%s", scope, id) }
+
+// window 失败次数统计窗口
+func (l *LoginLimiterAdapter) window() time.Duration {
+	return time.Duration(l.cfg.WindowMinutes) * time.Minute
+}
+
+// Check 检查用户名/IP当前是否处于退避或锁定期
+func (l *LoginLimiterAdapter) Check(username, ip string) (LoginStatus, error) {
+	var status LoginStatus
+	name := strings.ToLower(username)
+
+	// 1. 锁定检查（用户名、IP）
+	lockKeys := []string{loginLockKey("user", name)}
+	if ip != "" {
+		lockKeys = append(lockKeys, loginLockKey("ip", ip))
+	}
+	for _, key := range lockKeys {
+		ttl, err := l.cache.TTL(key)
+		if err != nil {
+			return status, err
+		}
+		if ttl > 0 {
+			status.Locked = true
+			if ttl > status.RetryAfter {
+				status.RetryAfter = ttl
+			}
+		}
+	}
+
+	// 2. 退避检查
+	if !status.Locked {
+		ttl, err := l.cache.TTL(loginDelayKey("user", name))
+		if err != nil {
+			return status, err
+		}
+		if ttl > 0 {
+			status.RetryAfter = ttl
+		}
+	}
+
+	// 3. 验证码标记
+	failures, err := l.cache.CountWindow(loginFailKey("user", name), l.window())
+	if err != nil {
+		return status, err
+	}
+	status.Failures = failures
+	status.CaptchaRequired = l.captchaRequired(failures)
+	return status, nil
+}
+
+// Reserve 原子地检查锁定/退避并预占一次登录尝试
+// 预占与锁定检查在同一个Redis脚本中完成，预占的尝试先计入失败次数，
+// 因此并发请求不会都在失败被记录前通过检查；窗口内次数已达上限时直接拒绝
+func (l *LoginLimiterAdapter) Reserve(username, ip string) (*LoginAttempt, LoginStatus, error) {
+	var status LoginStatus
+	name := strings.ToLower(username)
+	id, err := security.NewTokenID()
+	if err != nil {
+		return nil, status, err
+	}
+
+	blockKeys := []string{loginLockKey("user", name)}
+	windows := []cache.WindowLimit{{Key: loginFailKey("user", name), Max: int64(l.cfg.MaxFailuresPerUser)}}
+	if ip != "" {
+		blockKeys = append(blockKeys, loginLockKey("ip", ip))
+		windows = append(windows, cache.WindowLimit{Key: loginFailKey("ip", ip), Max: int64(l.cfg.MaxFailuresPerIP)})
+	}
+	blockKeys = append(blockKeys, loginDelayKey("user", name)) // 退避键放在最后
+
+	res, err := l.cache.ReserveWindow(blockKeys, windows, l.window(), id)
+	if err != nil {
+		return nil, status, err
+	}
+
+	switch {
+	case res.BlockTTLs != nil:
+		for i, ttl := range res.BlockTTLs {
+			if ttl <= 0 {
+				continue
+			}
+			if i < len(res.BlockTTLs)-1 {
+				status.Locked = true
+			}
+			if ttl > status.RetryAfter {
+				status.RetryAfter = ttl
+			}
+		}
+		return nil, status, nil
+	case res.Full:
+		status.Locked, status.RetryAfter = true, res.RetryAfter
+		return nil, status, nil
+	}
+
+	status.Failures = res.Counts[0]
+	status.CaptchaRequired = l.captchaRequired(status.Failures)
+	return &LoginAttempt{Username: username, IP: ip, ID: id}, status, nil
+}
+
+// Fail 把预占的尝试确认为失败（预占时已计入次数），按失败次数设置退避或锁定
+func (l *LoginLimiterAdapter) Fail(attempt *LoginAttempt) (LoginStatus, error) {
+	name := strings.ToLower(attempt.Username)
+	userFailures, err := l.cache.CountWindow(loginFailKey("user", name), l.window())
+	if err != nil {
+		return LoginStatus{}, err
+	}
+	var ipFailures int64
+	if attempt.IP != "" {
+		if ipFailures, err = l.cache.CountWindow(loginFailKey("ip", attempt.IP), l.window()); err != nil {
+			return LoginStatus{}, err
+		}
+	}
+	return l.apply(attempt.Username, attempt.IP, userFailures, ipFailures)
+}
+
+// Release 登录成功后撤销预占的尝试，并清除该用户名的失败记录
+func (l *LoginLimiterAdapter) Release(attempt *LoginAttempt) error {
+	keys := []string{loginFailKey("user", strings.ToLower(attempt.Username))}
+	if attempt.IP != "" {
+		keys = append(keys, loginFailKey("ip", attempt.IP))
+	}
+	if err := l.cache.RemoveFromWindow(attempt.ID, keys...); err != nil {
+		return err
+	}
+	return l.Reset(attempt.Username)
+}
+
+// RecordFailure 记录一次登录失败，返回记录后的状态
+func (l *LoginLimiterAdapter) RecordFailure(username, ip string) (LoginStatus, error) {
+	name := strings.ToLower(username)
+
+	userFailures, err := l.cache.AddToWindow(loginFailKey("user", name), l.window())
+	if err != nil {
+		return LoginStatus{}, err
+	}
+	var ipFailures int64
+	if ip != "" {
+		if ipFailures, err = l.cache.AddToWindow(loginFailKey("ip", ip), l.window()); err != nil {
+			return LoginStatus{}, err
+		}
+	}
+	return l.apply(username, ip, userFailures, ipFailures)
+}
+
+// apply 按窗口内的失败次数设置退避或锁定，返回对应的状态
+// 规则：
+// 1. 失败次数达到 DelayAfterFailures 后，每次失败的等待时间翻倍（不超过 MaxDelaySeconds）
+// 2. 用户名或IP失败次数达到上限后，锁定 LockoutMinutes 分钟
+func (l *LoginLimiterAdapter) apply(username, ip string, userFailures, ipFailures int64) (LoginStatus, error) {
+	var status LoginStatus
+	name := strings.ToLower(username)
+
+	status.Failures = userFailures
+	status.CaptchaRequired = l.captchaRequired(userFailures)
+	lockout := time.Duration(l.cfg.LockoutMinutes) * time.Minute
+
+	switch {
+	case userFailures >= int64(l.cfg.MaxFailuresPerUser):
+		if err := l.lock("user", name, username, ip, userFailures, lockout); err != nil {
+			return status, err
+		}
+		status.Locked, status.RetryAfter = true, lockout
+	case ip != "" && ipFailures >= int64(l.cfg.MaxFailuresPerIP):
+		if err := l.lock("ip", ip, username, ip, ipFailures, lockout); err != nil {
+			return status, err
+		}
+		status.Locked, status.RetryAfter = true, lockout
+	case userFailures >= int64(l.cfg.DelayAfterFailures):
+		delay := l.backoff(userFailures)
+		if err := l.cache.Set(loginDelayKey("user", name), time.Now().Add(delay).Unix(), delay); err != nil {
+			return status, err
+		}
+		status.RetryAfter = delay
+	}
+
+	return status, nil
+}
+
+// Reset 登录成功后清除该用户名的失败记录
+func (l *LoginLimiterAdapter) Reset(username string) error {
+	name := strings.ToLower(username)
+	if err := l.cache.Delete(loginFailKey("user", name)); err != nil {
+		return err
+	}
+	return l.cache.Delete(loginDelayKey("user", name))
+}
+
+// lock 锁定用户名或IP，并记录审计日志
+func (l *LoginLimiterAdapter) lock(scope, id, username, ip string, failures int64, duration time.Duration) error {
+	if err := l.cache.Set(loginLockKey(scope, id), time.Now().Add(duration).Unix(), duration); err != nil {
+		return err
+	}
+
+	logger.Warn("[audit] 登录失败次数过多，已锁定",
+		zap.String("event", "login_lockout"),
+		zap.String("scope", scope),
+		zap.String("username", username),
+		zap.String("ip", ip),
+		zap.Int64("failures", failures),
+		zap.Duration("duration", duration))
+	return nil
+}
+
+// backoff 计算指数退避时长
+func (l *LoginLimiterAdapter) backoff(failures int64) time.Duration {
+	maxDelay := time.Duration(l.cfg.MaxDelaySeconds) * time.Second
+	delay := time.Duration(l.cfg.BaseDelaySeconds) * time.Second
+	for i := int64(l.cfg.DelayAfterFailures); i < failures; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
+
+// captchaRequired 失败次数是否达到验证码阈值
+func (l *LoginLimiterAdapter) captchaRequired(failures int64) bool {
+	return l.cfg.CaptchaAfterFailures > 0 && failures >= int64(l.cfg.CaptchaAfterFailures)
+}
+
+// ============================================================
+// 第三方身份提供方适配器 - 基于OIDC客户端实现IdentityProvider接口
+// ============================================================
+
+// OIDCProviderAdapter OIDC身份提供方适配器
+type OIDCProviderAdapter struct {
+	provider *oidc.Provider
+}
+
+// NewIdentityProviders 根据 oidc 配置创建身份提供方（client为nil时使用默认HTTP客户端）
+// 配置不完整的提供方会被跳过并记录日志
+func NewIdentityProviders(client *http.Client) map[string]IdentityProvider {
+	providers := make(map[string]IdentityProvider)
+	for _, cfg := range config.GlobalConfig.OIDC.Providers {
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			logger.Warn("第三方登录配置不完整，已跳过", zap.String("provider", cfg.Name))
+			continue
+		}
+		providers[cfg.Name] = &OIDCProviderAdapter{
+			provider: oidc.New(oidc.Config{
+				Name:         cfg.Name,
+				Issuer:       cfg.Issuer,
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				RedirectURL:  cfg.RedirectURL,
+				Scopes:       cfg.Scopes,
+			}, client),
+		}
+	}
+	return providers
+}
+
+// AuthCodeURL 生成授权跳转地址
+func (a *OIDCProviderAdapter) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	return a.provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+}
+
+// Exchange 用授权码换取并验证ID Token
+func (a *OIDCProviderAdapter) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalIdentity, error) {
+	claims, err := a.provider.Exchange(ctx, code, codeVerifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalIdentity{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
 }
@@ -0,0 +1,161 @@
+// Package user - 用户模块头像处理
+// 功能：校验上传的头像图片，裁剪为正方形并重新编码为PNG主图和缩略图；校验资料中的头像引用
+package user
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	// 注册可解码的图片格式
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// 头像限制
+const (
+	maxAvatarBytes       = 2 << 20 // 上传文件最大2MB
+	maxAvatarDimension   = 4096    // 原图最大边长（防止解压炸弹）
+	minAvatarDimension   = 16      // 原图最小边长
+	avatarSize           = 256     // 主图边长
+	avatarThumbSize      = 64      // 缩略图边长
+	avatarKeyPrefix      = "avatars/"
+	avatarPresetPrefix   = "preset_"
+	defaultAvatarPresets = 12 // 未配置时的预设头像数量（preset_1 ~ preset_12）
+)
+
+// allowedAvatarTypes 允许上传的MIME类型（按文件内容嗅探，不信任客户端声明）
+var allowedAvatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// processAvatar 校验并处理头像，返回PNG编码的主图和缩略图
+// 重新编码会丢弃原图中的元数据（EXIF等）和非图像内容
+func processAvatar(data []byte) ([]byte, []byte, error) {
+	if len(data) > maxAvatarBytes {
+		return nil, nil, fmt.Errorf("头像文件过大")
+	}
+
+	mime := http.DetectContentType(data)
+	if !allowedAvatarTypes[mime] {
+		return nil, nil, fmt.Errorf("不支持的图片格式: %s", mime)
+	}
+
+	// 先只解析尺寸，避免解码超大图片
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("图片解析失败: %w", err)
+	}
+	if cfg.Width > maxAvatarDimension || cfg.Height > maxAvatarDimension {
+		return nil, nil, fmt.Errorf("图片尺寸过大: %dx%d", cfg.Width, cfg.Height)
+	}
+	if cfg.Width < minAvatarDimension || cfg.Height < minAvatarDimension {
+		return nil, nil, fmt.Errorf("图片尺寸过小: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("图片解码失败: %w", err)
+	}
+
+	square := cropSquare(src)
+	side := square.Bounds().Dx()
+
+	mainPNG, err := encodePNG(resizeBox(square, min(side, avatarSize)))
+	if err != nil {
+		return nil, nil, err
+	}
+	thumbPNG, err := encodePNG(resizeBox(square, min(side, avatarThumbSize)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return mainPNG, thumbPNG, nil
+}
+
+// cropSquare 居中裁剪为正方形，并转换为RGBA（预乘Alpha，便于平均取样）
+func cropSquare(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), src, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// resizeBox 使用区域平均（Box Filter）缩放正方形图片
+// 每个目标像素取其覆盖的源像素区域的平均值，适合缩小
+func resizeBox(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	if size >= side {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		sy0, sy1 := dy*side/size, (dy+1)*side/size
+		for dx := 0; dx < size; dx++ {
+			sx0, sx1 := dx*side/size, (dx+1)*side/size
+
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				off := sy*src.Stride + sx0*4
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					b += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// encodePNG 编码为PNG
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("图片编码失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// validAvatarRef 头像引用是否为预设头像ID（preset_1 ~ preset_{presets}）或允许的主机上的https图片地址
+// 上传的头像（存储key）不在此校验，只能通过上传接口设置
+func validAvatarRef(avatar string, presets int, hosts []string) bool {
+	if n, ok := strings.CutPrefix(avatar, avatarPresetPrefix); ok {
+		id, err := strconv.Atoi(n)
+		return err == nil && id >= 1 && id <= presets && strconv.Itoa(id) == n
+	}
+
+	u, err := url.Parse(avatar)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Opaque != "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range hosts {
+		if host != "" && host == strings.ToLower(h) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,11 @@
 // 功能：定义API请求和响应的数据结构
 package user
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // ============================================================
 // 请求DTO (Data Transfer Objects)
 // 设计模式：DTO模式 - 用于在不同层之间传输数据
@@ -9,14 +14,74 @@ package user
 
 // RegisterRequest 用户注册请求
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required" example:"player1"`
-	Password string `json:"password" binding:"required" example:"password123"`
+	Username   string `json:"username" binding:"required" example:"player1"`
+	Password   string `json:"password" binding:"required" example:"password123"`
+	DeviceName string `json:"device_name" example:"PC"` // 设备名称（可选）
 }
 
 // LoginRequest 用户登录请求
 type LoginRequest struct {
+	Username   string `json:"username" binding:"required" example:"player1"`
+	Password   string `json:"password" binding:"required" example:"password123"`
+	DeviceName string `json:"device_name" example:"PC"` // 设备名称（可选）
+}
+
+// GuestLoginRequest 游客登录请求
+type GuestLoginRequest struct {
+	DeviceID   string `json:"device_id" binding:"required,max=128" example:"8a1f3c2e-5b7d-4e9a-b0c1-2d3e4f5a6b7c"` // 设备唯一标识
+	Secret     string `json:"guest_secret" binding:"max=128" example:""`                                           // 游客凭证（首次登录时返回，之后每次登录必须提供）
+	DeviceName string `json:"device_name" example:"iPhone"`                                                        // 设备名称（可选）
+}
+
+// UpgradeGuestRequest 游客升级为正式账号请求
+type UpgradeGuestRequest struct {
 	Username string `json:"username" binding:"required" example:"player1"`
-	Password string `json:"password" binding:"required" example:"password123"`
+	Password string `json:"password" binding:"required" example:"N3w-Passw0rd"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required" example:"password123"`
+	NewPassword string `json:"new_password" binding:"required" example:"N3w-Passw0rd"`
+}
+
+// ResetPasswordRequest 使用重置令牌设置新密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required" example:"9f86d081884c7d659a2feaa0c55ad015..."`
+	NewPassword string `json:"new_password" binding:"required" example:"N3w-Passw0rd"`
+}
+
+// UpdateProfileRequest 更新资料请求（字段缺省表示不修改）
+type UpdateProfileRequest struct {
+	DisplayName *string         `json:"display_name" example:"小明"`
+	Avatar      *string         `json:"avatar" example:"preset_3"`
+	Bio         *string         `json:"bio" example:"喜欢解谜"`
+	Locale      *string         `json:"locale" example:"zh-CN"`
+	Preferences json.RawMessage `json:"preferences" swaggertype:"object"`
+}
+
+// BanUserRequest 封禁用户请求
+type BanUserRequest struct {
+	Scope         string `json:"scope" binding:"omitempty,oneof=full chat ranking" example:"ranking"` // 封禁范围，默认full
+	Reason        string `json:"reason" binding:"required,max=255" example:"使用外挂刷分"`
+	DurationHours int    `json:"duration_hours" binding:"min=0" example:"72"` // 封禁时长（小时），0表示永久
+}
+
+// SetRoleRequest 修改用户角色请求
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required" example:"moderator"` // player/moderator/admin
+}
+
+// DeleteAccountRequest 注销账号请求
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"password123"` // 当前密码（游客和仅通过第三方登录的账号无需填写）
+}
+
+// ClientInfo 客户端信息（由Handler从请求中提取，用于记录登录会话）
+type ClientInfo struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
 }
 
 // ============================================================
@@ -28,10 +93,132 @@ type LoginRequest struct {
 type UserVO struct {
 	ID       uint   `json:"id" example:"1"`
 	Username string `json:"username" example:"player1"`
+	Role     string `json:"role" example:"player"`
+	IsGuest  bool   `json:"is_guest" example:"false"`
 }
 
 // AuthResponse 统一认证响应（注册和登录）
 type AuthResponse struct {
 	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
 	User  UserVO `json:"user"`
+	// GuestSecret 游客凭证（只在创建游客账号时返回一次，客户端需要与设备ID一起保存）
+	GuestSecret string `json:"guest_secret,omitempty" example:"3f9c...e1"`
+}
+
+// LoginFailedData 登录失败附加信息
+type LoginFailedData struct {
+	RetryAfter      int  `json:"retry_after,omitempty" example:"30"` // 需等待的秒数
+	CaptchaRequired bool `json:"captcha_required" example:"false"`   // 是否需要验证码
+}
+
+// SessionVO 登录设备值对象
+type SessionVO struct {
+	ID         uint      `json:"id" example:"1"`
+	DeviceName string    `json:"device_name" example:"PC"`
+	IP         string    `json:"ip" example:"127.0.0.1"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0"`
+	CreatedAt  time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+	LastSeenAt time.Time `json:"last_seen_at" example:"2023-12-20T10:00:00Z"`
+	Current    bool      `json:"current" example:"true"` // 是否为当前请求使用的设备
+}
+
+// PasswordResetTokenVO 密码重置令牌（明文仅返回一次）
+type PasswordResetTokenVO struct {
+	UserID    uint      `json:"user_id" example:"1"`
+	Token     string    `json:"token" example:"9f86d081884c7d659a2feaa0c55ad015..."`
+	ExpiresAt time.Time `json:"expires_at" example:"2023-12-20T10:30:00Z"`
+}
+
+// ProfileVO 个人资料值对象（本人可见，包含设置）
+type ProfileVO struct {
+	UserID      uint            `json:"user_id" example:"1"`
+	Username    string          `json:"username" example:"player1"`
+	DisplayName string          `json:"display_name" example:"小明"`
+	Avatar      string          `json:"avatar" example:"preset_3"`
+	AvatarThumb string          `json:"avatar_thumb" example:"preset_3"`
+	Bio         string          `json:"bio" example:"喜欢解谜"`
+	Locale      string          `json:"locale" example:"zh-CN"`
+	Preferences json.RawMessage `json:"preferences" swaggertype:"object"`
+	IsGuest     bool            `json:"is_guest" example:"false"`
+	UpdatedAt   time.Time       `json:"updated_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// AccountDeletionVO 账号注销申请结果
+type AccountDeletionVO struct {
+	DeleteAt time.Time `json:"delete_at" example:"2023-12-27T10:00:00Z"` // 计划删除时间，此前重新登录即撤销注销
+}
+
+// IdentityVO 绑定的第三方身份
+type IdentityVO struct {
+	ID          uint      `json:"id" example:"1"`
+	Provider    string    `json:"provider" example:"google"`
+	Email       string    `json:"email" example:"player1@example.com"`
+	CreatedAt   time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+	LastLoginAt time.Time `json:"last_login_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// OIDCAuthorizeVO 第三方授权跳转地址
+type OIDCAuthorizeVO struct {
+	AuthorizeURL string `json:"authorize_url" example:"https://accounts.example.com/authorize?client_id=..."`
+}
+
+// PublicProfileVO 公开资料值对象（任何人可见）
+type PublicProfileVO struct {
+	UserID      uint      `json:"user_id" example:"1"`
+	Username    string    `json:"username" example:"player1"`
+	DisplayName string    `json:"display_name" example:"小明"`
+	Avatar      string    `json:"avatar" example:"preset_3"`
+	AvatarThumb string    `json:"avatar_thumb" example:"preset_3"`
+	Bio         string    `json:"bio" example:"喜欢解谜"`
+	CreatedAt   time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// AdminUserVO 管理后台用户信息
+type AdminUserVO struct {
+	ID             uint      `json:"id" example:"1"`
+	Username       string    `json:"username" example:"player1"`
+	DisplayName    string    `json:"display_name" example:"小明"`
+	Avatar         string    `json:"avatar,omitempty" example:"preset_3"`
+	Role           string    `json:"role" example:"player"`
+	IsGuest        bool      `json:"is_guest" example:"false"`
+	CreatedAt      time.Time `json:"created_at" example:"2023-12-20T10:00:00Z"`
+	LastLoginAt    time.Time `json:"last_login_at" example:"2023-12-20T10:00:00Z"`
+	ActiveSessions int       `json:"active_sessions" example:"2"` // 未过期的登录设备数（仅详情）
+	Bans           []BanVO   `json:"bans,omitempty"`              // 当前生效的封禁（仅详情）
+}
+
+// AdminUserListVO 管理后台用户列表
+type AdminUserListVO struct {
+	Total int64         `json:"total" example:"100"`
+	Users []AdminUserVO `json:"users"`
+}
+
+// BanVO 封禁记录值对象
+type BanVO struct {
+	ID        uint       `json:"id" example:"1"`
+	Scope     string     `json:"scope" example:"full"` // 封禁范围：full/chat/ranking
+	Reason    string     `json:"reason" example:"使用外挂刷分"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2023-12-23T10:00:00Z"` // null表示永久封禁
+	CreatedBy uint       `json:"created_by" example:"1"`
+	CreatedAt time.Time  `json:"created_at" example:"2023-12-20T10:00:00Z"`
+}
+
+// BanInfoVO 封禁信息（被封禁用户请求时随错误码返回）
+type BanInfoVO struct {
+	Scope     string     `json:"scope" example:"full"`
+	Reason    string     `json:"reason" example:"使用外挂刷分"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2023-12-23T10:00:00Z"` // null表示永久封禁
+}
+
+// BanEvent 封禁通知（断开WebSocket连接前推送）
+type BanEvent struct {
+	Type string    `json:"type" example:"banned"`
+	Code int       `json:"code" example:"20018"`
+	Ban  BanInfoVO `json:"ban"`
+}
+
+// AccountDeletionEvent 注销账号通知（断开WebSocket连接前推送）
+type AccountDeletionEvent struct {
+	Type     string    `json:"type" example:"account_deletion_scheduled"`
+	DeleteAt time.Time `json:"delete_at" example:"2023-12-27T10:00:00Z"`
 }
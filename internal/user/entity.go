@@ -5,6 +5,8 @@ package user
 
 import (
 	"time"
+
+	"faulty_in_culture/go_back/internal/shared/security"
 )
 
 // ============================================================
@@ -13,13 +15,129 @@ import (
 type Entity struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	Username    string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"username"`
-	Password    string    `gorm:"type:varchar(255);not null" json:"-"` // 密码不返回给前端
+	Password    string    `gorm:"type:varchar(255);not null" json:"-"`                        // 密码不返回给前端
+	Role        string    `gorm:"type:varchar(16);not null;default:player;index" json:"role"` // 角色：player/moderator/admin
+	IsGuest     bool      `gorm:"not null;default:false;index" json:"is_guest"`
+	DeviceID    *string   `gorm:"type:varchar(128);uniqueIndex" json:"-"`        // 游客绑定的设备ID（正式账号为NULL）
+	GuestSecret string    `gorm:"type:varchar(64);not null;default:''" json:"-"` // 游客凭证的SHA-256摘要（创建游客时签发，之后每次登录都要提供）
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 	LastLoginAt time.Time `json:"last_login_at"`
+	// DeletionScheduledAt 账号计划删除时间（用户申请注销后设置，宽限期内重新登录即撤销）
+	DeletionScheduledAt *time.Time `gorm:"index" json:"-"`
 }
 
+// GuestUsernamePrefix 游客账号用户名前缀（正式账号不可使用）
+const GuestUsernamePrefix = "guest_"
+
 // TableName 指定数据库表名
 // GORM约定：实现TableName()接口自定义表名
 func (Entity) TableName() string {
 	return "users"
 }
+
+// DeviceSession 登录会话实体（一个设备一次登录对应一条记录）
+// 职责：记录签发的Token（jti）与登录设备信息，用于设备管理和踢下线
+type DeviceSession struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"user_id"`
+	TokenID    string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // 对应JWT的jti
+	DeviceName string    `gorm:"type:varchar(100)" json:"device_name"`
+	IP         string    `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent  string    `gorm:"type:varchar(512)" json:"user_agent"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `gorm:"index" json:"expires_at"` // 与Token过期时间一致
+}
+
+// TableName 指定数据库表名
+func (DeviceSession) TableName() string {
+	return "user_sessions"
+}
+
+// PasswordResetToken 一次性密码重置令牌（由管理员签发）
+// 只保存令牌的SHA-256摘要，明文仅在签发时返回一次
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	CreatedBy uint       `gorm:"not null;default:0" json:"created_by"` // 签发的管理员ID
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定数据库表名
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// Identity 第三方登录身份（OIDC），一个用户可绑定多个身份提供方，每个提供方一个
+type Identity struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_identity_user_provider" json:"user_id"`
+	Provider    string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_identity_provider_subject;uniqueIndex:idx_identity_user_provider" json:"provider"`
+	Subject     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_provider_subject" json:"-"` // ID Token中的sub
+	Email       string    `gorm:"type:varchar(255)" json:"email"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// TableName 指定数据库表名
+func (Identity) TableName() string {
+	return "user_identities"
+}
+
+// Profile 玩家资料实体（与用户一对一，首次修改资料时创建）
+type Profile struct {
+	UserID      uint      `gorm:"primaryKey" json:"user_id"`
+	DisplayName string    `gorm:"type:varchar(64)" json:"display_name"` // 昵称（为空时展示用户名）
+	Avatar      string    `gorm:"type:varchar(255)" json:"avatar"`      // 头像引用（预设头像ID、允许主机上的https图片URL或上传文件的存储key）
+	Bio         string    `gorm:"type:varchar(500)" json:"bio"`         // 个人简介
+	Locale      string    `gorm:"type:varchar(16)" json:"locale"`       // 语言区域，如 zh-CN
+	Preferences string    `gorm:"type:text" json:"preferences"`         // 个人设置（JSON）
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定数据库表名
+func (Profile) TableName() string {
+	return "user_profiles"
+}
+
+// Ban 封禁记录
+// 同一用户可以有多条记录（历史封禁），未解除且未过期的记录为生效中的封禁
+// 范围为 full 时无法登录；chat/ranking 只限制对应功能
+type Ban struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Scope     string     `gorm:"type:varchar(16);not null;default:full" json:"scope"` // 封禁范围：full/chat/ranking
+	Reason    string     `gorm:"type:varchar(255);not null" json:"reason"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`              // 到期时间（NULL表示永久封禁）
+	CreatedBy uint       `gorm:"not null;default:0" json:"created_by"` // 执行封禁的管理员ID
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`                           // 提前解除时间
+	RevokedBy uint       `gorm:"not null;default:0" json:"revoked_by"` // 解除封禁的管理员ID
+}
+
+// TableName 指定数据库表名
+func (Ban) TableName() string {
+	return "user_bans"
+}
+
+// Active 封禁在指定时间是否生效
+func (b *Ban) Active(now time.Time) bool {
+	return b.RevokedAt == nil && (b.ExpiresAt == nil || b.ExpiresAt.After(now))
+}
+
+// Covers 封禁是否限制指定范围的功能（全部封禁覆盖所有范围）
+func (b *Ban) Covers(scope string) bool {
+	return b.Scope == scope || b.Scope == security.BanScopeFull
+}
+
+// Brief 用户简要信息（供排行榜等其他模块展示用户）
+type Brief struct {
+	UserID      uint
+	Username    string
+	DisplayName string // 已回退：未设置昵称时为用户名
+	Avatar      string // 头像（上传的头像已转换为访问地址）
+	AvatarThumb string // 头像缩略图（非上传头像时与Avatar相同）
+}
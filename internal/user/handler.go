@@ -4,9 +4,18 @@
 package user
 
 import (
+	"encoding/json"
+	stderrors "errors"
 	errcode "faulty_in_culture/go_back/internal/shared/errors"
 	"faulty_in_culture/go_back/internal/shared/response"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,13 +47,13 @@ func NewHandler(service *Service) *Handler {
 
 // Register 用户注册
 // @Summary 用户注册
-// @Description 用户通过用户名和密码注册新账号，密码将被加密存储
+// @Description 用户通过用户名和密码注册新账号，密码需符合密码策略并将被加密存储
 // @Tags user
 // @Accept json
 // @Produce json
 // @Param data body RegisterRequest true "注册信息"
 // @Success 200 {object} AuthResponse "注册成功，返回用户信息和token"
-// @Failure 400 {object} response.Response "参数错误或用户名已存在"
+// @Failure 400 {object} response.Response "参数错误、用户名已存在或密码强度不足"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Router /api/register [post]
 func (h *Handler) Register(c *gin.Context) {
@@ -55,19 +64,16 @@ func (h *Handler) Register(c *gin.Context) {
 	}
 
 	// 调用Service层处理业务逻辑
-	user, token, err := h.service.Register(req.Username, req.Password)
+	user, token, err := h.service.Register(req.Username, req.Password, clientInfo(c, req.DeviceName))
 	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, errcode.UserAlreadyExists, err.Error())
+		handleError(c, err)
 		return
 	}
 
 	// 返回响应
 	response.Success(c, AuthResponse{
 		Token: token,
-		User: UserVO{
-			ID:       user.ID,
-			Username: user.Username,
-		},
+		User:  toUserVO(user),
 	})
 }
 
@@ -80,7 +86,9 @@ func (h *Handler) Register(c *gin.Context) {
 // @Param data body LoginRequest true "登录信息"
 // @Success 200 {object} AuthResponse "登录成功，返回token和用户信息"
 // @Failure 400 {object} response.Response "参数错误"
-// @Failure 401 {object} response.Response "用户名或密码错误"
+// @Failure 401 {object} response.Response{data=LoginFailedData} "用户名或密码错误"
+// @Failure 403 {object} response.Response{data=BanInfoVO} "账号已被封禁（data给出封禁原因和到期时间）"
+// @Failure 429 {object} response.Response{data=LoginFailedData} "失败次数过多，已锁定或需等待（Retry-After头给出秒数）"
 // @Router /api/login [post]
 func (h *Handler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -90,18 +98,837 @@ func (h *Handler) Login(c *gin.Context) {
 	}
 
 	// 调用Service层处理业务逻辑
-	user, token, err := h.service.Login(req.Username, req.Password)
+	user, token, err := h.service.Login(req.Username, req.Password, clientInfo(c, req.DeviceName))
 	if err != nil {
-		response.Error(c, http.StatusUnauthorized, errcode.InvalidPassword)
+		var loginErr *LoginError
+		if !stderrors.As(err, &loginErr) {
+			var e *errcode.Error
+			if stderrors.As(err, &e) && e.Code == errcode.AccountBanned {
+				handleError(c, err)
+				return
+			}
+			response.Error(c, http.StatusUnauthorized, errcode.InvalidPassword)
+			return
+		}
+
+		status := loginErr.Status
+		data := LoginFailedData{CaptchaRequired: status.CaptchaRequired}
+		if status.Blocked() {
+			retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+			data.RetryAfter = retryAfter
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+		}
+		httpCode := http.StatusUnauthorized
+		if loginErr.Code != errcode.InvalidPassword {
+			httpCode = http.StatusTooManyRequests
+		}
+		response.ErrorWithData(c, httpCode, loginErr.Code, data)
 		return
 	}
 
 	// 返回响应
 	response.Success(c, AuthResponse{
 		Token: token,
-		User: UserVO{
-			ID:       user.ID,
-			Username: user.Username,
-		},
+		User:  toUserVO(user),
+	})
+}
+
+// ListSessions 获取登录设备列表
+// @Summary 登录设备列表
+// @Description 获取当前用户所有未过期的登录会话（设备、IP、最后活跃时间）
+// @Tags user
+// @Produce json
+// @Success 200 {object} response.Response{data=[]SessionVO}
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me/sessions [get]
+func (h *Handler) ListSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	currentTokenID := c.GetString("token_id")
+
+	sessions, err := h.service.ListSessions(userID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	vos := make([]SessionVO, len(sessions))
+	for i, s := range sessions {
+		vos[i] = SessionVO{
+			ID:         s.ID,
+			DeviceName: s.DeviceName,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			Current:    currentTokenID != "" && s.TokenID == currentTokenID,
+		}
+	}
+
+	response.Success(c, vos)
+}
+
+// RevokeSession 踢下线指定设备
+// @Summary 踢下线设备
+// @Description 删除指定的登录会话，该设备持有的token立即失效
+// @Tags user
+// @Produce json
+// @Param id path int true "登录会话ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "登录设备不存在"
+// @Router /api/me/sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	sessionID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if sessionID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	if err := h.service.RevokeSession(userID, uint(sessionID)); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "已下线", nil)
+}
+
+// ChangePassword 修改密码
+// @Summary 修改密码
+// @Description 校验原密码后设置新密码，成功后其他设备的登录会话将被注销
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param data body ChangePasswordRequest true "原密码和新密码"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "参数错误、原密码错误或密码强度不足"
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me/password [put]
+func (h *Handler) ChangePassword(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	if err := h.service.ChangePassword(userID, c.GetString("token_id"), req.OldPassword, req.NewPassword); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "密码修改成功", nil)
+}
+
+// ResetPassword 使用重置令牌设置新密码
+// @Summary 重置密码
+// @Description 使用管理员签发的一次性重置令牌设置新密码，成功后所有设备需重新登录
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param data body ResetPasswordRequest true "重置令牌和新密码"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "参数错误、令牌无效或密码强度不足"
+// @Router /api/password/reset [post]
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	if err := h.service.ResetPassword(req.Token, req.NewPassword); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "密码重置成功，请重新登录", nil)
+}
+
+// GuestLogin 游客登录
+// @Summary 游客登录
+// @Description 使用设备ID创建或登录游客账号，同一设备始终对应同一游客，可正常使用存档、聊天、排行榜；创建账号时返回guest_secret，之后登录必须同时提供
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param data body GuestLoginRequest true "设备信息"
+// @Success 200 {object} AuthResponse "登录成功，返回token和游客信息"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "游客凭证无效"
+// @Failure 403 {object} response.Response{data=BanInfoVO} "账号已被封禁"
+// @Failure 429 {object} response.Response{data=LoginFailedData} "凭证错误或创建游客次数过多，已锁定或需等待（Retry-After头给出秒数）"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/guest [post]
+func (h *Handler) GuestLogin(c *gin.Context) {
+	var req GuestLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	user, token, secret, err := h.service.GuestLogin(req.DeviceID, req.Secret, clientInfo(c, req.DeviceName))
+	if err != nil {
+		var loginErr *LoginError
+		if stderrors.As(err, &loginErr) {
+			status := loginErr.Status
+			retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			response.ErrorWithData(c, http.StatusTooManyRequests, loginErr.Code, LoginFailedData{RetryAfter: retryAfter})
+			return
+		}
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, AuthResponse{Token: token, User: toUserVO(user), GuestSecret: secret})
+}
+
+// UpgradeGuest 游客升级为正式账号
+// @Summary 游客升级
+// @Description 为游客账号设置用户名和密码，升级后用户ID不变，存档、排行榜、聊天记录全部保留；旧token失效，返回新token
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param data body UpgradeGuestRequest true "用户名和密码"
+// @Success 200 {object} AuthResponse "升级成功，返回新token"
+// @Failure 400 {object} response.Response "参数错误、用户名已存在、密码强度不足或非游客账号"
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me/upgrade [post]
+func (h *Handler) UpgradeGuest(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req UpgradeGuestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	user, token, err := h.service.UpgradeGuest(userID, req.Username, req.Password, clientInfo(c, ""))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, AuthResponse{Token: token, User: toUserVO(user)})
+}
+
+// GetMyProfile 获取当前用户资料
+// @Summary 获取我的资料
+// @Description 获取当前用户的昵称、头像、简介、语言区域和个人设置
+// @Tags user
+// @Produce json
+// @Success 200 {object} response.Response{data=ProfileVO}
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me/profile [get]
+func (h *Handler) GetMyProfile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	user, profile, err := h.service.GetProfile(userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, h.toProfileVO(user, profile))
+}
+
+// UpdateMyProfile 更新当前用户资料
+// @Summary 更新我的资料
+// @Description 部分更新资料，请求中未出现的字段保持不变；avatar 只能是预设头像ID（如 preset_3）或允许主机上的https图片地址；preferences 必须是JSON对象
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param data body UpdateProfileRequest true "资料字段"
+// @Success 200 {object} response.Response{data=ProfileVO}
+// @Failure 400 {object} response.Response "参数错误或资料格式错误"
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me/profile [put]
+func (h *Handler) UpdateMyProfile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	user, profile, err := h.service.UpdateProfile(userID, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, h.toProfileVO(user, profile))
+}
+
+// UploadAvatar 上传头像
+// @Summary 上传头像
+// @Description 上传PNG/JPEG/GIF图片作为头像（最大2MB），服务端居中裁剪并生成256px主图和64px缩略图
+// @Tags user
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "头像图片"
+// @Success 200 {object} response.Response{data=ProfileVO}
+// @Failure 400 {object} response.Response "文件缺失、格式不支持或尺寸不符"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 413 {object} response.Response "文件过大"
+// @Router /api/me/avatar [post]
+func (h *Handler) UploadAvatar(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	// 限制请求体大小（预留multipart表单开销）
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarBytes+64<<10)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			response.ErrorWithMessage(c, http.StatusRequestEntityTooLarge, errcode.InvalidAvatar, "头像文件过大")
+			return
+		}
+		response.ErrorWithMessage(c, http.StatusBadRequest, errcode.InvalidParams, "缺少头像文件")
+		return
+	}
+	if fileHeader.Size > maxAvatarBytes {
+		response.ErrorWithMessage(c, http.StatusRequestEntityTooLarge, errcode.InvalidAvatar, "头像文件过大")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarBytes+1))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	user, profile, err := h.service.UploadAvatar(userID, data)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, h.toProfileVO(user, profile))
+}
+
+// DeleteAccount 注销账号
+// @Summary 注销账号
+// @Description 申请注销当前账号：立即注销所有登录设备，宽限期结束后删除账号及存档、排行榜、聊天记录等全部数据。宽限期内重新登录即撤销注销
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param data body DeleteAccountRequest false "当前密码（游客和仅通过第三方登录的账号无需填写）"
+// @Success 200 {object} response.Response{data=AccountDeletionVO}
+// @Failure 400 {object} response.Response "参数错误或密码错误"
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me [delete]
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req DeleteAccountRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+			return
+		}
+	}
+
+	deleteAt, err := h.service.RequestDeletion(userID, req.Password)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "账号将在宽限期结束后删除，期间重新登录即可撤销", AccountDeletionVO{DeleteAt: deleteAt})
+}
+
+// GetPublicProfile 获取用户公开资料
+// @Summary 获取用户公开资料
+// @Description 获取任意用户的公开资料（昵称、头像、简介），不包含个人设置
+// @Tags user
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response{data=PublicProfileVO}
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/users/{id} [get]
+func (h *Handler) GetPublicProfile(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if userID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	user, profile, err := h.service.GetProfile(uint(userID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	brief := h.service.BriefOf(user, profile)
+	response.Success(c, PublicProfileVO{
+		UserID:      user.ID,
+		Username:    user.Username,
+		DisplayName: brief.DisplayName,
+		Avatar:      brief.Avatar,
+		AvatarThumb: brief.AvatarThumb,
+		Bio:         profile.Bio,
+		CreatedAt:   user.CreatedAt,
 	})
 }
+
+// ============================================================
+// 管理后台
+// ============================================================
+
+// AdminSearchUsers 搜索用户
+// @Summary 搜索用户（管理后台）
+// @Description 按用户名前缀搜索用户，关键字为空时按注册时间倒序列出；需要 user:view 权限
+// @Tags admin
+// @Produce json
+// @Param keyword query string false "用户名前缀"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} response.Response{data=AdminUserListVO}
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/users [get]
+func (h *Handler) AdminSearchUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	users, total, err := h.service.SearchUsers(c.Query("keyword"), page, limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, AdminUserListVO{Total: total, Users: users})
+}
+
+// AdminGetUser 获取用户详情
+// @Summary 获取用户详情（管理后台）
+// @Description 获取用户信息、当前封禁和登录设备数；需要 user:view 权限
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response{data=AdminUserVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/admin/users/{id} [get]
+func (h *Handler) AdminGetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	detail, err := h.service.GetUserDetail(userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, detail)
+}
+
+// AdminBanUser 封禁用户
+// @Summary 封禁用户（管理后台）
+// @Description 封禁指定用户，scope 为 full（默认，注销所有登录会话）、chat（禁言）或 ranking（禁止提交成绩），duration_hours 为0表示永久封禁；需要 user:ban 权限
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param data body BanUserRequest true "封禁原因和时长"
+// @Success 200 {object} response.Response{data=BanVO}
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "权限不足或目标为管理人员"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/admin/users/{id}/ban [post]
+func (h *Handler) AdminBanUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req BanUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	duration := time.Duration(req.DurationHours) * time.Hour
+	ban, err := h.service.BanUser(c.GetUint("user_id"), userID, req.Scope, req.Reason, duration)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, toBanVO(ban))
+}
+
+// AdminUnbanUser 解除封禁
+// @Summary 解除封禁（管理后台）
+// @Description 解除指定用户生效中的封禁，不指定 scope 时解除全部范围；需要 user:ban 权限
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param scope query string false "封禁范围：full/chat/ranking"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "该用户未被封禁"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /api/admin/users/{id}/ban [delete]
+func (h *Handler) AdminUnbanUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.UnbanUser(c.GetUint("user_id"), userID, c.Query("scope")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "已解除封禁", nil)
+}
+
+// AdminIssuePasswordReset 强制重置密码
+// @Summary 签发密码重置令牌（管理后台）
+// @Description 为指定用户签发一次性密码重置令牌（明文只返回这一次），用户通过 /api/password/reset 设置新密码；需要 user:reset_password 权限
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response{data=PasswordResetTokenVO}
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/admin/users/{id}/password-reset [post]
+func (h *Handler) AdminIssuePasswordReset(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	token, expiresAt, err := h.service.IssuePasswordReset(c.GetUint("user_id"), userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.Success(c, PasswordResetTokenVO{
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// AdminSetRole 修改用户角色
+// @Summary 修改用户角色（管理后台）
+// @Description 设置用户角色（player/moderator/admin），该用户所有设备需重新登录后生效；需要 user:manage_role 权限
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param data body SetRoleRequest true "角色"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "参数错误或角色无效"
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 404 {object} response.Response "用户不存在"
+// @Router /api/admin/users/{id}/role [put]
+func (h *Handler) AdminSetRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	if err := h.service.SetRole(c.GetUint("user_id"), userID, req.Role); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "角色已修改", nil)
+}
+
+// OIDCProviders 可用的第三方登录方式
+// @Summary 第三方登录方式列表
+// @Description 获取服务端已配置的OIDC身份提供方名称
+// @Tags user
+// @Produce json
+// @Success 200 {object} response.Response{data=[]string}
+// @Router /api/auth/oidc/providers [get]
+func (h *Handler) OIDCProviders(c *gin.Context) {
+	names := h.service.IdentityProviderNames()
+	sort.Strings(names)
+	response.Success(c, names)
+}
+
+// oidcBindingCookie 保存第三方登录浏览器绑定值的Cookie（只在回调路径下发送）
+const (
+	oidcBindingCookie = "oidc_binding"
+	oidcCookiePath    = "/api/auth/oidc/"
+)
+
+// setOIDCBinding 将浏览器绑定值写入HttpOnly Cookie（有效期与state相同）
+func setOIDCBinding(c *gin.Context, binding string) {
+	c.SetSameSite(http.SameSiteLaxMode) // 身份提供方跳转回来是顶层GET导航，Lax模式下会携带
+	c.SetCookie(oidcBindingCookie, binding, int(oidcStateTTL.Seconds()), oidcCookiePath, "", requestIsHTTPS(c), true)
+}
+
+// takeOIDCBinding 读取并清除浏览器绑定值
+func takeOIDCBinding(c *gin.Context) string {
+	binding, _ := c.Cookie(oidcBindingCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, "", -1, oidcCookiePath, "", requestIsHTTPS(c), true)
+	return binding
+}
+
+// requestIsHTTPS 请求是否通过HTTPS到达（包括反向代理转发的请求）
+func requestIsHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+// OIDCLogin 跳转到第三方登录
+// @Summary 第三方登录
+// @Description 302跳转到身份提供方的授权页面，并在浏览器中写入绑定本次登录的HttpOnly Cookie（回调时校验）。登录完成后回调 /api/auth/oidc/{provider}/callback；若指定return_to（需在允许列表中），回调后跳转到 return_to#token=...&user_id=...，否则回调直接返回JSON
+// @Tags user
+// @Param provider path string true "身份提供方名称"
+// @Param return_to query string false "登录完成后跳转回的客户端地址"
+// @Success 302 "跳转到身份提供方"
+// @Failure 400 {object} response.Response "不允许的跳转地址"
+// @Failure 404 {object} response.Response "不支持该登录方式"
+// @Router /api/auth/oidc/{provider}/login [get]
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	authURL, binding, err := h.service.BeginOIDC(c.Request.Context(), c.Param("provider"), 0, c.Query("return_to"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	setOIDCBinding(c, binding)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 第三方登录回调
+// @Summary 第三方登录回调
+// @Description 身份提供方授权后的回调地址：校验state和发起登录时写入的Cookie，验证ID Token后登录（未绑定过的身份自动创建账号）或完成绑定
+// @Tags user
+// @Produce json
+// @Param provider path string true "身份提供方名称"
+// @Param code query string true "授权码"
+// @Param state query string true "登录请求state"
+// @Success 200 {object} AuthResponse "登录成功（未指定return_to时）"
+// @Success 302 "跳转回客户端（指定了return_to时）"
+// @Failure 400 {object} response.Response "state无效、已过期或不是由本浏览器发起"
+// @Failure 401 {object} response.Response "第三方登录失败"
+// @Failure 403 {object} response.Response{data=BanInfoVO} "账号已被封禁"
+// @Failure 409 {object} response.Response "该第三方账号已绑定其他用户"
+// @Router /api/auth/oidc/{provider}/callback [get]
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		response.ErrorWithMessage(c, http.StatusUnauthorized, errcode.OIDCLoginFailed,
+			errcode.GetMessage(errcode.OIDCLoginFailed)+": "+errParam)
+		return
+	}
+
+	binding := takeOIDCBinding(c)
+	result, err := h.service.CompleteOIDC(c.Request.Context(), c.Param("provider"), c.Query("code"), c.Query("state"), binding, clientInfo(c, ""))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if result.ReturnTo != "" {
+		// Token放在URL片段中，不会被发送到客户端地址对应的服务器
+		fragment := url.Values{}
+		if result.Linked {
+			fragment.Set("linked", result.Identity.Provider)
+		} else {
+			fragment.Set("token", result.Token)
+			fragment.Set("user_id", strconv.FormatUint(uint64(result.User.ID), 10))
+		}
+		c.Redirect(http.StatusFound, result.ReturnTo+"#"+fragment.Encode())
+		return
+	}
+
+	if result.Linked {
+		response.SuccessWithMessage(c, "绑定成功", toIdentityVO(result.Identity))
+		return
+	}
+	response.Success(c, AuthResponse{
+		Token: result.Token,
+		User:  toUserVO(result.User),
+	})
+}
+
+// ListIdentities 获取绑定的第三方身份
+// @Summary 第三方身份列表
+// @Description 获取当前用户绑定的第三方登录身份
+// @Tags user
+// @Produce json
+// @Success 200 {object} response.Response{data=[]IdentityVO}
+// @Failure 401 {object} response.Response "未认证"
+// @Router /api/me/identities [get]
+func (h *Handler) ListIdentities(c *gin.Context) {
+	identities, err := h.service.ListIdentities(c.GetUint("user_id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	vos := make([]IdentityVO, len(identities))
+	for i, identity := range identities {
+		vos[i] = toIdentityVO(identity)
+	}
+	response.Success(c, vos)
+}
+
+// LinkIdentity 开始绑定第三方身份
+// @Summary 绑定第三方身份
+// @Description 返回身份提供方的授权地址，并写入绑定本次授权的HttpOnly Cookie；必须在同一浏览器中打开该地址（网页客户端请求时需携带凭据），完成授权后即绑定到当前用户（每个身份提供方只能绑定一个账号）
+// @Tags user
+// @Produce json
+// @Param provider path string true "身份提供方名称"
+// @Param return_to query string false "绑定完成后跳转回的客户端地址"
+// @Success 200 {object} response.Response{data=OIDCAuthorizeVO}
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 404 {object} response.Response "不支持该登录方式"
+// @Router /api/me/identities/{provider} [post]
+func (h *Handler) LinkIdentity(c *gin.Context) {
+	authURL, binding, err := h.service.BeginOIDC(c.Request.Context(), c.Param("provider"), c.GetUint("user_id"), c.Query("return_to"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	setOIDCBinding(c, binding)
+	response.Success(c, OIDCAuthorizeVO{AuthorizeURL: authURL})
+}
+
+// UnlinkIdentity 解绑第三方身份
+// @Summary 解绑第三方身份
+// @Description 解绑第三方登录身份；没有密码的账号不能解绑唯一的身份
+// @Tags user
+// @Produce json
+// @Param id path int true "身份ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "不能解绑唯一的登录方式"
+// @Failure 401 {object} response.Response "未认证"
+// @Failure 404 {object} response.Response "绑定的第三方账号不存在"
+// @Router /api/me/identities/{id} [delete]
+func (h *Handler) UnlinkIdentity(c *gin.Context) {
+	identityID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if identityID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return
+	}
+
+	if err := h.service.UnlinkIdentity(c.GetUint("user_id"), uint(identityID)); err != nil {
+		handleError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "已解绑", nil)
+}
+
+// userIDParam 解析路径中的用户ID（无效时直接返回400）
+func userIDParam(c *gin.Context) (uint, bool) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if userID == 0 {
+		response.Error(c, http.StatusBadRequest, errcode.InvalidParams)
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// toProfileVO 转换资料为ProfileVO
+func (h *Handler) toProfileVO(user *Entity, profile *Profile) ProfileVO {
+	preferences := profile.Preferences
+	if preferences == "" {
+		preferences = "{}"
+	}
+	brief := h.service.BriefOf(user, profile)
+	return ProfileVO{
+		UserID:      user.ID,
+		Username:    user.Username,
+		DisplayName: brief.DisplayName,
+		Avatar:      brief.Avatar,
+		AvatarThumb: brief.AvatarThumb,
+		Bio:         profile.Bio,
+		Locale:      profile.Locale,
+		Preferences: json.RawMessage(preferences),
+		IsGuest:     user.IsGuest,
+		UpdatedAt:   profile.UpdatedAt,
+	}
+}
+
+// toUserVO 转换Entity为UserVO
+func toUserVO(user *Entity) UserVO {
+	return UserVO{
+		ID:       user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		IsGuest:  user.IsGuest,
+	}
+}
+
+// toIdentityVO 转换Identity为IdentityVO
+func toIdentityVO(identity *Identity) IdentityVO {
+	return IdentityVO{
+		ID:          identity.ID,
+		Provider:    identity.Provider,
+		Email:       identity.Email,
+		CreatedAt:   identity.CreatedAt,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
+
+// clientInfo 从请求中提取客户端信息
+func clientInfo(c *gin.Context, deviceName string) ClientInfo {
+	return ClientInfo{
+		DeviceName: deviceName,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+}
+
+// handleError 统一处理业务错误（根据错误码映射HTTP状态码）
+func handleError(c *gin.Context, err error) {
+	var e *errcode.Error
+	if !stderrors.As(err, &e) {
+		response.Error(c, http.StatusInternalServerError, errcode.ServerError)
+		return
+	}
+
+	switch e.Code {
+	case errcode.LoginSessionNotFound, errcode.UserNotFound, errcode.OIDCProviderNotFound, errcode.IdentityNotFound:
+		response.Error(c, http.StatusNotFound, e.Code)
+	case errcode.OIDCLoginFailed:
+		response.Error(c, http.StatusUnauthorized, e.Code)
+	case errcode.IdentityLinked:
+		response.ErrorWithMessage(c, http.StatusConflict, e.Code, e.Message)
+	case errcode.AccountBanned, errcode.ChatBanned, errcode.RankingBanned:
+		response.ErrorWithData(c, http.StatusForbidden, e.Code, e.Data)
+	case errcode.PermissionDenied:
+		response.ErrorWithMessage(c, http.StatusForbidden, e.Code, e.Message)
+	case errcode.Unauthorized, errcode.TokenExpired, errcode.TokenInvalid, errcode.InvalidPassword, errcode.GuestSecretInvalid:
+		response.Error(c, http.StatusUnauthorized, e.Code)
+	case errcode.ServerError:
+		response.Error(c, http.StatusInternalServerError, e.Code)
+	default:
+		response.ErrorWithMessage(c, http.StatusBadRequest, e.Code, e.Message)
+	}
+}
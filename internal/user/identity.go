@@ -0,0 +1,389 @@
+// Package user - 用户模块第三方登录
+// 功能：OIDC授权码登录、绑定/解绑第三方身份
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/infra/oidc"
+	"faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/shared/security"
+
+	"go.uber.org/zap"
+)
+
+// ============================================================
+// 第三方登录 (OpenID Connect)
+// 流程：
+// 1. BeginOIDC 生成 state/nonce/PKCE 并保存到缓存，返回身份提供方的授权地址和浏览器绑定值
+//    （Handler写入HttpOnly Cookie，缓存中只保存摘要）
+// 2. 用户在身份提供方登录后回调，CompleteOIDC 原子地取出state，校验浏览器绑定值，换取并验证ID Token
+//    绑定值防止把他人发起的授权地址或回调地址发给受害者（绑定到他人账号、登录CSRF）
+// 3. 按 (provider, sub) 查找绑定的用户：找到则登录，否则创建新用户；绑定流程则关联到当前用户
+// ============================================================
+
+// oidcStateTTL 登录请求（state）有效期
+const oidcStateTTL = 10 * time.Minute
+
+// maxOIDCUsernameLength 自动生成的用户名最大长度（不含随机后缀）
+const maxOIDCUsernameLength = 24
+
+// ExternalIdentity 身份提供方返回的用户身份（已验证的ID Token声明）
+type ExternalIdentity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// IdentityProvider 身份提供方接口
+type IdentityProvider interface {
+	// AuthCodeURL 生成授权跳转地址
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange 用授权码换取并验证ID Token
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalIdentity, error)
+}
+
+// oidcState 一次登录请求的状态（保存在缓存中，回调时一次性取出）
+type oidcState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   uint   `json:"link_user_id,omitempty"` // 非0表示绑定到该用户，而不是登录
+	ReturnTo     string `json:"return_to,omitempty"`
+	BindingHash  string `json:"binding_hash"` // 发起登录的浏览器持有的绑定值的摘要
+}
+
+// OIDCResult 第三方登录回调结果
+type OIDCResult struct {
+	User     *Entity
+	Identity *Identity
+	Token    string // 登录时签发的Token（绑定时为空）
+	ReturnTo string // 完成后跳转回的客户端地址（为空时直接返回JSON）
+	Linked   bool   // 是否为绑定流程
+}
+
+// SetIdentityProviders 注入身份提供方（按名称索引）及允许跳转回的客户端地址前缀
+func (s *Service) SetIdentityProviders(providers map[string]IdentityProvider, allowedReturnURLs []string) {
+	s.identityProviders = providers
+	s.allowedReturnURLs = allowedReturnURLs
+}
+
+// IdentityProviderNames 已配置的身份提供方名称
+func (s *Service) IdentityProviderNames() []string {
+	names := make([]string, 0, len(s.identityProviders))
+	for name := range s.identityProviders {
+		names = append(names, name)
+	}
+	return names
+}
+
+// BeginOIDC 开始第三方登录或绑定（linkUserID非0时为绑定），返回授权跳转地址和浏览器绑定值
+// 绑定值须保存在发起登录的浏览器中（HttpOnly Cookie），回调时原样传给 CompleteOIDC
+func (s *Service) BeginOIDC(ctx context.Context, provider string, linkUserID uint, returnTo string) (string, string, error) {
+	p, ok := s.identityProviders[provider]
+	if !ok {
+		return "", "", errors.New(errors.OIDCProviderNotFound)
+	}
+	if s.cache == nil {
+		logger.Error("[user.BeginOIDC] 未配置缓存，无法保存登录状态")
+		return "", "", errors.New(errors.ServerError)
+	}
+	if returnTo != "" && !s.returnURLAllowed(returnTo) {
+		logger.Warn("[user.BeginOIDC] 不允许的跳转地址", zap.String("return_to", returnTo))
+		return "", "", errors.NewWithMessage(errors.InvalidParams, "不允许的跳转地址")
+	}
+
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	binding, err4 := oidc.RandomString()
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return "", "", errors.New(errors.ServerError)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		logger.Error("[user.BeginOIDC] 生成授权地址失败", zap.String("provider", provider), zap.Error(err))
+		return "", "", errors.New(errors.OIDCLoginFailed)
+	}
+
+	st := oidcState{
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ReturnTo:     returnTo,
+		BindingHash:  hashResetToken(binding),
+	}
+	if err := s.cache.Set(oidcStateKey(state), st, oidcStateTTL); err != nil {
+		logger.Error("[user.BeginOIDC] 保存登录状态失败", zap.Error(err))
+		return "", "", errors.New(errors.ServerError)
+	}
+
+	logger.Info("[user.BeginOIDC] 跳转到身份提供方",
+		zap.String("provider", provider),
+		zap.Uint("link_user_id", linkUserID))
+	return authURL, binding, nil
+}
+
+// CompleteOIDC 处理身份提供方回调：校验state和浏览器绑定值，换取ID Token，登录或绑定
+func (s *Service) CompleteOIDC(ctx context.Context, provider, code, state, binding string, client ClientInfo) (*OIDCResult, error) {
+	p, ok := s.identityProviders[provider]
+	if !ok {
+		return nil, errors.New(errors.OIDCProviderNotFound)
+	}
+	if state == "" || code == "" || s.cache == nil {
+		return nil, errors.New(errors.OIDCStateInvalid)
+	}
+
+	// state一次性使用：原子地取出并删除，并发回调时只有一个成功
+	var st oidcState
+	if err := s.cache.GetDel(oidcStateKey(state), &st); err != nil || st.Provider != provider {
+		logger.Warn("[user.CompleteOIDC] state无效或已过期", zap.String("provider", provider))
+		return nil, errors.New(errors.OIDCStateInvalid)
+	}
+	// 回调必须来自发起登录的浏览器
+	if binding == "" || subtle.ConstantTimeCompare([]byte(hashResetToken(binding)), []byte(st.BindingHash)) != 1 {
+		logger.Warn("[user.CompleteOIDC] 回调与发起登录的浏览器不一致",
+			zap.String("provider", provider),
+			zap.Uint("link_user_id", st.LinkUserID),
+			zap.String("ip", client.IP))
+		return nil, errors.New(errors.OIDCStateInvalid)
+	}
+
+	ext, err := p.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		logger.Warn("[user.CompleteOIDC] 换取ID Token失败", zap.String("provider", provider), zap.Error(err))
+		return nil, errors.New(errors.OIDCLoginFailed)
+	}
+
+	identity, err := s.repo.FindIdentity(provider, ext.Subject)
+	if err != nil {
+		logger.Error("[user.CompleteOIDC] 查询第三方身份失败", zap.Error(err))
+		return nil, errors.New(errors.ServerError)
+	}
+
+	if st.LinkUserID != 0 {
+		return s.linkIdentity(st, provider, ext, identity)
+	}
+	return s.loginWithIdentity(st, provider, ext, identity, client)
+}
+
+// loginWithIdentity 使用第三方身份登录（未绑定过的身份自动创建新用户）
+func (s *Service) loginWithIdentity(st oidcState, provider string, ext *ExternalIdentity, identity *Identity, client ClientInfo) (*OIDCResult, error) {
+	now := time.Now()
+	var user *Entity
+
+	if identity != nil {
+		u, err := s.repo.FindByID(identity.UserID)
+		if err != nil {
+			return nil, errors.New(errors.UserNotFound)
+		}
+		if err := s.CheckBan(u.ID, security.BanScopeFull); err != nil {
+			return nil, err
+		}
+		s.repo.UpdateLastLogin(u.ID)
+		s.repo.TouchIdentity(identity.ID, now)
+		s.cancelDeletion(u)
+		user = u
+	} else {
+		username, err := s.oidcUsername(ext)
+		if err != nil {
+			return nil, err
+		}
+		user = &Entity{
+			Username:    username,
+			Password:    "", // 仅通过第三方身份登录，无可用密码
+			Role:        security.RolePlayer,
+			CreatedAt:   now,
+			LastLoginAt: now,
+		}
+		identity = &Identity{
+			Provider:    provider,
+			Subject:     ext.Subject,
+			Email:       ext.Email,
+			LastLoginAt: now,
+		}
+		if err := s.repo.CreateUserWithIdentity(user, identity); err != nil {
+			logger.Error("[user.loginWithIdentity] 创建用户失败", zap.String("provider", provider), zap.Error(err))
+			return nil, errors.NewWithMessage(errors.ServerError, "创建用户失败")
+		}
+		logger.Info("[user.loginWithIdentity] 通过第三方身份创建用户",
+			zap.String("provider", provider),
+			zap.Uint("user_id", user.ID),
+			zap.String("username", user.Username))
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = provider
+	}
+	token, err := s.issueToken(user, client)
+	if err != nil {
+		logger.Error("[user.loginWithIdentity] 生成token失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return nil, errors.NewWithMessage(errors.ServerError, "生成token失败")
+	}
+
+	logger.Info("[user.loginWithIdentity] 第三方登录成功",
+		zap.String("provider", provider),
+		zap.Uint("user_id", user.ID))
+	return &OIDCResult{User: user, Identity: identity, Token: token, ReturnTo: st.ReturnTo}, nil
+}
+
+// linkIdentity 将第三方身份绑定到当前用户
+func (s *Service) linkIdentity(st oidcState, provider string, ext *ExternalIdentity, identity *Identity) (*OIDCResult, error) {
+	user, err := s.repo.FindByID(st.LinkUserID)
+	if err != nil {
+		return nil, errors.New(errors.UserNotFound)
+	}
+
+	if identity != nil {
+		if identity.UserID != user.ID {
+			logger.Warn("[user.linkIdentity] 第三方身份已绑定其他用户",
+				zap.String("provider", provider),
+				zap.Uint("user_id", user.ID))
+			return nil, errors.New(errors.IdentityLinked)
+		}
+		// 重复绑定同一身份视为成功
+		return &OIDCResult{User: user, Identity: identity, ReturnTo: st.ReturnTo, Linked: true}, nil
+	}
+
+	identities, err := s.repo.ListIdentities(user.ID)
+	if err != nil {
+		return nil, errors.New(errors.ServerError)
+	}
+	for _, existing := range identities {
+		if existing.Provider == provider {
+			return nil, errors.NewWithMessage(errors.IdentityLinked, "已绑定该登录方式的其他账号，请先解绑")
+		}
+	}
+
+	identity = &Identity{
+		UserID:      user.ID,
+		Provider:    provider,
+		Subject:     ext.Subject,
+		Email:       ext.Email,
+		LastLoginAt: time.Now(),
+	}
+	if err := s.repo.CreateIdentity(identity); err != nil {
+		logger.Error("[user.linkIdentity] 绑定第三方身份失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return nil, errors.NewWithMessage(errors.ServerError, "绑定失败")
+	}
+
+	logger.Warn("[audit] 绑定第三方身份",
+		zap.String("event", "identity_linked"),
+		zap.Uint("user_id", user.ID),
+		zap.String("provider", provider))
+	return &OIDCResult{User: user, Identity: identity, ReturnTo: st.ReturnTo, Linked: true}, nil
+}
+
+// ListIdentities 获取用户绑定的第三方身份
+func (s *Service) ListIdentities(userID uint) ([]*Identity, error) {
+	return s.repo.ListIdentities(userID)
+}
+
+// UnlinkIdentity 解绑第三方身份
+// 业务规则：没有密码的正式账号（仅通过第三方登录）至少保留一个身份，避免无法再登录
+func (s *Service) UnlinkIdentity(userID, identityID uint) error {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return errors.New(errors.UserNotFound)
+	}
+	identities, err := s.repo.ListIdentities(userID)
+	if err != nil {
+		return errors.New(errors.ServerError)
+	}
+
+	var target *Identity
+	for _, identity := range identities {
+		if identity.ID == identityID {
+			target = identity
+			break
+		}
+	}
+	if target == nil {
+		return errors.New(errors.IdentityNotFound)
+	}
+	if user.Password == "" && !user.IsGuest && len(identities) == 1 {
+		return errors.New(errors.LastLoginMethod)
+	}
+
+	if err := s.repo.DeleteIdentity(userID, identityID); err != nil {
+		logger.Error("[user.UnlinkIdentity] 解绑失败", zap.Uint("user_id", userID), zap.Error(err))
+		return errors.New(errors.ServerError)
+	}
+
+	logger.Warn("[audit] 解绑第三方身份",
+		zap.String("event", "identity_unlinked"),
+		zap.Uint("user_id", userID),
+		zap.String("provider", target.Provider))
+	return nil
+}
+
+// returnURLAllowed 跳转地址是否在允许的前缀列表中
+func (s *Service) returnURLAllowed(returnTo string) bool {
+	for _, prefix := range s.allowedReturnURLs {
+		if prefix != "" && strings.HasPrefix(returnTo, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcUsername 为第三方身份生成不重复的用户名
+// 依次尝试 preferred_username、邮箱前缀、姓名，被占用时追加随机后缀
+func (s *Service) oidcUsername(ext *ExternalIdentity) (string, error) {
+	base := ""
+	email := ext.Email
+	if i := strings.IndexByte(email, '@'); i >= 0 {
+		email = email[:i]
+	}
+	for _, candidate := range []string{ext.PreferredUsername, email, ext.Name} {
+		if base = sanitizeUsername(candidate); base != "" {
+			break
+		}
+	}
+	if base == "" || strings.HasPrefix(strings.ToLower(base), GuestUsernamePrefix) {
+		base = "player"
+	}
+
+	username := base
+	for i := 0; i < 5; i++ {
+		if exist, err := s.repo.FindByUsername(username); err != nil || exist == nil {
+			return username, nil
+		}
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			break
+		}
+		username = base + "_" + hex.EncodeToString(suffix)
+	}
+	return "", errors.NewWithMessage(errors.ServerError, "生成用户名失败")
+}
+
+// sanitizeUsername 只保留字母、数字、下划线、点和连字符，并截断长度
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-') {
+			b.WriteRune(r)
+		}
+		if b.Len() >= maxOIDCUsernameLength {
+			break
+		}
+	}
+	return b.String()
+}
+
+// oidcStateKey 登录请求状态缓存Key
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
@@ -0,0 +1,427 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"faulty_in_culture/go_back/internal/infra/logger"
+	"faulty_in_culture/go_back/internal/infra/oidc"
+	"faulty_in_culture/go_back/internal/infra/oidc/oidctest"
+	errcode "faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/shared/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 测试用的身份提供方配置
+const (
+	testProvider     = "mock"
+	testClientID     = "game-backend"
+	testClientSecret = "dev-secret"
+	testReturnPrefix = "mygame://auth"
+)
+
+// memoryCache 内存缓存（实现 Cache 接口）
+type memoryCache struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{data: make(map[string][]byte)}
+}
+
+func (c *memoryCache) Get(key string, dest interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	raw, ok := c.data[key]
+	if !ok {
+		return fmt.Errorf("键不存在: %s", key)
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+func (c *memoryCache) GetDel(key string, dest interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	raw, ok := c.data[key]
+	if !ok {
+		return fmt.Errorf("键不存在: %s", key)
+	}
+	delete(c.data, key)
+	return json.Unmarshal(raw, dest)
+}
+
+func (c *memoryCache) Set(key string, value interface{}, expiration time.Duration) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = raw
+	return nil
+}
+
+func (c *memoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+	return nil
+}
+
+// identityRepo 内存仓储，只实现第三方登录用到的方法（其他方法调用时panic）
+type identityRepo struct {
+	Repository
+
+	mu         sync.Mutex
+	users      map[uint]*Entity
+	identities []*Identity
+	nextID     uint
+}
+
+func newIdentityRepo() *identityRepo {
+	return &identityRepo{users: make(map[uint]*Entity), nextID: 1}
+}
+
+// addUser 创建测试用户
+func (r *identityRepo) addUser(username string) *Entity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := &Entity{ID: r.nextID, Username: username, Role: "player"}
+	r.users[u.ID] = u
+	r.nextID++
+	return u
+}
+
+func (r *identityRepo) FindByID(id uint) (*Entity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, fmt.Errorf("用户不存在")
+}
+
+func (r *identityRepo) FindByUsername(username string) (*Entity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("用户不存在")
+}
+
+func (r *identityRepo) UpdateLastLogin(userID uint) error                 { return nil }
+func (r *identityRepo) TouchIdentity(identityID uint, at time.Time) error { return nil }
+func (r *identityRepo) CreateSession(session *DeviceSession) error        { return nil }
+func (r *identityRepo) FindActiveBans(userID uint) ([]*Ban, error)        { return nil, nil }
+
+func (r *identityRepo) FindIdentity(provider, subject string) (*Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *identityRepo) ListIdentities(userID uint) ([]*Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []*Identity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			list = append(list, identity)
+		}
+	}
+	return list, nil
+}
+
+func (r *identityRepo) CreateIdentity(identity *Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *identityRepo) CreateUserWithIdentity(user *Entity, identity *Identity) error {
+	r.mu.Lock()
+	user.ID = r.nextID
+	r.users[user.ID] = user
+	r.nextID++
+	r.mu.Unlock()
+	identity.UserID = user.ID
+	return r.CreateIdentity(identity)
+}
+
+// stubTokens 固定格式的Token生成器
+type stubTokens struct{}
+
+func (stubTokens) Generate(userID uint, username, role, tokenID string) (string, error) {
+	return "token-" + strconv.FormatUint(uint64(userID), 10), nil
+}
+func (stubTokens) NewTokenID() (string, error) { return oidc.RandomString() }
+func (stubTokens) TTL() time.Duration          { return time.Hour }
+
+// oidcEnv 测试环境：模拟的身份提供方 + 只注册第三方登录路由的后端
+type oidcEnv struct {
+	idp  *httptest.Server
+	app  *httptest.Server
+	repo *identityRepo
+}
+
+// newOIDCEnv 启动模拟的身份提供方和后端
+func newOIDCEnv(t *testing.T) *oidcEnv {
+	t.Helper()
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+	gin.SetMode(gin.TestMode)
+
+	idp := httptest.NewServer(nil)
+	t.Cleanup(idp.Close)
+	mock, err := oidctest.NewServer(idp.URL, testClientID, testClientSecret)
+	if err != nil {
+		t.Fatalf("创建模拟身份提供方失败: %v", err)
+	}
+	idp.Config.Handler = mock
+
+	repo := newIdentityRepo()
+	svc := NewService(repo, nil, stubTokens{}, newMemoryCache(), nil, nil, nil)
+	h := NewHandler(svc)
+
+	router := gin.New()
+	router.GET("/api/auth/oidc/:provider/login", h.OIDCLogin)
+	router.GET("/api/auth/oidc/:provider/callback", h.OIDCCallback)
+	router.POST("/api/me/identities/:provider", func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.GetHeader("X-Test-User"), 10, 32) // 代替认证中间件
+		c.Set("user_id", uint(id))
+		h.LinkIdentity(c)
+	})
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+
+	svc.SetIdentityProviders(map[string]IdentityProvider{
+		testProvider: &OIDCProviderAdapter{provider: oidc.New(oidc.Config{
+			Name:         testProvider,
+			Issuer:       idp.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  app.URL + "/api/auth/oidc/" + testProvider + "/callback",
+		}, idp.Client())},
+	}, []string{testReturnPrefix})
+
+	return &oidcEnv{idp: idp, app: app, repo: repo}
+}
+
+// newBrowser 带Cookie的HTTP客户端，不自动跟随跳转
+func newBrowser(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("创建Cookie失败: %v", err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// beginLogin 在浏览器中发起第三方登录，返回身份提供方的授权地址
+func (e *oidcEnv) beginLogin(t *testing.T, browser *http.Client) string {
+	t.Helper()
+	resp, err := browser.Get(e.app.URL + "/api/auth/oidc/" + testProvider + "/login")
+	if err != nil {
+		t.Fatalf("发起登录失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("发起登录状态码 = %d, 期望 302", resp.StatusCode)
+	}
+	return resp.Header.Get("Location")
+}
+
+// beginLink 在浏览器中为用户发起绑定，返回身份提供方的授权地址
+func (e *oidcEnv) beginLink(t *testing.T, browser *http.Client, userID uint) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, e.app.URL+"/api/me/identities/"+testProvider, nil)
+	req.Header.Set("X-Test-User", strconv.FormatUint(uint64(userID), 10))
+	resp, err := browser.Do(req)
+	if err != nil {
+		t.Fatalf("发起绑定失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data OIDCAuthorizeVO `json:"data"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&body) != nil {
+		t.Fatalf("发起绑定状态码 = %d", resp.StatusCode)
+	}
+	return body.Data.AuthorizeURL
+}
+
+// authorize 在身份提供方以sub登录，返回跳转回后端的回调地址（nonce非空时替换授权请求中的nonce）
+func (e *oidcEnv) authorize(t *testing.T, authURL, sub, nonce string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("解析授权地址失败: %v", err)
+	}
+	form := u.Query()
+	form.Set("sub", sub)
+	form.Set("email", sub+"@example.com")
+	form.Set("name", sub)
+	if nonce != "" {
+		form.Set("nonce", nonce)
+	}
+
+	resp, err := newBrowser(t).PostForm(e.idp.URL+"/authorize", form)
+	if err != nil {
+		t.Fatalf("授权失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("授权状态码 = %d, 期望 302", resp.StatusCode)
+	}
+	return resp.Header.Get("Location")
+}
+
+// callback 在浏览器中打开回调地址，返回状态码和响应
+func callback(t *testing.T, browser *http.Client, callbackURL string) (int, response.Response) {
+	t.Helper()
+	resp, err := browser.Get(callbackURL)
+	if err != nil {
+		t.Fatalf("回调失败: %v", err)
+	}
+	defer resp.Body.Close()
+	var body response.Response
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+// TestOIDCLogin 第三方登录：成功登录后自动创建用户并绑定身份
+func TestOIDCLogin(t *testing.T) {
+	env := newOIDCEnv(t)
+	browser := newBrowser(t)
+
+	callbackURL := env.authorize(t, env.beginLogin(t, browser), "alice", "")
+	status, body := callback(t, browser, callbackURL)
+	if status != http.StatusOK {
+		t.Fatalf("回调状态码 = %d (%s), 期望 200", status, body.Message)
+	}
+	data, _ := body.Data.(map[string]interface{})
+	if token, _ := data["token"].(string); !strings.HasPrefix(token, "token-") {
+		t.Fatalf("未返回token: %v", body.Data)
+	}
+
+	identity, _ := env.repo.FindIdentity(testProvider, "alice")
+	if identity == nil {
+		t.Fatal("登录后未创建第三方身份")
+	}
+}
+
+// TestOIDCStateReuse 同一个state只能使用一次
+func TestOIDCStateReuse(t *testing.T) {
+	env := newOIDCEnv(t)
+	browser := newBrowser(t)
+
+	callbackURL := env.authorize(t, env.beginLogin(t, browser), "alice", "")
+	if status, body := callback(t, browser, callbackURL); status != http.StatusOK {
+		t.Fatalf("第一次回调状态码 = %d (%s), 期望 200", status, body.Message)
+	}
+	status, body := callback(t, browser, callbackURL)
+	if status != http.StatusBadRequest || body.Code != errcode.OIDCStateInvalid {
+		t.Fatalf("重复使用state: 状态码 = %d, 错误码 = %d, 期望 400/%d", status, body.Code, errcode.OIDCStateInvalid)
+	}
+}
+
+// TestOIDCOtherBrowser 回调必须来自发起登录的浏览器（防止登录CSRF）
+func TestOIDCOtherBrowser(t *testing.T) {
+	env := newOIDCEnv(t)
+
+	callbackURL := env.authorize(t, env.beginLogin(t, newBrowser(t)), "attacker", "")
+	status, body := callback(t, newBrowser(t), callbackURL)
+	if status != http.StatusBadRequest || body.Code != errcode.OIDCStateInvalid {
+		t.Fatalf("其他浏览器回调: 状态码 = %d, 错误码 = %d, 期望 400/%d", status, body.Code, errcode.OIDCStateInvalid)
+	}
+}
+
+// TestOIDCNonceMismatch ID Token中的nonce与登录请求不一致时拒绝
+func TestOIDCNonceMismatch(t *testing.T) {
+	env := newOIDCEnv(t)
+	browser := newBrowser(t)
+
+	callbackURL := env.authorize(t, env.beginLogin(t, browser), "alice", "forged-nonce")
+	status, body := callback(t, browser, callbackURL)
+	if status != http.StatusUnauthorized || body.Code != errcode.OIDCLoginFailed {
+		t.Fatalf("nonce不匹配: 状态码 = %d, 错误码 = %d, 期望 401/%d", status, body.Code, errcode.OIDCLoginFailed)
+	}
+	if identity, _ := env.repo.FindIdentity(testProvider, "alice"); identity != nil {
+		t.Fatal("nonce不匹配时不应创建第三方身份")
+	}
+}
+
+// TestOIDCReturnTo 只允许跳转回允许列表中的客户端地址
+func TestOIDCReturnTo(t *testing.T) {
+	env := newOIDCEnv(t)
+
+	tests := []struct {
+		returnTo string
+		status   int
+	}{
+		{testReturnPrefix + "/done", http.StatusFound},
+		{"https://evil.example.com/" + testReturnPrefix, http.StatusBadRequest},
+		{"mygame://other", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp, err := newBrowser(t).Get(env.app.URL + "/api/auth/oidc/" + testProvider + "/login?return_to=" + url.QueryEscape(tt.returnTo))
+		if err != nil {
+			t.Fatalf("发起登录失败: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("return_to=%s: 状态码 = %d, 期望 %d", tt.returnTo, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+// TestOIDCLinkAlreadyLinked 绑定已绑定其他用户的第三方身份时返回409
+func TestOIDCLinkAlreadyLinked(t *testing.T) {
+	env := newOIDCEnv(t)
+	owner := env.repo.addUser("owner")
+	other := env.repo.addUser("other")
+
+	browser := newBrowser(t)
+	status, body := callback(t, browser, env.authorize(t, env.beginLink(t, browser, owner.ID), "alice", ""))
+	if status != http.StatusOK {
+		t.Fatalf("第一次绑定状态码 = %d (%s), 期望 200", status, body.Message)
+	}
+
+	browser = newBrowser(t)
+	status, body = callback(t, browser, env.authorize(t, env.beginLink(t, browser, other.ID), "alice", ""))
+	if status != http.StatusConflict || body.Code != errcode.IdentityLinked {
+		t.Fatalf("重复绑定: 状态码 = %d, 错误码 = %d, 期望 409/%d", status, body.Code, errcode.IdentityLinked)
+	}
+	if identity, _ := env.repo.FindIdentity(testProvider, "alice"); identity == nil || identity.UserID != owner.ID {
+		t.Fatal("第三方身份不应改为绑定到其他用户")
+	}
+}
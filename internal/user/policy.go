@@ -0,0 +1,146 @@
+// Package user - 用户模块密码策略
+// 功能：校验密码强度（长度、字符类别、常见弱密码、与用户名相似度）
+package user
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"faulty_in_culture/go_back/internal/infra/config"
+	"faulty_in_culture/go_back/internal/shared/errors"
+)
+
+// ============================================================
+// 密码策略 (Password Policy)
+// 设计模式：策略对象 - 将密码规则集中管理，注册、改密、重置共用
+// ============================================================
+
+// commonPasswords 内置常见弱密码黑名单（小写）
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "111111", "000000",
+	"password", "password1", "password123", "passw0rd", "qwerty", "qwerty123",
+	"abc123", "abcd1234", "a1b2c3d4", "iloveyou", "admin", "admin123",
+	"welcome", "letmein", "monkey", "dragon", "football", "baseball",
+	"1q2w3e4r", "1qaz2wsx", "zxcvbnm", "asdfghjkl", "woaini", "woaini1314",
+}
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	minLength      int
+	maxLength      int
+	minCharClasses int
+	checkUsername  bool
+	blacklist      map[string]struct{}
+}
+
+// NewPasswordPolicy 根据配置创建密码策略（未配置的项使用默认值）
+func NewPasswordPolicy() *PasswordPolicy {
+	cfg := config.GlobalConfig.Password
+
+	p := &PasswordPolicy{
+		minLength:      cfg.MinLength,
+		maxLength:      cfg.MaxLength,
+		minCharClasses: cfg.MinCharClasses,
+		checkUsername:  cfg.CheckUsername,
+		blacklist:      make(map[string]struct{}),
+	}
+	if p.minLength <= 0 {
+		p.minLength = 8
+	}
+	if p.maxLength <= 0 {
+		p.maxLength = 72 // bcrypt 最多处理72字节
+	}
+	if p.minCharClasses <= 0 {
+		p.minCharClasses = 2
+	}
+
+	for _, pw := range commonPasswords {
+		p.blacklist[pw] = struct{}{}
+	}
+	for _, pw := range cfg.Blacklist {
+		p.blacklist[strings.ToLower(pw)] = struct{}{}
+	}
+	return p
+}
+
+// Validate 校验密码是否符合策略，不符合时返回 WeakPassword 错误（消息说明原因）
+func (p *PasswordPolicy) Validate(username, password string) error {
+	// 1. 长度
+	if n := len([]rune(password)); n < p.minLength {
+		return errors.NewWithMessage(errors.WeakPassword, fmt.Sprintf("密码长度不能少于%d位", p.minLength))
+	}
+	if len(password) > p.maxLength {
+		return errors.NewWithMessage(errors.WeakPassword, fmt.Sprintf("密码长度不能超过%d字节", p.maxLength))
+	}
+
+	// 2. 字符类别（小写、大写、数字、符号）
+	if classes := charClasses(password); classes < p.minCharClasses {
+		return errors.NewWithMessage(errors.WeakPassword,
+			fmt.Sprintf("密码需至少包含小写字母、大写字母、数字、符号中的%d类", p.minCharClasses))
+	}
+
+	// 3. 常见弱密码
+	lower := strings.ToLower(password)
+	if _, ok := p.blacklist[lower]; ok {
+		return errors.NewWithMessage(errors.WeakPassword, "密码过于常见，请更换")
+	}
+
+	// 4. 与用户名相似
+	if p.checkUsername && username != "" {
+		name := strings.ToLower(username)
+		if strings.Contains(lower, name) || strings.Contains(name, lower) || levenshtein(lower, name) <= 3 {
+			return errors.NewWithMessage(errors.WeakPassword, "密码不能与用户名相同或过于相似")
+		}
+	}
+
+	return nil
+}
+
+// charClasses 统计密码包含的字符类别数
+func charClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, has := range []bool{lower, upper, digit, symbol} {
+		if has {
+			count++
+		}
+	}
+	return count
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
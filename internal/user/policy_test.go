@@ -0,0 +1,82 @@
+package user
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"faulty_in_culture/go_back/internal/infra/config"
+	"faulty_in_culture/go_back/internal/shared/errors"
+)
+
+// newTestPolicy 按给定配置创建密码策略
+func newTestPolicy(t *testing.T, cfg config.PasswordConfig) *PasswordPolicy {
+	t.Helper()
+	old := config.GlobalConfig.Password
+	config.GlobalConfig.Password = cfg
+	t.Cleanup(func() { config.GlobalConfig.Password = old })
+	return NewPasswordPolicy()
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := newTestPolicy(t, config.PasswordConfig{
+		CheckUsername: true,
+		Blacklist:     []string{"Summer2024!"},
+	})
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  bool
+	}{
+		{"合格", "player1", "Tr0ub4dor&3", false},
+		{"过短", "player1", "Ab1!", true},
+		{"刚好最小长度", "player1", "abcd123x", false},
+		{"按字符计算长度", "player1", "密码密码密码密1", false},
+		{"超过最大字节数", "player1", "a1" + strings.Repeat("x", 71), true},
+		{"只有一类字符", "player1", "abcdefghij", true},
+		{"两类字符", "player1", "abcdefgh12", false},
+		{"内置弱密码", "player1", "password123", true},
+		{"弱密码忽略大小写", "player1", "PassWord123", true},
+		{"配置的弱密码", "player1", "summer2024!", true},
+		{"包含用户名", "player1", "Player1-2024", true},
+		{"与用户名相近", "dragonslayer", "dragonslay3r!", true},
+		{"不检查空用户名", "", "Tr0ub4dor&3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.username, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate(%q, %q) err = %v, wantErr %v", tt.username, tt.password, err, tt.wantErr)
+			}
+			var appErr *errors.Error
+			if err != nil && (!stderrors.As(err, &appErr) || appErr.Code != errors.WeakPassword) {
+				t.Errorf("Validate() err = %v, 期望 WeakPassword", err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.PasswordConfig
+		username string
+		password string
+		wantErr  bool
+	}{
+		{"提高最小长度", config.PasswordConfig{MinLength: 12}, "player1", "Tr0ub4dor&3", true},
+		{"降低最大长度", config.PasswordConfig{MaxLength: 10}, "player1", "Tr0ub4dor&3", true},
+		{"要求四类字符", config.PasswordConfig{MinCharClasses: 4}, "player1", "Troubador33", true},
+		{"不检查用户名", config.PasswordConfig{}, "player1", "Player1-2024", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newTestPolicy(t, tt.cfg)
+			if err := policy.Validate(tt.username, tt.password); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q, %q) err = %v, wantErr %v", tt.username, tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
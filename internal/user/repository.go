@@ -5,6 +5,7 @@ package user
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -37,6 +38,70 @@ type Repository interface {
 	UpdateLastLogin(userID uint) error
 	// FindByIDs 批量查询用户（优化N+1查询）
 	FindByIDs(userIDs []uint) ([]*Entity, error)
+	// Search 按用户名前缀搜索用户（管理后台），返回当前页和总数
+	Search(keyword string, offset, limit int) ([]*Entity, int64, error)
+	// UpdateRole 更新用户角色
+	UpdateRole(userID uint, role string) error
+	// UpdateRoleByUsernames 批量设置指定用户名的角色，返回实际更新的数量
+	UpdateRoleByUsernames(usernames []string, role string) (int64, error)
+
+	// CreateSession 创建登录会话
+	CreateSession(session *DeviceSession) error
+	// FindSessionByTokenID 根据Token ID查找登录会话
+	FindSessionByTokenID(tokenID string) (*DeviceSession, error)
+	// ListActiveSessions 获取用户未过期的登录会话
+	ListActiveSessions(userID uint) ([]*DeviceSession, error)
+	// FindSessionByID 根据ID查找用户的登录会话
+	FindSessionByID(userID, sessionID uint) (*DeviceSession, error)
+	// DeleteSession 删除登录会话
+	DeleteSession(sessionID uint) error
+	// TouchSession 更新会话最后活跃时间，返回更新的行数（会话已删除时为0）
+	TouchSession(sessionID uint, lastSeenAt time.Time) (int64, error)
+
+	// UpdatePassword 更新密码哈希
+	UpdatePassword(userID uint, hash string) error
+	// CreateResetToken 创建密码重置令牌
+	CreateResetToken(token *PasswordResetToken) error
+	// ConsumeResetToken 消费密码重置令牌（仅未使用且未过期的令牌可消费一次）
+	ConsumeResetToken(tokenHash string) (*PasswordResetToken, error)
+
+	// FindGuestByDeviceID 根据设备ID查找游客账号
+	FindGuestByDeviceID(deviceID string) (*Entity, error)
+	// FindInactiveGuestIDs 查找在截止时间之后没有任何活动的游客ID
+	FindInactiveGuestIDs(cutoff time.Time, limit int) ([]uint, error)
+	// DeleteUsersCascade 在一个事务中执行各模块的清理钩子并删除用户及其关联数据
+	DeleteUsersCascade(userIDs []uint, hooks []PurgeHook) error
+	// ScheduleDeletion 设置或清除（at为nil）账号计划删除时间
+	ScheduleDeletion(userID uint, at *time.Time) error
+	// FindUsersDueForDeletion 查找计划删除时间已到的用户ID
+	FindUsersDueForDeletion(now time.Time, limit int) ([]uint, error)
+
+	// FindIdentity 根据身份提供方和sub查找第三方身份（不存在时返回nil, nil）
+	FindIdentity(provider, subject string) (*Identity, error)
+	// ListIdentities 获取用户绑定的第三方身份
+	ListIdentities(userID uint) ([]*Identity, error)
+	// CreateIdentity 绑定第三方身份
+	CreateIdentity(identity *Identity) error
+	// CreateUserWithIdentity 在一个事务中创建用户及其第三方身份
+	CreateUserWithIdentity(user *Entity, identity *Identity) error
+	// DeleteIdentity 解绑用户的第三方身份
+	DeleteIdentity(userID, identityID uint) error
+	// TouchIdentity 更新第三方身份最后登录时间
+	TouchIdentity(identityID uint, at time.Time) error
+
+	// FindProfile 查找用户资料（不存在时返回nil, nil）
+	FindProfile(userID uint) (*Profile, error)
+	// FindProfilesByUserIDs 批量查询用户资料
+	FindProfilesByUserIDs(userIDs []uint) ([]*Profile, error)
+	// SaveProfile 创建或更新用户资料
+	SaveProfile(profile *Profile) error
+
+	// CreateBan 创建封禁记录
+	CreateBan(ban *Ban) error
+	// FindActiveBans 查找用户当前生效的所有封禁
+	FindActiveBans(userID uint) ([]*Ban, error)
+	// RevokeActiveBans 解除用户生效中的封禁（scope为空时解除全部范围），返回解除的数量
+	RevokeActiveBans(userID, revokedBy uint, scope string) (int64, error)
 }
 
 // repositoryImpl Repository的GORM实现
@@ -105,3 +170,303 @@ func (r *repositoryImpl) FindByIDs(userIDs []uint) ([]*Entity, error) {
 	}
 	return users, nil
 }
+
+// Search 按用户名前缀搜索用户（关键字为空时返回全部，按ID倒序）
+func (r *repositoryImpl) Search(keyword string, offset, limit int) ([]*Entity, int64, error) {
+	query := r.db.Model(&Entity{})
+	if keyword != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword)
+		query = query.Where("username LIKE ?", escaped+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []*Entity
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// UpdateRole 更新用户角色
+func (r *repositoryImpl) UpdateRole(userID uint, role string) error {
+	return r.db.Model(&Entity{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+// UpdateRoleByUsernames 批量设置指定用户名的角色
+func (r *repositoryImpl) UpdateRoleByUsernames(usernames []string, role string) (int64, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(&Entity{}).
+		Where("username IN ? AND role <> ?", usernames, role).
+		Update("role", role)
+	return result.RowsAffected, result.Error
+}
+
+// CreateSession 创建登录会话
+func (r *repositoryImpl) CreateSession(session *DeviceSession) error {
+	return r.db.Create(session).Error
+}
+
+// FindSessionByTokenID 根据Token ID查找登录会话
+func (r *repositoryImpl) FindSessionByTokenID(tokenID string) (*DeviceSession, error) {
+	var session DeviceSession
+	err := r.db.Where("token_id = ?", tokenID).First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("登录会话不存在")
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActiveSessions 获取用户未过期的登录会话（按最后活跃时间倒序）
+func (r *repositoryImpl) ListActiveSessions(userID uint) ([]*DeviceSession, error) {
+	var sessions []*DeviceSession
+	err := r.db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindSessionByID 根据ID查找用户的登录会话
+func (r *repositoryImpl) FindSessionByID(userID, sessionID uint) (*DeviceSession, error) {
+	var session DeviceSession
+	err := r.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("登录会话不存在")
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeleteSession 删除登录会话
+func (r *repositoryImpl) DeleteSession(sessionID uint) error {
+	return r.db.Delete(&DeviceSession{}, sessionID).Error
+}
+
+// TouchSession 更新会话最后活跃时间，返回更新的行数（会话已删除时为0）
+func (r *repositoryImpl) TouchSession(sessionID uint, lastSeenAt time.Time) (int64, error) {
+	result := r.db.Model(&DeviceSession{}).Where("id = ?", sessionID).
+		Update("last_seen_at", lastSeenAt)
+	return result.RowsAffected, result.Error
+}
+
+// UpdatePassword 更新密码哈希
+func (r *repositoryImpl) UpdatePassword(userID uint, hash string) error {
+	return r.db.Model(&Entity{}).Where("id = ?", userID).
+		Update("password", hash).Error
+}
+
+// CreateResetToken 创建密码重置令牌
+func (r *repositoryImpl) CreateResetToken(token *PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// ConsumeResetToken 消费密码重置令牌
+// 使用条件更新保证并发下同一令牌只能被消费一次
+func (r *repositoryImpl) ConsumeResetToken(tokenHash string) (*PasswordResetToken, error) {
+	var token PasswordResetToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&PasswordResetToken{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("重置令牌无效或已过期")
+		}
+		return tx.Where("token_hash = ?", tokenHash).First(&token).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindGuestByDeviceID 根据设备ID查找游客账号
+func (r *repositoryImpl) FindGuestByDeviceID(deviceID string) (*Entity, error) {
+	var user Entity
+	err := r.db.Where("device_id = ? AND is_guest = ?", deviceID, true).First(&user).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("用户不存在")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindInactiveGuestIDs 查找不活跃的游客ID
+// 不活跃：最后登录时间早于截止时间，且没有在截止时间之后活跃过的登录会话
+func (r *repositoryImpl) FindInactiveGuestIDs(cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&Entity{}).
+		Where("is_guest = ? AND last_login_at < ?", true, cutoff).
+		Where("NOT EXISTS (?)", r.db.Model(&DeviceSession{}).
+			Select("1").
+			Where("user_sessions.user_id = users.id AND user_sessions.last_seen_at >= ?", cutoff)).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// PurgeHook 删除用户时清理其他模块中该用户的数据，在删除用户的事务中执行（返回错误时整个删除回滚）
+type PurgeHook func(tx *gorm.DB, userIDs []uint) error
+
+// cascadeTables 用户模块中关联用户的表（其他模块的数据由各模块注册的 PurgeHook 清理）
+// 注意：GORM AutoMigrate 不会创建 ON DELETE CASCADE 外键，删除用户时需显式清理
+var cascadeTables = []string{
+	"user_profiles",
+	"user_sessions",
+	"password_reset_tokens",
+	"user_bans",
+	"user_identities",
+}
+
+// DeleteUsersCascade 在一个事务中依次执行各模块的清理钩子，再删除用户及其关联数据
+func (r *repositoryImpl) DeleteUsersCascade(userIDs []uint, hooks []PurgeHook) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, hook := range hooks {
+			if err := hook(tx, userIDs); err != nil {
+				return err
+			}
+		}
+		for _, table := range cascadeTables {
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id IN ?", table), userIDs).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN ?", userIDs).Delete(&Entity{}).Error
+	})
+}
+
+// ScheduleDeletion 设置或清除（at为nil）账号计划删除时间
+func (r *repositoryImpl) ScheduleDeletion(userID uint, at *time.Time) error {
+	return r.db.Model(&Entity{}).Where("id = ?", userID).Update("deletion_scheduled_at", at).Error
+}
+
+// FindUsersDueForDeletion 查找计划删除时间已到的用户ID
+func (r *repositoryImpl) FindUsersDueForDeletion(now time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&Entity{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// FindIdentity 根据身份提供方和sub查找第三方身份（不存在时返回nil, nil）
+func (r *repositoryImpl) FindIdentity(provider, subject string) (*Identity, error) {
+	var identity Identity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ListIdentities 获取用户绑定的第三方身份
+func (r *repositoryImpl) ListIdentities(userID uint) ([]*Identity, error) {
+	var identities []*Identity
+	err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&identities).Error
+	return identities, err
+}
+
+// CreateIdentity 绑定第三方身份
+func (r *repositoryImpl) CreateIdentity(identity *Identity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateUserWithIdentity 在一个事务中创建用户及其第三方身份
+func (r *repositoryImpl) CreateUserWithIdentity(user *Entity, identity *Identity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// DeleteIdentity 解绑用户的第三方身份
+func (r *repositoryImpl) DeleteIdentity(userID, identityID uint) error {
+	return r.db.Where("id = ? AND user_id = ?", identityID, userID).Delete(&Identity{}).Error
+}
+
+// TouchIdentity 更新第三方身份最后登录时间
+func (r *repositoryImpl) TouchIdentity(identityID uint, at time.Time) error {
+	return r.db.Model(&Identity{}).Where("id = ?", identityID).Update("last_login_at", at).Error
+}
+
+// FindProfile 查找用户资料（不存在时返回nil, nil）
+func (r *repositoryImpl) FindProfile(userID uint) (*Profile, error) {
+	var profile Profile
+	err := r.db.Where("user_id = ?", userID).First(&profile).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// FindProfilesByUserIDs 批量查询用户资料
+func (r *repositoryImpl) FindProfilesByUserIDs(userIDs []uint) ([]*Profile, error) {
+	if len(userIDs) == 0 {
+		return []*Profile{}, nil
+	}
+
+	var profiles []*Profile
+	err := r.db.Where("user_id IN ?", userIDs).Find(&profiles).Error
+	return profiles, err
+}
+
+// SaveProfile 创建或更新用户资料（主键为user_id）
+func (r *repositoryImpl) SaveProfile(profile *Profile) error {
+	return r.db.Save(profile).Error
+}
+
+// CreateBan 创建封禁记录
+func (r *repositoryImpl) CreateBan(ban *Ban) error {
+	return r.db.Create(ban).Error
+}
+
+// FindActiveBans 查找用户当前生效的所有封禁（永久封禁在前，其余按到期时间倒序）
+func (r *repositoryImpl) FindActiveBans(userID uint) ([]*Ban, error) {
+	var bans []*Ban
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Order("expires_at IS NULL DESC, expires_at DESC").
+		Find(&bans).Error
+	return bans, err
+}
+
+// RevokeActiveBans 解除用户生效中的封禁
+func (r *repositoryImpl) RevokeActiveBans(userID, revokedBy uint, scope string) (int64, error) {
+	now := time.Now()
+	query := r.db.Model(&Ban{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, now)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	result := query.Updates(map[string]interface{}{"revoked_at": now, "revoked_by": revokedBy})
+	return result.RowsAffected, result.Error
+}
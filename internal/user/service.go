@@ -1,11 +1,22 @@
 ﻿package user
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"faulty_in_culture/go_back/internal/infra/logger"
 	"faulty_in_culture/go_back/internal/shared/errors"
+	"faulty_in_culture/go_back/internal/shared/security"
 
 	"go.uber.org/zap"
 )
@@ -25,48 +36,167 @@ import (
 type PasswordHasher interface {
 	Hash(password string) (string, error)
 	Check(password, hash string) bool
+	// NeedsRehash 已存储的哈希是否弱于当前配置（需要登录时升级）
+	NeedsRehash(hash string) bool
 }
 
 // TokenGenerator Token生成器接口
 type TokenGenerator interface {
-	Generate(userID uint, username string) (string, error)
+	Generate(userID uint, username, role, tokenID string) (string, error)
+	NewTokenID() (string, error)
+	TTL() time.Duration
+}
+
+// LoginLimiter 登录限流器接口（防暴力破解）
+// 按用户名和IP分别统计滑动窗口内的失败次数
+type LoginLimiter interface {
+	// Check 检查用户名/IP当前是否处于退避或锁定期
+	Check(username, ip string) (LoginStatus, error)
+	// Reserve 原子地检查锁定/退避并预占一次尝试（预占的尝试先计入失败次数），被拒绝时返回nil和Blocked()的状态
+	Reserve(username, ip string) (*LoginAttempt, LoginStatus, error)
+	// Fail 把预占的尝试确认为失败，返回记录后的状态
+	Fail(attempt *LoginAttempt) (LoginStatus, error)
+	// Release 登录成功后撤销预占的尝试，并清除该用户名的失败记录
+	Release(attempt *LoginAttempt) error
+	// RecordFailure 记录一次登录失败，返回记录后的状态
+	RecordFailure(username, ip string) (LoginStatus, error)
+	// Reset 登录成功后清除该用户名的失败记录
+	Reset(username string) error
+}
+
+// LoginAttempt 预占的登录尝试（由Reserve返回，用于确认失败或撤销）
+type LoginAttempt struct {
+	Username string
+	IP       string
+	ID       string // 在失败次数窗口中的成员
+}
+
+// LoginStatus 登录限流状态
+type LoginStatus struct {
+	Failures        int64         // 窗口内该用户名的失败次数
+	Locked          bool          // 是否已锁定（达到最大失败次数）
+	RetryAfter      time.Duration // 需要等待的时间（退避或锁定剩余时间）
+	CaptchaRequired bool          // 是否需要验证码
+}
+
+// Blocked 当前是否禁止登录尝试
+func (st LoginStatus) Blocked() bool {
+	return st.RetryAfter > 0
+}
+
+// LoginError 登录失败错误（携带限流状态，供Handler设置Retry-After头和验证码标记）
+type LoginError struct {
+	Code   int
+	Status LoginStatus
+}
+
+// Error 实现error接口
+func (e *LoginError) Error() string {
+	return errors.GetMessage(e.Code)
 }
 
 // Cache 缓存接口
 type Cache interface {
 	Get(key string, dest interface{}) error
+	GetDel(key string, dest interface{}) error
 	Set(key string, value interface{}, expiration time.Duration) error
 	Delete(key string) error
 }
 
+// BlobStorage 文件存储接口（头像等上传文件）
+type BlobStorage interface {
+	Put(key string, r io.Reader, contentType string) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+// ConnectionKicker 实时连接管理接口（全部封禁时断开用户的WebSocket连接，禁言时只推送通知）
+type ConnectionKicker interface {
+	// KickUser 向用户的所有连接发送最后一条消息后断开，返回断开的连接数
+	KickUser(userID uint, message interface{}) int
+	// SendToUser 向用户的所有连接推送消息
+	SendToUser(userID uint, message interface{}) error
+}
+
 // Service 用户业务服务
 type Service struct {
-	repo           Repository     // 用户仓储
-	passwordHasher PasswordHasher // 密码哈希器
-	tokenGen       TokenGenerator // Token生成器
-	cache          Cache          // 缓存
+	repo           Repository           // 用户仓储
+	passwordHasher PasswordHasher       // 密码哈希器
+	tokenGen       TokenGenerator       // Token生成器
+	cache          Cache                // 缓存
+	limiter        LoginLimiter         // 登录限流器（可为nil）
+	policy         *PasswordPolicy      // 密码策略
+	storage        BlobStorage          // 文件存储（可为nil，此时不支持上传头像）
+	kicker         ConnectionKicker     // 实时连接管理（可为nil）
+	deletionGrace  time.Duration        // 注销账号的宽限期
+	onDeleted      func(userIDs []uint) // 用户数据删除后的回调（清理数据库之外的数据，可为nil）
+	purgeHooks     []PurgeHook          // 删除用户时各模块清理自己数据的钩子
+
+	identityProviders map[string]IdentityProvider // 第三方登录身份提供方（按名称）
+	allowedReturnURLs []string                    // 第三方登录完成后允许跳转回的客户端地址前缀
+
+	avatarPresets int      // 预设头像数量（preset_1 ~ preset_N）
+	avatarHosts   []string // 资料中允许引用的外部头像图片主机（只允许https）
 }
 
 // NewService 创建用户服务实例
 // 设计模式：依赖注入 (Dependency Injection)
 // 通过构造函数注入依赖，便于测试和解耦
-func NewService(repo Repository, hasher PasswordHasher, tokenGen TokenGenerator, cache Cache) *Service {
+func NewService(repo Repository, hasher PasswordHasher, tokenGen TokenGenerator, cache Cache, limiter LoginLimiter, policy *PasswordPolicy, storage BlobStorage) *Service {
 	return &Service{
 		repo:           repo,
 		passwordHasher: hasher,
 		tokenGen:       tokenGen,
 		cache:          cache,
+		limiter:        limiter,
+		policy:         policy,
+		storage:        storage,
+		deletionGrace:  defaultDeletionGrace,
+	}
+}
+
+// SetConnectionKicker 注入实时连接管理器（WebSocket管理器在用户服务之后创建）
+func (s *Service) SetConnectionKicker(kicker ConnectionKicker) {
+	s.kicker = kicker
+}
+
+// SetDeletionHook 设置用户数据删除后的回调（例如从Redis排行榜中移除）
+func (s *Service) SetDeletionHook(fn func(userIDs []uint)) {
+	s.onDeleted = fn
+}
+
+// AddPurgeHook 注册删除用户时清理其他模块数据的钩子（按注册顺序在删除用户的事务中执行）
+func (s *Service) AddPurgeHook(hook PurgeHook) {
+	s.purgeHooks = append(s.purgeHooks, hook)
+}
+
+// SetDeletionGrace 设置注销账号的宽限期（不大于0时保持默认值）
+func (s *Service) SetDeletionGrace(grace time.Duration) {
+	if grace > 0 {
+		s.deletionGrace = grace
 	}
 }
 
 // Register 用户注册业务逻辑
 // 业务规则：
 // 1. 用户名不能重复
-// 2. 密码必须加密存储
+// 2. 密码必须符合密码策略并加密存储
 // 3. 自动设置创建时间和登录时间
-func (s *Service) Register(username, password string) (*Entity, string, error) {
+func (s *Service) Register(username, password string, client ClientInfo) (*Entity, string, error) {
 	logger.Info("[user.Register] 开始注册", zap.String("username", username))
-	
+
+	// 游客用户名前缀为保留前缀
+	if strings.HasPrefix(strings.ToLower(username), GuestUsernamePrefix) {
+		logger.Warn("[user.Register] 用户名使用了保留前缀", zap.String("username", username))
+		return nil, "", errors.NewWithMessage(errors.InvalidParams, "用户名不能以"+GuestUsernamePrefix+"开头")
+	}
+
+	// 校验密码策略
+	if err := s.policy.Validate(username, password); err != nil {
+		logger.Warn("[user.Register] 密码不符合策略", zap.String("username", username), zap.Error(err))
+		return nil, "", err
+	}
+
 	// 检查用户名是否存在
 	existUser, err := s.repo.FindByUsername(username)
 	if err == nil && existUser != nil {
@@ -94,6 +224,7 @@ func (s *Service) Register(username, password string) (*Entity, string, error) {
 	user := &Entity{
 		Username:    username,
 		Password:    hash,
+		Role:        security.RolePlayer,
 		CreatedAt:   now,
 		LastLoginAt: now,
 	}
@@ -105,8 +236,8 @@ func (s *Service) Register(username, password string) (*Entity, string, error) {
 	}
 	logger.Info("[user.Register] 用户创建成功", zap.String("username", username), zap.Uint("user_id", user.ID))
 
-	// 生成Token
-	token, err := s.tokenGen.Generate(user.ID, user.Username)
+	// 生成Token并记录登录会话
+	token, err := s.issueToken(user, client)
 	if err != nil {
 		logger.Error("[user.Register] 生成token失败", zap.Uint("user_id", user.ID), zap.Error(err))
 		return nil, "", fmt.Errorf("生成token失败: %w", err)
@@ -121,14 +252,32 @@ func (s *Service) Register(username, password string) (*Entity, string, error) {
 // 1. 验证用户名和密码
 // 2. 更新最后登录时间
 // 3. 密码验证不使用缓存，确保安全性
-func (s *Service) Login(username, password string) (*Entity, string, error) {
+// 4. 每次登录创建一条设备会话记录
+// 5. 连续失败触发指数退避和临时锁定（按用户名和IP统计）
+func (s *Service) Login(username, password string, client ClientInfo) (*Entity, string, error) {
 	logger.Info("[user.Login] 开始登录", zap.String("username", username))
-	
+
+	// 检查是否处于退避或锁定期，并在验证密码前预占本次尝试（防止并发请求绕过失败次数上限）
+	var attempt *LoginAttempt
+	if s.limiter != nil {
+		reserved, status, err := s.limiter.Reserve(username, client.IP)
+		if err != nil {
+			logger.Error("[user.Login] 登录限流检查失败", zap.String("username", username), zap.Error(err))
+		} else if status.Blocked() {
+			logger.Warn("[user.Login] 登录尝试过于频繁",
+				zap.String("username", username),
+				zap.String("ip", client.IP),
+				zap.Duration("retry_after", status.RetryAfter))
+			return nil, "", loginBlockedError(status)
+		}
+		attempt = reserved
+	}
+
 	// 从数据库查询用户（不使用缓存，因为需要验证密码hash）
 	user, err := s.repo.FindByUsername(username)
 	if err != nil {
 		logger.Warn("[user.Login] 用户不存在", zap.String("username", username))
-		return nil, "", errors.New(errors.InvalidPassword) // 不暴露用户是否存在
+		return nil, "", s.loginFailed(attempt) // 不暴露用户是否存在
 	}
 
 	// 安全截取密码hash用于日志
@@ -145,17 +294,37 @@ func (s *Service) Login(username, password string) (*Entity, string, error) {
 	if !s.passwordHasher.Check(password, user.Password) {
 		logger.Warn("[user.Login] 密码验证失败",
 			zap.String("username", username))
-		return nil, "", errors.New(errors.InvalidPassword)
+		return nil, "", s.loginFailed(attempt)
 	}
 
 	logger.Info("[user.Login] 密码验证成功", zap.String("username", username))
 
+	// 撤销预占并清除失败记录
+	if attempt != nil {
+		if err := s.limiter.Release(attempt); err != nil {
+			logger.Warn("[user.Login] 清除失败记录失败", zap.String("username", username), zap.Error(err))
+		}
+	}
+
+	// 密码正确后再检查封禁，避免向猜测密码的人暴露封禁状态
+	if err := s.CheckBan(user.ID, security.BanScopeFull); err != nil {
+		return nil, "", err
+	}
+
+	// 哈希算法或参数已升级时，使用明文密码透明地重新计算哈希
+	if s.passwordHasher.NeedsRehash(user.Password) {
+		s.rehashPassword(user, password)
+	}
+
 	// 更新最后登录时间
 	s.repo.UpdateLastLogin(user.ID)
 	logger.Info("[user.Login] 更新登录时间", zap.Uint("user_id", user.ID))
 
-	// 生成Token
-	token, err := s.tokenGen.Generate(user.ID, user.Username)
+	// 宽限期内重新登录视为撤销注销
+	s.cancelDeletion(user)
+
+	// 生成Token并记录登录会话
+	token, err := s.issueToken(user, client)
 	if err != nil {
 		logger.Error("[user.Login] 生成token失败", zap.Uint("user_id", user.ID), zap.Error(err))
 		return nil, "", fmt.Errorf("生成token失败: %w", err)
@@ -165,6 +334,46 @@ func (s *Service) Login(username, password string) (*Entity, string, error) {
 	return user, token, nil
 }
 
+// rehashPassword 按当前哈希配置重新计算密码哈希（失败不影响登录）
+func (s *Service) rehashPassword(user *Entity, password string) {
+	hash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		logger.Warn("[user.rehashPassword] 重新计算哈希失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return
+	}
+	if err := s.repo.UpdatePassword(user.ID, hash); err != nil {
+		logger.Warn("[user.rehashPassword] 保存新哈希失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return
+	}
+	user.Password = hash
+	logger.Info("[user.rehashPassword] 密码哈希已升级", zap.Uint("user_id", user.ID))
+}
+
+// loginFailed 把预占的尝试确认为失败并构造返回给调用方的错误（attempt为nil表示未限流）
+func (s *Service) loginFailed(attempt *LoginAttempt) error {
+	if attempt == nil {
+		return errors.New(errors.InvalidPassword)
+	}
+
+	status, err := s.limiter.Fail(attempt)
+	if err != nil {
+		logger.Error("[user.loginFailed] 记录登录失败次数失败", zap.String("username", attempt.Username), zap.Error(err))
+		return errors.New(errors.InvalidPassword)
+	}
+	if status.Locked {
+		return loginBlockedError(status)
+	}
+	return &LoginError{Code: errors.InvalidPassword, Status: status}
+}
+
+// loginBlockedError 根据限流状态构造锁定/退避错误
+func loginBlockedError(status LoginStatus) error {
+	if status.Locked {
+		return &LoginError{Code: errors.AccountLocked, Status: status}
+	}
+	return &LoginError{Code: errors.LoginTooFrequent, Status: status}
+}
+
 // GetUsername 根据用户ID获取用户名
 func (s *Service) GetUsername(userID uint) (string, error) {
 	logger.Debug("[user.GetUsername] 获取用户名", zap.Uint("user_id", userID))
@@ -177,19 +386,1241 @@ func (s *Service) GetUsername(userID uint) (string, error) {
 	return user.Username, nil
 }
 
-// GetUsernames 批量获取用户名
-func (s *Service) GetUsernames(userIDs []uint) (map[uint]string, error) {
-	logger.Debug("[user.GetUsernames] 批量获取用户名", zap.Int("count", len(userIDs)))
+// GetUsernames 批量获取用户展示信息（用户名、昵称、头像）
+func (s *Service) GetUsernames(userIDs []uint) (map[uint]Brief, error) {
+	logger.Debug("[user.GetUsernames] 批量获取用户信息", zap.Int("count", len(userIDs)))
 	users, err := s.repo.FindByIDs(userIDs)
 	if err != nil {
 		logger.Error("[user.GetUsernames] 批量查询失败", zap.Int("count", len(userIDs)), zap.Error(err))
 		return nil, err
 	}
 
-	usernameMap := make(map[uint]string, len(users))
+	profiles, err := s.repo.FindProfilesByUserIDs(userIDs)
+	if err != nil {
+		// 资料查询失败时降级为只返回用户名
+		logger.Warn("[user.GetUsernames] 批量查询资料失败", zap.Int("count", len(userIDs)), zap.Error(err))
+		profiles = nil
+	}
+	profileMap := make(map[uint]*Profile, len(profiles))
+	for _, p := range profiles {
+		profileMap[p.UserID] = p
+	}
+
+	briefs := make(map[uint]Brief, len(users))
 	for _, user := range users {
-		usernameMap[user.ID] = user.Username
+		briefs[user.ID] = s.BriefOf(user, profileMap[user.ID])
 	}
 	logger.Debug("[user.GetUsernames] 成功", zap.Int("count", len(users)))
-	return usernameMap, nil
+	return briefs, nil
+}
+
+// FindUserIDByUsername 根据用户名查找用户ID（用于按用户名添加好友等）
+func (s *Service) FindUserIDByUsername(username string) (uint, error) {
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		if err.Error() == "用户不存在" {
+			return 0, errors.New(errors.UserNotFound)
+		}
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// ============================================================
+// 登录会话（设备管理）
+// ============================================================
+
+// sessionTouchInterval 会话活跃时间的最小刷新间隔（避免每个请求都写库）
+const sessionTouchInterval = time.Minute
+
+// sessionCacheTTL 登录会话的缓存时间上限（缓存写回与踢下线并发时，被删除的会话最多在缓存中残留这么久）
+const sessionCacheTTL = sessionTouchInterval
+
+// sessionCacheKey 登录会话缓存Key
+func sessionCacheKey(tokenID string) string {
+	return fmt.Sprintf("user:session:%s", tokenID)
+}
+
+// issueToken 创建登录会话并签发Token
+func (s *Service) issueToken(user *Entity, client ClientInfo) (string, error) {
+	tokenID, err := s.tokenGen.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	deviceName := client.DeviceName
+	if deviceName == "" {
+		deviceName = "未知设备"
+	}
+
+	now := time.Now()
+	session := &DeviceSession{
+		UserID:     user.ID,
+		TokenID:    tokenID,
+		DeviceName: truncate(deviceName, 100),
+		IP:         truncate(client.IP, 64),
+		UserAgent:  truncate(client.UserAgent, 512),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.tokenGen.TTL()),
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		return "", err
+	}
+
+	logger.Info("[user.issueToken] 登录会话已创建",
+		zap.Uint("user_id", user.ID),
+		zap.Uint("session_id", session.ID),
+		zap.String("device_name", session.DeviceName),
+		zap.String("ip", session.IP))
+
+	return s.tokenGen.Generate(user.ID, user.Username, user.Role, tokenID)
+}
+
+// CheckSession 校验Token对应的登录会话是否仍然有效（供认证中间件调用）
+// 业务规则：
+// 1. 未携带jti的旧Token直接放行，随Token过期自然失效
+// 2. 会话被踢下线（记录已删除）后Token立即失效
+// 3. 按固定间隔刷新最后活跃时间，刷新时发现会话已删除则Token失效（缓存中的会话不会被写回）
+func (s *Service) CheckSession(userID uint, tokenID string) error {
+	if tokenID == "" {
+		return nil
+	}
+
+	var session DeviceSession
+	cached := s.cache != nil && s.cache.Get(sessionCacheKey(tokenID), &session) == nil
+	if !cached {
+		found, err := s.repo.FindSessionByTokenID(tokenID)
+		if err != nil {
+			logger.Warn("[user.CheckSession] 登录会话不存在",
+				zap.Uint("user_id", userID),
+				zap.String("token_id", tokenID))
+			return errors.New(errors.TokenInvalid)
+		}
+		session = *found
+	}
+
+	if session.UserID != userID {
+		return errors.New(errors.TokenInvalid)
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		session.LastSeenAt = now
+		rows, err := s.repo.TouchSession(session.ID, now)
+		if err != nil {
+			logger.Warn("[user.CheckSession] 更新活跃时间失败",
+				zap.Uint("session_id", session.ID),
+				zap.Error(err))
+			return nil // 只是刷新活跃时间失败，会话本身有效，但不写回缓存
+		}
+		if rows == 0 {
+			// 会话已被踢下线，不能把缓存中的旧会话写回去
+			logger.Warn("[user.CheckSession] 登录会话已删除",
+				zap.Uint("user_id", userID),
+				zap.Uint("session_id", session.ID))
+			if s.cache != nil {
+				s.cache.Delete(sessionCacheKey(tokenID))
+			}
+			return errors.New(errors.TokenInvalid)
+		}
+		cached = false
+	}
+
+	if s.cache != nil && !cached {
+		ttl := time.Until(session.ExpiresAt)
+		if ttl > sessionCacheTTL {
+			ttl = sessionCacheTTL
+		}
+		s.cache.Set(sessionCacheKey(tokenID), session, ttl)
+	}
+	return nil
+}
+
+// ListSessions 获取用户的登录设备列表
+func (s *Service) ListSessions(userID uint) ([]*DeviceSession, error) {
+	logger.Debug("[user.ListSessions] 获取登录设备", zap.Uint("user_id", userID))
+
+	sessions, err := s.repo.ListActiveSessions(userID)
+	if err != nil {
+		logger.Error("[user.ListSessions] 查询失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession 踢下线指定设备（删除登录会话，对应Token立即失效）
+func (s *Service) RevokeSession(userID, sessionID uint) error {
+	logger.Info("[user.RevokeSession] 踢下线设备",
+		zap.Uint("user_id", userID),
+		zap.Uint("session_id", sessionID))
+
+	session, err := s.repo.FindSessionByID(userID, sessionID)
+	if err != nil {
+		logger.Warn("[user.RevokeSession] 登录会话不存在",
+			zap.Uint("user_id", userID),
+			zap.Uint("session_id", sessionID))
+		return errors.New(errors.LoginSessionNotFound)
+	}
+
+	if err := s.repo.DeleteSession(session.ID); err != nil {
+		logger.Error("[user.RevokeSession] 删除失败", zap.Uint("session_id", sessionID), zap.Error(err))
+		return err
+	}
+	if s.cache != nil {
+		s.cache.Delete(sessionCacheKey(session.TokenID))
+	}
+
+	logger.Info("[user.RevokeSession] 设备已下线",
+		zap.Uint("user_id", userID),
+		zap.Uint("session_id", sessionID))
+	return nil
+}
+
+// truncate 按字符截断字符串，避免超出字段长度
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) > max {
+		return string(r[:max])
+	}
+	return s
+}
+
+// ============================================================
+// 密码管理（修改密码、管理员重置）
+// ============================================================
+
+// resetTokenTTL 密码重置令牌有效期
+const resetTokenTTL = 30 * time.Minute
+
+// ChangePassword 修改密码
+// 业务规则：
+// 1. 必须提供正确的原密码
+// 2. 新密码必须符合密码策略
+// 3. 修改成功后注销其他设备的登录会话，保留当前设备
+func (s *Service) ChangePassword(userID uint, currentTokenID, oldPassword, newPassword string) error {
+	logger.Info("[user.ChangePassword] 修改密码", zap.Uint("user_id", userID))
+
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		logger.Warn("[user.ChangePassword] 用户不存在", zap.Uint("user_id", userID))
+		return errors.New(errors.UserNotFound)
+	}
+
+	if !s.passwordHasher.Check(oldPassword, user.Password) {
+		logger.Warn("[user.ChangePassword] 原密码错误", zap.Uint("user_id", userID))
+		return errors.New(errors.OldPasswordIncorrect)
+	}
+
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
+	}
+
+	s.revokeSessions(userID, currentTokenID)
+	logger.Info("[user.ChangePassword] 密码修改成功", zap.Uint("user_id", userID))
+	return nil
+}
+
+// IssuePasswordReset 管理员为指定用户签发一次性密码重置令牌
+// 返回令牌明文（仅此一次）和过期时间
+func (s *Service) IssuePasswordReset(adminID, userID uint) (string, time.Time, error) {
+	logger.Info("[user.IssuePasswordReset] 签发密码重置令牌",
+		zap.Uint("admin_id", adminID),
+		zap.Uint("user_id", userID))
+
+	if _, err := s.repo.FindByID(userID); err != nil {
+		logger.Warn("[user.IssuePasswordReset] 用户不存在", zap.Uint("user_id", userID))
+		return "", time.Time{}, errors.New(errors.UserNotFound)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		logger.Error("[user.IssuePasswordReset] 生成令牌失败", zap.Error(err))
+		return "", time.Time{}, errors.NewWithMessage(errors.ServerError, "生成重置令牌失败")
+	}
+	plain := hex.EncodeToString(raw)
+
+	token := &PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hashResetToken(plain),
+		CreatedBy: adminID,
+		ExpiresAt: time.Now().Add(resetTokenTTL),
+	}
+	if err := s.repo.CreateResetToken(token); err != nil {
+		logger.Error("[user.IssuePasswordReset] 保存令牌失败", zap.Uint("user_id", userID), zap.Error(err))
+		return "", time.Time{}, errors.NewWithMessage(errors.ServerError, "生成重置令牌失败")
+	}
+
+	logger.Warn("[audit] 管理员签发密码重置令牌",
+		zap.String("event", "password_reset_issued"),
+		zap.Uint("admin_id", adminID),
+		zap.Uint("user_id", userID),
+		zap.Time("expires_at", token.ExpiresAt))
+	return plain, token.ExpiresAt, nil
+}
+
+// ResetPassword 使用一次性令牌重置密码
+// 业务规则：
+// 1. 令牌只能使用一次且必须在有效期内
+// 2. 新密码必须符合密码策略
+// 3. 重置成功后注销该用户所有设备的登录会话
+func (s *Service) ResetPassword(plainToken, newPassword string) error {
+	logger.Info("[user.ResetPassword] 使用令牌重置密码")
+
+	token, err := s.repo.ConsumeResetToken(hashResetToken(plainToken))
+	if err != nil {
+		logger.Warn("[user.ResetPassword] 令牌无效", zap.Error(err))
+		return errors.New(errors.ResetTokenInvalid)
+	}
+
+	user, err := s.repo.FindByID(token.UserID)
+	if err != nil {
+		logger.Warn("[user.ResetPassword] 用户不存在", zap.Uint("user_id", token.UserID))
+		return errors.New(errors.UserNotFound)
+	}
+
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
+	}
+
+	s.revokeSessions(user.ID, "")
+	logger.Info("[user.ResetPassword] 密码重置成功", zap.Uint("user_id", user.ID))
+	return nil
+}
+
+// setPassword 校验密码策略并保存新密码哈希
+func (s *Service) setPassword(user *Entity, password string) error {
+	if err := s.policy.Validate(user.Username, password); err != nil {
+		logger.Warn("[user.setPassword] 密码不符合策略", zap.Uint("user_id", user.ID), zap.Error(err))
+		return err
+	}
+
+	hash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		logger.Error("[user.setPassword] 密码加密失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return errors.NewWithMessage(errors.ServerError, "密码加密失败")
+	}
+
+	if err := s.repo.UpdatePassword(user.ID, hash); err != nil {
+		logger.Error("[user.setPassword] 更新密码失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return errors.NewWithMessage(errors.ServerError, "更新密码失败")
+	}
+	user.Password = hash
+	return nil
+}
+
+// revokeSessions 注销用户的登录会话（keepTokenID 对应的会话保留，为空则全部注销）
+func (s *Service) revokeSessions(userID uint, keepTokenID string) {
+	sessions, err := s.repo.ListActiveSessions(userID)
+	if err != nil {
+		logger.Error("[user.revokeSessions] 查询登录会话失败", zap.Uint("user_id", userID), zap.Error(err))
+		return
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if keepTokenID != "" && session.TokenID == keepTokenID {
+			continue
+		}
+		if err := s.repo.DeleteSession(session.ID); err != nil {
+			logger.Error("[user.revokeSessions] 删除会话失败", zap.Uint("session_id", session.ID), zap.Error(err))
+			continue
+		}
+		if s.cache != nil {
+			s.cache.Delete(sessionCacheKey(session.TokenID))
+		}
+		revoked++
+	}
+
+	logger.Info("[user.revokeSessions] 已注销登录会话",
+		zap.Uint("user_id", userID),
+		zap.Int("count", revoked))
+}
+
+// hashResetToken 计算重置令牌、游客凭证的摘要（数据库只保存摘要）
+func hashResetToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// ============================================================
+// 游客账号
+// ============================================================
+
+// guestCleanupBatch 每次清理的游客数量上限
+const guestCleanupBatch = 500
+
+// GuestLogin 游客登录，返回用户、Token和新签发的游客凭证（未签发新凭证时为空）
+// 业务规则：
+// 1. 同一设备ID始终对应同一个游客账号（重复调用返回新Token）
+// 2. 游客账号没有可用密码，创建时签发随机的游客凭证，之后必须同时提供设备ID和凭证才能登录
+// 3. 使用登录限流器：凭证错误计为该设备和IP的登录失败，创建游客计入IP的失败次数，同一IP创建过多游客时被锁定
+// 4. 游客可正常使用存档、聊天、排行榜，升级后数据保留
+func (s *Service) GuestLogin(deviceID, secret string, client ClientInfo) (*Entity, string, string, error) {
+	logger.Info("[user.GuestLogin] 游客登录", zap.String("device_id", deviceID))
+
+	limiterName := guestLimiterName(deviceID)
+	if s.limiter != nil {
+		status, err := s.limiter.Check(limiterName, client.IP)
+		if err != nil {
+			logger.Error("[user.GuestLogin] 登录限流检查失败", zap.String("device_id", deviceID), zap.Error(err))
+		} else if status.Blocked() {
+			logger.Warn("[user.GuestLogin] 游客登录过于频繁",
+				zap.String("device_id", deviceID),
+				zap.String("ip", client.IP),
+				zap.Duration("retry_after", status.RetryAfter))
+			return nil, "", "", loginBlockedError(status)
+		}
+	}
+
+	user, err := s.repo.FindGuestByDeviceID(deviceID)
+	if err != nil && err.Error() != "用户不存在" {
+		logger.Error("[user.GuestLogin] 查询游客失败", zap.String("device_id", deviceID), zap.Error(err))
+		return nil, "", "", err
+	}
+
+	var issued string
+	if user == nil {
+		if issued, err = newGuestSecret(); err != nil {
+			return nil, "", "", errors.NewWithMessage(errors.ServerError, "创建游客失败")
+		}
+		suffix, err := s.tokenGen.NewTokenID()
+		if err != nil {
+			return nil, "", "", errors.NewWithMessage(errors.ServerError, "创建游客失败")
+		}
+
+		now := time.Now()
+		user = &Entity{
+			Username:    GuestUsernamePrefix + suffix[:12],
+			Password:    "", // 游客无密码，无法通过用户名密码登录
+			Role:        security.RolePlayer,
+			IsGuest:     true,
+			DeviceID:    &deviceID,
+			GuestSecret: hashResetToken(issued),
+			CreatedAt:   now,
+			LastLoginAt: now,
+		}
+		if err := s.repo.Create(user); err != nil {
+			logger.Error("[user.GuestLogin] 创建游客失败", zap.String("device_id", deviceID), zap.Error(err))
+			return nil, "", "", errors.NewWithMessage(errors.ServerError, "创建游客失败")
+		}
+		logger.Info("[user.GuestLogin] 游客账号已创建", zap.Uint("user_id", user.ID), zap.String("username", user.Username))
+		s.recordGuestAttempt(limiterName, client.IP) // 创建游客计入IP的次数，限制同一IP批量创建
+	} else {
+		// 没有凭证的游客（GuestSecret为空）不能登录，防止只凭设备ID接管账号
+		if user.GuestSecret == "" || subtle.ConstantTimeCompare([]byte(hashResetToken(secret)), []byte(user.GuestSecret)) != 1 {
+			logger.Warn("[user.GuestLogin] 游客凭证无效", zap.Uint("user_id", user.ID), zap.String("ip", client.IP))
+			if status := s.recordGuestAttempt(limiterName, client.IP); status.Locked {
+				return nil, "", "", loginBlockedError(status)
+			}
+			return nil, "", "", errors.New(errors.GuestSecretInvalid)
+		}
+		if err := s.CheckBan(user.ID, security.BanScopeFull); err != nil {
+			return nil, "", "", err
+		}
+		if s.limiter != nil {
+			if err := s.limiter.Reset(limiterName); err != nil {
+				logger.Warn("[user.GuestLogin] 清除失败记录失败", zap.String("device_id", deviceID), zap.Error(err))
+			}
+		}
+		s.repo.UpdateLastLogin(user.ID)
+		s.cancelDeletion(user)
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = "游客设备"
+	}
+	token, err := s.issueToken(user, client)
+	if err != nil {
+		logger.Error("[user.GuestLogin] 生成token失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return nil, "", "", fmt.Errorf("生成token失败: %w", err)
+	}
+	return user, token, issued, nil
+}
+
+// guestLimiterName 游客登录在登录限流器中使用的名称（与用户名区分）
+func guestLimiterName(deviceID string) string {
+	return "guest:" + deviceID
+}
+
+// recordGuestAttempt 在登录限流器中记录一次游客凭证错误或游客创建，返回记录后的状态
+func (s *Service) recordGuestAttempt(name, ip string) LoginStatus {
+	if s.limiter == nil {
+		return LoginStatus{}
+	}
+	status, err := s.limiter.RecordFailure(name, ip)
+	if err != nil {
+		logger.Error("[user.recordGuestAttempt] 记录游客登录次数失败", zap.String("name", name), zap.Error(err))
+	}
+	return status
+}
+
+// newGuestSecret 生成游客凭证（32字节随机数，数据库只保存摘要）
+func newGuestSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UpgradeGuest 游客升级为正式账号
+// 业务规则：
+// 1. 仅游客账号可升级
+// 2. 用户ID不变，存档、排行榜、聊天记录全部保留
+// 3. 解除设备绑定，旧Token全部失效并签发新Token
+func (s *Service) UpgradeGuest(userID uint, username, password string, client ClientInfo) (*Entity, string, error) {
+	logger.Info("[user.UpgradeGuest] 游客升级", zap.Uint("user_id", userID), zap.String("username", username))
+
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, "", errors.New(errors.UserNotFound)
+	}
+	if !user.IsGuest {
+		logger.Warn("[user.UpgradeGuest] 非游客账号", zap.Uint("user_id", userID))
+		return nil, "", errors.New(errors.NotGuestAccount)
+	}
+
+	if strings.HasPrefix(strings.ToLower(username), GuestUsernamePrefix) {
+		return nil, "", errors.NewWithMessage(errors.InvalidParams, "用户名不能以"+GuestUsernamePrefix+"开头")
+	}
+	if err := s.policy.Validate(username, password); err != nil {
+		return nil, "", err
+	}
+	if exist, err := s.repo.FindByUsername(username); err == nil && exist != nil {
+		logger.Warn("[user.UpgradeGuest] 用户名已存在", zap.String("username", username))
+		return nil, "", errors.New(errors.UserAlreadyExists)
+	}
+
+	hash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		logger.Error("[user.UpgradeGuest] 密码加密失败", zap.Error(err))
+		return nil, "", errors.NewWithMessage(errors.ServerError, "密码加密失败")
+	}
+
+	user.Username = username
+	user.Password = hash
+	user.IsGuest = false
+	user.DeviceID = nil
+	user.GuestSecret = ""
+	if err := s.repo.Update(user); err != nil {
+		logger.Error("[user.UpgradeGuest] 更新用户失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, "", errors.NewWithMessage(errors.ServerError, "升级账号失败")
+	}
+
+	// 旧Token中的用户名已过时，全部注销后重新签发
+	s.revokeSessions(userID, "")
+	token, err := s.issueToken(user, client)
+	if err != nil {
+		logger.Error("[user.UpgradeGuest] 生成token失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, "", fmt.Errorf("生成token失败: %w", err)
+	}
+
+	logger.Info("[user.UpgradeGuest] 升级成功", zap.Uint("user_id", userID), zap.String("username", username))
+	return user, token, nil
+}
+
+// CleanupInactiveGuests 清理长期不活跃的游客账号及其全部数据（由定时任务调用）
+func (s *Service) CleanupInactiveGuests(inactiveFor time.Duration) (int, error) {
+	cutoff := time.Now().Add(-inactiveFor)
+	total := 0
+
+	for {
+		ids, err := s.repo.FindInactiveGuestIDs(cutoff, guestCleanupBatch)
+		if err != nil {
+			logger.Error("[user.CleanupInactiveGuests] 查询不活跃游客失败", zap.Error(err))
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		if err := s.purgeUsers(ids); err != nil {
+			logger.Error("[user.CleanupInactiveGuests] 删除游客失败", zap.Int("count", len(ids)), zap.Error(err))
+			return total, err
+		}
+		total += len(ids)
+
+		if len(ids) < guestCleanupBatch {
+			break
+		}
+	}
+
+	logger.Info("[user.CleanupInactiveGuests] 清理完成",
+		zap.Time("cutoff", cutoff),
+		zap.Int("deleted", total))
+	return total, nil
+}
+
+// purgeUsers 删除用户及其全部数据（数据库记录在一个事务中删除）
+func (s *Service) purgeUsers(ids []uint) error {
+	// 用户上传的头像文件不在数据库中，需单独删除
+	if profiles, err := s.repo.FindProfilesByUserIDs(ids); err == nil {
+		for _, p := range profiles {
+			s.deleteAvatarFiles(p.Avatar)
+		}
+	}
+	// 先注销登录会话并清除缓存的会话和封禁状态，避免删除后缓存中的token仍然有效
+	for _, id := range ids {
+		s.revokeSessions(id, "")
+		s.clearBanCache(id)
+	}
+	if err := s.repo.DeleteUsersCascade(ids, s.purgeHooks); err != nil {
+		return err
+	}
+	if s.onDeleted != nil {
+		s.onDeleted(ids)
+	}
+	return nil
+}
+
+// ============================================================
+// 注销账号
+// ============================================================
+
+// 注销账号相关常量
+const (
+	defaultDeletionGrace = 7 * 24 * time.Hour // 默认宽限期
+	deletionPurgeBatch   = 100                // 每次删除的账号数量上限
+)
+
+// RequestDeletion 申请注销账号
+// 业务规则：
+// 1. 设置了密码的账号需校验当前密码，游客和仅通过第三方登录的账号可直接申请
+// 2. 申请后立即注销所有登录会话并断开实时连接
+// 3. 宽限期结束后由定时任务删除账号及全部数据；宽限期内重新登录即撤销
+func (s *Service) RequestDeletion(userID uint, password string) (time.Time, error) {
+	logger.Info("[user.RequestDeletion] 申请注销账号", zap.Uint("user_id", userID))
+
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return time.Time{}, errors.New(errors.UserNotFound)
+	}
+	// 游客和仅通过第三方登录的账号没有密码
+	if user.Password != "" && !s.passwordHasher.Check(password, user.Password) {
+		logger.Warn("[user.RequestDeletion] 密码错误", zap.Uint("user_id", userID))
+		return time.Time{}, errors.New(errors.PasswordIncorrect)
+	}
+
+	deleteAt := time.Now().Add(s.deletionGrace)
+	if err := s.repo.ScheduleDeletion(userID, &deleteAt); err != nil {
+		logger.Error("[user.RequestDeletion] 保存注销申请失败", zap.Uint("user_id", userID), zap.Error(err))
+		return time.Time{}, errors.NewWithMessage(errors.ServerError, "注销账号失败")
+	}
+
+	s.revokeSessions(userID, "")
+	if s.kicker != nil {
+		s.kicker.KickUser(userID, AccountDeletionEvent{Type: "account_deletion_scheduled", DeleteAt: deleteAt})
+	}
+
+	logger.Warn("[audit] 用户申请注销账号",
+		zap.String("event", "account_deletion_requested"),
+		zap.Uint("user_id", userID),
+		zap.Time("delete_at", deleteAt))
+	return deleteAt, nil
+}
+
+// cancelDeletion 撤销注销申请（用户在宽限期内重新登录时调用）
+func (s *Service) cancelDeletion(user *Entity) {
+	if user.DeletionScheduledAt == nil {
+		return
+	}
+	if err := s.repo.ScheduleDeletion(user.ID, nil); err != nil {
+		logger.Error("[user.cancelDeletion] 撤销注销失败", zap.Uint("user_id", user.ID), zap.Error(err))
+		return
+	}
+	user.DeletionScheduledAt = nil
+	logger.Warn("[audit] 用户重新登录，撤销注销账号",
+		zap.String("event", "account_deletion_cancelled"),
+		zap.Uint("user_id", user.ID))
+}
+
+// PurgeDeletedAccounts 删除宽限期已结束的账号及其全部数据（由定时任务调用）
+func (s *Service) PurgeDeletedAccounts() (int, error) {
+	now := time.Now()
+	total := 0
+
+	for {
+		ids, err := s.repo.FindUsersDueForDeletion(now, deletionPurgeBatch)
+		if err != nil {
+			logger.Error("[user.PurgeDeletedAccounts] 查询待删除账号失败", zap.Error(err))
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		if err := s.purgeUsers(ids); err != nil {
+			logger.Error("[user.PurgeDeletedAccounts] 删除账号失败", zap.Int("count", len(ids)), zap.Error(err))
+			return total, err
+		}
+		for _, id := range ids {
+			logger.Warn("[audit] 账号已删除",
+				zap.String("event", "account_deleted"),
+				zap.Uint("user_id", id))
+		}
+		total += len(ids)
+
+		if len(ids) < deletionPurgeBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("[user.PurgeDeletedAccounts] 删除完成", zap.Int("deleted", total))
+	}
+	return total, nil
+}
+
+// ============================================================
+// 玩家资料
+// ============================================================
+
+// 资料字段限制
+const (
+	maxDisplayNameLength = 32
+	maxBioLength         = 500
+	maxPreferencesSize   = 4096
+)
+
+// localePattern 语言区域格式，如 zh、zh-CN、en_US
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})*$`)
+
+// GetProfile 获取用户及其资料（未设置过资料时返回默认资料）
+func (s *Service) GetProfile(userID uint) (*Entity, *Profile, error) {
+	logger.Debug("[user.GetProfile] 获取资料", zap.Uint("user_id", userID))
+
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, nil, errors.New(errors.UserNotFound)
+	}
+
+	profile, err := s.repo.FindProfile(userID)
+	if err != nil {
+		logger.Error("[user.GetProfile] 查询资料失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, nil, err
+	}
+	if profile == nil {
+		profile = &Profile{UserID: userID, Preferences: "{}"}
+	}
+	return user, profile, nil
+}
+
+// UpdateProfile 更新用户资料（仅更新请求中出现的字段）
+func (s *Service) UpdateProfile(userID uint, req *UpdateProfileRequest) (*Entity, *Profile, error) {
+	logger.Info("[user.UpdateProfile] 更新资料", zap.Uint("user_id", userID))
+
+	user, profile, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, fmt.Sprintf("昵称不能超过%d个字符", maxDisplayNameLength))
+		}
+		profile.DisplayName = name
+	}
+	oldAvatar := profile.Avatar
+	if req.Avatar != nil {
+		avatar := strings.TrimSpace(*req.Avatar)
+		if len(avatar) > 255 {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, "头像引用过长")
+		}
+		// 上传的头像只能通过上传接口设置，防止引用他人的上传文件
+		if isUploadedAvatar(avatar) && avatar != oldAvatar {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, "请通过上传接口设置头像")
+		}
+		// 其他头像只能是预设头像或允许的主机上的https图片，防止在公开资料和排行榜中插入任意链接
+		if avatar != "" && !isUploadedAvatar(avatar) && !s.validAvatar(avatar) {
+			return nil, nil, errors.NewWithMessage(errors.InvalidParams, "头像只能是预设头像或允许的图片地址")
+		}
+		profile.Avatar = avatar
+	}
+	if req.Bio != nil {
+		if utf8.RuneCountInString(*req.Bio) > maxBioLength {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, fmt.Sprintf("简介不能超过%d个字符", maxBioLength))
+		}
+		profile.Bio = *req.Bio
+	}
+	if req.Locale != nil {
+		if *req.Locale != "" && !localePattern.MatchString(*req.Locale) {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, "语言区域格式错误")
+		}
+		profile.Locale = *req.Locale
+	}
+	if len(req.Preferences) > 0 {
+		if len(req.Preferences) > maxPreferencesSize {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, "个人设置过大")
+		}
+		if !json.Valid(req.Preferences) || req.Preferences[0] != '{' {
+			return nil, nil, errors.NewWithMessage(errors.InvalidProfile, "个人设置必须是JSON对象")
+		}
+		profile.Preferences = string(req.Preferences)
+	}
+
+	if err := s.repo.SaveProfile(profile); err != nil {
+		logger.Error("[user.UpdateProfile] 保存资料失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, nil, errors.NewWithMessage(errors.ServerError, "保存资料失败")
+	}
+
+	// 换成其他头像后，删除之前上传的文件
+	if profile.Avatar != oldAvatar {
+		s.deleteAvatarFiles(oldAvatar)
+	}
+
+	logger.Info("[user.UpdateProfile] 资料已更新", zap.Uint("user_id", userID))
+	return user, profile, nil
+}
+
+// UploadAvatar 上传头像
+// 业务规则：
+// 1. 按文件内容校验格式（PNG/JPEG/GIF）和尺寸
+// 2. 居中裁剪为正方形，生成256px主图和64px缩略图，统一重新编码为PNG
+// 3. 上传成功后删除之前上传的头像文件
+func (s *Service) UploadAvatar(userID uint, data []byte) (*Entity, *Profile, error) {
+	logger.Info("[user.UploadAvatar] 上传头像", zap.Uint("user_id", userID), zap.Int("size", len(data)))
+
+	if s.storage == nil {
+		return nil, nil, errors.NewWithMessage(errors.ServerError, "未配置文件存储")
+	}
+
+	mainPNG, thumbPNG, err := processAvatar(data)
+	if err != nil {
+		logger.Warn("[user.UploadAvatar] 头像校验失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, nil, errors.NewWithMessage(errors.InvalidAvatar, err.Error())
+	}
+
+	user, profile, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 每次上传使用新文件名，避免CDN/浏览器缓存旧头像
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		logger.Error("[user.UploadAvatar] 生成文件名失败", zap.Error(err))
+		return nil, nil, errors.NewWithMessage(errors.ServerError, "上传头像失败")
+	}
+	key := fmt.Sprintf("%s%d/%s.png", avatarKeyPrefix, userID, hex.EncodeToString(raw))
+
+	if err := s.storage.Put(key, bytes.NewReader(mainPNG), "image/png"); err != nil {
+		logger.Error("[user.UploadAvatar] 保存头像失败", zap.String("key", key), zap.Error(err))
+		return nil, nil, errors.NewWithMessage(errors.ServerError, "上传头像失败")
+	}
+	if err := s.storage.Put(avatarThumbKey(key), bytes.NewReader(thumbPNG), "image/png"); err != nil {
+		logger.Error("[user.UploadAvatar] 保存缩略图失败", zap.String("key", key), zap.Error(err))
+		s.deleteAvatarFiles(key)
+		return nil, nil, errors.NewWithMessage(errors.ServerError, "上传头像失败")
+	}
+
+	oldAvatar := profile.Avatar
+	profile.Avatar = key
+	if err := s.repo.SaveProfile(profile); err != nil {
+		logger.Error("[user.UploadAvatar] 保存资料失败", zap.Uint("user_id", userID), zap.Error(err))
+		s.deleteAvatarFiles(key)
+		return nil, nil, errors.NewWithMessage(errors.ServerError, "保存资料失败")
+	}
+	s.deleteAvatarFiles(oldAvatar)
+
+	logger.Info("[user.UploadAvatar] 头像已更新", zap.Uint("user_id", userID), zap.String("key", key))
+	return user, profile, nil
+}
+
+// BriefOf 组装用户简要信息（未设置昵称时使用用户名，上传的头像转换为访问地址）
+func (s *Service) BriefOf(user *Entity, profile *Profile) Brief {
+	brief := Brief{
+		UserID:      user.ID,
+		Username:    user.Username,
+		DisplayName: user.Username,
+	}
+	if profile != nil {
+		if profile.DisplayName != "" {
+			brief.DisplayName = profile.DisplayName
+		}
+		brief.Avatar, brief.AvatarThumb = s.avatarURLs(profile.Avatar)
+	}
+	return brief
+}
+
+// avatarURLs 解析头像引用：上传的头像返回主图和缩略图地址，预设头像ID或允许的外部URL原样返回，其他引用返回空
+func (s *Service) avatarURLs(avatar string) (string, string) {
+	if !isUploadedAvatar(avatar) {
+		if !s.validAvatar(avatar) {
+			return "", ""
+		}
+		return avatar, avatar
+	}
+	if s.storage == nil {
+		return "", ""
+	}
+	return s.storage.URL(avatar), s.storage.URL(avatarThumbKey(avatar))
+}
+
+// SetAvatarSources 设置预设头像数量和允许引用的外部头像主机（presets为0时使用默认数量）
+func (s *Service) SetAvatarSources(presets int, hosts []string) {
+	s.avatarPresets = presets
+	s.avatarHosts = hosts
+}
+
+// validAvatar 头像引用是否为预设头像或允许的外部图片地址
+func (s *Service) validAvatar(avatar string) bool {
+	presets := s.avatarPresets
+	if presets <= 0 {
+		presets = defaultAvatarPresets
+	}
+	return validAvatarRef(avatar, presets, s.avatarHosts)
+}
+
+// deleteAvatarFiles 删除上传的头像文件（非上传头像直接忽略，删除失败只记录日志）
+func (s *Service) deleteAvatarFiles(avatar string) {
+	if !isUploadedAvatar(avatar) || s.storage == nil {
+		return
+	}
+	for _, key := range []string{avatar, avatarThumbKey(avatar)} {
+		if err := s.storage.Delete(key); err != nil {
+			logger.Warn("[user.deleteAvatarFiles] 删除头像文件失败", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// isUploadedAvatar 头像引用是否为上传到存储中的文件
+func isUploadedAvatar(avatar string) bool {
+	return strings.HasPrefix(avatar, avatarKeyPrefix)
+}
+
+// avatarThumbKey 由主图key得到缩略图key
+func avatarThumbKey(key string) string {
+	return strings.TrimSuffix(key, ".png") + "_thumb.png"
+}
+
+// ============================================================
+// 管理后台
+// ============================================================
+
+// EnsureAdmins 为配置中的用户名授予管理员角色（启动时调用，用于初始化管理员）
+func (s *Service) EnsureAdmins(usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	updated, err := s.repo.UpdateRoleByUsernames(usernames, security.RoleAdmin)
+	if err != nil {
+		logger.Error("[user.EnsureAdmins] 授予管理员角色失败", zap.Error(err))
+		return err
+	}
+	if updated > 0 {
+		logger.Warn("[audit] 启动时授予管理员角色",
+			zap.String("event", "admin_bootstrap"),
+			zap.Strings("usernames", usernames),
+			zap.Int64("updated", updated))
+	}
+	return nil
+}
+
+// SearchUsers 按用户名前缀搜索用户
+func (s *Service) SearchUsers(keyword string, page, limit int) ([]AdminUserVO, int64, error) {
+	logger.Debug("[user.SearchUsers] 搜索用户", zap.String("keyword", keyword), zap.Int("page", page))
+
+	users, total, err := s.repo.Search(strings.TrimSpace(keyword), (page-1)*limit, limit)
+	if err != nil {
+		logger.Error("[user.SearchUsers] 查询失败", zap.String("keyword", keyword), zap.Error(err))
+		return nil, 0, err
+	}
+
+	vos := make([]AdminUserVO, len(users))
+	for i, user := range users {
+		vos[i] = toAdminUserVO(user)
+	}
+	return vos, total, nil
+}
+
+// GetUserDetail 获取用户详情（资料、当前封禁、在线设备数）
+func (s *Service) GetUserDetail(userID uint) (*AdminUserVO, error) {
+	logger.Debug("[user.GetUserDetail] 获取用户详情", zap.Uint("user_id", userID))
+
+	user, profile, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	vo := toAdminUserVO(user)
+	brief := s.BriefOf(user, profile)
+	vo.DisplayName = brief.DisplayName
+	vo.Avatar = brief.Avatar
+
+	bans, err := s.repo.FindActiveBans(userID)
+	if err != nil {
+		logger.Error("[user.GetUserDetail] 查询封禁失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	vo.Bans = make([]BanVO, len(bans))
+	for i, ban := range bans {
+		vo.Bans[i] = toBanVO(ban)
+	}
+
+	sessions, err := s.repo.ListActiveSessions(userID)
+	if err != nil {
+		logger.Error("[user.GetUserDetail] 查询登录会话失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	vo.ActiveSessions = len(sessions)
+	return &vo, nil
+}
+
+// BanUser 封禁用户
+// 业务规则：
+// 1. 不能封禁自己，也不能封禁管理人员（需先取消角色）
+// 2. duration 为0表示永久封禁
+// 3. 全部封禁立即注销该用户所有登录会话并断开其实时连接
+// 4. 禁言不断开实时连接（好友在线状态、排行榜推送仍可接收），只推送封禁通知，发送消息时由接口拒绝
+func (s *Service) BanUser(operatorID, userID uint, scope, reason string, duration time.Duration) (*Ban, error) {
+	logger.Info("[user.BanUser] 封禁用户",
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("user_id", userID),
+		zap.String("scope", scope),
+		zap.Duration("duration", duration))
+
+	if scope == "" {
+		scope = security.BanScopeFull
+	}
+	if !security.ValidBanScope(scope) {
+		return nil, errors.NewWithMessage(errors.InvalidParams, "封禁范围无效")
+	}
+
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New(errors.UserNotFound)
+	}
+	if user.ID == operatorID {
+		return nil, errors.NewWithMessage(errors.InvalidParams, "不能封禁自己")
+	}
+	if user.Role != security.RolePlayer {
+		return nil, errors.NewWithMessage(errors.PermissionDenied, "不能封禁管理人员")
+	}
+
+	ban := &Ban{
+		UserID:    userID,
+		Scope:     scope,
+		Reason:    truncate(strings.TrimSpace(reason), 255),
+		CreatedBy: operatorID,
+	}
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+	if err := s.repo.CreateBan(ban); err != nil {
+		logger.Error("[user.BanUser] 保存封禁记录失败", zap.Uint("user_id", userID), zap.Error(err))
+		return nil, errors.NewWithMessage(errors.ServerError, "封禁失败")
+	}
+	s.clearBanCache(userID)
+
+	if scope == security.BanScopeFull {
+		s.revokeSessions(userID, "")
+	}
+	if s.kicker != nil {
+		event := BanEvent{Type: "banned", Code: security.BanErrorCode(scope), Ban: toBanInfoVO(ban)}
+		if scope == security.BanScopeFull {
+			kicked := s.kicker.KickUser(userID, event)
+			logger.Info("[user.BanUser] 已断开实时连接", zap.Uint("user_id", userID), zap.Int("count", kicked))
+		} else if err := s.kicker.SendToUser(userID, event); err != nil {
+			logger.Warn("[user.BanUser] 推送封禁通知失败", zap.Uint("user_id", userID), zap.Error(err))
+		}
+	}
+
+	logger.Warn("[audit] 封禁用户",
+		zap.String("event", "user_banned"),
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("user_id", userID),
+		zap.String("scope", scope),
+		zap.String("reason", ban.Reason),
+		zap.Timep("expires_at", ban.ExpiresAt))
+	return ban, nil
+}
+
+// UnbanUser 解除用户封禁（scope为空时解除全部范围）
+func (s *Service) UnbanUser(operatorID, userID uint, scope string) error {
+	logger.Info("[user.UnbanUser] 解除封禁",
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("user_id", userID),
+		zap.String("scope", scope))
+
+	if scope != "" && !security.ValidBanScope(scope) {
+		return errors.NewWithMessage(errors.InvalidParams, "封禁范围无效")
+	}
+	if _, err := s.repo.FindByID(userID); err != nil {
+		return errors.New(errors.UserNotFound)
+	}
+
+	revoked, err := s.repo.RevokeActiveBans(userID, operatorID, scope)
+	if err != nil {
+		logger.Error("[user.UnbanUser] 解除封禁失败", zap.Uint("user_id", userID), zap.Error(err))
+		return errors.NewWithMessage(errors.ServerError, "解除封禁失败")
+	}
+	s.clearBanCache(userID)
+	if revoked == 0 {
+		return errors.NewWithMessage(errors.InvalidParams, "该用户未被封禁")
+	}
+
+	logger.Warn("[audit] 解除封禁",
+		zap.String("event", "user_unbanned"),
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("user_id", userID),
+		zap.String("scope", scope),
+		zap.Int64("count", revoked))
+	return nil
+}
+
+// SetRole 修改用户角色
+// 角色写在Token中，修改后注销该用户所有登录会话，重新登录后生效
+func (s *Service) SetRole(operatorID, userID uint, role string) error {
+	logger.Info("[user.SetRole] 修改角色",
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("user_id", userID),
+		zap.String("role", role))
+
+	if !security.ValidRole(role) {
+		return errors.New(errors.InvalidRole)
+	}
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return errors.New(errors.UserNotFound)
+	}
+	if user.ID == operatorID {
+		return errors.NewWithMessage(errors.InvalidParams, "不能修改自己的角色")
+	}
+	if user.IsGuest && role != security.RolePlayer {
+		return errors.NewWithMessage(errors.InvalidRole, "游客账号不能设置为管理人员")
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	if err := s.repo.UpdateRole(userID, role); err != nil {
+		logger.Error("[user.SetRole] 更新角色失败", zap.Uint("user_id", userID), zap.Error(err))
+		return errors.NewWithMessage(errors.ServerError, "修改角色失败")
+	}
+	s.revokeSessions(userID, "")
+
+	logger.Warn("[audit] 修改用户角色",
+		zap.String("event", "role_changed"),
+		zap.Uint("operator_id", operatorID),
+		zap.Uint("user_id", userID),
+		zap.String("from", user.Role),
+		zap.String("to", role))
+	return nil
+}
+
+// banCacheTTL 封禁记录缓存时间（封禁/解封时主动清除，到期由Active判断）
+const banCacheTTL = 5 * time.Minute
+
+// banFallbackTTL 封禁记录备份的保存时间（数据库查询失败时使用，封禁/解封时与缓存一起清除）
+const banFallbackTTL = 24 * time.Hour
+
+// banCacheKey 用户封禁记录缓存Key
+func banCacheKey(userID uint) string {
+	return fmt.Sprintf("user:bans:%d", userID)
+}
+
+// banFallbackKey 用户封禁记录备份Key
+func banFallbackKey(userID uint) string {
+	return fmt.Sprintf("user:bans:fallback:%d", userID)
+}
+
+// CheckBan 检查用户在指定范围内是否被封禁（供登录、认证中间件和WebSocket连接调用）
+// 被封禁时返回携带封禁信息（范围、原因、到期时间）的错误
+// 无法确认封禁状态时（查询失败且没有备份）拒绝访问，返回 ServerError
+func (s *Service) CheckBan(userID uint, scope string) error {
+	bans, err := s.activeBans(userID)
+	if err != nil {
+		logger.Error("[user.CheckBan] 查询封禁失败", zap.Uint("user_id", userID), zap.Error(err))
+		return errors.NewWithMessage(errors.ServerError, "暂时无法确认封禁状态")
+	}
+
+	now := time.Now()
+	for _, ban := range bans {
+		if ban.Active(now) && ban.Covers(scope) {
+			logger.Warn("[user.CheckBan] 用户处于封禁中",
+				zap.Uint("user_id", userID),
+				zap.Uint("ban_id", ban.ID),
+				zap.String("ban_scope", ban.Scope),
+				zap.String("scope", scope),
+				zap.Timep("expires_at", ban.ExpiresAt))
+			return errors.NewWithData(security.BanErrorCode(ban.Scope), toBanInfoVO(&ban))
+		}
+	}
+	return nil
+}
+
+// activeBans 获取用户生效中的封禁（优先读缓存）
+func (s *Service) activeBans(userID uint) ([]Ban, error) {
+	var bans []Ban
+	if s.cache != nil && s.cache.Get(banCacheKey(userID), &bans) == nil {
+		return bans, nil
+	}
+
+	found, err := s.repo.FindActiveBans(userID)
+	if err != nil {
+		// 查询失败时使用最近一次查到的封禁记录（期间没有封禁/解封操作）
+		if s.cache != nil && s.cache.Get(banFallbackKey(userID), &bans) == nil {
+			logger.Warn("[user.activeBans] 查询封禁失败，使用备份的封禁记录", zap.Uint("user_id", userID), zap.Error(err))
+			return bans, nil
+		}
+		return nil, err
+	}
+	bans = make([]Ban, len(found))
+	for i, ban := range found {
+		bans[i] = *ban
+	}
+	if s.cache != nil {
+		s.cache.Set(banCacheKey(userID), bans, banCacheTTL)
+		s.cache.Set(banFallbackKey(userID), bans, banFallbackTTL)
+	}
+	return bans, nil
+}
+
+// clearBanCache 清除用户封禁缓存
+func (s *Service) clearBanCache(userID uint) {
+	if s.cache != nil {
+		s.cache.Delete(banCacheKey(userID))
+		s.cache.Delete(banFallbackKey(userID))
+	}
+}
+
+// toBanInfoVO 转换为返回给被封禁用户的封禁信息
+func toBanInfoVO(ban *Ban) BanInfoVO {
+	return BanInfoVO{
+		Scope:     ban.Scope,
+		Reason:    ban.Reason,
+		ExpiresAt: ban.ExpiresAt,
+	}
+}
+
+// toAdminUserVO 转换为管理后台用户信息
+func toAdminUserVO(user *Entity) AdminUserVO {
+	role := user.Role
+	if role == "" {
+		role = security.RolePlayer
+	}
+	return AdminUserVO{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.Username,
+		Role:        role,
+		IsGuest:     user.IsGuest,
+		CreatedAt:   user.CreatedAt,
+		LastLoginAt: user.LastLoginAt,
+	}
+}
+
+// toBanVO 转换封禁记录为BanVO
+func toBanVO(ban *Ban) BanVO {
+	return BanVO{
+		ID:        ban.ID,
+		Scope:     ban.Scope,
+		Reason:    ban.Reason,
+		ExpiresAt: ban.ExpiresAt,
+		CreatedBy: ban.CreatedBy,
+		CreatedAt: ban.CreatedAt,
+	}
 }